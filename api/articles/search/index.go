@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"main/lib/article"
+	"main/lib/feed"
+	"main/lib/logger"
+	"main/lib/middleware"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// articleSearchHandler searches the collected iGaming articles
+//
+//	GET ?q=<query>                                 required
+//	    &category=&source=&jurisdiction=&minScore= same filters as /api/feed
+//	    &limit=<n>&offset=<n>                      page size (default 20, max 100) and start
+//	    &date=YYYY-MM-DD&days=<n>                  window end (default today) and length (default 14, max 60)
+func articleSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		logger.Warn("Empty article search query", ctx)
+		middleware.WriteJSONError(w, http.StatusBadRequest, "Query parameter 'q' is required")
+		return
+	}
+
+	filter, err := article.ParseFilterQuery(query)
+	if err != nil {
+		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid 'minScore' parameter (0-1)")
+		return
+	}
+	filter.Search = q
+
+	filter.Limit = defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid 'limit' parameter (1-100)")
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid 'offset' parameter")
+			return
+		}
+		filter.Offset = offset
+	}
+
+	opts := feed.ArticleSearchOptions{Filter: filter}
+	if date := query.Get("date"); date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+			return
+		}
+		opts.EndDate = date
+	}
+	if value := query.Get("days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 || days > feed.MaxArticleSearchDays {
+			middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid 'days' parameter (1-60)")
+			return
+		}
+		opts.Days = days
+	}
+
+	ctx["search_query"] = q
+	logger.Info("Searching articles", ctx)
+
+	results, err := feed.SearchArticles(r.Context(), feed.NewBlobDigestStore(), opts)
+	if err != nil {
+		logger.Error("Article search failed", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	middleware.WriteJSONResponse(w, http.StatusOK, results)
+}
+
+// Handler is the Vercel serverless function entrypoint for article search.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	cacheOpts := middleware.CacheOptions{
+		Config: middleware.CacheConfig{
+			MaxAge:               0,   // No browser caching
+			SMaxAge:              300, // 5 minutes CDN cache, articles change once a day
+			StaleWhileRevalidate: 600, // 10 minutes stale-while-revalidate
+			StaleIfError:         0,   // No stale-if-error
+		},
+		ETagKey: "article-search",
+		Enabled: true,
+	}
+	middleware.MethodAndCache(http.MethodGet, cacheOpts)(articleSearchHandler)(w, r)
+}
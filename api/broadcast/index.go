@@ -2,11 +2,15 @@ package handler
 
 import (
 	"crypto/subtle"
+	"errors"
 	"main/lib/broadcast"
+	"main/lib/jobs"
 	"main/lib/logger"
+	"main/lib/mail"
 	"main/lib/middleware"
 	"net/http"
 	"os"
+	"strconv"
 )
 
 // broadcastHandler contains the main logic for the broadcast endpoint
@@ -48,7 +52,37 @@ func broadcastHandler(w http.ResponseWriter, r *http.Request) {
 
 	// 3. Trigger the broadcast
 	logger.Info("Starting broadcast process", ctx)
-	if err := broadcast.SendDailyBroadcast(); err != nil {
+	query := r.URL.Query()
+	force, _ := strconv.ParseBool(query.Get("force"))
+	dryRun, _ := strconv.ParseBool(query.Get("dryRun"))
+	opts := jobs.BroadcastOptions()
+	opts.Force, opts.DryRun = force, dryRun
+	ctx["force"] = force
+	ctx["dry_run"] = dryRun
+
+	run, err := broadcast.SendDailyBroadcast(r.Context(), jobs.Runner(), mail.Default(), opts)
+	if run != nil {
+		ctx["run_id"] = run.ID
+	}
+	if errors.Is(err, broadcast.ErrAlreadySent) {
+		logger.Warn("Broadcast already sent for this date", ctx)
+		middleware.WriteJSONResponse(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"error":   "Broadcast already sent for this date; pass force=1 to send it again",
+			"runId":   run.ID,
+		})
+		return
+	}
+	if errors.Is(err, broadcast.ErrStaleFeed) {
+		logger.Warn("Broadcast aborted on a stale feed", ctx)
+		middleware.WriteJSONResponse(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+			"runId":   run.ID,
+		})
+		return
+	}
+	if err != nil {
 		logger.Error("Broadcast process failed", err, ctx)
 		middleware.WriteJSONError(w, http.StatusInternalServerError, "Server error")
 		return
@@ -57,7 +91,11 @@ func broadcastHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Broadcast completed successfully", ctx)
 
 	// 4. Return success
-	middleware.WriteJSONResponse(w, http.StatusOK, middleware.SuccessResponse{Success: true})
+	middleware.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"runId":   run.ID,
+		"dryRun":  dryRun,
+	})
 }
 
 // Handler is the Vercel serverless function entrypoint.
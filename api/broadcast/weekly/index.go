@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"main/lib/broadcast"
+	"main/lib/jobs"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/middleware"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// weeklyBroadcastHandler sends the weekly roundup. It takes the same secret header as the
+// daily broadcast; ?date=YYYY-MM-DD picks the week-ending date (default today UTC)
+func weeklyBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+
+	logger.Info("Weekly broadcast request initiated", ctx)
+
+	// 1. Validate environment configuration
+	expectedSecret := os.Getenv("DAILY_BROADCAST_KEY")
+	if expectedSecret == "" {
+		logger.Error("Broadcast key not configured", nil, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Server configuration error")
+		return
+	}
+
+	// 2. Check secret key authentication (constant-time)
+	incomingSecret := r.Header.Get("secret")
+	if incomingSecret == "" || subtle.ConstantTimeCompare([]byte(incomingSecret), []byte(expectedSecret)) != 1 {
+		logger.Warn("Missing or invalid authentication secret", ctx)
+		middleware.WriteJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 3. Validate the week-ending date
+	weekEnding := r.URL.Query().Get("date")
+	if weekEnding != "" {
+		if _, err := time.Parse("2006-01-02", weekEnding); err != nil {
+			middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid date format, expected YYYY-MM-DD")
+			return
+		}
+	}
+	ctx["week_ending"] = weekEnding
+
+	// 4. Trigger the broadcast
+	query := r.URL.Query()
+	force, _ := strconv.ParseBool(query.Get("force"))
+	dryRun, _ := strconv.ParseBool(query.Get("dryRun"))
+	opts := jobs.BroadcastOptions()
+	opts.Force, opts.DryRun = force, dryRun
+	ctx["force"] = force
+	ctx["dry_run"] = dryRun
+
+	run, err := broadcast.SendWeeklyBroadcast(r.Context(), jobs.Runner(), mail.Default(), weekEnding, opts)
+	if run != nil {
+		ctx["run_id"] = run.ID
+	}
+	if errors.Is(err, broadcast.ErrAlreadySent) {
+		logger.Warn("Broadcast already sent for this date", ctx)
+		middleware.WriteJSONResponse(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"error":   "Broadcast already sent for this date; pass force=1 to send it again",
+			"runId":   run.ID,
+		})
+		return
+	}
+	if err != nil {
+		logger.Error("Weekly broadcast process failed", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Server error")
+		return
+	}
+
+	logger.Info("Weekly broadcast completed successfully", ctx)
+
+	middleware.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"runId":      run.ID,
+		"weekEnding": run.Params["weekEnding"],
+		"dryRun":     dryRun,
+	})
+}
+
+// Handler is the Vercel serverless function entrypoint.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Broadcast endpoints should not be cached
+	middleware.NoCache(http.MethodPost)(weeklyBroadcastHandler)(w, r)
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/middleware"
+	"main/lib/subscribe"
+	"net/http"
+	"time"
+)
+
+// cleanupSubscriptionsHandler removes subscriptions never confirmed within subscribe.ConfirmationTTL,
+// records unsubscribes made through the provider's link and prunes ended rate limit windows
+func cleanupSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+	startTime := time.Now()
+
+	if !middleware.CronAuthorized(r) {
+		logger.Warn("Unauthorized cron request", ctx)
+		middleware.WriteJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	now := time.Now().UTC()
+	deleted, err := subscribe.CleanupPending(r.Context(), subscribe.DefaultStore(), now)
+	if err != nil {
+		logger.LogRequestError(r, err, http.StatusInternalServerError)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Subscription cleanup failed")
+		return
+	}
+
+	// Opt-outs through Resend's own link only flag the contact there until they are synced
+	unsubscribed, err := subscribe.SyncUnsubscribes(r.Context(), subscribe.DefaultStore(), mail.Default())
+	if err != nil {
+		logger.LogRequestError(r, err, http.StatusInternalServerError)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Unsubscribe sync failed")
+		return
+	}
+
+	// Stale counters only take space, so a failure here doesn't fail the cron
+	pruned, err := subscribe.DefaultRateLimiter().Prune(r.Context(), now.Add(-subscribe.RateLimitsFromEnv().Window))
+	if err != nil {
+		logger.Error("Failed to prune subscribe rate limits", err, ctx)
+	}
+
+	logger.LogRequestComplete(r, http.StatusOK, time.Since(startTime))
+	middleware.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"deleted":      deleted,
+		"unsubscribed": unsubscribed,
+		"pruned":       pruned,
+	})
+}
+
+// Handler is the Vercel serverless function entrypoint for the subscription cleanup cron
+// Vercel Cron sends GET; POST is accepted for manual runs
+func Handler(w http.ResponseWriter, r *http.Request) {
+	middleware.MethodValidator(http.MethodGet, http.MethodPost)(cleanupSubscriptionsHandler)(w, r)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"main/lib/feed"
+	"main/lib/jobs"
+	"main/lib/logger"
+	"main/lib/middleware"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// shouldBroadcast reads ?broadcast=, falling back to DIGEST_BROADCAST (default false)
+func shouldBroadcast(r *http.Request) bool {
+	value := r.URL.Query().Get("broadcast")
+	if value == "" {
+		value = os.Getenv("DIGEST_BROADCAST")
+	}
+	enabled, _ := strconv.ParseBool(value)
+	return enabled
+}
+
+// generateDigestHandler runs the daily iGaming digest pipeline for Vercel Cron
+// Query params: date (YYYY-MM-DD, default today UTC), force=1 to regenerate, broadcast=1|0
+func generateDigestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+	startTime := time.Now()
+
+	if !middleware.CronAuthorized(r) {
+		logger.Warn("Unauthorized cron request", ctx)
+		middleware.WriteJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	date := query.Get("date")
+	if date == "" {
+		date = time.Now().UTC().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+		return
+	}
+	force, _ := strconv.ParseBool(query.Get("force"))
+
+	opts := jobs.DigestOptions()
+	opts.Date = date
+	opts.Force = force
+	opts.Broadcast = shouldBroadcast(r)
+
+	result, err := feed.RunDigestPipeline(r.Context(), opts)
+	if errors.Is(err, feed.ErrDigestLocked) {
+		logger.Warn("Digest pipeline already running", map[string]interface{}{"date": date})
+		middleware.WriteJSONError(w, http.StatusConflict, "Digest generation already in progress for "+date)
+		return
+	}
+	if err != nil {
+		logger.LogRequestError(r, err, http.StatusInternalServerError)
+		runID := ""
+		if result != nil {
+			runID = result.RunID
+		}
+		middleware.WriteJSONResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Digest generation failed: " + err.Error(),
+			"runId":   runID,
+		})
+		return
+	}
+
+	articleCount := 0
+	if result.Digest != nil {
+		articleCount = len(result.Digest.Articles)
+	}
+
+	logger.LogRequestComplete(r, http.StatusOK, time.Since(startTime))
+	middleware.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"runId":        result.RunID,
+		"date":         result.Date,
+		"status":       result.Status,
+		"articleCount": articleCount,
+		"broadcastId":  result.BroadcastID,
+		"broadcasted":  result.Broadcasted,
+		"durationMs":   result.Duration.Milliseconds(),
+	})
+}
+
+// Handler is the Vercel serverless function entrypoint for the digest cron
+// Vercel Cron sends GET; POST is accepted for manual runs
+func Handler(w http.ResponseWriter, r *http.Request) {
+	middleware.MethodValidator(http.MethodGet, http.MethodPost)(generateDigestHandler)(w, r)
+}
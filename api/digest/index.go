@@ -6,6 +6,7 @@ import (
 	"main/lib/feed"
 	"main/lib/logger"
 	"main/lib/middleware"
+	"main/lib/rss"
 	"net/http"
 	"os"
 	"time"
@@ -31,8 +32,13 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize cache manager
-	cacheManager := feed.GetGlobalCacheManager(24*time.Hour, 5000)
+	// ?format= or Accept selects RSS, Atom or JSON Feed; otherwise the digest JSON is returned
+	w.Header().Set("Vary", "Accept")
+	format, err := rss.NegotiateFormat(r, "")
+	if err != nil {
+		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid format. Use 'rss', 'atom' or 'json'")
+		return
+	}
 
 	// Get Claude API key from environment
 	claudeAPIKey := os.Getenv("CLAUDE_API_KEY")
@@ -108,10 +114,34 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format != "" {
+		writeDigestFeed(w, r, digest, format, ctx)
+		return
+	}
+
 	// Return digest as JSON
 	middleware.WriteJSONSuccess(w, http.StatusOK, digest)
 }
 
+// writeDigestFeed renders the digest as a syndication feed linking to its /gaming page
+func writeDigestFeed(w http.ResponseWriter, r *http.Request, digest *article.DailyDigest, format rss.Format, ctx map[string]interface{}) {
+	doc := rss.DigestDocument(digest, rss.BaseURL()+"/gaming/"+digest.Date)
+	doc.FeedURL = rss.BaseURL() + r.URL.RequestURI()
+
+	body, err := rss.Render(doc, format)
+	if err != nil {
+		logger.Error("Failed to render digest feed", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Failed to render digest feed")
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		logger.Error("Failed to write digest feed response", err, ctx)
+	}
+}
+
 // getSampleArticles returns sample articles for demonstration
 // In production, this would fetch real articles from the feed
 func getSampleArticles() []article.ArticleData {
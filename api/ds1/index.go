@@ -2,10 +2,10 @@ package handler
 
 import (
 	"context"
-	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
-	"math"
+	"main/lib/embd"
 	"main/lib/logger"
 	"main/lib/middleware"
 	"main/lib/paper"
@@ -63,6 +63,15 @@ func proxyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	// Lists larger than one endpoint batch are split into chunks by the embedding service
+	if texts, err := parseInputs(body); err == nil && len(texts) > embeddingService.MaxBatchSize() {
+		embeddings, ok := generateChunked(w, r, ctx, embeddingService, texts)
+		if ok {
+			middleware.WriteJSONResponse(w, http.StatusOK, embeddings)
+		}
+		return
+	}
+
 	// Get SageMaker client and endpoint name
 	client, endpointName := embeddingService.GetClient()
 
@@ -155,10 +164,20 @@ func proxyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// binaryHandler returns embeddings in binary format with header + raw float32 data
+// binaryHandler returns embeddings in the EMBD binary format (see lib/embd)
+// The dtype query parameter selects float32 (default), float16, int8 or binary encoding
 func binaryHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := logger.Log.WithRequest(r)
 
+	dtype, err := embd.ParseDtype(r.URL.Query().Get("dtype"))
+	if err != nil {
+		logger.Warn("Invalid dtype parameter", ctx)
+		middleware.WriteJSONResponse(w, http.StatusBadRequest, middleware.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
 	// Get embedding service to access SageMaker client
 	embeddingService, err := paper.GetEmbeddingService()
 	if err != nil {
@@ -180,6 +199,15 @@ func binaryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	// Lists larger than one endpoint batch are split into chunks by the embedding service
+	if texts, err := parseInputs(body); err == nil && len(texts) > embeddingService.MaxBatchSize() {
+		embeddings, ok := generateChunked(w, r, ctx, embeddingService, texts)
+		if ok {
+			writeBinaryEmbeddings(w, ctx, embeddings, dtype)
+		}
+		return
+	}
+
 	// Get SageMaker client and endpoint name
 	client, endpointName := embeddingService.GetClient()
 
@@ -247,6 +275,11 @@ func binaryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeBinaryEmbeddings(w, ctx, embeddings, dtype)
+}
+
+// writeBinaryEmbeddings validates and writes a single EMBD record response
+func writeBinaryEmbeddings(w http.ResponseWriter, ctx map[string]interface{}, embeddings [][]float32, dtype embd.Dtype) {
 	if len(embeddings) == 0 {
 		logger.Warn("Empty embeddings array", ctx)
 		middleware.WriteJSONResponse(w, http.StatusBadRequest, middleware.ErrorResponse{
@@ -268,61 +301,203 @@ func binaryHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create binary response
-	// Header format (16 bytes):
-	// - Magic: 4 bytes "EMBD"
-	// - Version: 1 byte (1)
-	// - Batch: 2 bytes uint16
-	// - Dims: 2 bytes uint16
-	// - Dtype: 1 byte (0 = float32)
-	// - Endian: 1 byte (0 = little-endian)
-	// - Reserved: 5 bytes
-	header := make([]byte, 16)
-	copy(header[0:4], []byte("EMBD")) // Magic
-	header[4] = 1                      // Version
-	binary.LittleEndian.PutUint16(header[5:7], uint16(batchSize))
-	binary.LittleEndian.PutUint16(header[7:9], uint16(dims))
-	header[9] = 0  // Dtype: float32
-	header[10] = 0 // Endian: little-endian
-	// header[11:16] reserved (zeros)
-
-	// Write float32 data (batch * dims * 4 bytes)
-	dataSize := batchSize * dims * 4
-	binaryData := make([]byte, 16+dataSize)
-	copy(binaryData[0:16], header)
-
-	// Write embeddings as little-endian float32
-	offset := 16
-	for _, emb := range embeddings {
-		for _, val := range emb {
-			binary.LittleEndian.PutUint32(binaryData[offset:offset+4], uint32(math.Float32bits(val)))
-			offset += 4
-		}
+	// Create binary response (EMBD header + vectors in the requested dtype)
+	binaryData, err := embd.Encode(embeddings, dtype)
+	if err != nil {
+		logger.Error("Failed to encode binary embeddings", err, ctx)
+		middleware.WriteJSONResponse(w, http.StatusBadGateway, middleware.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
 	}
 
 	ctx["batch_size"] = batchSize
 	ctx["dims"] = dims
+	ctx["dtype"] = dtype.String()
 	ctx["binary_size"] = len(binaryData)
 	logger.Info("Binary embeddings response prepared", ctx)
 
 	// Set headers and write binary response
 	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("Content-Length", strconv.Itoa(len(binaryData)))
+	w.Header().Set("X-Embedding-Dtype", dtype.String())
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(binaryData); err != nil {
 		logger.Error("Failed to write binary response", err, ctx)
 	}
 }
 
+// parseInputs extracts the TEI "inputs" field, which may be a single string or a list
+func parseInputs(body []byte) ([]string, error) {
+	var req struct {
+		Inputs json.RawMessage `json:"inputs"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if len(req.Inputs) == 0 {
+		return nil, fmt.Errorf("missing inputs")
+	}
+
+	var single string
+	if err := json.Unmarshal(req.Inputs, &single); err == nil {
+		return []string{single}, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(req.Inputs, &list); err != nil {
+		return nil, fmt.Errorf("inputs must be a string or a list of strings")
+	}
+	return list, nil
+}
+
+// generateChunked embeds a large input list through the embedding service in maxBatchSize chunks
+// Writes an error response and returns false on failure
+func generateChunked(w http.ResponseWriter, r *http.Request, ctx map[string]interface{}, embeddingService *paper.EmbeddingService, texts []string) ([][]float32, bool) {
+	ctx["input_count"] = len(texts)
+
+	embeddings, err := embeddingService.GenerateEmbeddings(r.Context(), texts)
+	if err != nil {
+		logger.Error("Chunked embedding generation failed", err, ctx)
+		middleware.WriteJSONResponse(w, http.StatusBadGateway, middleware.ErrorResponse{
+			Error: err.Error(),
+		})
+		return nil, false
+	}
+
+	return embeddings, true
+}
+
+// Streaming output formats
+const (
+	streamNDJSON = "ndjson"
+	streamEMBD   = "embd"
+)
+
+// streamLine is one NDJSON record: the embedding for inputs[index], or the error that stopped the stream
+type streamLine struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// streamHandler embeds arbitrarily large input lists and streams the results in input order,
+// flushing after every maxBatchSize chunk
+// NDJSON emits one {"index", "embedding"} line per input; EMBD emits one framed record per chunk
+// Errors after the first chunk can't change the status code, so they are reported in an
+// X-Embedding-Error trailer (and an {"index", "error"} line for NDJSON)
+func streamHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := logger.Log.WithRequest(r)
+		ctx["stream_format"] = format
+
+		dtype, err := embd.ParseDtype(r.URL.Query().Get("dtype"))
+		if err != nil {
+			logger.Warn("Invalid dtype parameter", ctx)
+			middleware.WriteJSONResponse(w, http.StatusBadRequest, middleware.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+
+		embeddingService, err := paper.GetEmbeddingService()
+		if err != nil {
+			logger.Error("Embedding service initialization failed", err, ctx)
+			middleware.WriteJSONResponse(w, http.StatusInternalServerError, middleware.ErrorResponse{
+				Error: "Failed to initialize embedding service",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Warn("Failed to read request body", ctx)
+			middleware.WriteJSONResponse(w, http.StatusBadRequest, middleware.ErrorResponse{
+				Error: "Failed to read request body",
+			})
+			return
+		}
+		defer r.Body.Close()
+
+		texts, err := parseInputs(body)
+		if err == nil && len(texts) == 0 {
+			err = fmt.Errorf("inputs must not be empty")
+		}
+		if err != nil {
+			middleware.WriteJSONResponse(w, http.StatusBadRequest, middleware.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		ctx["input_count"] = len(texts)
+
+		if format == streamEMBD {
+			w.Header().Set("Content-Type", embd.StreamContentType)
+			w.Header().Set("X-Embedding-Dtype", dtype.String())
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("X-Embedding-Count", strconv.Itoa(len(texts)))
+		w.Header().Set("Trailer", "X-Embedding-Error")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+
+		controller := http.NewResponseController(w)
+		encoder := json.NewEncoder(w)
+		written := 0
+
+		err = embeddingService.StreamEmbeddings(r.Context(), texts, func(start int, embeddings [][]float32) error {
+			if format == streamEMBD {
+				record, err := embd.Encode(embeddings, dtype)
+				if err != nil {
+					return err
+				}
+				if _, err := w.Write(record); err != nil {
+					return err
+				}
+			} else {
+				for i, embedding := range embeddings {
+					if err := encoder.Encode(streamLine{Index: start + i, Embedding: embedding}); err != nil {
+						return err
+					}
+				}
+			}
+
+			written = start + len(embeddings)
+			_ = controller.Flush()
+			return nil
+		})
+
+		ctx["written"] = written
+		if err != nil {
+			logger.Error("Embedding stream failed", err, ctx)
+			if format == streamNDJSON {
+				_ = encoder.Encode(streamLine{Index: written, Error: err.Error()})
+			}
+			w.Header().Set("X-Embedding-Error", err.Error())
+			return
+		}
+
+		logger.Info("Embedding stream completed", ctx)
+	}
+}
+
 // Handler is the Vercel serverless function entrypoint
 func Handler(w http.ResponseWriter, r *http.Request) {
-	// Check if binary format is requested via Accept header or query parameter
+	// Check if binary or streaming format is requested via Accept header or query parameter
 	acceptHeader := r.Header.Get("Accept")
 	formatParam := r.URL.Query().Get("format")
+	streamParam := r.URL.Query().Get("stream")
 	
-	if formatParam == "binary" || strings.Contains(acceptHeader, "application/octet-stream") {
+	switch {
+	case formatParam == "ndjson" || strings.Contains(acceptHeader, "application/x-ndjson"):
+		middleware.MethodValidator(http.MethodPost)(streamHandler(streamNDJSON))(w, r)
+	case formatParam == "embd-stream" || strings.Contains(acceptHeader, embd.StreamContentType) ||
+		(formatParam == "binary" && (streamParam == "true" || streamParam == "1")):
+		middleware.MethodValidator(http.MethodPost)(streamHandler(streamEMBD))(w, r)
+	case formatParam == "binary" || strings.Contains(acceptHeader, "application/octet-stream"):
 		middleware.MethodValidator(http.MethodPost)(binaryHandler)(w, r)
-	} else {
+	default:
 		middleware.MethodValidator(http.MethodPost)(proxyHandler)(w, r)
 	}
 }
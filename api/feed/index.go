@@ -1,37 +1,23 @@
 package handler
 
 import (
-	"fmt"
 	"io"
+	"main/lib/article"
+	"main/lib/feed"
 	"main/lib/logger"
 	"main/lib/middleware"
+	"main/lib/rss"
 	"main/lib/summary"
 	"net/http"
+	"net/url"
 	"os"
-	"regexp"
+	"strconv"
 	"strings"
-
-	"github.com/mmcdole/gofeed"
 )
 
 // constructAbsoluteURL constructs an absolute URL using BASE_URL and Vercel fallbacks
 func constructAbsoluteURL(path string) string {
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		// Try Vercel environment variables in order of preference
-		if prodURL := os.Getenv("VERCEL_PROJECT_PRODUCTION_URL"); prodURL != "" {
-			baseURL = "https://" + prodURL
-		} else if deployURL := os.Getenv("VERCEL_URL"); deployURL != "" {
-			baseURL = "https://" + deployURL
-		} else if branchURL := os.Getenv("VERCEL_BRANCH_URL"); branchURL != "" {
-			baseURL = "https://" + branchURL
-		} else {
-			baseURL = "https://tldr.takara.ai"
-		}
-	}
-	// Remove leading slash from path if present
-	path = strings.TrimPrefix(path, "/")
-	return baseURL + "/" + path
+	return rss.BaseURL() + "/" + strings.TrimPrefix(path, "/")
 }
 
 // feedHandler contains the main logic for the feed endpoint
@@ -41,6 +27,12 @@ func constructAbsoluteURL(path string) string {
 func feedHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := logger.Log.WithRequest(r)
 
+	// Any digest filter selects the iGaming digest feed instead of the TLDR JSON
+	if isDigestFeedRequest(r.URL.Query()) {
+		digestFeedHandler(w, r, ctx)
+		return
+	}
+
 	// Check if blob cache is disabled - if so, generate fresh data
 	disableBlob := os.Getenv("DISABLE_BLOB_CACHE") == "1" || os.Getenv("DISABLE_BLOB_CACHE") == "true"
 	if disableBlob {
@@ -94,7 +86,7 @@ func feedHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 2. Parse the RSS data to convert to JSON format expected by feed endpoint
-	feedData, err := parseRSSToFeedData(rssData)
+	feedData, err := rss.ParseTldr(rssData)
 	if err != nil {
 		logger.Error("Failed to parse RSS to feed data", err, ctx)
 		middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
@@ -107,135 +99,87 @@ func feedHandler(w http.ResponseWriter, r *http.Request) {
 	logger.LogRequestComplete(r, http.StatusOK, 0) // Duration will be tracked by middleware
 }
 
-// FeedItem matches the structure expected by the frontend
-type FeedItem struct {
-	Title       string `json:"title"`
-	Link        string `json:"link"`
-	Description string `json:"description"`
-	PubDate     string `json:"pubDate"`
-	GUID        string `json:"guid"`
-}
-
-// RssFeed matches the structure expected by the frontend
-type RssFeed struct {
-	Title         string     `json:"title"`
-	Description   string     `json:"description"`
-	Link          string     `json:"link"`
-	LastBuildDate string     `json:"lastBuildDate,omitempty"`
-	Items         []FeedItem `json:"items"`
-}
-
-var (
-	// Capture the inner content of the outermost div (non-greedy, dotall, allow attributes)
-	divRegex = regexp.MustCompile(`(?is)<div[^>]*>(.*?)</div>`)
-	// Capture the entire first paragraph following the Morning Headline h2 (including links/inline HTML)
-	headlineRegex = regexp.MustCompile(`(?is)<h2[^>]*>\s*Morning\s+Headline\s*</h2>\s*(<p[\s\S]*?>[\s\S]*?</p>)`)
-	// Find all h2 tags to split content into sections
-	h2TagRegex = regexp.MustCompile(`(?is)<h2[^>]*>.*?</h2>`)
-)
-
-// processRssItem extracts the headline and sections from RSS content
-func processRssItem(item *gofeed.Item) (string, []FeedItem) {
-	// Prefer full content when available
-	content := item.Content
-	if strings.TrimSpace(content) == "" {
-		content = item.Description
-	}
-
-	// Extract content from wrapper div if present
-	divMatches := divRegex.FindStringSubmatch(content)
-	if len(divMatches) >= 2 {
-		content = divMatches[1]
-	}
-
-	// Extract headline from Morning Headline paragraph
-	headline := ""
-	if m := headlineRegex.FindStringSubmatch(content); len(m) > 1 {
-		// Extract inner content from <p> tag
-		p := strings.TrimSpace(m[1])
-		if idx := strings.Index(p, ">"); idx != -1 {
-			inner := p[idx+1:]
-			if end := strings.LastIndex(inner, "</p>"); end != -1 {
-				headline = strings.TrimSpace(inner[:end])
-			}
+// isDigestFeedRequest reports whether any digest filter parameter is present, which
+// switches /api/feed from the TLDR JSON to a filtered iGaming digest feed
+func isDigestFeedRequest(query url.Values) bool {
+	for _, param := range article.FilterQueryParams {
+		if _, ok := query[param]; ok {
+			return true
 		}
 	}
+	return false
+}
 
-	// Split content by h2 sections
-	var feedItems []FeedItem
-	h2Positions := h2TagRegex.FindAllStringIndex(content, -1)
-
-	for i, pos := range h2Positions {
-		// Extract section title
-		h2Match := regexp.MustCompile(`(?is)<h2[^>]*>(.*?)</h2>`).FindStringSubmatch(content[pos[0]:pos[1]])
-		if len(h2Match) < 2 {
-			continue
-		}
-		title := strings.TrimSpace(h2Match[1])
-
-		// Skip Morning Headline section
-		if strings.EqualFold(title, "Morning Headline") {
-			continue
-		}
-
-		// Extract section content (from current h2 to next h2 or end)
-		var endPos int
-		if i+1 < len(h2Positions) {
-			endPos = h2Positions[i+1][0]
-		} else {
-			endPos = len(content)
+// canonicalFeedURL is the self link for a filter combination: every equivalent query
+// (reordered, repeated or comma-separated values) maps to the same URL and so the same feed ID
+func canonicalFeedURL(filter article.ArticleFilter, format string) string {
+	query := url.Values{}
+	for key, values := range map[string][]string{
+		"category":     filter.Categories,
+		"source":       filter.SourceNames,
+		"jurisdiction": filter.Jurisdictions,
+	} {
+		if len(values) > 0 {
+			query.Set(key, strings.Join(values, ","))
 		}
-
-		sectionContent := strings.TrimSpace(content[pos[0]:endPos])
-
-		feedItems = append(feedItems, FeedItem{
-			Title:       title,
-			Link:        item.Link,
-			Description: sectionContent,
-			PubDate:     item.Published,
-			GUID:        fmt.Sprintf("%s-section-%d", item.GUID, i),
-		})
 	}
-
-	return headline, feedItems
+	if filter.MinScore > 0 {
+		query.Set("minScore", strconv.FormatFloat(filter.MinScore, 'g', -1, 64))
+	}
+	if format != "" {
+		query.Set("format", format)
+	}
+	return rss.BaseURL() + "/api/feed?" + query.Encode()
 }
 
-// parseRSSToFeedData converts RSS XML bytes to RssFeed JSON structure with proper section parsing
-func parseRSSToFeedData(rssData []byte) (*RssFeed, error) {
-	fp := gofeed.NewParser()
-	feed, err := fp.ParseString(string(rssData))
+// digestFeedHandler serves the filtered digest as RSS (default), Atom or JSON Feed
+func digestFeedHandler(w http.ResponseWriter, r *http.Request, ctx map[string]interface{}) {
+	w.Header().Set("Vary", "Accept")
+	query := r.URL.Query()
+
+	filter, err := article.ParseFilterQuery(query)
 	if err != nil {
-		return nil, err
+		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid 'minScore' parameter (0-1)")
+		return
 	}
-
-	if len(feed.Items) == 0 {
-		return &RssFeed{
-			Title:         "Takara TLDR",
-			Description:   "Daily AI research summaries",
-			Link:          "https://tldr.takara.ai",
-			LastBuildDate: "",
-			Items:         []FeedItem{},
-		}, nil
+	format, err := rss.NegotiateFormat(r, rss.FormatRSS)
+	if err != nil {
+		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid format. Use 'rss', 'atom' or 'json'")
+		return
 	}
 
-	// Process the first item to extract headline and sections
-	firstItem := feed.Items[0]
-	headline, feedItems := processRssItem(firstItem)
-
-	lastBuildDate := feed.Published
-	if firstItem.Published != "" {
-		lastBuildDate = firstItem.Published
+	// Keep ?format= in the self link so readers re-fetch the format they subscribed to
+	explicitFormat := ""
+	if query.Get("format") != "" {
+		explicitFormat = string(format)
+	}
+	ctx["format"] = string(format)
+	ctx["filter"] = canonicalFeedURL(filter, "")
+	logger.Info("Serving filtered digest feed", ctx)
+
+	doc, err := feed.BuildDigestFeed(r.Context(), feed.NewBlobDigestStore(), feed.DigestFeedOptions{
+		Filter:  filter,
+		Link:    rss.BaseURL() + "/gaming",
+		FeedURL: canonicalFeedURL(filter, explicitFormat),
+	})
+	if err != nil {
+		logger.Error("Failed to build filtered digest feed", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
 	}
 
-	result := &RssFeed{
-		Title:         feed.Title,
-		Description:   headline,
-		Link:          feed.Link,
-		LastBuildDate: lastBuildDate,
-		Items:         feedItems,
+	body, err := rss.Render(doc, format)
+	if err != nil {
+		logger.Error("Failed to render filtered digest feed", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
 	}
 
-	return result, nil
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		logger.Error("Failed to write filtered digest feed", err, ctx)
+	}
 }
 
 // Handler is the Vercel serverless function entrypoint for the feed API.
@@ -95,6 +95,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		},
 		ETagKey: "paper",
 		Enabled: true,
+		// Server-side copy so CDN misses don't refetch from HuggingFace/arXiv
+		Server: &middleware.ResponseCacheOptions{
+			Store:     paper.ResponseStore(),
+			KeyPrefix: "paper",
+		},
 	}
 	middleware.MethodAndCache(http.MethodGet, cacheOpts)(paperHandler)(w, r)
 }
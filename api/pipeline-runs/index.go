@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"main/lib/jobs"
+	"main/lib/logger"
+	"main/lib/middleware"
+	"main/lib/pipeline"
+	"net/http"
+	"strconv"
+)
+
+const maxListLimit = 100
+
+// pipelineRunsHandler inspects and resumes recorded pipeline runs
+//
+//	GET  ?id=<run>                       one run with its stages
+//	GET  ?pipeline=<name>&limit=<n>      newest runs first (default 20, max 100)
+//	POST ?id=<run>&from=<stage>          re-run from a stage (default: the first failed stage)
+func pipelineRunsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+
+	if !middleware.CronAuthorized(r) {
+		logger.Warn("Unauthorized pipeline runs request", ctx)
+		middleware.WriteJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	id := query.Get("id")
+
+	if r.Method == http.MethodPost {
+		if id == "" {
+			middleware.WriteJSONError(w, http.StatusBadRequest, "Missing 'id' parameter")
+			return
+		}
+		ctx["run_id"] = id
+		ctx["from"] = query.Get("from")
+		logger.Info("Resuming pipeline run", ctx)
+
+		run, err := jobs.Resume(r.Context(), id, query.Get("from"))
+		if errors.Is(err, pipeline.ErrRunNotFound) {
+			middleware.WriteJSONError(w, http.StatusNotFound, "Run not found")
+			return
+		}
+		if run == nil {
+			logger.Error("Failed to resume pipeline run", err, ctx)
+			middleware.WriteJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		status := http.StatusOK
+		if err != nil {
+			logger.Error("Resumed pipeline run failed", err, ctx)
+			status = http.StatusInternalServerError
+		}
+		middleware.WriteJSONResponse(w, status, run)
+		return
+	}
+
+	store := jobs.RunStore()
+	if id != "" {
+		run, err := store.GetRun(r.Context(), id)
+		if errors.Is(err, pipeline.ErrRunNotFound) {
+			middleware.WriteJSONError(w, http.StatusNotFound, "Run not found")
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to load pipeline run", err, ctx)
+			middleware.WriteJSONError(w, http.StatusInternalServerError, "Failed to load run")
+			return
+		}
+		middleware.WriteJSONResponse(w, http.StatusOK, run)
+		return
+	}
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid 'limit' parameter (1-100)")
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := store.ListRuns(r.Context(), query.Get("pipeline"), limit)
+	if err != nil {
+		logger.Error("Failed to list pipeline runs", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Failed to list runs")
+		return
+	}
+	middleware.WriteJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"runs": runs,
+	})
+}
+
+// Handler is the Vercel serverless function entrypoint for the pipeline run ledger
+func Handler(w http.ResponseWriter, r *http.Request) {
+	middleware.MethodValidator(http.MethodGet, http.MethodPost)(pipelineRunsHandler)(w, r)
+}
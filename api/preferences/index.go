@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"errors"
+	"main/lib/article"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/middleware"
+	"main/lib/subscribe"
+	"net/http"
+	"strings"
+)
+
+// wantsJSON reports whether the caller is the site rather than a browser following an email link
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json") ||
+		strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+// writePage renders the preferences form, or its JSON equivalent (pages are only shown
+// for active subscribers)
+func writePage(w http.ResponseWriter, r *http.Request, status int, page subscribe.PreferencesPage, ctx map[string]interface{}) {
+	if wantsJSON(r) {
+		if page.Error != "" {
+			middleware.WriteJSONError(w, status, page.Error)
+			return
+		}
+		categories := make([]string, len(article.Categories))
+		for i, c := range article.Categories {
+			categories[i] = string(c)
+		}
+		middleware.WriteJSONResponse(w, status, subscribe.PreferencesResponse{
+			Email:       page.Email,
+			Status:      subscribe.StatusActive,
+			Preferences: page.Preferences,
+			Categories:  categories,
+		})
+		return
+	}
+
+	if page.Email != "" {
+		if link, err := subscribe.UnsubscribeURL(page.Email); err == nil {
+			page.UnsubscribeURL = link
+		}
+	}
+	subscribe.WritePreferencesPage(w, status, page, ctx)
+}
+
+// writeStatus renders a page without the form, for links that can't be used
+func writeStatus(w http.ResponseWriter, r *http.Request, status int, title, message string, ctx map[string]interface{}) {
+	if wantsJSON(r) {
+		middleware.WriteJSONError(w, status, message)
+		return
+	}
+	subscribe.WriteStatusPage(w, status, subscribe.StatusPage{Title: title, Message: message}, ctx)
+}
+
+// readRequest takes the token and preferences from a JSON body or the posted form
+func readRequest(r *http.Request) (string, subscribe.Preferences, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body subscribe.PreferencesRequest
+		if err := middleware.ParseJSONBody(r, &body); err != nil {
+			return "", subscribe.Preferences{}, err
+		}
+		return body.Token, body.Preferences, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return "", subscribe.Preferences{}, err
+	}
+	return r.FormValue("token"), subscribe.Preferences{
+		Products:   r.PostForm["products"],
+		Frequency:  r.PostFormValue("frequency"),
+		Categories: r.PostForm["categories"],
+		TimeZone:   r.PostFormValue("timeZone"),
+	}, nil
+}
+
+// preferencesHandler shows and saves a subscriber's preferences using the signed link from their emails
+//
+//	GET  ?token=<token>                        the preferences form (JSON with Accept: application/json)
+//	POST token, products, frequency, ...       save from the form
+//	POST {"token": ..., "preferences": {...}}  save as JSON
+func preferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+
+	if r.Method == http.MethodGet {
+		sub, err := subscribe.LoadPreferences(r.Context(), subscribe.DefaultStore(), r.URL.Query().Get("token"))
+		if errors.Is(err, subscribe.ErrInvalidToken) {
+			logger.Warn("Invalid preferences token", ctx)
+			writeStatus(w, r, http.StatusBadRequest, "Invalid link", "This preferences link isn't valid. Please use the full link from your email.", ctx)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to load preferences", err, ctx)
+			writeStatus(w, r, http.StatusInternalServerError, "Something went wrong", "We couldn't load your preferences. Please try again in a few minutes.", ctx)
+			return
+		}
+		if sub.Status != subscribe.StatusActive {
+			writeStatus(w, r, http.StatusConflict, "Not subscribed", "This address isn't subscribed any more. Subscribe again to choose what you receive.", ctx)
+			return
+		}
+		writePage(w, r, http.StatusOK, subscribe.PreferencesPage{
+			Email:       sub.Email,
+			Token:       r.URL.Query().Get("token"),
+			Preferences: sub.Preferences,
+		}, ctx)
+		return
+	}
+
+	token, prefs, err := readRequest(r)
+	if err != nil {
+		logger.Warn("Failed to parse preferences request", ctx)
+		writeStatus(w, r, http.StatusBadRequest, "Invalid request", "We couldn't read your preferences. Please try again.", ctx)
+		return
+	}
+
+	sub, err := subscribe.SavePreferences(r.Context(), subscribe.DefaultStore(), mail.Default(), token, prefs)
+	switch {
+	case errors.Is(err, subscribe.ErrInvalidToken):
+		logger.Warn("Invalid preferences token", ctx)
+		writeStatus(w, r, http.StatusBadRequest, "Invalid link", "This preferences link isn't valid. Please use the full link from your email.", ctx)
+	case errors.Is(err, subscribe.ErrNotSubscribed):
+		logger.Warn("Preferences update for an address that isn't subscribed", ctx)
+		writeStatus(w, r, http.StatusConflict, "Not subscribed", "This address isn't subscribed any more. Subscribe again to choose what you receive.", ctx)
+	case errors.Is(err, subscribe.ErrInvalidPreferences):
+		logger.Warn("Invalid preferences", ctx)
+		// Show the form again with what was submitted, so nothing has to be re-entered
+		current, loadErr := subscribe.LoadPreferences(r.Context(), subscribe.DefaultStore(), token)
+		if loadErr != nil {
+			writeStatus(w, r, http.StatusBadRequest, "Invalid preferences", err.Error(), ctx)
+			return
+		}
+		writePage(w, r, http.StatusBadRequest, subscribe.PreferencesPage{
+			Email:       current.Email,
+			Token:       token,
+			Preferences: prefs,
+			Error:       strings.TrimPrefix(err.Error(), subscribe.ErrInvalidPreferences.Error()+": "),
+		}, ctx)
+	case err != nil:
+		logger.Error("Failed to save preferences", err, ctx)
+		writeStatus(w, r, http.StatusInternalServerError, "Something went wrong", "We couldn't save your preferences. Please try again in a few minutes.", ctx)
+	default:
+		logger.Info("Preferences saved", ctx)
+		writePage(w, r, http.StatusOK, subscribe.PreferencesPage{
+			Email:       sub.Email,
+			Token:       token,
+			Preferences: sub.Preferences,
+			Message:     "Your preferences have been saved.",
+		}, ctx)
+	}
+}
+
+// Handler is the Vercel serverless function entrypoint for subscriber preferences.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Preferences are personal: never cached
+	middleware.MethodValidator(http.MethodGet, http.MethodPost)(preferencesHandler)(w, r)
+}
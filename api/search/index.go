@@ -256,6 +256,14 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 				},
 				ETagKey: "batch-embedding",
 				Enabled: true,
+				// Embeddings only change with the model, so keep them server-side for a day
+				// to avoid SageMaker calls on CDN misses
+				Server: &middleware.ResponseCacheOptions{
+					Store:     paper.ResponseStore(),
+					TTL:       24 * time.Hour,
+					StaleTTL:  7 * 24 * time.Hour,
+					KeyPrefix: "batch-embedding:" + paper.ActiveEmbeddingModel().String(),
+				},
 			}
 			middleware.MethodAndCache(http.MethodGet, cacheOpts)(embedBatchHandler)(w, r)
 		} else {
@@ -271,6 +279,12 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 				},
 				ETagKey: "query-embedding",
 				Enabled: true,
+				Server: &middleware.ResponseCacheOptions{
+					Store:     paper.ResponseStore(),
+					TTL:       24 * time.Hour,
+					StaleTTL:  7 * 24 * time.Hour,
+					KeyPrefix: "query-embedding:" + paper.ActiveEmbeddingModel().String(),
+				},
 			}
 			middleware.MethodAndCache(http.MethodGet, cacheOpts)(embedQueryHandler)(w, r)
 		}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/middleware"
+	"main/lib/subscribe"
+	"net/http"
+)
+
+// confirmHandler completes a double opt-in subscription
+//
+//	GET  ?token=<token>   the link from the confirmation email: shows a confirm button
+//	POST token=<token>    activates the subscription
+//
+// The link itself changes nothing, because mail scanners prefetch links in new emails.
+func confirmHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+
+	token := r.FormValue("token")
+	if token == "" {
+		subscribe.WriteStatusPage(w, http.StatusBadRequest, subscribe.StatusPage{
+			Title:   "Invalid link",
+			Message: "This confirmation link is incomplete. Please use the full link from your email.",
+		}, ctx)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		subscribe.WriteStatusPage(w, http.StatusOK, subscribe.StatusPage{
+			Title:      "Confirm your subscription",
+			Message:    "Press the button to start receiving Takara TLDR.",
+			FormAction: "/api/subscribe/confirm",
+			FormToken:  token,
+			FormLabel:  "Confirm subscription",
+		}, ctx)
+		return
+	}
+
+	_, err := subscribe.ConfirmSubscription(r.Context(), subscribe.DefaultStore(), mail.Default(), token)
+	switch {
+	case errors.Is(err, subscribe.ErrExpiredToken):
+		logger.Warn("Expired confirmation token", ctx)
+		subscribe.WriteStatusPage(w, http.StatusGone, subscribe.StatusPage{
+			Title:   "Link expired",
+			Message: "This confirmation link has expired. Please subscribe again to get a new one.",
+		}, ctx)
+	case errors.Is(err, subscribe.ErrInvalidToken):
+		logger.Warn("Invalid confirmation token", ctx)
+		subscribe.WriteStatusPage(w, http.StatusBadRequest, subscribe.StatusPage{
+			Title:   "Invalid link",
+			Message: "This confirmation link isn't valid. Please use the full link from your email.",
+		}, ctx)
+	case err != nil:
+		logger.Error("Subscription confirmation failed", err, ctx)
+		subscribe.WriteStatusPage(w, http.StatusInternalServerError, subscribe.StatusPage{
+			Title:   "Something went wrong",
+			Message: "We couldn't confirm your subscription. Please try the link again in a few minutes.",
+		}, ctx)
+	default:
+		logger.Info("Subscription confirmed", ctx)
+		subscribe.WriteStatusPage(w, http.StatusOK, subscribe.StatusPage{
+			Title:   "You're subscribed",
+			Message: "Thanks for confirming. Your first summary will arrive with the next daily email.",
+		}, ctx)
+	}
+}
+
+// Handler is the Vercel serverless function entrypoint for subscription confirmation.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	middleware.MethodValidator(http.MethodGet, http.MethodPost)(confirmHandler)(w, r)
+}
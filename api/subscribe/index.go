@@ -1,12 +1,28 @@
 package handler
 
 import (
+	"errors"
 	"main/lib/logger"
+	"main/lib/mail"
 	"main/lib/middleware"
 	"main/lib/subscribe"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// writeRateLimited answers a request over a subscribe rate limit with 429 and Retry-After
+func writeRateLimited(w http.ResponseWriter, err error, ctx map[string]interface{}) {
+	var limited *subscribe.RateLimitError
+	if errors.As(err, &limited) {
+		ctx["rate_limit_key"] = limited.Key
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+	}
+	logger.Warn("Subscription request rate limited", ctx)
+	middleware.WriteJSONResponse(w, http.StatusTooManyRequests, subscribe.ApiResponse{Error: "Too many requests, try again later."})
+}
+
 // subscribeHandler contains the main logic for the subscribe endpoint
 func subscribeHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := logger.Log.WithRequest(r)
@@ -22,9 +38,10 @@ func subscribeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	token := reqBody.Token()
 	ctx["email"] = reqBody.Email
-	ctx["has_turnstile_token"] = reqBody.TurnstileToken != ""
-	ctx["turnstile_token_length"] = len(reqBody.TurnstileToken)
+	ctx["has_challenge_token"] = token != ""
+	ctx["challenge_token_length"] = len(token)
 
 	logger.Debug("Request body parsed successfully", ctx)
 
@@ -35,40 +52,88 @@ func subscribeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if reqBody.TurnstileToken == "" {
-		logger.Warn("Missing Turnstile token in subscription request", ctx)
-		middleware.WriteJSONResponse(w, http.StatusBadRequest, subscribe.ApiResponse{Error: "Turnstile token required."})
+	if token == "" {
+		logger.Warn("Missing challenge token in subscription request", ctx)
+		middleware.WriteJSONResponse(w, http.StatusBadRequest, subscribe.ApiResponse{Error: "Challenge token required."})
 		return
 	}
 
-	// 3. Verify Turnstile token
-	logger.Debug("Verifying Turnstile token", ctx)
-	isVerified, err := subscribe.VerifyTurnstileToken(reqBody.TurnstileToken)
+	// 3. Rate limit per client IP, before spending a verification call
+	clientIP := middleware.ClientIP(r)
+	limits := subscribe.RateLimitsFromEnv()
+	if err := subscribe.CheckIPRateLimit(r.Context(), subscribe.DefaultRateLimiter(), limits, clientIP, time.Now()); err != nil {
+		writeRateLimited(w, err, ctx)
+		return
+	}
+
+	// 4. Verify the bot challenge
+	verifier, err := subscribe.DefaultChallengeVerifier()
+	if err != nil {
+		logger.Error("Challenge verifier not configured", err, ctx)
+		middleware.WriteJSONResponse(w, http.StatusInternalServerError, subscribe.ApiResponse{Error: "Server error"})
+		return
+	}
+	ctx["challenge_provider"] = verifier.Name()
+	logger.Debug("Verifying challenge token", ctx)
+	isVerified, err := verifier.Verify(r.Context(), token, clientIP)
 	if err != nil {
-		logger.Error("Turnstile verification failed with error", err, ctx)
+		logger.Error("Challenge verification failed with error", err, ctx)
 		middleware.WriteJSONResponse(w, http.StatusInternalServerError, subscribe.ApiResponse{Error: "Server error"})
 		return
 	}
 	if !isVerified {
-		logger.Warn("Turnstile verification failed - invalid token", ctx)
+		logger.Warn("Challenge verification failed - invalid token", ctx)
 		middleware.WriteJSONResponse(w, http.StatusForbidden, subscribe.ApiResponse{Error: "Verification failed"})
 		return
 	}
 
-	logger.Info("Turnstile verification successful", ctx)
+	logger.Info("Challenge verification successful", ctx)
 
-	// 4. Subscribe the email
+	// 5. Rate limit per email domain, counting verified requests only
+	if err := subscribe.CheckDomainRateLimit(r.Context(), subscribe.DefaultRateLimiter(), limits, reqBody.Email, time.Now()); err != nil {
+		writeRateLimited(w, err, ctx)
+		return
+	}
+
+	// 6. Record the pending subscription and send the confirmation email
 	logger.Debug("Processing email subscription", ctx)
-	if err := subscribe.SubscribeEmail(reqBody.Email); err != nil {
+	prefs := subscribe.DefaultPreferences()
+	if reqBody.Preferences != nil {
+		prefs = *reqBody.Preferences
+	}
+	if err := subscribe.RequestSubscription(r.Context(), subscribe.DefaultStore(), mail.Default(), subscribe.DefaultEmailValidator(), reqBody.Email, prefs); err != nil {
+		if errors.Is(err, subscribe.ErrDisposableEmail) {
+			logger.Warn("Disposable email in subscription request", ctx)
+			middleware.WriteJSONResponse(w, http.StatusBadRequest, subscribe.ApiResponse{Error: "Please use a permanent email address."})
+			return
+		}
+		if errors.Is(err, subscribe.ErrNoMailServer) {
+			logger.Warn("Email domain does not accept mail", ctx)
+			middleware.WriteJSONResponse(w, http.StatusBadRequest, subscribe.ApiResponse{Error: "That email domain can't receive mail."})
+			return
+		}
+		if errors.Is(err, subscribe.ErrInvalidEmail) {
+			logger.Warn("Invalid email in subscription request", ctx)
+			middleware.WriteJSONResponse(w, http.StatusBadRequest, subscribe.ApiResponse{Error: "Invalid email address."})
+			return
+		}
+		if errors.Is(err, subscribe.ErrInvalidPreferences) {
+			logger.Warn("Invalid preferences in subscription request", ctx)
+			middleware.WriteJSONResponse(w, http.StatusBadRequest, subscribe.ApiResponse{Error: err.Error()})
+			return
+		}
 		logger.Error("Email subscription failed", err, ctx)
 		middleware.WriteJSONResponse(w, http.StatusInternalServerError, subscribe.ApiResponse{Error: "Server error"})
 		return
 	}
 
-	logger.Info("Email subscription completed successfully", ctx)
+	logger.Info("Subscription pending confirmation", ctx)
 
-	// 5. Return success
-	middleware.WriteJSONResponse(w, http.StatusOK, subscribe.ApiResponse{Success: true})
+	// 7. Return success
+	middleware.WriteJSONResponse(w, http.StatusOK, subscribe.ApiResponse{
+		Success: true,
+		Message: "Check your inbox to confirm your subscription.",
+	})
 }
 
 // Handler is the Vercel serverless function entrypoint for the subscribe API.
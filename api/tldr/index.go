@@ -2,9 +2,9 @@ package handler
 
 import (
 	"io"
-	"main/lib/feed"
 	"main/lib/logger"
 	"main/lib/middleware"
+	"main/lib/rss"
 	"main/lib/summary"
 	"net/http"
 	"os"
@@ -26,11 +26,14 @@ func constructAbsoluteURL(path string) string {
 func tldrHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := logger.Log.WithRequest(r)
 
-	// Determine requested format from query parameter
+	// Determine requested format from ?format= or the Accept header
 	// Default to RSS for RSS reader compatibility
-	format := r.URL.Query().Get("format")
-	if format == "" {
-		format = "rss" // Default to RSS for RSS readers
+	w.Header().Set("Vary", "Accept")
+	format, err := rss.NegotiateFormat(r, rss.FormatRSS)
+	if err != nil {
+		logger.Warn("Invalid format requested", ctx)
+		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid format. Use 'rss', 'atom' or 'json'. Default is RSS for RSS reader compatibility")
+		return
 	}
 
 	// Determine content type: "feed" for raw papers, "summary" for AI-generated
@@ -39,7 +42,7 @@ func tldrHandler(w http.ResponseWriter, r *http.Request) {
 		contentType = "summary" // Default to summary (LLM-generated)
 	}
 
-	ctx["format"] = format
+	ctx["format"] = string(format)
 	ctx["content_type"] = contentType
 
 	logger.Info("Processing TLDR request", ctx)
@@ -60,7 +63,7 @@ func tldrHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleFeed serves the raw feed data in the requested format
-func handleFeed(w http.ResponseWriter, r *http.Request, format string, ctx map[string]interface{}) {
+func handleFeed(w http.ResponseWriter, r *http.Request, format rss.Format, ctx map[string]interface{}) {
 	service := summary.NewService()
 
 	// Construct absolute URL using BASE_URL
@@ -74,33 +77,11 @@ func handleFeed(w http.ResponseWriter, r *http.Request, format string, ctx map[s
 		return
 	}
 
-	switch format {
-	case "rss":
-		logger.Debug("Serving RSS feed format", ctx)
-		w.Header().Set("Content-Type", "application/rss+xml")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write(result.Data); err != nil {
-			logger.Error("Failed to write RSS response", err, ctx)
-			middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
-		}
-	case "json":
-		logger.Debug("Serving JSON feed format", ctx)
-		// For JSON format, we need to get the feed data and marshal it
-		result, err := feed.GetFeedRaw()
-		if err != nil {
-			logger.Error("Failed to fetch feed for JSON format", err, ctx)
-			middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
-			return
-		}
-		middleware.WriteJSONResponse(w, http.StatusOK, result.Data)
-	default:
-		logger.Warn("Invalid format requested for feed", ctx)
-		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid format. Use 'json' or 'rss'. Default is RSS for RSS reader compatibility")
-	}
+	writeFeed(w, r, result.Data, format, ctx)
 }
 
 // handleSummary serves the AI-generated summary in the requested format
-func handleSummary(w http.ResponseWriter, r *http.Request, format string, ctx map[string]interface{}) {
+func handleSummary(w http.ResponseWriter, r *http.Request, format rss.Format, ctx map[string]interface{}) {
 	service := summary.NewService()
 
 	// Construct absolute URL using BASE_URL
@@ -144,28 +125,31 @@ func handleSummary(w http.ResponseWriter, r *http.Request, format string, ctx ma
 		rssData = result.Data
 	}
 
-	switch format {
-	case "rss":
-		logger.Debug("Serving RSS summary format", ctx)
-		w.Header().Set("Content-Type", "application/rss+xml")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write(rssData); err != nil {
-			logger.Error("Failed to write RSS summary response", err, ctx)
-			middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
-		}
-	case "json":
-		logger.Debug("Serving JSON summary format", ctx)
-		// For JSON format with summary, we'd need to parse the RSS and return JSON
-		// For now, just serve the RSS as-is
-		w.Header().Set("Content-Type", "application/rss+xml")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write(rssData); err != nil {
-			logger.Error("Failed to write JSON summary response", err, ctx)
-			middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
-		}
-	default:
-		logger.Warn("Invalid format requested for summary", ctx)
-		middleware.WriteJSONError(w, http.StatusBadRequest, "Invalid format. Use 'json' or 'rss'. Default is RSS for RSS reader compatibility")
+	writeFeed(w, r, rssData, format, ctx)
+}
+
+// writeFeed re-renders the stored RSS document in the negotiated format
+func writeFeed(w http.ResponseWriter, r *http.Request, data []byte, format rss.Format, ctx map[string]interface{}) {
+	doc, err := rss.ParseDocument(data)
+	if err != nil {
+		logger.Error("Failed to parse stored RSS", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	doc.FeedURL = constructAbsoluteURL(strings.TrimPrefix(r.URL.RequestURI(), "/"))
+
+	body, err := rss.Render(doc, format)
+	if err != nil {
+		logger.Error("Failed to render feed", err, ctx)
+		middleware.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	logger.Debug("Serving feed", ctx)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		logger.Error("Failed to write feed response", err, ctx)
 	}
 }
 
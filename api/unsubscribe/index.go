@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/middleware"
+	"main/lib/subscribe"
+	"net/http"
+)
+
+// unsubscribeHandler opts a recipient out using the signed link from their email
+//
+//	GET  ?token=<token>                                 the link in the footer: shows an unsubscribe button
+//	POST ?token=<token>, List-Unsubscribe=One-Click     RFC 8058 one-click from the mail client
+//	POST token=<token>                                  the button on the page
+//
+// Only POST unsubscribes, as RFC 8058 requires, so link prefetching can't opt anyone out.
+func unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := logger.Log.WithRequest(r)
+
+	token := r.FormValue("token")
+	if token == "" {
+		subscribe.WriteStatusPage(w, http.StatusBadRequest, subscribe.StatusPage{
+			Title:   "Invalid link",
+			Message: "This unsubscribe link is incomplete. Please use the full link from your email.",
+		}, ctx)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		subscribe.WriteStatusPage(w, http.StatusOK, subscribe.StatusPage{
+			Title:      "Unsubscribe",
+			Message:    "Press the button to stop receiving Takara TLDR emails.",
+			FormAction: "/api/unsubscribe",
+			FormToken:  token,
+			FormLabel:  "Unsubscribe",
+		}, ctx)
+		return
+	}
+
+	source := subscribe.SourcePage
+	if r.PostFormValue("List-Unsubscribe") == "One-Click" {
+		source = subscribe.SourceOneClick
+	}
+	ctx["source"] = source
+
+	_, err := subscribe.Unsubscribe(r.Context(), subscribe.DefaultStore(), mail.Default(), token, source)
+	switch {
+	case errors.Is(err, subscribe.ErrInvalidToken):
+		logger.Warn("Invalid unsubscribe token", ctx)
+		subscribe.WriteStatusPage(w, http.StatusBadRequest, subscribe.StatusPage{
+			Title:   "Invalid link",
+			Message: "This unsubscribe link isn't valid. Please use the full link from your email.",
+		}, ctx)
+	case err != nil:
+		// Mail clients retry failed one-click requests, and repeating it is harmless
+		logger.Error("Unsubscribe failed", err, ctx)
+		subscribe.WriteStatusPage(w, http.StatusInternalServerError, subscribe.StatusPage{
+			Title:   "Something went wrong",
+			Message: "We couldn't unsubscribe you. Please try the link again in a few minutes.",
+		}, ctx)
+	default:
+		logger.Info("Unsubscribe completed", ctx)
+		subscribe.WriteStatusPage(w, http.StatusOK, subscribe.StatusPage{
+			Title:   "You're unsubscribed",
+			Message: "You won't receive any more Takara TLDR emails. You can subscribe again at any time.",
+		}, ctx)
+	}
+}
+
+// Handler is the Vercel serverless function entrypoint for unsubscribing.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	middleware.MethodValidator(http.MethodGet, http.MethodPost)(unsubscribeHandler)(w, r)
+}
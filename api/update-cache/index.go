@@ -2,6 +2,7 @@ package handler
 
 import (
 	"crypto/subtle"
+	"main/lib/jobs"
 	"main/lib/middleware"
 	"main/lib/summary"
 	"net/http"
@@ -37,9 +38,14 @@ func updateCacheHandler(w http.ResponseWriter, r *http.Request) {
 	// Construct absolute URL using BASE_URL (use base URL for canonical cache content)
 	requestURL := constructAbsoluteURL("api/tldr")
 	// Update both papers and summary caches with fresh data
-	err := service.UpdateCache(r.Context(), requestURL)
+	run, err := service.UpdateCache(r.Context(), jobs.Runner(), requestURL)
 	if err != nil {
-		middleware.WriteJSONError(w, http.StatusInternalServerError, "Error updating cache: "+err.Error())
+		response := map[string]interface{}{"error": "Error updating cache: " + err.Error()}
+		if run != nil {
+			// Resume with POST /api/pipeline-runs?id=<runId>
+			response["runId"] = run.ID
+		}
+		middleware.WriteJSONResponse(w, http.StatusInternalServerError, response)
 		return
 	}
 
@@ -48,6 +54,7 @@ func updateCacheHandler(w http.ResponseWriter, r *http.Request) {
 		"status":    "Cache updated successfully",
 		"message":   "Both papers and summary caches have been refreshed with fresh data",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"runId":     run.ID,
 	}
 	middleware.WriteJSONResponse(w, http.StatusOK, response)
 }
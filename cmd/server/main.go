@@ -0,0 +1,178 @@
+// Command server runs every Go API handler in one process, mounted at the same
+// paths Vercel serves them on, so the API can be self-hosted and integration-tested
+// without the Vercel/Next toolchain
+//
+//	go run ./cmd/server            # listens on :8080 (or $PORT / $ADDR)
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	archive "main/api/archive"
+	articlesearch "main/api/articles/search"
+	broadcast "main/api/broadcast"
+	weeklybroadcast "main/api/broadcast/weekly"
+	cleanupsubscriptions "main/api/cron/cleanup-subscriptions"
+	generatedigest "main/api/cron/generate-digest"
+	digest "main/api/digest"
+	ds1 "main/api/ds1"
+	feed "main/api/feed"
+	og "main/api/og"
+	paperapi "main/api/paper"
+	papers "main/api/papers"
+	pipelineruns "main/api/pipeline-runs"
+	preferences "main/api/preferences"
+	search "main/api/search"
+	spectrogram "main/api/spectrogram"
+	subscribe "main/api/subscribe"
+	subscribeconfirm "main/api/subscribe/confirm"
+	tldr "main/api/tldr"
+	unsubscribe "main/api/unsubscribe"
+	updatecache "main/api/update-cache"
+	"main/lib/logger"
+	"main/lib/middleware"
+)
+
+const (
+	defaultPort = "8080"
+	// Time in-flight requests get to finish after SIGINT/SIGTERM
+	shutdownTimeout = 30 * time.Second
+)
+
+// routes maps production paths to their Vercel handlers (api/<path>/index.go -> /api/<path>)
+var routes = map[string]http.HandlerFunc{
+	"/api/archive":                    archive.Handler,
+	"/api/articles/search":            articlesearch.Handler,
+	"/api/broadcast":                  broadcast.Handler,
+	"/api/broadcast/weekly":           weeklybroadcast.Handler,
+	"/api/cron/cleanup-subscriptions": cleanupsubscriptions.Handler,
+	"/api/cron/generate-digest":       generatedigest.Handler,
+	"/api/digest":                     digest.Handler,
+	"/api/ds1":                        ds1.Handler,
+	"/api/feed":                       feed.Handler,
+	"/api/og":                         og.Handler,
+	"/api/paper":                      paperapi.Handler,
+	"/api/papers":                     papers.Handler,
+	"/api/pipeline-runs":              pipelineruns.Handler,
+	"/api/preferences":                preferences.Handler,
+	"/api/search":                     search.Handler,
+	"/api/spectrogram":                spectrogram.Handler,
+	"/api/subscribe":                  subscribe.Handler,
+	"/api/subscribe/confirm":          subscribeconfirm.Handler,
+	"/api/tldr":                       tldr.Handler,
+	"/api/unsubscribe":                unsubscribe.Handler,
+	"/api/update-cache":               updatecache.Handler,
+}
+
+// newMux registers every route behind the shared server middleware
+// The trailing-slash form is mounted too, so /api/paper/ resolves like /api/paper
+func newMux() *http.ServeMux {
+	shared := middleware.CombineMiddlewares(
+		middleware.RequestID,
+		middleware.RequestLogger,
+		middleware.Recoverer,
+	)
+
+	mux := http.NewServeMux()
+	for path, handler := range routes {
+		h := shared(handler)
+		mux.HandleFunc(path, h)
+		mux.HandleFunc(path+"/", h)
+	}
+
+	mux.HandleFunc("GET /healthz", shared(func(w http.ResponseWriter, r *http.Request) {
+		middleware.WriteJSONSuccess(w, http.StatusOK, middleware.SuccessResponse{Success: true})
+	}))
+
+	return mux
+}
+
+// newServer builds the HTTP server for addr
+// There is no WriteTimeout: digest generation and ds1 streams can run for minutes
+func newServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           newMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+}
+
+// listenAddr returns $ADDR, or :$PORT, defaulting to :8080
+func listenAddr() string {
+	if addr := os.Getenv("ADDR"); addr != "" {
+		return addr
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort("", port)
+}
+
+// run serves on ln until ctx is cancelled, then shuts down gracefully
+func run(ctx context.Context, server *http.Server, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down server", map[string]interface{}{
+		"timeout": shutdownTimeout.String(),
+	})
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func main() {
+	// Initialize environment (load .env if available)
+	err := godotenv.Load()
+	if err != nil {
+		logger.Warn("Error loading .env file", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := newServer(listenAddr())
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", server.Addr, err)
+	}
+
+	logger.Info("Server listening", map[string]interface{}{
+		"addr":   ln.Addr().String(),
+		"routes": len(routes),
+	})
+
+	if err := run(ctx, server, ln); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	logger.Info("Server stopped", nil)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"main/lib/middleware"
+)
+
+// TestRoutesCoverAPIHandlers checks every api/<path>/index.go is mounted at /api/<path>
+func TestRoutesCoverAPIHandlers(t *testing.T) {
+	var files []string
+	for _, pattern := range []string{"../../api/*/index.go", "../../api/*/*/index.go"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatalf("Failed to list api handlers: %v", err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		t.Fatal("Expected api handlers to exist")
+	}
+
+	for _, file := range files {
+		rel, err := filepath.Rel("../../api", filepath.Dir(file))
+		if err != nil {
+			t.Fatalf("Failed to resolve %s: %v", file, err)
+		}
+		path := "/api/" + filepath.ToSlash(rel)
+		if _, ok := routes[path]; !ok {
+			t.Errorf("Handler %s is not mounted at %s", file, path)
+		}
+	}
+}
+
+func TestServerRouting(t *testing.T) {
+	server := httptest.NewServer(newMux())
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+	}{
+		{name: "Health check", method: http.MethodGet, path: "/healthz", expected: http.StatusOK},
+		{name: "Wrong method on paper", method: http.MethodPost, path: "/api/paper", expected: http.StatusMethodNotAllowed},
+		{name: "Wrong method on og with trailing slash", method: http.MethodDelete, path: "/api/og/", expected: http.StatusMethodNotAllowed},
+		{name: "Unknown route", method: http.MethodGet, path: "/api/unknown", expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("Failed to build request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			if resp.StatusCode != tt.expected {
+				t.Errorf("%s %s = %d; expected %d", tt.method, tt.path, resp.StatusCode, tt.expected)
+			}
+			if tt.expected != http.StatusNotFound && resp.Header.Get("X-Request-ID") == "" {
+				t.Error("Expected shared middleware to set X-Request-ID")
+			}
+		})
+	}
+}
+
+func TestRecovererReturns500(t *testing.T) {
+	handler := middleware.Recoverer(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/paper", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+	var body middleware.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error == "" {
+		t.Errorf("Expected JSON error body, got %q", rec.Body.String())
+	}
+}
+
+func TestRunGracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := newServer(ln.Addr().String())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, server, ln)
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/healthz")
+	if err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run() = %v; expected clean shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not shut down")
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     string
+		port     string
+		expected string
+	}{
+		{name: "Default", expected: ":8080"},
+		{name: "PORT", port: "3001", expected: ":3001"},
+		{name: "ADDR wins", addr: "127.0.0.1:9000", port: "3001", expected: "127.0.0.1:9000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADDR", tt.addr)
+			t.Setenv("PORT", tt.port)
+			if tt.addr == "" {
+				_ = os.Unsetenv("ADDR")
+			}
+			if got := listenAddr(); got != tt.expected {
+				t.Errorf("listenAddr() = %q; expected %q", got, tt.expected)
+			}
+		})
+	}
+}
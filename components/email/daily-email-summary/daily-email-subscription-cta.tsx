@@ -47,9 +47,11 @@ export function DailyEmailSubscriptionCTA() {
 			});
 
 			if (response.ok) {
+				const data = await response.json();
 				setStatus({
 					type: "success",
-					message: "You've been subscribed successfully!",
+					message:
+						data.message || "Check your inbox to confirm your subscription.",
 				});
 				setEmail(""); // Clear input
 				setTurnstileToken("");
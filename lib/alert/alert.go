@@ -0,0 +1,61 @@
+// Package alert tells the team when a scheduled job gives up instead of doing its work:
+// always as an error log, and on the ALERT_WEBHOOK_URL incoming webhook (Slack-compatible
+// {"text": ...} payload) when it is set
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"main/lib/logger"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Send logs the alert and posts it to ALERT_WEBHOOK_URL. fields are appended one per
+// line, sorted by key. The error is only about delivering the alert.
+func Send(ctx context.Context, title string, fields map[string]interface{}) error {
+	logger.Error("ALERT: "+title, nil, fields)
+
+	webhookURL := os.Getenv("ALERT_WEBHOOK_URL")
+	if webhookURL == "" {
+		return nil
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var text strings.Builder
+	text.WriteString(title)
+	for _, key := range keys {
+		fmt.Fprintf(&text, "\n%s: %v", key, fields[key])
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text.String()})
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned non-2xx status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package article
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterQueryParams are the query parameters ParseFilterQuery reads
+var FilterQueryParams = []string{"category", "source", "jurisdiction", "minScore"}
+
+// ParseFilterQuery reads category=, source=, jurisdiction= and minScore= into a filter.
+// Values can be repeated (category=a&category=b) or comma-separated (category=a,b);
+// they're deduplicated case-insensitively and sorted so equivalent queries compare equal.
+func ParseFilterQuery(query url.Values) (ArticleFilter, error) {
+	filter := ArticleFilter{
+		Categories:    queryList(query, "category", strings.TrimSpace),
+		SourceNames:   queryList(query, "source", strings.TrimSpace),
+		Jurisdictions: queryList(query, "jurisdiction", NormalizeJurisdiction),
+	}
+
+	if value := query.Get("minScore"); value != "" {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 1 {
+			return filter, fmt.Errorf("invalid minScore %q", value)
+		}
+		filter.MinScore = score
+	}
+	return filter, nil
+}
+
+func queryList(query url.Values, key string, normalize func(string) string) []string {
+	seen := map[string]bool{}
+	var values []string
+	for _, raw := range query[key] {
+		for _, value := range strings.Split(raw, ",") {
+			value = normalize(value)
+			if value == "" || seen[strings.ToLower(value)] {
+				continue
+			}
+			seen[strings.ToLower(value)] = true
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return strings.ToLower(values[i]) < strings.ToLower(values[j]) })
+	return values
+}
+
+// IsEmpty reports whether the filter selects every article
+func (f *ArticleFilter) IsEmpty() bool {
+	return len(f.SourceNames) == 0 && len(f.Categories) == 0 && len(f.Jurisdictions) == 0 &&
+		f.MinScore <= 0 && f.DateFrom.IsZero() && f.DateTo.IsZero() && f.Search == ""
+}
+
+// Matches reports whether an article passes every set criterion. Each list matches if any
+// of its values does (case-insensitive); sources match on name or ID. MinScore, Limit and
+// Offset are ignored here, see MatchesRanked and Apply.
+func (f *ArticleFilter) Matches(a ArticleData) bool {
+	if len(f.SourceNames) > 0 && !containsFold(f.SourceNames, a.SourceName, a.SourceID) {
+		return false
+	}
+	if len(f.Categories) > 0 && !containsFold(f.Categories, a.Categories...) {
+		return false
+	}
+	if len(f.Jurisdictions) > 0 {
+		wanted := make([]string, len(f.Jurisdictions))
+		for i, value := range f.Jurisdictions {
+			wanted[i] = NormalizeJurisdiction(value)
+		}
+		if !containsFold(wanted, Jurisdictions(a)...) {
+			return false
+		}
+	}
+
+	if !f.DateFrom.IsZero() || !f.DateTo.IsZero() {
+		published, err := time.Parse(time.RFC3339, a.PublishedDate)
+		if err != nil {
+			return false
+		}
+		if !f.DateFrom.IsZero() && published.Before(f.DateFrom) {
+			return false
+		}
+		if !f.DateTo.IsZero() && published.After(f.DateTo) {
+			return false
+		}
+	}
+
+	if search := strings.ToLower(strings.TrimSpace(f.Search)); search != "" {
+		text := strings.ToLower(a.Title + "\n" + a.Summary + "\n" + a.OriginalSum)
+		if !strings.Contains(text, search) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesRanked is Matches plus the MinScore threshold
+func (f *ArticleFilter) MatchesRanked(r RankedArticle) bool {
+	return r.Score >= f.MinScore && f.Matches(r.Article)
+}
+
+// Apply keeps the ranked articles that match, in order, then applies Offset and Limit
+func (f *ArticleFilter) Apply(articles []RankedArticle) []RankedArticle {
+	matched := make([]RankedArticle, 0, len(articles))
+	for _, ranked := range articles {
+		if f.MatchesRanked(ranked) {
+			matched = append(matched, ranked)
+		}
+	}
+
+	if f.Offset > 0 {
+		if f.Offset >= len(matched) {
+			return []RankedArticle{}
+		}
+		matched = matched[f.Offset:]
+	}
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	return matched
+}
+
+// containsFold reports whether any of values equals any of wanted, ignoring case
+func containsFold(wanted []string, values ...string) bool {
+	for _, w := range wanted {
+		w = strings.TrimSpace(w)
+		for _, v := range values {
+			if v != "" && strings.EqualFold(w, strings.TrimSpace(v)) {
+				return true
+			}
+		}
+	}
+	return false
+}
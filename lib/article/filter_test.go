@@ -0,0 +1,115 @@
+package article
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func filterFixtures() []RankedArticle {
+	return []RankedArticle{
+		{Rank: 1, Score: 0.9, Article: ArticleData{
+			Title:         "UKGC announces affordability checks",
+			SourceName:    "iGamingBusiness",
+			SourceID:      "igamingbusiness",
+			Categories:    []string{"Regulations"},
+			PublishedDate: "2025-01-15T05:30:00Z",
+		}},
+		{Rank: 2, Score: 0.6, Article: ArticleData{
+			Title:         "Payment processor expands in Ontario",
+			SourceName:    "Gambling Insider",
+			SourceID:      "gamblinginsider",
+			Categories:    []string{"Payments", "Business"},
+			PublishedDate: "2025-01-14T10:00:00Z",
+		}},
+		{Rank: 3, Score: 0.4, Article: ArticleData{
+			Title:         "Operator launches new slot studio",
+			SourceName:    "eGaming Review",
+			SourceID:      "egamingreview",
+			Categories:    []string{"Technology"},
+			PublishedDate: "2025-01-13T10:00:00Z",
+			Metadata:      map[string]interface{}{"jurisdiction": "us-nj"},
+		}},
+	}
+}
+
+func TestArticleFilterApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ArticleFilter
+		want   []int
+	}{
+		{name: "Empty", want: []int{1, 2, 3}},
+		{name: "Categories any-of, case-insensitive", filter: ArticleFilter{Categories: []string{"regulations", "Payments"}}, want: []int{1, 2}},
+		{name: "Source by ID", filter: ArticleFilter{SourceNames: []string{"gamblinginsider"}}, want: []int{2}},
+		{name: "Jurisdiction by name", filter: ArticleFilter{Jurisdictions: []string{"United Kingdom"}}, want: []int{1}},
+		{name: "Jurisdiction from alias", filter: ArticleFilter{Jurisdictions: []string{"ontario"}}, want: []int{2}},
+		{name: "Jurisdiction from metadata", filter: ArticleFilter{Jurisdictions: []string{"US-NJ"}}, want: []int{3}},
+		{name: "Min score", filter: ArticleFilter{MinScore: 0.5}, want: []int{1, 2}},
+		{name: "Date range", filter: ArticleFilter{DateFrom: time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC)}, want: []int{1, 2}},
+		{name: "Search", filter: ArticleFilter{Search: "SLOT"}, want: []int{3}},
+		{name: "Criteria combine", filter: ArticleFilter{Categories: []string{"Payments", "Technology"}, MinScore: 0.5}, want: []int{2}},
+		{name: "Offset and limit", filter: ArticleFilter{Offset: 1, Limit: 1}, want: []int{2}},
+		{name: "Offset past the end", filter: ArticleFilter{Offset: 5}, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Apply(filterFixtures())
+			if len(got) != len(tt.want) {
+				t.Fatalf("Apply() returned %d articles; expected ranks %v", len(got), tt.want)
+			}
+			for i, ranked := range got {
+				if ranked.Rank != tt.want[i] {
+					t.Errorf("Apply()[%d] = rank %d; expected %d", i, ranked.Rank, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if !(&ArticleFilter{Limit: 5}).IsEmpty() || (&ArticleFilter{MinScore: 0.1}).IsEmpty() {
+		t.Error("IsEmpty() should ignore paging and notice criteria")
+	}
+}
+
+func TestParseFilterQuery(t *testing.T) {
+	query, _ := url.ParseQuery("category=Payments,regulations&category=payments&jurisdiction=GB&source=%20Gambling%20Insider&minScore=0.5")
+	filter, err := ParseFilterQuery(query)
+	if err != nil {
+		t.Fatalf("ParseFilterQuery() error = %v", err)
+	}
+	if strings.Join(filter.Categories, ",") != "Payments,regulations" || strings.Join(filter.Jurisdictions, ",") != "uk" ||
+		strings.Join(filter.SourceNames, ",") != "Gambling Insider" || filter.MinScore != 0.5 {
+		t.Errorf("ParseFilterQuery() = %+v", filter)
+	}
+
+	for _, value := range []string{"1.5", "-0.1", "high"} {
+		if _, err := ParseFilterQuery(url.Values{"minScore": {value}}); err == nil {
+			t.Errorf("ParseFilterQuery(minScore=%s) expected an error", value)
+		}
+	}
+}
+
+func TestJurisdictions(t *testing.T) {
+	a := ArticleData{
+		Title:    "Maltese supplier enters US market after MGA approval",
+		Summary:  "The company also holds a UKGC licence.",
+		Metadata: map[string]interface{}{"jurisdictions": []interface{}{"GB", "us-pa"}},
+	}
+	got := Jurisdictions(a)
+	want := []string{"uk", "us-pa", "us", "mt"}
+	if len(got) != len(want) {
+		t.Fatalf("Jurisdictions() = %v; expected %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Jurisdictions() = %v; expected %v", got, want)
+			break
+		}
+	}
+
+	if got := Jurisdictions(ArticleData{Title: "Focus on user experience"}); len(got) != 0 {
+		t.Errorf("Jurisdictions() = %v; expected lowercase words not to match codes", got)
+	}
+}
@@ -0,0 +1,90 @@
+package article
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// jurisdiction is a market articles can be filtered by, detected from the names,
+// demonyms and regulators that appear in an article's text
+type jurisdiction struct {
+	Code    string
+	Name    string
+	Aliases []string
+	pattern *regexp.Regexp
+}
+
+var jurisdictions = []*jurisdiction{
+	{Code: "uk", Name: "United Kingdom", Aliases: []string{"gb", "great britain"}, pattern: regexp.MustCompile(`\b(UK|U\.K\.|UKGC|United Kingdom|Great Britain|Britain|British|England|Scotland|Wales)\b`)},
+	{Code: "us", Name: "United States", Aliases: []string{"usa"}, pattern: regexp.MustCompile(`\b(US|U\.S\.|USA|United States|American)\b`)},
+	{Code: "ca", Name: "Canada", Aliases: []string{"ontario"}, pattern: regexp.MustCompile(`\b(Canada|Canadian|Ontario|AGCO|Alberta|Quebec)\b`)},
+	{Code: "mt", Name: "Malta", pattern: regexp.MustCompile(`\b(Malta|Maltese|MGA)\b`)},
+	{Code: "de", Name: "Germany", pattern: regexp.MustCompile(`\b(Germany|German|GGL)\b`)},
+	{Code: "nl", Name: "Netherlands", pattern: regexp.MustCompile(`\b(Netherlands|Dutch|Kansspelautoriteit)\b`)},
+	{Code: "se", Name: "Sweden", pattern: regexp.MustCompile(`\b(Sweden|Swedish|Spelinspektionen)\b`)},
+	{Code: "es", Name: "Spain", pattern: regexp.MustCompile(`\b(Spain|Spanish|DGOJ)\b`)},
+	{Code: "it", Name: "Italy", pattern: regexp.MustCompile(`\b(Italy|Italian)\b`)},
+	{Code: "fr", Name: "France", pattern: regexp.MustCompile(`\b(France|French|ANJ)\b`)},
+	{Code: "ie", Name: "Ireland", pattern: regexp.MustCompile(`\b(Ireland|Irish|GRAI)\b`)},
+	{Code: "br", Name: "Brazil", pattern: regexp.MustCompile(`\b(Brazil|Brazilian)\b`)},
+	{Code: "au", Name: "Australia", pattern: regexp.MustCompile(`\b(Australia|Australian|ACMA)\b`)},
+	{Code: "ph", Name: "Philippines", pattern: regexp.MustCompile(`\b(Philippines|Philippine|PAGCOR)\b`)},
+}
+
+// NormalizeJurisdiction maps a jurisdiction code, name or alias ("GB", "united kingdom")
+// to its code ("uk"). Unknown values are lowercased and kept, so metadata-tagged
+// jurisdictions such as "us-nj" still match exactly.
+func NormalizeJurisdiction(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, j := range jurisdictions {
+		if value == j.Code || value == strings.ToLower(j.Name) {
+			return j.Code
+		}
+		for _, alias := range j.Aliases {
+			if value == alias {
+				return j.Code
+			}
+		}
+	}
+	return value
+}
+
+// Jurisdictions returns the jurisdiction codes an article covers: any tagged in
+// Metadata["jurisdiction"] or Metadata["jurisdictions"], plus those detected in its
+// title, summaries and categories
+func Jurisdictions(a ArticleData) []string {
+	var codes []string
+	seen := map[string]bool{}
+	add := func(code string) {
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+
+	for _, key := range []string{"jurisdiction", "jurisdictions"} {
+		switch tagged := a.Metadata[key].(type) {
+		case string:
+			for _, value := range strings.Split(tagged, ",") {
+				add(NormalizeJurisdiction(value))
+			}
+		case []string:
+			for _, value := range tagged {
+				add(NormalizeJurisdiction(value))
+			}
+		case []interface{}:
+			for _, value := range tagged {
+				add(NormalizeJurisdiction(fmt.Sprint(value)))
+			}
+		}
+	}
+
+	text := strings.Join(append([]string{a.Title, a.Summary, a.OriginalSum}, a.Categories...), "\n")
+	for _, j := range jurisdictions {
+		if j.pattern.MatchString(text) {
+			add(j.Code)
+		}
+	}
+	return codes
+}
@@ -63,6 +63,18 @@ const (
 	CategoryResponsibleGaming ArticleCategory = "Responsible Gaming"
 )
 
+// Categories lists every ArticleCategory, in display order
+var Categories = []ArticleCategory{
+	CategoryRegulations,
+	CategoryBusiness,
+	CategoryTechnology,
+	CategorySportsBetting,
+	CategoryMergerAcquisition,
+	CategoryInternational,
+	CategoryPayments,
+	CategoryResponsibleGaming,
+}
+
 // ErrorCode defines error types for article operations
 type ErrorCode string
 
@@ -87,6 +99,8 @@ type ApiError struct {
 type ArticleFilter struct {
 	SourceNames  []string
 	Categories   []string
+	Jurisdictions []string // Codes or names, e.g. "uk", "Ontario"; see NormalizeJurisdiction
+	MinScore     float64  // Minimum ranking score (0-1); only applies to ranked articles
 	DateFrom     time.Time
 	DateTo       time.Time
 	Search       string
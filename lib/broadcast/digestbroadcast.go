@@ -0,0 +1,364 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"main/lib/analytics"
+	"main/lib/article"
+	"main/lib/feed"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/rss"
+	"main/lib/subscribe"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// digestEmailTemplateStr renders the iGaming DailyDigest: headline, summary, then the ranked
+// articles, each with its image, source, why it was picked and a link to the original
+const digestEmailTemplateStr = `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8" />
+</head>
+<body style="font-family: 'Lato', 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; color: rgb(74, 77, 78);">
+    <table width="100%" border="0" cellspacing="0" cellpadding="0">
+        <tr>
+            <td align="center" style="padding-top: 40px; padding-bottom: 20px;">
+                <a href="https://tldr.takara.ai" style="text-decoration: none;">
+                    <span style="font-family: 'Lato', sans-serif; font-weight: 900; font-size: 40px; color: rgb(74, 77, 78); display: inline;">tldr.</span><span style="font-family: 'Lato', sans-serif; font-weight: 900; font-size: 40px; color: rgb(217, 16, 9); display: inline;">takara.ai</span>
+                </a>
+            </td>
+        </tr>
+    </table>
+
+    <table width="100%" border="0" cellspacing="0" cellpadding="0" style="padding: 20px;">
+        <tr>
+            <td>
+                <p style="font-size: 18px; font-weight: bold; letter-spacing: 2px; text-transform: uppercase; color: rgb(217, 16, 9); margin: 0;">iGaming TLDR · {{ .FormattedDate }}</p>
+                <h1 style="font-family: 'Noto Sans', Helvetica, Arial, sans-serif; font-size: 40px; margin-top: 10px; margin-bottom: 10px;">{{ .Digest.Headline }}</h1>
+                <p style="font-size: 20px; line-height: 150%;">{{ .Digest.Summary }}</p>
+                <hr style="margin-top: 16px; margin-bottom: 16px; border: 0; border-top: 1px solid rgba(74, 77, 78, 0.4);" />
+                {{range .Articles}}
+                <table width="100%" border="0" cellspacing="0" cellpadding="0" style="margin-bottom: 32px;">
+                    {{- if .ImageURL }}
+                    <tr>
+                        <td colspan="2" style="padding-bottom: 12px;">
+                            <a href="{{ .URL }}" style="text-decoration: none;"><img src="{{ .ImageURL }}" alt="{{ .Title }}" width="560" style="width: 100%; max-width: 560px; height: auto; border-radius: 6px;" /></a>
+                        </td>
+                    </tr>
+                    {{- end }}
+                    <tr>
+                        <td valign="top" style="width: 48px; font-weight: 900; font-size: 32px; line-height: 1; color: rgb(217, 16, 9);">{{ .Rank }}</td>
+                        <td valign="top">
+                            <a href="{{ .URL }}" style="font-size: 22px; font-weight: bold; color: rgb(217, 16, 9); text-decoration: none;">{{ .Title }}</a>
+                            <p style="font-size: 13px; color: rgba(74, 77, 78, 0.8); margin: 6px 0;">{{ .Attribution }}</p>
+                            {{- if .Summary }}
+                            <p style="font-size: 17px; line-height: 150%; margin: 0;">{{ .Summary }}</p>
+                            {{- end }}
+                            {{- if .Reasons }}
+                            <p style="font-size: 13px; margin: 8px 0 0 0;"><span style="font-weight: bold; color: rgb(217, 16, 9);">Why it's here:</span> {{ join .Reasons ", " }}</p>
+                            {{- end }}
+                        </td>
+                    </tr>
+                </table>
+                {{end}}
+                <p style="font-size: 16px;">Read this digest online at <a href="{{ .WebURL }}" style="color: rgb(217, 16, 9); text-decoration: none;">{{ .WebURL }}</a>.</p>
+            </td>
+        </tr>
+    </table>
+
+    <table width="100%" border="0" cellspacing="0" cellpadding="0" style="padding: 12px 20px; max-width: 100%;">
+        <tr>
+            <td>
+                <hr style="margin-top: 16px; margin-bottom: 16px; border: 0; border-top: 1px solid rgba(74, 77, 78, 0.4);" />
+                <table width="100%" border="0" cellspacing="0" cellpadding="0" style="text-align: center; font-size: 12px; color: rgba(74, 77, 78, 0.8);">
+                    <tr><td style="padding-top: 10px;">Want to stop receiving emails? {{ "<a href=\"{{{RESEND_UNSUBSCRIBE_URL}}}\" style=\"color: rgb(217, 16, 9); text-decoration: none;\">Unsubscribe</a>" | safeHTML }}</td></tr>
+                    <tr><td>Articles are summarised from their original publishers, credited above. Disclaimer: takara.ai Ltd is not responsible for the content of these articles as all content is from third-party sources.</td></tr>
+                    <tr><td>© 2025 takara.ai Ltd. All rights reserved.</td></tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`
+
+// digestTextTemplateStr is the plain-text alternative of digestEmailTemplateStr
+const digestTextTemplateStr = `iGaming TLDR · {{ .FormattedDate }}
+
+{{ .Digest.Headline }}
+
+{{ .Digest.Summary }}
+{{ range .Articles }}
+{{ .Rank }}. {{ .Title }}
+   {{ .Attribution }}
+{{- if .Summary }}
+   {{ .Summary }}
+{{- end }}
+{{- if .Reasons }}
+   Why it's here: {{ join .Reasons ", " }}
+{{- end }}
+   {{ .URL }}
+{{ end }}
+Read this digest online: {{ .WebURL }}
+
+--
+Unsubscribe: {{"{{{RESEND_UNSUBSCRIBE_URL}}}"}}
+Articles are summarised from their original publishers, credited above. takara.ai Ltd is not responsible for the content of these articles as all content is from third-party sources.
+`
+
+// digestReasonLabels turns the ranking engine's reasons into what readers see.
+// Unknown reasons are shown as they are; "unscored" is left out.
+var digestReasonLabels = map[string]string{
+	"trending":        "Trending",
+	"authoritative":   "Trusted source",
+	"high-engagement": "High engagement",
+	"diverse":         "Broadens coverage",
+	"featured":        "Featured",
+	"unscored":        "",
+}
+
+// digestArticle is one ranked article as the digest templates show it
+type digestArticle struct {
+	Rank        int
+	Title       string
+	URL         string
+	ImageURL    string
+	Attribution string // Source, publish date and categories
+	Summary     string
+	Reasons     []string
+}
+
+// digestTemplateData holds the data for the digest email templates
+type digestTemplateData struct {
+	Digest        *article.DailyDigest
+	FormattedDate string
+	WebURL        string
+	Articles      []digestArticle
+}
+
+// renderedDigest is a digest email ready to broadcast
+type renderedDigest struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// newDigestTemplateData prepares a digest for the templates, with publish times in loc
+func newDigestTemplateData(digest *article.DailyDigest, loc *time.Location) digestTemplateData {
+	formattedDate := digest.Date
+	if t, err := time.Parse("2006-01-02", digest.Date); err == nil {
+		formattedDate = t.Format("January 2, 2006")
+	}
+
+	data := digestTemplateData{
+		Digest:        digest,
+		FormattedDate: formattedDate,
+		WebURL:        rss.BaseURL() + "/gaming/" + digest.Date,
+		Articles:      make([]digestArticle, len(digest.Articles)),
+	}
+	for i, ranked := range digest.Articles {
+		art := ranked.Article
+		rank := ranked.Rank
+		if rank == 0 {
+			rank = i + 1
+		}
+		summary := art.Summary
+		if summary == "" {
+			summary = art.OriginalSum
+		}
+
+		attribution := []string{art.SourceName}
+		if published, err := time.Parse(time.RFC3339, art.PublishedDate); err == nil {
+			attribution = append(attribution, published.In(loc).Format("Jan 2, 15:04 MST"))
+		}
+		if len(art.Categories) > 0 {
+			attribution = append(attribution, strings.Join(art.Categories, ", "))
+		}
+		if art.SourceName == "" {
+			attribution = attribution[1:]
+		}
+
+		var reasons []string
+		for _, reason := range strings.Split(ranked.Reason, ",") {
+			reason = strings.TrimSpace(reason)
+			label, known := digestReasonLabels[reason]
+			if !known {
+				label = reason
+			}
+			if label != "" {
+				reasons = append(reasons, label)
+			}
+		}
+
+		data.Articles[i] = digestArticle{
+			Rank:        rank,
+			Title:       art.Title,
+			URL:         art.URL,
+			ImageURL:    art.ImageURL,
+			Attribution: strings.Join(attribution, " · "),
+			Summary:     summary,
+			Reasons:     reasons,
+		}
+	}
+	return data
+}
+
+// renderDigestEmail renders the digest's subject, HTML body and plain-text alternative,
+// showing publish times in loc
+func renderDigestEmail(digest *article.DailyDigest, loc *time.Location) (*renderedDigest, error) {
+	data := newDigestTemplateData(digest, loc)
+	funcs := map[string]interface{}{
+		"safeHTML": func(s string) template.HTML { return template.HTML(s) },
+		"join":     strings.Join,
+	}
+
+	htmlTpl, err := template.New("digestEmail").Funcs(funcs).Parse(digestEmailTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse digest email template: %w", err)
+	}
+	var html bytes.Buffer
+	if err := htmlTpl.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to execute digest email template: %w", err)
+	}
+
+	textTpl, err := texttemplate.New("digestText").Funcs(funcs).Parse(digestTextTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse digest text template: %w", err)
+	}
+	var text bytes.Buffer
+	if err := textTpl.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to execute digest text template: %w", err)
+	}
+
+	return &renderedDigest{
+		Subject: fmt.Sprintf("iGaming TLDR: %s", digest.Headline),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+// NewDigestBroadcaster returns a broadcaster sending digests through mailer to the daily
+// digest segment. The segment has its own audience (RESEND_DIGEST_AUDIENCE_ID), separate
+// from the TLDR papers list. Subscribers in store who chose digest categories aren't in it;
+// they are sent their own digest afterwards (see sendPersonalDigests)
+func NewDigestBroadcaster(mailer mail.Mailer, store subscribe.Store) feed.DigestBroadcaster {
+	return func(ctx context.Context, digest *article.DailyDigest) (string, error) {
+		audienceID := subscribe.Segment{Product: subscribe.ProductDigest, Frequency: subscribe.FrequencyDaily}.AudienceID()
+		fromEmail := mail.FromAddress()
+
+		if audienceID == "" || fromEmail == "" {
+			return "", fmt.Errorf("missing RESEND_DIGEST_AUDIENCE_ID or RESEND_FROM_EMAIL environment variables")
+		}
+
+		rendered, err := renderDigestEmail(digest, time.UTC)
+		if err != nil {
+			return "", err
+		}
+		if err := mail.Lint(rendered.HTML); err != nil {
+			return "", err
+		}
+		subject := rendered.Subject
+
+		logger.Info("Creating digest broadcast", map[string]interface{}{"subject": subject, "audienceId": audienceID, "date": digest.Date})
+
+		broadcastID, err := mailer.CreateBroadcast(ctx, mail.Broadcast{
+			Name:       "iGaming digest " + digest.Date,
+			AudienceID: audienceID,
+			From:       fromEmail,
+			Subject:    subject,
+			HTML:       rendered.HTML,
+			Text:       rendered.Text,
+
+			UnsubscribeURL: subscribe.UnsubscribeURL,
+		})
+		if err != nil {
+			return "", err
+		}
+		if err := mailer.SendBroadcast(ctx, broadcastID); err != nil {
+			return "", err
+		}
+
+		personal := sendPersonalDigests(ctx, mailer, store, digest, fromEmail)
+
+		logger.Info("Successfully sent digest broadcast", map[string]interface{}{"broadcastId": broadcastID, "date": digest.Date, "personal": personal})
+		_ = analytics.Track("digest_broadcast_sent", broadcastID, map[string]interface{}{"subject": subject, "date": digest.Date, "personal": personal})
+		return broadcastID, nil
+	}
+}
+
+// personalDigest narrows a digest to the categories in prefs, ranked again from 1 in the
+// original order. Returns nil when none of its articles are in them
+func personalDigest(digest *article.DailyDigest, prefs subscribe.Preferences) *article.DailyDigest {
+	filter := article.ArticleFilter{Categories: prefs.Categories}
+	matched := filter.Apply(digest.Articles)
+	if len(matched) == 0 {
+		return nil
+	}
+
+	personal := *digest
+	personal.Articles = make([]article.RankedArticle, len(matched))
+	for i, ranked := range matched {
+		ranked.Rank = i + 1
+		personal.Articles[i] = ranked
+	}
+	return &personal
+}
+
+// sendPersonalDigests sends each active subscriber with a PersonalDigest the digest's articles
+// in their categories, with publish times in their time zone, as a message of its own with
+// their unsubscribe link. It runs once the audience broadcast is out, so failures are logged
+// and skipped rather than failing (and re-sending) the broadcast. Returns how many were sent
+func sendPersonalDigests(ctx context.Context, mailer mail.Mailer, store subscribe.Store, digest *article.DailyDigest, from string) int {
+	if store == nil {
+		return 0
+	}
+	subscribers, err := store.ListActive(ctx)
+	if err != nil {
+		logger.Error("Failed to list subscribers for personal digests", err, map[string]interface{}{"date": digest.Date})
+		return 0
+	}
+
+	sent := 0
+	for _, sub := range subscribers {
+		if !sub.Preferences.PersonalDigest() {
+			continue
+		}
+		logCtx := map[string]interface{}{"email": sub.Email, "date": digest.Date, "categories": sub.Preferences.Categories}
+
+		personal := personalDigest(digest, sub.Preferences)
+		if personal == nil {
+			logger.Debug("No digest articles in the subscriber's categories", logCtx)
+			continue
+		}
+		rendered, err := renderDigestEmail(personal, sub.Preferences.Location())
+		if err != nil {
+			logger.Error("Failed to render personal digest", err, logCtx)
+			continue
+		}
+		link, err := subscribe.UnsubscribeURL(sub.Email)
+		if err != nil {
+			logger.Error("Failed to create unsubscribe link for personal digest", err, logCtx)
+			continue
+		}
+
+		msg := mail.WithUnsubscribeLink(mail.Message{
+			From:    from,
+			To:      []string{sub.Email},
+			Subject: rendered.Subject,
+			HTML:    rendered.HTML,
+			Text:    rendered.Text,
+		}, link)
+		if _, err := mailer.Send(ctx, msg); err != nil {
+			logger.Error("Failed to send personal digest", err, logCtx)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
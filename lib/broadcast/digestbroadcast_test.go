@@ -0,0 +1,153 @@
+package broadcast
+
+import (
+	"context"
+	"flag"
+	"main/lib/article"
+	"main/lib/mail"
+	"main/lib/subscribe"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test ./lib/broadcast -run Digest -update rewrites the golden files in testdata
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func digestFixture() *article.DailyDigest {
+	return &article.DailyDigest{
+		Date:     "2025-01-15",
+		Headline: "UKGC tightens affordability checks",
+		Summary:  "Regulation led the day, with the UK Gambling Commission setting new thresholds & a $2bn merger close behind.",
+		Created:  time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC),
+		Articles: []article.RankedArticle{
+			{
+				Rank:   1,
+				Score:  0.92,
+				Reason: "trending, authoritative",
+				Article: article.ArticleData{
+					Title:         "UKGC announces affordability checks",
+					Summary:       "Operators must run checks above £150 net loss.",
+					URL:           "https://news.test/ukgc-affordability",
+					SourceName:    "iGamingBusiness",
+					PublishedDate: "2025-01-15T05:30:00Z",
+					ImageURL:      "https://news.test/ukgc.png",
+					Categories:    []string{"Regulations"},
+				},
+			},
+			{
+				Rank:   2,
+				Score:  0.71,
+				Reason: "featured",
+				Article: article.ArticleData{
+					Title:         "Operators merge <in> $2bn deal",
+					OriginalSum:   "Two operators combine.",
+					URL:           "https://news.test/merger?a=1&b=2",
+					SourceName:    "Gambling Insider",
+					PublishedDate: "2025-01-14T22:00:00Z",
+					Categories:    []string{"M&A", "Business"},
+				},
+			},
+			{
+				Rank:   3,
+				Reason: "unscored",
+				Article: article.ArticleData{
+					Title:      "Payments roundup",
+					URL:        "https://news.test/payments",
+					SourceName: "SBC News",
+				},
+			},
+		},
+	}
+}
+
+func TestRenderDigestEmailGolden(t *testing.T) {
+	t.Setenv("BASE_URL", "https://tldr.takara.ai")
+
+	rendered, err := renderDigestEmail(digestFixture(), time.UTC)
+	if err != nil {
+		t.Fatalf("renderDigestEmail() error = %v", err)
+	}
+	if rendered.Subject != "iGaming TLDR: UKGC tightens affordability checks" {
+		t.Errorf("Subject = %q", rendered.Subject)
+	}
+
+	for name, got := range map[string]string{"digest-email.html": rendered.HTML, "digest-email.txt": rendered.Text} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name)
+			if *update {
+				if err := os.MkdirAll("testdata", 0o755); err != nil {
+					t.Fatalf("failed to create testdata: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatalf("failed to write golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("rendered output differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestDigestBroadcaster(t *testing.T) {
+	t.Setenv("RESEND_DIGEST_AUDIENCE_ID", "aud-digest")
+	t.Setenv("MAIL_FROM", "news@example.com")
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+	ctx := context.Background()
+	outbox := mail.NewOutbox("")
+	outbox.UpsertContact(ctx, "aud-digest", mail.Contact{Email: "reader@example.com"})
+	outbox.UpsertContact(ctx, "aud-tldr", mail.Contact{Email: "papers@example.com"})
+
+	// Readers who chose categories get a personal digest instead of the audience's
+	store := subscribe.NewMemoryStore()
+	now := time.Now()
+	for email, prefs := range map[string]subscribe.Preferences{
+		"reader@example.com":   {Products: []string{subscribe.ProductDigest}, Frequency: subscribe.FrequencyDaily, TimeZone: "UTC"},
+		"deals@example.com":    {Products: []string{subscribe.ProductDigest}, Frequency: subscribe.FrequencyDaily, Categories: []string{"M&A"}, TimeZone: "America/New_York"},
+		"payments@example.com": {Products: []string{subscribe.ProductDigest}, Frequency: subscribe.FrequencyDaily, Categories: []string{"Payments"}, TimeZone: "UTC"},
+	} {
+		store.AddPending(ctx, email, prefs, now)
+		store.Confirm(ctx, email, now)
+	}
+
+	id, err := NewDigestBroadcaster(outbox, store)(ctx, digestFixture())
+	if err != nil || id == "" {
+		t.Fatalf("broadcaster = %q, %v", id, err)
+	}
+
+	// Only the digest audience gets the broadcast, with both parts
+	messages := outbox.Messages()
+	if len(messages) != 2 || messages[0].To[0] != "reader@example.com" {
+		t.Fatalf("messages = %+v, want the digest audience's then one personal digest", messages)
+	}
+	if !strings.Contains(messages[0].HTML, "UKGC announces affordability checks") || !strings.Contains(messages[0].Text, "1. UKGC announces affordability checks") {
+		t.Error("digest email is missing the top article")
+	}
+	// Local backends swap Resend's unsubscribe tag for the reader's own link
+	if strings.Contains(messages[0].HTML+messages[0].Text, mail.ResendUnsubscribePlaceholder) || !strings.Contains(messages[0].Headers["List-Unsubscribe"], "/api/unsubscribe?token=") {
+		t.Errorf("digest email unsubscribe = %v, want the reader's own link", messages[0].Headers)
+	}
+
+	// The personal digest has only the reader's categories, with times in their zone;
+	// nothing is in Payments, so that reader gets nothing today
+	personal := messages[1]
+	if personal.To[0] != "deals@example.com" {
+		t.Fatalf("personal digest sent to %v, want deals@example.com", personal.To)
+	}
+	if strings.Contains(personal.Text, "UKGC announces") || !strings.Contains(personal.Text, "1. Operators merge <in> $2bn deal") {
+		t.Errorf("personal digest articles:\n%s", personal.Text)
+	}
+	if !strings.Contains(personal.Text, "Jan 14, 17:00 EST") {
+		t.Errorf("personal digest times aren't in the reader's zone:\n%s", personal.Text)
+	}
+	if strings.Contains(personal.HTML+personal.Text, mail.ResendUnsubscribePlaceholder) || !strings.Contains(personal.Headers["List-Unsubscribe"], "/api/unsubscribe?token=") {
+		t.Errorf("personal digest unsubscribe = %v, want the reader's own link", personal.Headers)
+	}
+}
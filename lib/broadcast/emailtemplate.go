@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"main/lib/mail"
+	"main/lib/rss"
 	"time"
 )
 
@@ -13,10 +15,7 @@ const dailyEmailTemplateStr = `
 <!DOCTYPE html>
 <html>
 <head>
-    <style>
-        a { color: rgb(217, 16, 9) !important; text-decoration: none; }
-        a:hover { text-decoration: underline; }
-    </style>
+    <meta charset="utf-8" />
 </head>
 <body style="font-family: 'Lato', 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;">
     <table width="100%" border="0" cellspacing="0" cellpadding="0">
@@ -60,7 +59,7 @@ const dailyEmailTemplateStr = `
                             <p style="font-family: 'Noto Sans', sans-serif; line-height: 200%; font-weight: bold; font-size: 25px; color: rgb(217, 16, 9); margin: 0;">類を変革する</p>
                         </td>
                         <td align="right" valign="top" style="width: 50%;">
-                            <a href="https://takara.ai">
+                            <a href="https://takara.ai" style="text-decoration: none;">
                                 <img src="https://tldr.takara.ai/icon.svg" alt="Origami Crane Logo" style="width: 250px; height: 194px; max-width: 100%;" />
                             </a>
                         </td>
@@ -68,7 +67,7 @@ const dailyEmailTemplateStr = `
                 </table>
 
                 <table width="100%" border="0" cellspacing="0" cellpadding="0" style="text-align: center; font-family: 'Lato', sans-serif; font-size: 12px; color: rgba(74, 77, 78, 0.8);">
-                    <tr><td style="padding-top: 10px;">Want to stop receiving emails? {{ "<a href=\"{{{RESEND_UNSUBSCRIBE_URL}}}\">Unsubscribe</a>" | safeHTML }}</td></tr>
+                    <tr><td style="padding-top: 10px;">Want to stop receiving emails? {{ "<a href=\"{{{RESEND_UNSUBSCRIBE_URL}}}\" style=\"color: rgb(217, 16, 9); text-decoration: none;\">Unsubscribe</a>" | safeHTML }}</td></tr>
                     <tr><td>Disclaimer: takara.ai Ltd is not responsible for the content of these articles as all content is from third-party sources.</td></tr>
                     <tr><td>© 2025 takara.ai Ltd. All rights reserved.</td></tr>
                 </table>
@@ -79,17 +78,21 @@ const dailyEmailTemplateStr = `
 </html>
 `
 
+// emailLinkStyle colors links in feed content, which carry no styles of their own.
+// Email clients drop <style> blocks, so every link is styled inline.
+const emailLinkStyle = "color: rgb(217, 16, 9); text-decoration: none;"
+
 // TemplateData holds the data for the email template.
 // The Items slice contains structs with a `template.HTML` field to ensure
 // the content is not escaped by the template engine.
 type TemplateData struct {
-	Feed          RssFeed
+	Feed          rss.RssFeed
 	FormattedDate string
 	Items         []struct{ Description template.HTML }
 }
 
 // generateEmailHTML executes the Go template to produce the final email body.
-func generateEmailHTML(feed RssFeed) (string, error) {
+func generateEmailHTML(feed rss.RssFeed) (string, error) {
 	// Prepare the data for the template.
 	templateData := TemplateData{
 		Feed:          feed,
@@ -99,7 +102,7 @@ func generateEmailHTML(feed RssFeed) (string, error) {
 
 	// Convert each item's description from string to template.HTML
 	for i, item := range feed.Items {
-		templateData.Items[i] = struct{ Description template.HTML }{Description: template.HTML(item.Description)}
+		templateData.Items[i] = struct{ Description template.HTML }{Description: template.HTML(mail.StyleLinks(item.Description, emailLinkStyle))}
 	}
 
 	// Parse the template string.
@@ -1,15 +1,61 @@
 package broadcast
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	"main/lib/mail"
+	"main/lib/rss"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
-// TestRenderDailyEmailTemplate fetches the RSS feed using ParseRssFeed,
+// TestEmailTemplatesPassLint renders every broadcast email and checks it against
+// mail.Lint, which the broadcast stages enforce before anything is sent
+func TestEmailTemplatesPassLint(t *testing.T) {
+	t.Setenv("BASE_URL", "https://tldr.takara.ai")
+
+	daily, err := generateEmailHTML(rss.RssFeed{
+		Description:   "Today in AI research",
+		LastBuildDate: "Wed, 15 Jan 2025 07:00:00 +0000",
+		Items:         []rss.FeedItem{{Description: `<h2>What's New</h2><p><a href="https://tldr.takara.ai/p/2501.00001">Small Models</a> beat larger ones.</p>`}},
+	})
+	if err != nil {
+		t.Fatalf("generateEmailHTML() error = %v", err)
+	}
+	if !strings.Contains(daily, `<a style="color: rgb(217, 16, 9); text-decoration: none;" href="https://tldr.takara.ai/p/2501.00001">`) {
+		t.Error("daily email leaves feed links unstyled")
+	}
+	if text := mail.PlainText(daily); !strings.Contains(text, "Small Models (https://tldr.takara.ai/p/2501.00001) beat larger ones.") {
+		t.Errorf("daily text part is missing the paper link:\n%s", text)
+	}
+
+	weekly, err := generateWeeklyEmailHTML(weeklyIssue{
+		WeekEnding: "2025-01-12",
+		Headline:   "Small models win",
+		Papers:     []weeklyPaper{{ArxivID: "2501.00001", Title: "Small Models", Link: "https://tldr.takara.ai/p/2501.00001", Date: "2025-01-06", Summary: "They win."}},
+	})
+	if err != nil {
+		t.Fatalf("generateWeeklyEmailHTML() error = %v", err)
+	}
+
+	digest, err := renderDigestEmail(digestFixture(), time.UTC)
+	if err != nil {
+		t.Fatalf("renderDigestEmail() error = %v", err)
+	}
+
+	for name, html := range map[string]string{"daily": daily, "weekly": weekly, "digest": digest.HTML} {
+		if err := mail.Lint(html); err != nil {
+			t.Errorf("%s email: %v", name, err)
+		}
+	}
+}
+
+// TestRenderDailyEmailTemplate fetches the RSS feed using rss.FetchTldr,
 // renders the daily email HTML with the existing template, and writes
 // the output to tests/email/daily-email.html for manual viewing.
 func TestRenderDailyEmailTemplate(t *testing.T) {
@@ -18,12 +64,12 @@ func TestRenderDailyEmailTemplate(t *testing.T) {
 		t.Skip("BASE_URL is not set; skipping email template render test")
 	}
 
-	feed, err := ParseRssFeed()
+	feed, err := rss.FetchTldr(context.Background())
 	if err != nil {
 		t.Fatalf("failed to parse RSS feed: %v", err)
 	}
 	if feed == nil {
-		t.Fatalf("ParseRssFeed returned nil feed")
+		t.Fatalf("FetchTldr returned nil feed")
 	}
 
 	html, err := generateEmailHTML(*feed)
@@ -72,7 +118,7 @@ func TestRenderDailyEmailTemplateFromAPI(t *testing.T) {
 		t.Fatalf("unexpected status from TLDR API feed: %s; body=%s", resp.Status, string(b))
 	}
 
-	var feed RssFeed
+	var feed rss.RssFeed
 	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
 		t.Fatalf("failed to decode TLDR API feed JSON: %v", err)
 	}
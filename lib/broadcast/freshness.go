@@ -0,0 +1,189 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/lib/alert"
+	"main/lib/analytics"
+	"main/lib/logger"
+	"main/lib/rss"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// What the daily broadcast does with a feed that fails its FreshnessPolicy
+const (
+	StaleRegenerate = "regenerate" // Regenerate the feed and re-check until the deadline
+	StaleWait       = "wait"       // Re-check until the deadline, for when another job is regenerating it
+	StaleAbort      = "abort"      // Give up straight away
+)
+
+// ErrStaleFeed is returned when the feed still fails the freshness policy at the deadline.
+// The broadcast is aborted and an alert sent, so readers never get a repeated issue.
+var ErrStaleFeed = errors.New("feed is not fresh enough to broadcast")
+
+// FreshnessPolicy decides whether the TLDR feed is today's issue and fit to send
+type FreshnessPolicy struct {
+	// MaxAge is how old the feed's build date may be; 0 turns the check off
+	MaxAge time.Duration
+	// MinItems is the fewest sections the issue may have
+	MinItems int
+	// RequireSummary refuses an issue without its summary paragraph
+	RequireSummary bool
+
+	// OnStale is StaleRegenerate, StaleWait or StaleAbort
+	OnStale string
+	// Deadline is how long to keep waiting or regenerating before aborting
+	Deadline time.Duration
+	// RetryInterval is the pause between checks
+	RetryInterval time.Duration
+	// Regenerate rebuilds the feed (summary.Service.UpdateCache); nil makes StaleRegenerate wait
+	Regenerate func(ctx context.Context) error
+}
+
+// FreshnessPolicyFromEnv reads the policy from the environment, without Regenerate:
+//
+//	BROADCAST_MAX_FEED_AGE       default 20h; 0 turns the age check off
+//	BROADCAST_MIN_ITEMS          default 1
+//	BROADCAST_REQUIRE_SUMMARY    default true
+//	BROADCAST_ON_STALE           regenerate (default), wait or abort
+//	BROADCAST_FRESHNESS_DEADLINE default 4m, inside the function's 300s limit
+//	BROADCAST_FRESHNESS_RETRY    default 30s
+func FreshnessPolicyFromEnv() FreshnessPolicy {
+	policy := FreshnessPolicy{
+		MaxAge:         envDuration("BROADCAST_MAX_FEED_AGE", 20*time.Hour),
+		MinItems:       1,
+		RequireSummary: true,
+		OnStale:        StaleRegenerate,
+		Deadline:       envDuration("BROADCAST_FRESHNESS_DEADLINE", 4*time.Minute),
+		RetryInterval:  envDuration("BROADCAST_FRESHNESS_RETRY", 30*time.Second),
+	}
+	if n, err := strconv.Atoi(os.Getenv("BROADCAST_MIN_ITEMS")); err == nil && n >= 0 {
+		policy.MinItems = n
+	}
+	if required, err := strconv.ParseBool(os.Getenv("BROADCAST_REQUIRE_SUMMARY")); err == nil {
+		policy.RequireSummary = required
+	}
+	switch onStale := os.Getenv("BROADCAST_ON_STALE"); onStale {
+	case "":
+	case StaleRegenerate, StaleWait, StaleAbort:
+		policy.OnStale = onStale
+	default:
+		logger.Warn("Unknown BROADCAST_ON_STALE, regenerating stale feeds", map[string]interface{}{"onStale": onStale})
+	}
+	return policy
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		logger.Warn("Invalid duration, using the default", map[string]interface{}{"name": name, "value": value, "default": fallback.String()})
+		return fallback
+	}
+	return d
+}
+
+// Check returns why the feed fails the policy at now, or nil if it is fit to send
+func (p FreshnessPolicy) Check(feed *rss.RssFeed, now time.Time) error {
+	if feed == nil {
+		return fmt.Errorf("no feed")
+	}
+	var problems []string
+	if p.MaxAge > 0 {
+		built, ok := parseFeedTime(feed.LastBuildDate)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("unparseable build date %q", feed.LastBuildDate))
+		case now.Sub(built) > p.MaxAge:
+			problems = append(problems, fmt.Sprintf("built %s ago (%s), over the %s limit",
+				now.Sub(built).Round(time.Minute), feed.LastBuildDate, p.MaxAge))
+		}
+	}
+	if len(feed.Items) < p.MinItems {
+		problems = append(problems, fmt.Sprintf("%d items, need at least %d", len(feed.Items), p.MinItems))
+	}
+	if p.RequireSummary && strings.TrimSpace(feed.Description) == "" {
+		problems = append(problems, "no summary")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// fetchFreshFeed fetches the feed until it passes the policy, regenerating or waiting for it
+// as OnStale says until the deadline. A failed regeneration is retried; after one succeeds
+// the feed is only re-checked, as regenerating again would make the same issue. Past the
+// deadline it alerts and returns ErrStaleFeed. A dry run checks once and neither
+// regenerates nor alerts.
+func fetchFreshFeed(ctx context.Context, policy FreshnessPolicy, fetch func(ctx context.Context) (*rss.RssFeed, error), dryRun bool) (*rss.RssFeed, error) {
+	deadline := time.Now().Add(policy.Deadline)
+	regenerated := false
+	for attempt := 1; ; attempt++ {
+		feed, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		problem := policy.Check(feed, time.Now())
+		if problem == nil {
+			if attempt > 1 {
+				logger.Info("Feed is fresh, continuing the broadcast", map[string]interface{}{"attempts": attempt, "feedLastBuildDate": feed.LastBuildDate})
+			}
+			return feed, nil
+		}
+
+		logCtx := map[string]interface{}{"attempt": attempt, "problem": problem.Error(), "feedLastBuildDate": feed.LastBuildDate, "onStale": policy.OnStale}
+		if dryRun {
+			return nil, fmt.Errorf("%w: %v", ErrStaleFeed, problem)
+		}
+		if policy.OnStale == StaleAbort || !time.Now().Before(deadline) {
+			return nil, abortStale(ctx, problem, feed, attempt)
+		}
+
+		if policy.OnStale == StaleRegenerate && policy.Regenerate != nil && !regenerated {
+			logger.Warn("Feed is stale, regenerating it", logCtx)
+			if err := policy.Regenerate(ctx); err != nil {
+				logger.Error("Failed to regenerate the feed", err, logCtx)
+			} else {
+				regenerated = true
+				continue
+			}
+		} else {
+			logger.Warn("Feed is stale, waiting for it to be regenerated", logCtx)
+		}
+
+		wait := policy.RetryInterval
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return nil, abortStale(ctx, fmt.Errorf("%v; %w", problem, ctx.Err()), feed, attempt)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// abortStale alerts that the daily broadcast was skipped and returns the ErrStaleFeed for it
+func abortStale(ctx context.Context, problem error, feed *rss.RssFeed, attempts int) error {
+	fields := map[string]interface{}{
+		"problem":           problem.Error(),
+		"feedLastBuildDate": feed.LastBuildDate,
+		"items":             len(feed.Items),
+		"attempts":          attempts,
+	}
+	alertCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := alert.Send(alertCtx, "Daily broadcast aborted: the TLDR feed is stale", fields); err != nil {
+		logger.Error("Failed to send stale feed alert", err, fields)
+	}
+	_ = analytics.Track("broadcast_aborted", feedDate(feed.LastBuildDate), fields)
+	return fmt.Errorf("%w: %v", ErrStaleFeed, problem)
+}
@@ -0,0 +1,142 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"main/lib/rss"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFreshnessPolicyCheck(t *testing.T) {
+	now := time.Date(2025, 1, 15, 7, 10, 0, 0, time.UTC)
+	policy := FreshnessPolicy{MaxAge: 20 * time.Hour, MinItems: 1, RequireSummary: true}
+	feed := func(built time.Time, items int, summary string) *rss.RssFeed {
+		return &rss.RssFeed{
+			Description:   summary,
+			LastBuildDate: built.Format(time.RFC1123Z),
+			Items:         make([]rss.FeedItem, items),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		feed    *rss.RssFeed
+		problem string
+	}{
+		{"fresh", feed(now.Add(-time.Hour), 3, "Today in iGaming"), ""},
+		{"yesterday", feed(now.Add(-24*time.Hour), 3, "Today in iGaming"), "over the 20h0m0s limit"},
+		{"no items", feed(now.Add(-time.Hour), 0, "Today in iGaming"), "0 items"},
+		{"no summary", feed(now.Add(-time.Hour), 3, "  "), "no summary"},
+		{"bad date", &rss.RssFeed{Description: "x", LastBuildDate: "soon", Items: make([]rss.FeedItem, 1)}, "unparseable build date"},
+		{"nil", nil, "no feed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.feed, now)
+			if tt.problem == "" {
+				if err != nil {
+					t.Fatalf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("Check() = %v, want %q", err, tt.problem)
+			}
+		})
+	}
+
+	// MaxAge 0 turns the age check off
+	if err := (FreshnessPolicy{}).Check(feed(now.Add(-72*time.Hour), 0, ""), now); err != nil {
+		t.Fatalf("zero policy Check() = %v, want nil", err)
+	}
+}
+
+func TestFetchFreshFeed(t *testing.T) {
+	var alerts []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		alerts = append(alerts, payload["text"])
+	}))
+	defer webhook.Close()
+	t.Setenv("ALERT_WEBHOOK_URL", webhook.URL)
+
+	ctx := context.Background()
+	stale := &rss.RssFeed{Description: "Yesterday", LastBuildDate: time.Now().Add(-30 * time.Hour).Format(time.RFC1123Z), Items: make([]rss.FeedItem, 2)}
+	fresh := &rss.RssFeed{Description: "Today", LastBuildDate: time.Now().Format(time.RFC1123Z), Items: make([]rss.FeedItem, 2)}
+	policy := FreshnessPolicy{
+		MaxAge:        20 * time.Hour,
+		MinItems:      1,
+		OnStale:       StaleRegenerate,
+		Deadline:      200 * time.Millisecond,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	// Regenerating swaps in today's feed
+	current := stale
+	fetch := func(context.Context) (*rss.RssFeed, error) { return current, nil }
+	regenerations := 0
+	regenerating := policy
+	regenerating.Regenerate = func(context.Context) error {
+		regenerations++
+		current = fresh
+		return nil
+	}
+	got, err := fetchFreshFeed(ctx, regenerating, fetch, false)
+	if err != nil || got != fresh {
+		t.Fatalf("regenerate: fetchFreshFeed() = %v, %v, want the fresh feed", got, err)
+	}
+	if regenerations != 1 || len(alerts) != 0 {
+		t.Fatalf("regenerate: %d regenerations, %d alerts, want 1 and 0", regenerations, len(alerts))
+	}
+
+	// A regeneration that still yields a stale feed is not repeated; the deadline aborts
+	current, regenerations = stale, 0
+	regenerating.Regenerate = func(context.Context) error {
+		regenerations++
+		return nil
+	}
+	if _, err := fetchFreshFeed(ctx, regenerating, fetch, false); !errors.Is(err, ErrStaleFeed) {
+		t.Fatalf("still stale: fetchFreshFeed() = %v, want ErrStaleFeed", err)
+	}
+	if regenerations != 1 || len(alerts) != 1 {
+		t.Fatalf("still stale: %d regenerations, %d alerts, want 1 and 1", regenerations, len(alerts))
+	}
+	if !strings.Contains(alerts[0], "Daily broadcast aborted") || !strings.Contains(alerts[0], "problem: ") {
+		t.Errorf("alert text = %q", alerts[0])
+	}
+
+	// Waiting picks up a feed regenerated elsewhere
+	checks := 0
+	waiting := policy
+	waiting.OnStale = StaleWait
+	fetchLater := func(context.Context) (*rss.RssFeed, error) {
+		checks++
+		if checks < 3 {
+			return stale, nil
+		}
+		return fresh, nil
+	}
+	if got, err := fetchFreshFeed(ctx, waiting, fetchLater, false); err != nil || got != fresh {
+		t.Fatalf("wait: fetchFreshFeed() = %v, %v, want the fresh feed", got, err)
+	}
+
+	// Abort alerts on the first stale check; a dry run neither waits nor alerts
+	aborting := policy
+	aborting.OnStale = StaleAbort
+	checks = 0
+	if _, err := fetchFreshFeed(ctx, aborting, func(context.Context) (*rss.RssFeed, error) {
+		checks++
+		return stale, nil
+	}, false); !errors.Is(err, ErrStaleFeed) || checks != 1 || len(alerts) != 2 {
+		t.Fatalf("abort: err = %v after %d checks and %d alerts", err, checks, len(alerts))
+	}
+	if _, err := fetchFreshFeed(ctx, regenerating, fetch, true); !errors.Is(err, ErrStaleFeed) || len(alerts) != 2 {
+		t.Fatalf("dry run: err = %v with %d alerts", err, len(alerts))
+	}
+}
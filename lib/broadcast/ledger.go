@@ -0,0 +1,244 @@
+package broadcast
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Send states recorded in the SendLedger. A record moves created -> sending -> sent; one
+// left in sending means a send started and its outcome is unknown, so it blocks like sent.
+const (
+	SendCreated = "created"
+	SendSending = "sending"
+	SendSent    = "sent"
+)
+
+var (
+	// ErrAlreadySent is returned when an audience already got (or may have got) the issue
+	// for a feed date and the run isn't forced
+	ErrAlreadySent = errors.New("broadcast already sent to this audience for this date")
+	// ErrSendNotFound is returned by ledgers when nothing was recorded for an audience and date
+	ErrSendNotFound = errors.New("no broadcast recorded for this audience and date")
+)
+
+// SendRecord is the ledger entry for one issue sent to one audience
+type SendRecord struct {
+	AudienceID string `json:"audienceId"`
+	// FeedDate is the issue date (YYYY-MM-DD): the feed's build date for the daily
+	// broadcast, the week-ending date for the weekly one
+	FeedDate    string     `json:"feedDate"`
+	Pipeline    string     `json:"pipeline"`
+	State       string     `json:"state"`
+	BroadcastID string     `json:"broadcastId"`
+	Subject     string     `json:"subject,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	SentAt      *time.Time `json:"sentAt,omitempty"`
+}
+
+// SendLedger records which broadcast was created and sent for each (audience, feed date),
+// so a retried cron or a second manual call can't email an audience the same issue twice.
+// The pipeline run ledger only covers one run chain; this covers every run.
+type SendLedger interface {
+	// Get returns ErrSendNotFound when nothing was recorded for the audience and date
+	Get(ctx context.Context, audienceID, feedDate string) (*SendRecord, error)
+	// RecordCreated stores a newly created broadcast as created, replacing a record that
+	// was never sent. Returns ErrAlreadySent if the record is sending or sent, unless force.
+	RecordCreated(ctx context.Context, record SendRecord, force bool) error
+	// ClaimSend moves a created record to sending, so only one caller sends it. Returns
+	// ErrAlreadySent if it is sending or sent, unless force, and ErrSendNotFound without a record.
+	ClaimSend(ctx context.Context, audienceID, feedDate string, force bool) (*SendRecord, error)
+	// FinishSend records the outcome of a claimed send: sent, or back to created after a
+	// send that failed, so the next run can retry it
+	FinishSend(ctx context.Context, audienceID, feedDate string, sent bool) error
+}
+
+// MemorySendLedger is an in-process SendLedger for local runs and tests
+// It only protects against duplicates within one process
+type MemorySendLedger struct {
+	mu      sync.Mutex
+	records map[string]*SendRecord
+}
+
+// NewMemorySendLedger creates an empty in-memory ledger
+func NewMemorySendLedger() *MemorySendLedger {
+	return &MemorySendLedger{records: make(map[string]*SendRecord)}
+}
+
+func sendKey(audienceID, feedDate string) string {
+	return audienceID + "/" + feedDate
+}
+
+// Get returns a copy of the record for an audience and date
+func (l *MemorySendLedger) Get(ctx context.Context, audienceID, feedDate string) (*SendRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record, ok := l.records[sendKey(audienceID, feedDate)]
+	if !ok {
+		return nil, ErrSendNotFound
+	}
+	copied := *record
+	return &copied, nil
+}
+
+// RecordCreated stores a created broadcast unless the audience already has the issue
+func (l *MemorySendLedger) RecordCreated(ctx context.Context, record SendRecord, force bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := sendKey(record.AudienceID, record.FeedDate)
+	if existing, ok := l.records[key]; ok && existing.State != SendCreated && !force {
+		return ErrAlreadySent
+	}
+	record.State = SendCreated
+	record.CreatedAt = time.Now().UTC()
+	record.SentAt = nil
+	l.records[key] = &record
+	return nil
+}
+
+// ClaimSend moves a created record to sending
+func (l *MemorySendLedger) ClaimSend(ctx context.Context, audienceID, feedDate string, force bool) (*SendRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.records[sendKey(audienceID, feedDate)]
+	if !ok {
+		return nil, ErrSendNotFound
+	}
+	if record.State != SendCreated && !force {
+		return nil, ErrAlreadySent
+	}
+	record.State = SendSending
+	copied := *record
+	return &copied, nil
+}
+
+// FinishSend records the outcome of a claimed send
+func (l *MemorySendLedger) FinishSend(ctx context.Context, audienceID, feedDate string, sent bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.records[sendKey(audienceID, feedDate)]
+	if !ok || record.State != SendSending {
+		return nil
+	}
+	if sent {
+		now := time.Now().UTC()
+		record.State = SendSent
+		record.SentAt = &now
+	} else {
+		record.State = SendCreated
+	}
+	return nil
+}
+
+// PostgresSendLedger keeps the ledger in the broadcast_sends table (migration 0009)
+// State changes are conditional updates, so two invocations racing on the same issue
+// can't both claim the send
+type PostgresSendLedger struct {
+	db *sql.DB
+}
+
+// NewPostgresSendLedger creates a ledger on an open database
+func NewPostgresSendLedger(db *sql.DB) *PostgresSendLedger {
+	return &PostgresSendLedger{db: db}
+}
+
+// Get loads the record for an audience and date
+func (l *PostgresSendLedger) Get(ctx context.Context, audienceID, feedDate string) (*SendRecord, error) {
+	record, err := scanSendRecord(l.db.QueryRowContext(ctx, `
+		SELECT audience_id, feed_date, pipeline, state, broadcast_id, subject, created_at, sent_at
+		FROM broadcast_sends WHERE audience_id = $1 AND feed_date = $2`,
+		audienceID, feedDate,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrSendNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load broadcast send: %w", err)
+	}
+	return record, nil
+}
+
+// RecordCreated upserts the record unless it is past created (or force is set)
+func (l *PostgresSendLedger) RecordCreated(ctx context.Context, record SendRecord, force bool) error {
+	result, err := l.db.ExecContext(ctx, `
+		INSERT INTO broadcast_sends (audience_id, feed_date, pipeline, state, broadcast_id, subject, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		ON CONFLICT (audience_id, feed_date) DO UPDATE SET
+			pipeline = EXCLUDED.pipeline,
+			state = EXCLUDED.state,
+			broadcast_id = EXCLUDED.broadcast_id,
+			subject = EXCLUDED.subject,
+			created_at = NOW(),
+			sent_at = NULL,
+			updated_at = NOW()
+		WHERE broadcast_sends.state = $4 OR $7`,
+		record.AudienceID, record.FeedDate, record.Pipeline, SendCreated, record.BroadcastID, record.Subject, force,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record broadcast creation: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrAlreadySent
+	}
+	return nil
+}
+
+// ClaimSend moves a created record to sending in one conditional update
+func (l *PostgresSendLedger) ClaimSend(ctx context.Context, audienceID, feedDate string, force bool) (*SendRecord, error) {
+	record, err := scanSendRecord(l.db.QueryRowContext(ctx, `
+		UPDATE broadcast_sends SET state = $3, updated_at = NOW()
+		WHERE audience_id = $1 AND feed_date = $2 AND (state = $4 OR $5)
+		RETURNING audience_id, feed_date, pipeline, state, broadcast_id, subject, created_at, sent_at`,
+		audienceID, feedDate, SendSending, SendCreated, force,
+	))
+	if err == sql.ErrNoRows {
+		// Either there is no record or it is already past created
+		if _, getErr := l.Get(ctx, audienceID, feedDate); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrAlreadySent
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim broadcast send: %w", err)
+	}
+	return record, nil
+}
+
+// FinishSend records the outcome of a claimed send
+func (l *PostgresSendLedger) FinishSend(ctx context.Context, audienceID, feedDate string, sent bool) error {
+	state := SendCreated
+	if sent {
+		state = SendSent
+	}
+	_, err := l.db.ExecContext(ctx, `
+		UPDATE broadcast_sends SET
+			state = $3,
+			sent_at = CASE WHEN $3 = $5 THEN NOW() ELSE sent_at END,
+			updated_at = NOW()
+		WHERE audience_id = $1 AND feed_date = $2 AND state = $4`,
+		audienceID, feedDate, state, SendSending, SendSent,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record broadcast send: %w", err)
+	}
+	return nil
+}
+
+func scanSendRecord(row *sql.Row) (*SendRecord, error) {
+	var record SendRecord
+	var sentAt sql.NullTime
+	if err := row.Scan(&record.AudienceID, &record.FeedDate, &record.Pipeline, &record.State,
+		&record.BroadcastID, &record.Subject, &record.CreatedAt, &sentAt); err != nil {
+		return nil, err
+	}
+	if sentAt.Valid {
+		record.SentAt = &sentAt.Time
+	}
+	return &record, nil
+}
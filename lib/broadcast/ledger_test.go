@@ -0,0 +1,129 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"main/lib/mail"
+	"main/lib/pipeline"
+	"main/lib/subscribe"
+	"testing"
+)
+
+// failingSend fails the next SendBroadcast, like a provider outage mid-run
+type failingSend struct {
+	*mail.Outbox
+	fail bool
+}
+
+func (f *failingSend) SendBroadcast(ctx context.Context, broadcastID string) error {
+	if f.fail {
+		f.fail = false
+		return errors.New("provider unavailable")
+	}
+	return f.Outbox.SendBroadcast(ctx, broadcastID)
+}
+
+func TestSendLedgerStages(t *testing.T) {
+	t.Setenv("RESEND_AUDIENCE_ID", "aud-tldr")
+	t.Setenv("MAIL_FROM", "news@example.com")
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+	ctx := context.Background()
+	outbox := &failingSend{Outbox: mail.NewOutbox("")}
+	outbox.UpsertContact(ctx, "aud-tldr", mail.Contact{Email: "reader@example.com"})
+	ledger := NewMemorySendLedger()
+	segment := subscribe.Segment{Product: subscribe.ProductTLDR, Frequency: subscribe.FrequencyDaily}
+
+	// run sends the issue for date through the shared create and send stages
+	run := func(date string, opts SendOptions) (*pipeline.Run, error) {
+		opts.Ledger = ledger
+		rendered, _ := json.Marshal(map[string]string{"date": date, "subject": "Daily " + date, "html": "<p>Daily</p>", "text": "Daily"})
+		stages := []pipeline.Stage{
+			{Name: "render-email", Run: func(context.Context, []byte) ([]byte, error) { return rendered, nil }},
+			createBroadcastStage(outbox, DailyBroadcastPipeline, segment, opts),
+			sendBroadcastStage(outbox, "daily broadcast", "broadcast_sent", opts),
+		}
+		return pipeline.NewRunner(nil).Start(ctx, DailyBroadcastPipeline, opts.params(), stages)
+	}
+
+	// A dry run checks everything but creates and sends nothing
+	if _, err := run("2025-01-15", SendOptions{DryRun: true}); err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	if len(outbox.Messages()) != 0 {
+		t.Fatalf("dry run sent %d messages", len(outbox.Messages()))
+	}
+	if _, err := ledger.Get(ctx, "aud-tldr", "2025-01-15"); !errors.Is(err, ErrSendNotFound) {
+		t.Fatalf("dry run recorded a send: %v", err)
+	}
+
+	// A failed send leaves the broadcast created, and the retry reuses it
+	outbox.fail = true
+	if _, err := run("2025-01-15", SendOptions{}); err == nil {
+		t.Fatal("run with a failing send succeeded")
+	}
+	record, err := ledger.Get(ctx, "aud-tldr", "2025-01-15")
+	if err != nil || record.State != SendCreated {
+		t.Fatalf("record after failed send = %+v, %v", record, err)
+	}
+	if _, err := run("2025-01-15", SendOptions{}); err != nil {
+		t.Fatalf("retry error = %v", err)
+	}
+	sent, _ := ledger.Get(ctx, "aud-tldr", "2025-01-15")
+	if sent.State != SendSent || sent.BroadcastID != record.BroadcastID || sent.SentAt == nil {
+		t.Fatalf("record after retry = %+v, want the first broadcast sent", sent)
+	}
+	if n := len(outbox.Messages()); n != 1 {
+		t.Fatalf("messages after retry = %d, want 1", n)
+	}
+
+	// The same date again is refused, dry run included, until forced
+	for _, opts := range []SendOptions{{}, {DryRun: true}} {
+		if _, err := run("2025-01-15", opts); !errors.Is(err, ErrAlreadySent) {
+			t.Errorf("duplicate run (%+v) error = %v, want ErrAlreadySent", opts, err)
+		}
+	}
+	if n := len(outbox.Messages()); n != 1 {
+		t.Fatalf("messages after duplicate runs = %d, want 1", n)
+	}
+	if _, err := run("2025-01-15", SendOptions{Force: true}); err != nil {
+		t.Fatalf("forced run error = %v", err)
+	}
+	if n := len(outbox.Messages()); n != 2 {
+		t.Fatalf("messages after forced run = %d, want 2", n)
+	}
+
+	// The next day is a new issue
+	if _, err := run("2025-01-16", SendOptions{}); err != nil {
+		t.Fatalf("next day error = %v", err)
+	}
+	if n := len(outbox.Messages()); n != 3 {
+		t.Fatalf("messages after next day = %d, want 3", n)
+	}
+}
+
+func TestMemorySendLedgerClaim(t *testing.T) {
+	ctx := context.Background()
+	ledger := NewMemorySendLedger()
+
+	if _, err := ledger.ClaimSend(ctx, "aud", "2025-01-15", false); !errors.Is(err, ErrSendNotFound) {
+		t.Fatalf("ClaimSend() without a record = %v, want ErrSendNotFound", err)
+	}
+	if err := ledger.RecordCreated(ctx, SendRecord{AudienceID: "aud", FeedDate: "2025-01-15", BroadcastID: "b1"}, false); err != nil {
+		t.Fatalf("RecordCreated() error = %v", err)
+	}
+
+	// Only one claim wins; a send left claimed blocks until forced
+	if _, err := ledger.ClaimSend(ctx, "aud", "2025-01-15", false); err != nil {
+		t.Fatalf("first ClaimSend() error = %v", err)
+	}
+	if _, err := ledger.ClaimSend(ctx, "aud", "2025-01-15", false); !errors.Is(err, ErrAlreadySent) {
+		t.Fatalf("second ClaimSend() = %v, want ErrAlreadySent", err)
+	}
+	if err := ledger.RecordCreated(ctx, SendRecord{AudienceID: "aud", FeedDate: "2025-01-15", BroadcastID: "b2"}, false); !errors.Is(err, ErrAlreadySent) {
+		t.Fatalf("RecordCreated() over a claimed send = %v, want ErrAlreadySent", err)
+	}
+	if _, err := ledger.ClaimSend(ctx, "aud", "2025-01-15", true); err != nil {
+		t.Fatalf("forced ClaimSend() error = %v", err)
+	}
+}
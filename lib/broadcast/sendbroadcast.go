@@ -1,141 +1,337 @@
 package broadcast
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"main/lib/analytics"
 	"main/lib/logger"
-	"os"
+	"main/lib/mail"
+	"main/lib/pipeline"
+	"main/lib/rss"
+	"main/lib/subscribe"
 	"time"
 
-	"github.com/resend/resend-go/v2"
 	feedpkg "main/lib/feed"
 )
 
-// SendDailyBroadcast orchestrates fetching, parsing, and sending the broadcast email.
-func SendDailyBroadcast() error {
-	logger.Info("Starting daily broadcast process", nil)
+// DailyBroadcastPipeline is the run ledger name for SendDailyBroadcast
+const DailyBroadcastPipeline = "daily-broadcast"
 
-	// 1. Get Feed Data
-	feed, err := ParseRssFeed()
-	if err != nil {
-		return fmt.Errorf("failed during feed parsing: %w", err)
-	}
+// SendOptions configures a daily or weekly broadcast run
+type SendOptions struct {
+	// Force creates and sends the issue even if the send ledger says the audience already got it
+	Force bool
+	// DryRun renders and lints the email and checks the send ledger, without creating,
+	// sending or archiving anything
+	DryRun bool
+	// Ledger records what was created and sent per audience and feed date; nil keeps the
+	// record in memory for this run only
+	Ledger SendLedger
+	// Freshness gates the daily broadcast on today's feed; nil uses FreshnessPolicyFromEnv()
+	// without regeneration. The weekly broadcast is built from archived feeds and ignores it.
+	Freshness *FreshnessPolicy
+}
 
-	if feed == nil || feed.LastBuildDate == "" {
-		logger.Error("No valid feed data available to send broadcast", nil, nil)
-		return fmt.Errorf("no valid feed data")
-	}
+// params records the options with the run, so resuming it uses the same ones
+func (o SendOptions) params() map[string]string {
+	return map[string]string{"force": fmt.Sprint(o.Force), "dryRun": fmt.Sprint(o.DryRun)}
+}
 
-	// Guard: after 07:05 UTC, ensure the feed date is today; warn if not
-	if t, err := time.Parse(time.RFC1123Z, feed.LastBuildDate); err == nil {
-		now := time.Now().UTC()
-		afterSevenOhFive := now.Hour() > 7 || (now.Hour() == 7 && now.Minute() >= 5)
-		sameYMD := t.UTC().Year() == now.Year() && t.UTC().Month() == now.Month() && t.UTC().Day() == now.Day()
-		if afterSevenOhFive && !sameYMD {
-			logger.Warn("Daily broadcast feed date appears stale after 07:05 UTC", map[string]interface{}{"feedLastBuildDate": feed.LastBuildDate})
-		}
+// SendFlagsFromParams restores the Force and DryRun options recorded with a run
+func SendFlagsFromParams(params map[string]string) (force, dryRun bool) {
+	return params["force"] == "true", params["dryRun"] == "true"
+}
+
+// renderedBroadcast is the render-email stage output
+type renderedBroadcast struct {
+	Feed    rss.RssFeed `json:"feed"`
+	Date    string      `json:"date"` // Feed date (YYYY-MM-DD), the send ledger key
+	Subject string      `json:"subject"`
+	HTML    string      `json:"html"`
+	Text    string      `json:"text"`
+}
+
+// SendDailyBroadcast orchestrates fetching, parsing, and sending the broadcast email.
+// runs records each stage so a failed broadcast can be resumed; nil keeps the record in memory only.
+// mailer sends it; nil uses mail.Default(). Each audience gets a feed date at most once
+// (see SendLedger) unless opts.Force is set
+func SendDailyBroadcast(ctx context.Context, runs *pipeline.Runner, mailer mail.Mailer, opts SendOptions) (*pipeline.Run, error) {
+	logger.Info("Starting daily broadcast process", map[string]interface{}{"force": opts.Force, "dryRun": opts.DryRun})
+	if runs == nil {
+		runs = pipeline.NewRunner(nil)
+	}
+	if mailer == nil {
+		mailer = mail.Default()
 	}
+	return runs.Start(ctx, DailyBroadcastPipeline, opts.params(), DailyBroadcastStages(mailer, opts))
+}
 
-	// 2. Generate Email HTML
-	emailHTML, err := generateEmailHTML(*feed)
-	if err != nil {
-		return fmt.Errorf("failed to generate email HTML: %w", err)
+// DailyBroadcastStages splits the broadcast into resumable stages:
+// parse-feed -> check-freshness -> render-email -> archive-feed -> create-broadcast -> send-broadcast
+// send-broadcast runs at most once per run chain, so resuming never emails twice
+func DailyBroadcastStages(mailer mail.Mailer, opts SendOptions) []pipeline.Stage {
+	if opts.Ledger == nil {
+		opts.Ledger = NewMemorySendLedger()
 	}
+	if opts.Freshness == nil {
+		policy := FreshnessPolicyFromEnv()
+		opts.Freshness = &policy
+	}
+	return []pipeline.Stage{
+		{
+			Name:    "parse-feed",
+			Retries: 1,
+			Run: func(ctx context.Context, _ []byte) ([]byte, error) {
+				feed, err := rss.FetchTldr(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed during feed parsing: %w", err)
+				}
+
+				if feed == nil || feed.LastBuildDate == "" {
+					logger.Error("No valid feed data available to send broadcast", nil, nil)
+					return nil, fmt.Errorf("no valid feed data")
+				}
 
-	// 3. Set up Resend Client
-	apiKey := os.Getenv("RESEND_API_KEY")
-	audienceID := os.Getenv("RESEND_AUDIENCE_ID")
-	fromEmail := os.Getenv("RESEND_FROM_EMAIL")
+				return json.Marshal(feed)
+			},
+		},
+		{
+			// No stage retries: the policy does its own waiting, and an abort has already alerted
+			Name: "check-freshness",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				var parsed rss.RssFeed
+				if err := json.Unmarshal(input, &parsed); err != nil {
+					return nil, fmt.Errorf("failed to decode feed: %w", err)
+				}
 
-	if apiKey == "" || audienceID == "" || fromEmail == "" {
-		return fmt.Errorf("missing RESEND_API_KEY, RESEND_AUDIENCE_ID, or RESEND_FROM_EMAIL environment variables")
+				// The parsed feed is checked first; later checks fetch it again
+				first := true
+				fetch := func(ctx context.Context) (*rss.RssFeed, error) {
+					if first {
+						first = false
+						return &parsed, nil
+					}
+					return rss.FetchTldr(ctx)
+				}
+				feed, err := fetchFreshFeed(ctx, *opts.Freshness, fetch, opts.DryRun)
+				if err != nil {
+					return nil, err
+				}
+				return json.Marshal(feed)
+			},
+		},
+		{
+			Name: "render-email",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				var feed rss.RssFeed
+				if err := json.Unmarshal(input, &feed); err != nil {
+					return nil, fmt.Errorf("failed to decode feed: %w", err)
+				}
+
+				emailHTML, err := generateEmailHTML(feed)
+				if err != nil {
+					return nil, fmt.Errorf("failed to generate email HTML: %w", err)
+				}
+
+				return json.Marshal(renderedBroadcast{
+					Feed:    feed,
+					Date:    feedDate(feed.LastBuildDate),
+					Subject: fmt.Sprintf("Takara TLDR: %s", formatDateForSubject(feed.LastBuildDate)),
+					HTML:    emailHTML,
+					Text:    mail.PlainText(emailHTML),
+				})
+			},
+		},
+		{
+			// Best-effort: store the feed in blob storage using the feed's own date
+			Name:     "archive-feed",
+			Optional: true,
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				if opts.DryRun {
+					return input, nil
+				}
+				var rendered renderedBroadcast
+				if err := json.Unmarshal(input, &rendered); err != nil {
+					return nil, fmt.Errorf("failed to decode rendered broadcast: %w", err)
+				}
+				if err := feedpkg.StoreTldrFeed(&rendered.Feed); err != nil {
+					logger.Warn("Failed to store TLDR feed from broadcast", map[string]interface{}{"error": err.Error()})
+					return nil, err
+				}
+				return input, nil
+			},
+		},
+		// Daily TLDR segment; weekly readers are in their own audience
+		createBroadcastStage(mailer, DailyBroadcastPipeline, subscribe.Segment{Product: subscribe.ProductTLDR, Frequency: subscribe.FrequencyDaily}, opts),
+		sendBroadcastStage(mailer, "daily broadcast", "broadcast_sent", opts),
 	}
+}
 
-	client := resend.NewClient(apiKey)
+// createBroadcastStage creates the broadcast for a rendered email (any stage output with
+// date, subject, html and text) addressed to the segment's audience, without sending it.
+// HTML that fails mail.Lint is refused here, before anyone can receive it, and so is an
+// issue the send ledger shows the audience already got, unless opts.Force. A broadcast
+// created earlier but never sent is reused rather than created again. In a dry run the
+// checks still happen but nothing is created.
+func createBroadcastStage(mailer mail.Mailer, pipelineName string, segment subscribe.Segment, opts SendOptions) pipeline.Stage {
+	return pipeline.Stage{
+		Name: "create-broadcast",
+		Run: func(ctx context.Context, input []byte) ([]byte, error) {
+			var rendered struct {
+				Date    string `json:"date"`
+				Subject string `json:"subject"`
+				HTML    string `json:"html"`
+				Text    string `json:"text"`
+			}
+			if err := json.Unmarshal(input, &rendered); err != nil {
+				return nil, fmt.Errorf("failed to decode rendered broadcast: %w", err)
+			}
+			if err := mail.Lint(rendered.HTML); err != nil {
+				return nil, err
+			}
 
-	// 4. Create Broadcast
-	dateStr := formatDateForSubject(feed.LastBuildDate)
-	subject := fmt.Sprintf("Takara TLDR: %s", dateStr)
+			audienceID := segment.AudienceID()
+			fromEmail := mail.FromAddress()
 
-	logger.Info("Creating Resend broadcast", map[string]interface{}{"subject": subject, "audienceId": audienceID})
+			if audienceID == "" || fromEmail == "" {
+				return nil, fmt.Errorf("missing %s or RESEND_FROM_EMAIL environment variables", segment.AudienceEnv())
+			}
+			logCtx := map[string]interface{}{"subject": rendered.Subject, "audienceId": audienceID, "date": rendered.Date}
 
-	createParams := &resend.CreateBroadcastRequest{
-		From:       fromEmail,
-		Subject:    subject,
-		Html:       emailHTML,
-		AudienceId: audienceID,
-	}
+			existing, err := opts.Ledger.Get(ctx, audienceID, rendered.Date)
+			if err != nil && !errors.Is(err, ErrSendNotFound) {
+				return nil, err
+			}
+			if existing != nil && existing.State != SendCreated && !opts.Force {
+				logger.Warn("Broadcast already sent for this date, refusing to send again", map[string]interface{}{
+					"audienceId": audienceID, "date": rendered.Date, "broadcastId": existing.BroadcastID, "state": existing.State,
+				})
+				return nil, fmt.Errorf("%w: %s for %s is %s (broadcast %s); force to send anyway",
+					ErrAlreadySent, audienceID, rendered.Date, existing.State, existing.BroadcastID)
+			}
 
-	createdBroadcast, err := client.Broadcasts.Create(createParams)
-	if err != nil {
-		logger.Error("Failed to create Resend broadcast", err, nil)
-		return fmt.Errorf("resend broadcast creation failed: %w", err)
-	}
+			created := map[string]string{"subject": rendered.Subject, "audienceId": audienceID, "date": rendered.Date}
+			if opts.DryRun {
+				logger.Info("Dry run: broadcast rendered and checked, not creating it", logCtx)
+				created["dryRun"] = "true"
+				return json.Marshal(created)
+			}
+			if existing != nil && existing.State == SendCreated && !opts.Force {
+				logger.Info("Reusing broadcast created earlier but never sent", map[string]interface{}{"broadcastId": existing.BroadcastID, "date": rendered.Date})
+				created["broadcastId"] = existing.BroadcastID
+				return json.Marshal(created)
+			}
 
-	if createdBroadcast.Id == "" {
-		logger.Error("Resend broadcast creation returned no data", nil, nil)
-		return fmt.Errorf("resend broadcast creation returned no data")
-	}
+			logger.Info("Creating broadcast", logCtx)
+
+			broadcastID, err := mailer.CreateBroadcast(ctx, mail.Broadcast{
+				From:       fromEmail,
+				Subject:    rendered.Subject,
+				HTML:       rendered.HTML,
+				Text:       rendered.Text,
+				AudienceID: audienceID,
 
-	logger.Info("Successfully created Resend broadcast", map[string]interface{}{"broadcastId": createdBroadcast.Id})
-
-	// Best-effort: store the feed in blob storage using the feed's own date
-	go func(f RssFeed) {
-		items := make([]feedpkg.FeedItem, 0, len(f.Items))
-		for _, it := range f.Items {
-			items = append(items, feedpkg.FeedItem{
-				Title:       it.Title,
-				Link:        it.Link,
-				Description: it.Description,
-				PubDate:     it.PubDate,
-				GUID:        feedpkg.GUIDString(it.GUID),
+				UnsubscribeURL: subscribe.UnsubscribeURL,
 			})
-		}
-		converted := &feedpkg.RssFeed{
-			Title:         f.Title,
-			Description:   f.Description,
-			Link:          f.Link,
-			LastBuildDate: f.LastBuildDate,
-			Items:         items,
-		}
-		if err := feedpkg.StoreTldrFeed(converted); err != nil {
-			logger.Warn("Failed to store TLDR feed from broadcast", map[string]interface{}{"error": err.Error()})
-		}
-	}(*feed)
+			if err != nil {
+				logger.Error("Failed to create broadcast", err, nil)
+				return nil, err
+			}
+			err = opts.Ledger.RecordCreated(ctx, SendRecord{
+				AudienceID:  audienceID,
+				FeedDate:    rendered.Date,
+				Pipeline:    pipelineName,
+				BroadcastID: broadcastID,
+				Subject:     rendered.Subject,
+			}, opts.Force)
+			if err != nil {
+				// Another run got there first; leave its broadcast to it
+				return nil, fmt.Errorf("failed to record broadcast %s: %w", broadcastID, err)
+			}
 
-	// 5. Send Broadcast
-	logger.Info("Sending Resend broadcast", map[string]interface{}{"broadcastId": createdBroadcast.Id})
-	sendParams := &resend.SendBroadcastRequest{
-		BroadcastId: createdBroadcast.Id,
-	}
-	_, sendErr := client.Broadcasts.Send(sendParams)
-	if sendErr != nil {
-		logger.Error("Failed to send Resend broadcast", sendErr, map[string]interface{}{"broadcastId": createdBroadcast.Id})
-		return fmt.Errorf("resend broadcast send failed: %w", sendErr)
+			logger.Info("Successfully created broadcast", map[string]interface{}{"broadcastId": broadcastID})
+			created["broadcastId"] = broadcastID
+			return json.Marshal(created)
+		},
 	}
+}
+
+// sendBroadcastStage sends the broadcast created by createBroadcastStage and tracks event.
+// It runs at most once per run chain, so resuming never emails twice, and claims the send
+// in the send ledger first, so no other run can send the same issue to the audience
+func sendBroadcastStage(mailer mail.Mailer, label, event string, opts SendOptions) pipeline.Stage {
+	return pipeline.Stage{
+		Name: "send-broadcast",
+		Once: true,
+		Run: func(ctx context.Context, input []byte) ([]byte, error) {
+			var created map[string]string
+			if err := json.Unmarshal(input, &created); err != nil {
+				return nil, fmt.Errorf("failed to decode created broadcast: %w", err)
+			}
+			if opts.DryRun {
+				logger.Info("Dry run: not sending "+label, map[string]interface{}{"audienceId": created["audienceId"], "date": created["date"]})
+				return input, nil
+			}
+			broadcastID := created["broadcastId"]
+			audienceID, date := created["audienceId"], created["date"]
+
+			if _, err := opts.Ledger.ClaimSend(ctx, audienceID, date, opts.Force); err != nil {
+				logger.Warn("Could not claim broadcast send", map[string]interface{}{"broadcastId": broadcastID, "date": date, "error": err.Error()})
+				return nil, err
+			}
+
+			// Record the outcome even if the request is cancelled: a send left claimed
+			// blocks the issue until someone forces it
+			recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
 
-	logger.Info("Successfully sent daily broadcast", map[string]interface{}{"broadcastId": createdBroadcast.Id})
-	_ = analytics.Track("broadcast_sent", createdBroadcast.Id, map[string]interface{}{"subject": subject})
-	return nil
+			logger.Info("Sending broadcast", map[string]interface{}{"broadcastId": broadcastID})
+			if err := mailer.SendBroadcast(ctx, broadcastID); err != nil {
+				logger.Error("Failed to send broadcast", err, map[string]interface{}{"broadcastId": broadcastID})
+				if finishErr := opts.Ledger.FinishSend(recordCtx, audienceID, date, false); finishErr != nil {
+					logger.Error("Failed to release broadcast send", finishErr, map[string]interface{}{"broadcastId": broadcastID})
+				}
+				return nil, err
+			}
+			if err := opts.Ledger.FinishSend(recordCtx, audienceID, date, true); err != nil {
+				logger.Error("Broadcast sent but not recorded as sent", err, map[string]interface{}{"broadcastId": broadcastID, "date": date})
+			}
+
+			logger.Info("Successfully sent "+label, map[string]interface{}{"broadcastId": broadcastID})
+			_ = analytics.Track(event, broadcastID, map[string]interface{}{"subject": created["subject"]})
+			return input, nil
+		},
+	}
 }
 
 // formatDateForSubject formats the date specifically for the email subject line.
 func formatDateForSubject(dateStr string) string {
-	if dateStr == "" {
-		return time.Now().UTC().Format("January 2, 2006")
+	return parseFeedDate(dateStr).Format("January 2, 2006")
+}
+
+// feedDate formats the feed date as YYYY-MM-DD, the send ledger key
+func feedDate(dateStr string) string {
+	return parseFeedDate(dateStr).Format("2006-01-02")
+}
+
+// parseFeedDate parses an RSS feed date, falling back to now (UTC) if it is empty or unparseable
+func parseFeedDate(dateStr string) time.Time {
+	if t, ok := parseFeedTime(dateStr); ok {
+		return t
 	}
+	return time.Now().UTC()
+}
+
+// parseFeedTime parses an RSS feed date in any of the common RSS layouts
+func parseFeedTime(dateStr string) (time.Time, bool) {
 	// Use the same robust parsing as the template formatter.
 	layouts := []string{time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822, time.RubyDate}
-	var t time.Time
-	var err error
 	for _, layout := range layouts {
-		t, err = time.Parse(layout, dateStr)
-		if err == nil {
-			break
+		if t, err := time.Parse(layout, dateStr); err == nil {
+			return t, true
 		}
 	}
-	if err != nil {
-		return time.Now().UTC().Format("January 2, 2006")
-	}
-	return t.Format("January 2, 2006")
+	return time.Time{}, false
 }
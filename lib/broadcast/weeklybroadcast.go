@@ -0,0 +1,391 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/paper"
+	"main/lib/pipeline"
+	"main/lib/rss"
+	"main/lib/subscribe"
+	"main/lib/summary"
+	"main/lib/tldr"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WeeklyBroadcastPipeline is the run ledger name for SendWeeklyBroadcast
+const WeeklyBroadcastPipeline = "weekly-broadcast"
+
+const (
+	// weeklyDays is how many daily TLDRs a roundup covers, ending on its week-ending date
+	weeklyDays = 7
+	// weeklyTopPapers is how many papers the roundup features
+	weeklyTopPapers = 5
+	// weeklySummaryLength caps each paper's blurb, in characters
+	weeklySummaryLength = 280
+)
+
+var (
+	weeklyParagraphRegex = regexp.MustCompile(`(?is)<(p|li)[^>]*>(.*?)</(?:p|li)>`)
+	weeklyLinkRegex      = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]+)"[^>]*>(.*?)</a>`)
+	weeklyTagRegex       = regexp.MustCompile(`(?s)<[^>]*>`)
+	arxivIDRegex         = regexp.MustCompile(`^([0-9]{4}\.[0-9]{4,5})(v[0-9]+)?$`)
+)
+
+// weeklyPaper is a paper linked from one of the week's daily TLDRs
+type weeklyPaper struct {
+	ArxivID string `json:"arxivId"`
+	Title   string `json:"title"`
+	Link    string `json:"link"`
+	// Date is the first daily TLDR that mentioned the paper (YYYY-MM-DD)
+	Date string `json:"date"`
+	// Summary is the plain text of the sentence or paragraph that linked it
+	Summary  string `json:"summary"`
+	Mentions int    `json:"mentions"`
+	// Upvotes are the paper's Hugging Face upvotes, when known
+	Upvotes int `json:"upvotes,omitempty"`
+	// Centrality is the mean cosine similarity of the paper's embedding to the week's other papers
+	Centrality float64 `json:"centrality,omitempty"`
+}
+
+// weeklyIssue is the roundup as it moves through the pipeline
+type weeklyIssue struct {
+	WeekEnding string        `json:"weekEnding"`
+	Dates      []string      `json:"dates"`
+	Headline   string        `json:"headline,omitempty"`
+	Papers     []weeklyPaper `json:"papers"`
+}
+
+// renderedWeekly is the render-email stage output
+type renderedWeekly struct {
+	Issue   weeklyIssue `json:"issue"`
+	Date    string      `json:"date"` // Week-ending date, the send ledger key
+	Subject string      `json:"subject"`
+	HTML    string      `json:"html"`
+	Text    string      `json:"text"`
+}
+
+// SendWeeklyBroadcast sends the weekly roundup of the seven daily TLDRs up to weekEnding
+// (YYYY-MM-DD, empty for today UTC) to the weekly TLDR segment.
+// runs records each stage so a failed broadcast can be resumed; nil keeps the record in memory only.
+// mailer sends it; nil uses mail.Default(). Like the daily broadcast, each week goes to the
+// audience at most once unless opts.Force is set
+func SendWeeklyBroadcast(ctx context.Context, runs *pipeline.Runner, mailer mail.Mailer, weekEnding string, opts SendOptions) (*pipeline.Run, error) {
+	if weekEnding == "" {
+		weekEnding = time.Now().UTC().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", weekEnding); err != nil {
+		return nil, fmt.Errorf("invalid week ending date: expected YYYY-MM-DD, got %s", weekEnding)
+	}
+	logger.Info("Starting weekly broadcast process", map[string]interface{}{"weekEnding": weekEnding, "force": opts.Force, "dryRun": opts.DryRun})
+	if runs == nil {
+		runs = pipeline.NewRunner(nil)
+	}
+	if mailer == nil {
+		mailer = mail.Default()
+	}
+	params := opts.params()
+	params["weekEnding"] = weekEnding
+	return runs.Start(ctx, WeeklyBroadcastPipeline, params, WeeklyBroadcastStages(mailer, weekEnding, opts))
+}
+
+// WeeklyBroadcastStages splits the weekly roundup into resumable stages:
+// collect-papers -> rank-papers -> write-headline -> render-email -> create-broadcast -> send-broadcast
+func WeeklyBroadcastStages(mailer mail.Mailer, weekEnding string, opts SendOptions) []pipeline.Stage {
+	if opts.Ledger == nil {
+		opts.Ledger = NewMemorySendLedger()
+	}
+	return []pipeline.Stage{
+		{
+			Name:    "collect-papers",
+			Retries: 1,
+			Run: func(ctx context.Context, _ []byte) ([]byte, error) {
+				available, err := tldr.ListTldrFeedDates()
+				if err != nil {
+					return nil, fmt.Errorf("failed to list TLDR feeds: %w", err)
+				}
+				dates := weekDates(available, weekEnding)
+
+				feeds := make(map[string]*rss.RssFeed, len(dates))
+				for _, date := range dates {
+					feed, err := tldr.GetTldrFeed(date)
+					if err != nil {
+						return nil, err
+					}
+					if feed == nil {
+						logger.Warn("Listed TLDR feed not found", map[string]interface{}{"date": date})
+						continue
+					}
+					feeds[date] = feed
+				}
+
+				papers := collectWeeklyPapers(dates, feeds)
+				if len(papers) == 0 {
+					return nil, fmt.Errorf("no papers in the TLDR feeds for the week ending %s", weekEnding)
+				}
+				logger.Info("Collected weekly papers", map[string]interface{}{"weekEnding": weekEnding, "feeds": len(feeds), "papers": len(papers)})
+				return json.Marshal(weeklyIssue{WeekEnding: weekEnding, Dates: dates, Papers: papers})
+			},
+		},
+		{
+			Name: "rank-papers",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				var issue weeklyIssue
+				if err := json.Unmarshal(input, &issue); err != nil {
+					return nil, fmt.Errorf("failed to decode weekly issue: %w", err)
+				}
+
+				ids := make([]string, len(issue.Papers))
+				for i, p := range issue.Papers {
+					ids[i] = p.ArxivID
+				}
+				issue.Papers = rankWeeklyPapers(issue.Papers, fetchUpvotes(ctx, ids), fetchEmbeddings(ctx, ids))
+				if len(issue.Papers) > weeklyTopPapers {
+					issue.Papers = issue.Papers[:weeklyTopPapers]
+				}
+				return json.Marshal(issue)
+			},
+		},
+		{
+			// The LLM headline is best-effort: without it the top paper leads
+			Name: "write-headline",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				var issue weeklyIssue
+				if err := json.Unmarshal(input, &issue); err != nil {
+					return nil, fmt.Errorf("failed to decode weekly issue: %w", err)
+				}
+
+				prompt := make([]summary.WeeklyPaper, len(issue.Papers))
+				for i, p := range issue.Papers {
+					prompt[i] = summary.WeeklyPaper{Title: p.Title, Summary: p.Summary}
+				}
+				headline, err := summary.GenerateWeeklyHeadline(ctx, prompt)
+				if err != nil {
+					logger.Warn("Failed to generate weekly headline, using the top paper", map[string]interface{}{"error": err.Error()})
+					headline = fallbackWeeklyHeadline(issue.Papers)
+				}
+				issue.Headline = headline
+				return json.Marshal(issue)
+			},
+		},
+		{
+			Name: "render-email",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				var issue weeklyIssue
+				if err := json.Unmarshal(input, &issue); err != nil {
+					return nil, fmt.Errorf("failed to decode weekly issue: %w", err)
+				}
+
+				emailHTML, err := generateWeeklyEmailHTML(issue)
+				if err != nil {
+					return nil, fmt.Errorf("failed to generate weekly email HTML: %w", err)
+				}
+
+				return json.Marshal(renderedWeekly{
+					Issue:   issue,
+					Date:    issue.WeekEnding,
+					Subject: fmt.Sprintf("Takara TLDR Weekly: %s", formatWeekRange(issue.WeekEnding)),
+					HTML:    emailHTML,
+					Text:    mail.PlainText(emailHTML),
+				})
+			},
+		},
+		createBroadcastStage(mailer, WeeklyBroadcastPipeline, subscribe.Segment{Product: subscribe.ProductTLDR, Frequency: subscribe.FrequencyWeekly}, opts),
+		sendBroadcastStage(mailer, "weekly broadcast", "weekly_broadcast_sent", opts),
+	}
+}
+
+// weekDates returns the available feed dates in the weeklyDays ending on weekEnding, oldest first
+func weekDates(available []string, weekEnding string) []string {
+	end, err := time.Parse("2006-01-02", weekEnding)
+	if err != nil {
+		return nil
+	}
+	start := end.AddDate(0, 0, -(weeklyDays - 1)).Format("2006-01-02")
+
+	var dates []string
+	seen := make(map[string]bool)
+	for _, date := range available {
+		if date >= start && date <= weekEnding && !seen[date] {
+			seen[date] = true
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// collectWeeklyPapers finds every arXiv paper linked from the feeds, visiting dates in
+// order. A paper linked on several days is kept once, with its first mention.
+func collectWeeklyPapers(dates []string, feeds map[string]*rss.RssFeed) []weeklyPaper {
+	var papers []weeklyPaper
+	index := make(map[string]int)
+	for _, date := range dates {
+		feed := feeds[date]
+		if feed == nil {
+			continue
+		}
+		for _, item := range feed.Items {
+			for _, paragraph := range weeklyParagraphRegex.FindAllStringSubmatch(item.Description, -1) {
+				text := plainText(paragraph[2])
+				for _, link := range weeklyLinkRegex.FindAllStringSubmatch(paragraph[2], -1) {
+					id := arxivIDFromLink(link[1])
+					if id == "" {
+						continue
+					}
+					if i, ok := index[id]; ok {
+						papers[i].Mentions++
+						continue
+					}
+					index[id] = len(papers)
+					papers = append(papers, weeklyPaper{
+						ArxivID:  id,
+						Title:    plainText(link[2]),
+						Link:     "https://tldr.takara.ai/p/" + id,
+						Date:     date,
+						Summary:  truncateText(text, weeklySummaryLength),
+						Mentions: 1,
+					})
+				}
+			}
+		}
+	}
+	return papers
+}
+
+// rankWeeklyPapers orders papers by upvotes, then embedding centrality, then mentions,
+// keeping the feed order otherwise. Papers without a signal simply rank below those with one.
+func rankWeeklyPapers(papers []weeklyPaper, upvotes map[string]int, embeddings map[string][]float32) []weeklyPaper {
+	ranked := append([]weeklyPaper(nil), papers...)
+	for i := range ranked {
+		ranked[i].Upvotes = upvotes[ranked[i].ArxivID]
+		ranked[i].Centrality = centrality(ranked[i].ArxivID, embeddings)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Upvotes != ranked[j].Upvotes {
+			return ranked[i].Upvotes > ranked[j].Upvotes
+		}
+		if ranked[i].Centrality != ranked[j].Centrality {
+			return ranked[i].Centrality > ranked[j].Centrality
+		}
+		return ranked[i].Mentions > ranked[j].Mentions
+	})
+	return ranked
+}
+
+// centrality is the mean cosine similarity of id's embedding to the other embeddings;
+// a paper close to many others sits at the centre of the week's main cluster
+func centrality(id string, embeddings map[string][]float32) float64 {
+	own, ok := embeddings[id]
+	if !ok {
+		return 0
+	}
+	var total float64
+	var count int
+	for other, embedding := range embeddings {
+		if other == id {
+			continue
+		}
+		total += cosineSimilarity(own, embedding)
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// fetchUpvotes looks up each paper's Hugging Face upvotes in the paper cache; misses are skipped
+func fetchUpvotes(ctx context.Context, ids []string) map[string]int {
+	upvotes := make(map[string]int, len(ids))
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		data, err := paper.GetPaper(id)
+		if err != nil {
+			logger.Debug("Failed to load paper for weekly ranking", map[string]interface{}{"arxivId": id, "error": err.Error()})
+			continue
+		}
+		if data != nil && data.Upvotes > 0 {
+			upvotes[id] = data.Upvotes
+		}
+	}
+	return upvotes
+}
+
+// fetchEmbeddings loads the papers' stored embeddings; without a database there are none
+func fetchEmbeddings(ctx context.Context, ids []string) map[string][]float32 {
+	if paper.InitDB() != nil || !paper.IsDBEnabled() {
+		return nil
+	}
+	embeddings, err := paper.GetVectorDBCache().GetResultEmbeddingsBatch(ctx, ids)
+	if err != nil {
+		logger.Warn("Failed to load paper embeddings for weekly ranking", map[string]interface{}{"error": err.Error()})
+		return nil
+	}
+	return embeddings
+}
+
+// fallbackWeeklyHeadline leads with the top paper when the LLM headline isn't available
+func fallbackWeeklyHeadline(papers []weeklyPaper) string {
+	if len(papers) == 0 {
+		return "This week in AI research"
+	}
+	return fmt.Sprintf("This week's top paper: %s", papers[0].Title)
+}
+
+// arxivIDFromLink extracts the arXiv ID from TLDR (/p/ID), arXiv and Hugging Face paper links
+func arxivIDFromLink(link string) string {
+	link = strings.TrimRight(strings.SplitN(strings.SplitN(link, "?", 2)[0], "#", 2)[0], "/")
+	segment := link[strings.LastIndex(link, "/")+1:]
+	segment = strings.TrimSuffix(segment, ".pdf")
+	if m := arxivIDRegex.FindStringSubmatch(segment); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// plainText strips tags and entities and collapses whitespace
+func plainText(html string) string {
+	text := weeklyTagRegex.ReplaceAllString(html, " ")
+	text = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'", "&nbsp;", " ").Replace(text)
+	text = strings.Join(strings.Fields(text), " ")
+	// Tags replaced by spaces leave gaps before punctuation
+	return strings.NewReplacer(" ,", ",", " .", ".", " ;", ";", " :", ":", " )", ")", "( ", "(").Replace(text)
+}
+
+// truncateText cuts text to at most max characters on a word boundary, adding an ellipsis
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	cut := string(runes[:max])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:") + "…"
+}
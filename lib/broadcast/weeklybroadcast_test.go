@@ -0,0 +1,123 @@
+package broadcast
+
+import (
+	"main/lib/rss"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWeekDates(t *testing.T) {
+	available := []string{"2025-01-13", "2025-01-12", "2025-01-10", "2025-01-06", "2025-01-05", "2025-01-10"}
+	got := weekDates(available, "2025-01-12")
+	want := []string{"2025-01-06", "2025-01-10", "2025-01-12"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("weekDates() = %v, want %v", got, want)
+	}
+
+	for weekEnding, want := range map[string]string{
+		"2025-01-12": "January 6 – 12, 2025",
+		"2025-02-02": "January 27 – February 2, 2025",
+		"2025-01-03": "December 28, 2024 – January 3, 2025",
+	} {
+		if got := formatWeekRange(weekEnding); got != want {
+			t.Errorf("formatWeekRange(%s) = %q, want %q", weekEnding, got, want)
+		}
+	}
+}
+
+func TestCollectWeeklyPapers(t *testing.T) {
+	feeds := map[string]*rss.RssFeed{
+		"2025-01-06": {Items: []rss.FeedItem{{Description: `<h2>What's New</h2>` +
+			`<p><a href="https://tldr.takara.ai/p/2501.00001">Small Models</a> beat larger ones &amp; cost less.</p>` +
+			`<p>See <a href="https://github.com/example/repo">the code</a>.</p>`}}},
+		"2025-01-07": {Items: []rss.FeedItem{{Description: `<ul>` +
+			`<li><a href="https://arxiv.org/abs/2501.00002v2">Agents</a> plan better.</li>` +
+			`<li>Also <a href="https://huggingface.co/papers/2501.00001">Small Models</a> again.</li></ul>`}}},
+	}
+
+	papers := collectWeeklyPapers([]string{"2025-01-06", "2025-01-07", "2025-01-08"}, feeds)
+	if len(papers) != 2 {
+		t.Fatalf("collectWeeklyPapers() = %+v, want the two arXiv papers", papers)
+	}
+	first := papers[0]
+	if first.ArxivID != "2501.00001" || first.Title != "Small Models" || first.Date != "2025-01-06" || first.Mentions != 2 {
+		t.Errorf("first paper = %+v", first)
+	}
+	if first.Summary != "Small Models beat larger ones & cost less." {
+		t.Errorf("first paper summary = %q", first.Summary)
+	}
+	if papers[1].ArxivID != "2501.00002" || papers[1].Link != "https://tldr.takara.ai/p/2501.00002" {
+		t.Errorf("second paper = %+v", papers[1])
+	}
+}
+
+func TestRankWeeklyPapers(t *testing.T) {
+	papers := []weeklyPaper{
+		{ArxivID: "a", Mentions: 1},
+		{ArxivID: "b", Mentions: 1},
+		{ArxivID: "c", Mentions: 3},
+		{ArxivID: "d", Mentions: 1},
+	}
+
+	// Upvotes lead
+	ranked := rankWeeklyPapers(papers, map[string]int{"b": 40, "d": 90}, nil)
+	if got := ids(ranked); got != "d,b,c,a" {
+		t.Errorf("ranked by upvotes = %s, want d,b,c,a", got)
+	}
+
+	// Without upvotes, the paper closest to the rest of the week leads
+	embeddings := map[string][]float32{
+		"a": {1, 0, 0},
+		"b": {0.9, 0.1, 0},
+		"c": {0, 0, 1},
+		"d": {0.8, 0.2, 0},
+	}
+	ranked = rankWeeklyPapers(papers, nil, embeddings)
+	if ranked[0].ArxivID != "b" || ranked[len(ranked)-1].ArxivID != "c" {
+		t.Errorf("ranked by centrality = %s, want b first and c last", ids(ranked))
+	}
+	if papers[0].Centrality != 0 {
+		t.Error("rankWeeklyPapers() modified its input")
+	}
+
+	// Without either signal, mentions then feed order
+	if got := ids(rankWeeklyPapers(papers, nil, nil)); got != "c,a,b,d" {
+		t.Errorf("ranked without signals = %s, want c,a,b,d", got)
+	}
+}
+
+func TestGenerateWeeklyEmailHTML(t *testing.T) {
+	html, err := generateWeeklyEmailHTML(weeklyIssue{
+		WeekEnding: "2025-01-12",
+		Headline:   "Small models <win>",
+		Papers: []weeklyPaper{
+			{ArxivID: "2501.00001", Title: "Small Models", Link: "https://tldr.takara.ai/p/2501.00001", Date: "2025-01-06", Summary: "They win.", Upvotes: 42},
+			{ArxivID: "2501.00002", Title: "Agents", Link: "https://tldr.takara.ai/p/2501.00002", Date: "2025-01-07", Summary: "They plan."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("generateWeeklyEmailHTML() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"January 6 – 12, 2025",
+		"Small models &lt;win&gt;",
+		`href="https://tldr.takara.ai/p/2501.00001"`,
+		"Covered Monday, January 6 · 42 upvotes",
+		"Covered Tuesday, January 7\n",
+		"{{{RESEND_UNSUBSCRIBE_URL}}}",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("weekly email is missing %q", want)
+		}
+	}
+}
+
+func ids(papers []weeklyPaper) string {
+	out := make([]string, len(papers))
+	for i, p := range papers {
+		out[i] = p.ArxivID
+	}
+	return strings.Join(out, ",")
+}
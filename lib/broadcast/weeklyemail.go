@@ -0,0 +1,149 @@
+package broadcast
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// weeklyEmailTemplateStr is the weekly roundup: the week's headline, then the top papers
+// ranked, each with where it was first covered and the TLDR's own blurb
+const weeklyEmailTemplateStr = `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8" />
+</head>
+<body style="font-family: 'Lato', 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;">
+    <table width="100%" border="0" cellspacing="0" cellpadding="0">
+        <tr>
+            <td align="center" style="padding-top: 40px; padding-bottom: 20px;">
+                <a href="https://tldr.takara.ai" style="text-decoration: none;">
+                    <span style="font-family: 'Lato', sans-serif; font-weight: 900; font-size: 40px; color: rgb(74, 77, 78); display: inline;">tldr.</span><span style="font-family: 'Lato', sans-serif; font-weight: 900; font-size: 40px; color: rgb(217, 16, 9); display: inline;">takara.ai</span>
+                </a>
+            </td>
+        </tr>
+    </table>
+
+    <table width="100%" border="0" cellspacing="0" cellpadding="0" style="padding: 20px;">
+        <tr>
+            <td>
+                <p style="font-family: 'Lato', sans-serif; font-weight: bold; font-size: 18px; letter-spacing: 2px; text-transform: uppercase; color: rgb(217, 16, 9); margin: 0;">
+                    The Week in AI Research
+                </p>
+                <h1 style="font-family: 'Noto Sans', Helvetica, Arial, sans-serif; font-weight: bold; font-size: 48px; color: rgb(74, 77, 78); margin-top: 10px; margin-bottom: 10px;">
+                    {{ .WeekRange }}
+                </h1>
+                <p style="font-family: 'Lato', sans-serif; font-weight: normal; font-size: 23px; color: rgb(74, 77, 78);">
+                    {{ .Headline }}
+                </p>
+                <hr style="margin-top: 16px; margin-bottom: 16px; border: 0; border-top: 1px solid rgba(74, 77, 78, 0.4);" />
+
+                {{range $i, $paper := .Papers}}
+                <table width="100%" border="0" cellspacing="0" cellpadding="0" style="margin-bottom: 28px;">
+                    <tr>
+                        <td valign="top" style="width: 56px; font-family: 'Lato', sans-serif; font-weight: 900; font-size: 40px; line-height: 1; color: rgb(217, 16, 9);">{{ inc $i }}</td>
+                        <td valign="top" style="font-family: 'Lato', sans-serif; color: rgb(74, 77, 78);">
+                            <a href="{{ $paper.Link }}" style="font-size: 26px; font-weight: bold; color: rgb(217, 16, 9); text-decoration: none;">{{ $paper.Title }}</a>
+                            <p style="font-size: 14px; color: rgba(74, 77, 78, 0.8); margin: 6px 0;">
+                                Covered {{ formatDay $paper.Date }}{{ if gt $paper.Upvotes 0 }} · {{ $paper.Upvotes }} upvotes{{ end }}
+                            </p>
+                            <p style="font-size: 20px; margin: 0;">{{ $paper.Summary }}</p>
+                        </td>
+                    </tr>
+                </table>
+                {{end}}
+
+                <p style="font-family: 'Lato', sans-serif; font-size: 18px; color: rgb(74, 77, 78);">
+                    Every paper from the week is in the daily TLDRs at <a href="https://tldr.takara.ai" style="color: rgb(217, 16, 9); text-decoration: none;">tldr.takara.ai</a>.
+                </p>
+            </td>
+        </tr>
+    </table>
+
+    <table width="100%" border="0" cellspacing="0" cellpadding="0" style="padding: 12px 20px; max-width: 100%;">
+        <tr>
+            <td>
+                <hr style="margin-top: 16px; margin-bottom: 16px; border: 0; border-top: 1px solid rgba(74, 77, 78, 0.4);" />
+                <table width="100%" border="0" cellspacing="0" cellpadding="0">
+                    <tr>
+                        <td valign="top">
+                            <p style="font-family: 'Lato', sans-serif; line-height: 100%; font-weight: bolder; font-size: 50px; color: rgb(74, 77, 78); margin: 0;">Transforming Humanity</p>
+                            <p style="font-family: 'Noto Sans', sans-serif; line-height: 200%; font-weight: bold; font-size: 25px; color: rgb(217, 16, 9); margin: 0;">類を変革する</p>
+                        </td>
+                        <td align="right" valign="top" style="width: 50%;">
+                            <a href="https://takara.ai" style="text-decoration: none;">
+                                <img src="https://tldr.takara.ai/icon.svg" alt="Origami Crane Logo" style="width: 250px; height: 194px; max-width: 100%;" />
+                            </a>
+                        </td>
+                    </tr>
+                </table>
+
+                <table width="100%" border="0" cellspacing="0" cellpadding="0" style="text-align: center; font-family: 'Lato', sans-serif; font-size: 12px; color: rgba(74, 77, 78, 0.8);">
+                    <tr><td style="padding-top: 10px;">You chose the weekly TLDR in your email preferences. Want to stop receiving emails? {{ "<a href=\"{{{RESEND_UNSUBSCRIBE_URL}}}\" style=\"color: rgb(217, 16, 9); text-decoration: none;\">Unsubscribe</a>" | safeHTML }}</td></tr>
+                    <tr><td>Disclaimer: takara.ai Ltd is not responsible for the content of these articles as all content is from third-party sources.</td></tr>
+                    <tr><td>© 2025 takara.ai Ltd. All rights reserved.</td></tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`
+
+// weeklyTemplateData holds the data for the weekly email template
+type weeklyTemplateData struct {
+	WeekRange string
+	Headline  string
+	Papers    []weeklyPaper
+}
+
+// generateWeeklyEmailHTML renders the weekly roundup email body
+func generateWeeklyEmailHTML(issue weeklyIssue) (string, error) {
+	tpl, err := template.New("weeklyEmail").Funcs(template.FuncMap{
+		"safeHTML":  func(s string) template.HTML { return template.HTML(s) },
+		"inc":       func(i int) int { return i + 1 },
+		"formatDay": formatDay,
+	}).Parse(weeklyEmailTemplateStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse weekly email template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, weeklyTemplateData{
+		WeekRange: formatWeekRange(issue.WeekEnding),
+		Headline:  issue.Headline,
+		Papers:    issue.Papers,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute weekly email template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// formatWeekRange formats the weeklyDays ending on weekEnding (YYYY-MM-DD), e.g. "January 6 – 12, 2025"
+func formatWeekRange(weekEnding string) string {
+	end, err := time.Parse("2006-01-02", weekEnding)
+	if err != nil {
+		return weekEnding
+	}
+	start := end.AddDate(0, 0, -(weeklyDays - 1))
+	switch {
+	case start.Year() != end.Year():
+		return start.Format("January 2, 2006") + " – " + end.Format("January 2, 2006")
+	case start.Month() != end.Month():
+		return start.Format("January 2") + " – " + end.Format("January 2, 2006")
+	default:
+		return start.Format("January 2") + " – " + end.Format("2, 2006")
+	}
+}
+
+// formatDay formats a YYYY-MM-DD date as "Monday, January 6"
+func formatDay(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.Format("Monday, January 2")
+}
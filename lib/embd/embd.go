@@ -0,0 +1,418 @@
+// Package embd encodes and decodes the "EMBD" binary embedding format served by /api/ds1
+//
+// Layout: a 16-byte header followed by batch vectors of equal dimension.
+//
+//	Magic    4 bytes  "EMBD"
+//	Version  1 byte   1
+//	Batch    2 bytes  uint16 little-endian
+//	Dims     2 bytes  uint16 little-endian
+//	Dtype    1 byte   see Dtype
+//	Endian   1 byte   0 = little-endian
+//	Reserved 5 bytes  zero
+//
+// Each vector is encoded according to the dtype:
+//
+//	float32  dims * 4 bytes (IEEE 754 single)
+//	float16  dims * 2 bytes (IEEE 754 half, round-to-nearest-even)
+//	int8     4-byte float32 scale, then dims signed bytes; value = q * scale
+//	binary   ceil(dims/8) bytes, one sign bit per dimension (1 = positive), MSB first
+//
+// A stream is a sequence of complete records (header + vectors) written back to back,
+// each covering the next chunk of inputs in order. ReadRecord reads one record at a time.
+package embd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	// Magic identifies an EMBD payload
+	Magic = "EMBD"
+	// Version is the current format version
+	Version = 1
+	// HeaderSize is the fixed header length in bytes
+	HeaderSize = 16
+	// MaxBatch and MaxDims are bounded by the uint16 header fields
+	MaxBatch = math.MaxUint16
+	MaxDims  = math.MaxUint16
+	// ContentType is used for single records, StreamContentType for framed record streams
+	ContentType       = "application/octet-stream"
+	StreamContentType = "application/x-embd-stream"
+)
+
+// Dtype is the per-value encoding of the vectors
+type Dtype uint8
+
+const (
+	DtypeFloat32 Dtype = 0
+	DtypeFloat16 Dtype = 1
+	DtypeInt8    Dtype = 2
+	DtypeBinary  Dtype = 3
+)
+
+// ParseDtype parses a dtype name as used in the ds1 "dtype" query parameter
+// An empty string means float32
+func ParseDtype(s string) (Dtype, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "float32", "f32", "fp32":
+		return DtypeFloat32, nil
+	case "float16", "f16", "fp16", "half":
+		return DtypeFloat16, nil
+	case "int8", "i8":
+		return DtypeInt8, nil
+	case "binary", "bit", "ubinary":
+		return DtypeBinary, nil
+	default:
+		return 0, fmt.Errorf("unknown dtype %q (expected float32, float16, int8 or binary)", s)
+	}
+}
+
+// String returns the canonical dtype name
+func (d Dtype) String() string {
+	switch d {
+	case DtypeFloat32:
+		return "float32"
+	case DtypeFloat16:
+		return "float16"
+	case DtypeInt8:
+		return "int8"
+	case DtypeBinary:
+		return "binary"
+	default:
+		return fmt.Sprintf("dtype(%d)", uint8(d))
+	}
+}
+
+// VectorSize returns the encoded size of one vector of dims values
+func (d Dtype) VectorSize(dims int) int {
+	switch d {
+	case DtypeFloat32:
+		return dims * 4
+	case DtypeFloat16:
+		return dims * 2
+	case DtypeInt8:
+		return 4 + dims
+	case DtypeBinary:
+		return (dims + 7) / 8
+	default:
+		return 0
+	}
+}
+
+// valid reports whether the dtype is known
+func (d Dtype) valid() bool {
+	return d <= DtypeBinary
+}
+
+// Header describes an EMBD payload
+type Header struct {
+	Version uint8
+	Batch   int
+	Dims    int
+	Dtype   Dtype
+}
+
+// PayloadSize returns the total encoded size including the header
+func (h Header) PayloadSize() int {
+	return HeaderSize + h.Batch*h.Dtype.VectorSize(h.Dims)
+}
+
+// AppendHeader appends the 16-byte header to dst
+func AppendHeader(dst []byte, h Header) ([]byte, error) {
+	if !h.Dtype.valid() {
+		return dst, fmt.Errorf("unknown dtype %d", h.Dtype)
+	}
+	if h.Batch < 0 || h.Batch > MaxBatch {
+		return dst, fmt.Errorf("batch size %d out of range (max %d)", h.Batch, MaxBatch)
+	}
+	if h.Dims < 0 || h.Dims > MaxDims {
+		return dst, fmt.Errorf("dimension %d out of range (max %d)", h.Dims, MaxDims)
+	}
+
+	var header [HeaderSize]byte
+	copy(header[0:4], Magic)
+	header[4] = Version
+	binary.LittleEndian.PutUint16(header[5:7], uint16(h.Batch))
+	binary.LittleEndian.PutUint16(header[7:9], uint16(h.Dims))
+	header[9] = byte(h.Dtype)
+	header[10] = 0 // Endian: little-endian
+	// header[11:16] reserved (zeros)
+
+	return append(dst, header[:]...), nil
+}
+
+// ParseHeader reads and validates the header at the start of data
+func ParseHeader(data []byte) (Header, error) {
+	if len(data) < HeaderSize {
+		return Header{}, fmt.Errorf("payload too short for header: %d bytes", len(data))
+	}
+	if string(data[0:4]) != Magic {
+		return Header{}, fmt.Errorf("invalid magic %q", data[0:4])
+	}
+	if data[4] != Version {
+		return Header{}, fmt.Errorf("unsupported version %d", data[4])
+	}
+	if data[10] != 0 {
+		return Header{}, fmt.Errorf("unsupported endianness %d", data[10])
+	}
+
+	h := Header{
+		Version: data[4],
+		Batch:   int(binary.LittleEndian.Uint16(data[5:7])),
+		Dims:    int(binary.LittleEndian.Uint16(data[7:9])),
+		Dtype:   Dtype(data[9]),
+	}
+	if !h.Dtype.valid() {
+		return Header{}, fmt.Errorf("unknown dtype %d", h.Dtype)
+	}
+
+	return h, nil
+}
+
+// Encode encodes a batch of equal-length embeddings with the given dtype
+func Encode(embeddings [][]float32, dtype Dtype) ([]byte, error) {
+	dims := 0
+	if len(embeddings) > 0 {
+		dims = len(embeddings[0])
+	}
+	for i, emb := range embeddings {
+		if len(emb) != dims {
+			return nil, fmt.Errorf("embedding %d has %d dimensions, expected %d", i, len(emb), dims)
+		}
+	}
+
+	h := Header{Version: Version, Batch: len(embeddings), Dims: dims, Dtype: dtype}
+	buf := make([]byte, 0, h.PayloadSize())
+	buf, err := AppendHeader(buf, h)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, emb := range embeddings {
+		buf = AppendVector(buf, emb, dtype)
+	}
+
+	return buf, nil
+}
+
+// Decode decodes a full EMBD payload back to float32 vectors
+// int8 values are rescaled; binary values decode to +1 / -1
+func Decode(data []byte) (Header, [][]float32, error) {
+	h, err := ParseHeader(data)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	if len(data) != h.PayloadSize() {
+		return h, nil, fmt.Errorf("payload is %d bytes, header describes %d", len(data), h.PayloadSize())
+	}
+
+	vectorSize := h.Dtype.VectorSize(h.Dims)
+	embeddings := make([][]float32, h.Batch)
+	offset := HeaderSize
+	for i := range embeddings {
+		embeddings[i] = DecodeVector(data[offset:offset+vectorSize], h.Dims, h.Dtype)
+		offset += vectorSize
+	}
+
+	return h, embeddings, nil
+}
+
+// ReadRecord reads the next record of a framed stream
+// Returns io.EOF when the stream ends cleanly between records
+func ReadRecord(r io.Reader) (Header, [][]float32, error) {
+	headerBytes := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, headerBytes); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Header{}, nil, fmt.Errorf("truncated record header: %w", err)
+		}
+		return Header{}, nil, err
+	}
+
+	h, err := ParseHeader(headerBytes)
+	if err != nil {
+		return Header{}, nil, err
+	}
+
+	payload := make([]byte, h.PayloadSize())
+	copy(payload, headerBytes)
+	if _, err := io.ReadFull(r, payload[HeaderSize:]); err != nil {
+		return h, nil, fmt.Errorf("truncated record body: %w", err)
+	}
+
+	return Decode(payload)
+}
+
+// AppendVector appends one encoded vector to dst
+func AppendVector(dst []byte, v []float32, dtype Dtype) []byte {
+	switch dtype {
+	case DtypeFloat16:
+		for _, val := range v {
+			dst = binary.LittleEndian.AppendUint16(dst, Float32ToFloat16(val))
+		}
+
+	case DtypeInt8:
+		scale := Int8Scale(v)
+		dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(scale))
+		for _, val := range v {
+			dst = append(dst, byte(quantizeInt8(val, scale)))
+		}
+
+	case DtypeBinary:
+		var current byte
+		for i, val := range v {
+			if val > 0 {
+				current |= 0x80 >> (i % 8)
+			}
+			if i%8 == 7 {
+				dst = append(dst, current)
+				current = 0
+			}
+		}
+		if len(v)%8 != 0 {
+			dst = append(dst, current)
+		}
+
+	default:
+		for _, val := range v {
+			dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(val))
+		}
+	}
+
+	return dst
+}
+
+// DecodeVector decodes one vector of dims values from data
+// data must be exactly dtype.VectorSize(dims) bytes
+func DecodeVector(data []byte, dims int, dtype Dtype) []float32 {
+	v := make([]float32, dims)
+
+	switch dtype {
+	case DtypeFloat16:
+		for i := range v {
+			v[i] = Float16ToFloat32(binary.LittleEndian.Uint16(data[i*2:]))
+		}
+
+	case DtypeInt8:
+		scale := math.Float32frombits(binary.LittleEndian.Uint32(data[0:4]))
+		for i := range v {
+			v[i] = float32(int8(data[4+i])) * scale
+		}
+
+	case DtypeBinary:
+		for i := range v {
+			if data[i/8]&(0x80>>(i%8)) != 0 {
+				v[i] = 1
+			} else {
+				v[i] = -1
+			}
+		}
+
+	default:
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+	}
+
+	return v
+}
+
+// Int8Scale returns the symmetric per-vector scale so the largest magnitude maps to 127
+func Int8Scale(v []float32) float32 {
+	var maxAbs float32
+	for _, val := range v {
+		if abs := float32(math.Abs(float64(val))); abs > maxAbs {
+			maxAbs = abs
+		}
+	}
+	return maxAbs / 127
+}
+
+// quantizeInt8 rounds val/scale to the nearest int8 in [-127, 127]
+func quantizeInt8(val, scale float32) int8 {
+	if scale == 0 {
+		return 0
+	}
+	q := math.Round(float64(val / scale))
+	if q > 127 {
+		q = 127
+	} else if q < -127 {
+		q = -127
+	}
+	return int8(q)
+}
+
+// Float32ToFloat16 converts to IEEE 754 half precision with round-to-nearest-even
+// Values beyond the half range become ±Inf; NaN stays NaN
+func Float32ToFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	// Inf / NaN
+	if exp == 0xff {
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		return sign | 0x7c00 // Overflow to infinity
+	}
+
+	if e <= 0 {
+		// Subnormal half (or underflow to zero)
+		if e < -10 {
+			return sign
+		}
+		mant |= 0x800000 // Implicit leading bit
+		shift := uint32(14 - e)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	half := uint32(e)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++ // May carry into the exponent, which is the correct rounding
+	}
+	return sign | uint16(half)
+}
+
+// Float16ToFloat32 converts an IEEE 754 half precision value to float32
+func Float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case exp == 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Normalize the subnormal
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
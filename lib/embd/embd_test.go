@@ -0,0 +1,215 @@
+package embd
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+func sampleEmbeddings() [][]float32 {
+	return [][]float32{
+		{0.5, -0.25, 0.125, 0, 1, -1, 0.333, -0.9, 0.01},
+		{-0.1, 0.2, -0.3, 0.4, -0.5, 0.6, -0.7, 0.8, -0.95},
+	}
+}
+
+func TestParseDtype(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Dtype
+		wantErr  bool
+	}{
+		{input: "", expected: DtypeFloat32},
+		{input: "float32", expected: DtypeFloat32},
+		{input: "F16", expected: DtypeFloat16},
+		{input: "int8", expected: DtypeInt8},
+		{input: "binary", expected: DtypeBinary},
+		{input: "bit", expected: DtypeBinary},
+		{input: "int4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseDtype(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseDtype(%q) expected error", tt.input)
+				}
+				return
+			}
+			if err != nil || result != tt.expected {
+				t.Errorf("ParseDtype(%q) = %v, %v; expected %v", tt.input, result, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHeaderLayout(t *testing.T) {
+	data, err := Encode(sampleEmbeddings(), DtypeInt8)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	if string(data[0:4]) != "EMBD" || data[4] != 1 {
+		t.Errorf("Unexpected magic/version: %q %d", data[0:4], data[4])
+	}
+	if data[5] != 2 || data[6] != 0 || data[7] != 9 || data[8] != 0 {
+		t.Errorf("Unexpected batch/dims bytes: %v", data[5:9])
+	}
+	if data[9] != byte(DtypeInt8) || data[10] != 0 {
+		t.Errorf("Unexpected dtype/endian bytes: %v", data[9:11])
+	}
+	if len(data) != HeaderSize+2*(4+9) {
+		t.Errorf("Expected %d bytes, got %d", HeaderSize+2*(4+9), len(data))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		dtype     Dtype
+		tolerance float64
+	}{
+		{dtype: DtypeFloat32, tolerance: 0},
+		{dtype: DtypeFloat16, tolerance: 1e-3},
+		{dtype: DtypeInt8, tolerance: 1.0 / 127},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dtype.String(), func(t *testing.T) {
+			input := sampleEmbeddings()
+			data, err := Encode(input, tt.dtype)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			header, decoded, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if header.Dtype != tt.dtype || header.Batch != 2 || header.Dims != 9 {
+				t.Errorf("Unexpected header: %+v", header)
+			}
+
+			for i := range input {
+				for j := range input[i] {
+					diff := math.Abs(float64(decoded[i][j] - input[i][j]))
+					if diff > tt.tolerance {
+						t.Errorf("[%d][%d] = %v; expected %v (±%v)", i, j, decoded[i][j], input[i][j], tt.tolerance)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	input := sampleEmbeddings()
+	data, err := Encode(input, DtypeBinary)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// 9 dims pack into 2 bytes per vector
+	if len(data) != HeaderSize+2*2 {
+		t.Fatalf("Expected %d bytes, got %d", HeaderSize+4, len(data))
+	}
+	// First vector signs: + - + 0 + - + - + => 1010 1010 1.......
+	if data[HeaderSize] != 0xAA || data[HeaderSize+1] != 0x80 {
+		t.Errorf("Unexpected packed bits: %08b %08b", data[HeaderSize], data[HeaderSize+1])
+	}
+
+	_, decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	for i := range input {
+		for j, val := range input[i] {
+			expected := float32(-1)
+			if val > 0 {
+				expected = 1
+			}
+			if decoded[i][j] != expected {
+				t.Errorf("[%d][%d] = %v; expected %v", i, j, decoded[i][j], expected)
+			}
+		}
+	}
+}
+
+func TestFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		input    float32
+		expected uint16
+	}{
+		{input: 0, expected: 0x0000},
+		{input: 1, expected: 0x3c00},
+		{input: -2, expected: 0xc000},
+		{input: 65504, expected: 0x7bff},
+		{input: 1e6, expected: 0x7c00},
+		{input: float32(math.Inf(-1)), expected: 0xfc00},
+		{input: 5.960464477539063e-08, expected: 0x0001}, // Smallest subnormal
+		{input: 6.103515625e-05, expected: 0x0400},       // Smallest normal
+	}
+
+	for _, tt := range tests {
+		result := Float32ToFloat16(tt.input)
+		if result != tt.expected {
+			t.Errorf("Float32ToFloat16(%v) = %#04x; expected %#04x", tt.input, result, tt.expected)
+		}
+		if !math.IsInf(float64(tt.input), 0) && tt.input <= 65504 {
+			if back := Float16ToFloat32(result); back != tt.input {
+				t.Errorf("Float16ToFloat32(%#04x) = %v; expected %v", result, back, tt.input)
+			}
+		}
+	}
+
+	if !math.IsNaN(float64(Float16ToFloat32(Float32ToFloat16(float32(math.NaN()))))) {
+		t.Error("NaN should survive a float16 round trip")
+	}
+}
+
+func TestEncodeRejectsRaggedBatch(t *testing.T) {
+	if _, err := Encode([][]float32{{1, 2}, {1}}, DtypeFloat32); err == nil {
+		t.Error("Expected error for embeddings with different dimensions")
+	}
+}
+
+func TestDecodeRejectsTruncatedPayload(t *testing.T) {
+	data, _ := Encode(sampleEmbeddings(), DtypeFloat16)
+	if _, _, err := Decode(data[:len(data)-1]); err == nil {
+		t.Error("Expected error for truncated payload")
+	}
+	if _, _, err := Decode([]byte("NOPE")); err == nil {
+		t.Error("Expected error for invalid header")
+	}
+}
+
+func TestReadRecordStream(t *testing.T) {
+	first, _ := Encode(sampleEmbeddings()[:1], DtypeFloat16)
+	second, _ := Encode(sampleEmbeddings()[1:], DtypeFloat16)
+	stream := bytes.NewReader(append(first, second...))
+
+	var total int
+	for {
+		header, embeddings, err := ReadRecord(stream)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadRecord failed: %v", err)
+		}
+		if header.Batch != 1 || len(embeddings) != 1 {
+			t.Errorf("Expected one vector per record, got %d", len(embeddings))
+		}
+		total += len(embeddings)
+	}
+
+	if total != 2 {
+		t.Errorf("Expected 2 vectors across the stream, got %d", total)
+	}
+
+	truncated := bytes.NewReader(first[:len(first)-2])
+	if _, _, err := ReadRecord(truncated); err == nil || err == io.EOF {
+		t.Errorf("Expected truncation error, got %v", err)
+	}
+}
@@ -0,0 +1,457 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"main/lib/article"
+	"main/lib/logger"
+	"main/lib/search"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ArticleSearchDays is how many days of collected articles a search covers by default
+	ArticleSearchDays = 14
+	// MaxArticleSearchDays bounds the window, and is the window of the index the digest
+	// pipeline stores; without a stored index every day costs two blob reads
+	MaxArticleSearchDays = 60
+	// snippetLength is the size of highlighted summary snippets, in bytes
+	snippetLength = 240
+)
+
+// ArticleSearchOptions selects the articles to search and the page of results
+type ArticleSearchOptions struct {
+	Filter  article.ArticleFilter // Search is the query; Offset and Limit page the results
+	EndDate string                // YYYY-MM-DD, default today UTC
+	Days    int                   // Days to search, counting back from EndDate (default ArticleSearchDays)
+}
+
+// ArticleHighlights holds HTML-escaped text with the matched words in <mark> tags
+type ArticleHighlights struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary,omitempty"` // Snippet of the summary, or the excerpt if only it matched
+}
+
+// ArticleSearchHit is an article matching a search
+type ArticleSearchHit struct {
+	Article    article.ArticleData `json:"article"`
+	Date       string              `json:"date"`            // Day the article was collected
+	Relevance  float64             `json:"relevance"`       // BM25 score for the query
+	Score      float64             `json:"score,omitempty"` // Digest ranking score, if it made a digest
+	Rank       int                 `json:"rank,omitempty"`  // Digest position, if it made a digest
+	Highlights ArticleHighlights   `json:"highlights"`
+}
+
+// ArticleSearchResults is one page of search hits, best match first
+type ArticleSearchResults struct {
+	Query   string             `json:"query"`
+	Total   int                `json:"total"` // Hits across all pages
+	Offset  int                `json:"offset"`
+	Limit   int                `json:"limit"`
+	Results []ArticleSearchHit `json:"results"`
+}
+
+// articleSearchBoosts weights where a query term appears: a hit in the headline says more
+// about what the article is about than one deep in the scraped body
+var articleSearchBoosts = map[string]float64{
+	"title":      3,
+	"summary":    1.5,
+	"excerpt":    1,
+	"categories": 2,
+	"source":     1,
+	"content":    0.5,
+}
+
+// searchDoc is an article in the search corpus with what the digests said about it
+type searchDoc struct {
+	article article.ArticleData
+	date    string
+	score   float64
+	rank    int
+}
+
+// SearchArticles runs a full-text search over the articles collected in the last Days days.
+// Titles, summaries, excerpts, categories, sources and scraped bodies are indexed with BM25,
+// titles weighted highest (see articleSearchBoosts); the other Filter criteria then narrow
+// the hits (MinScore keeps only digest-ranked articles).
+// An article collected on several days is searched once, as of its newest day.
+// The index the digest pipeline stored for the window is used when there is one, so a search
+// reads one blob; otherwise the window's articles are loaded and indexed on the spot.
+func SearchArticles(ctx context.Context, store DigestStore, opts ArticleSearchOptions) (*ArticleSearchResults, error) {
+	if opts.Days > MaxArticleSearchDays {
+		opts.Days = MaxArticleSearchDays
+	}
+	dates, err := windowDates(opts.EndDate, opts.Days, ArticleSearchDays)
+	if err != nil {
+		return nil, err
+	}
+
+	index := storedArticleSearchIndex(ctx, store, dates)
+	prebuilt := index != nil
+	if !prebuilt {
+		if index, err = BuildArticleSearchIndex(ctx, store, dates[0], len(dates)); err != nil {
+			return nil, err
+		}
+	}
+	// A stored index can reach further back than the window asked for
+	since := dates[len(dates)-1]
+
+	filter := opts.Filter
+	query := strings.TrimSpace(filter.Search)
+	filter.Search = ""
+
+	results := &ArticleSearchResults{
+		Query:   query,
+		Offset:  opts.Filter.Offset,
+		Limit:   opts.Filter.Limit,
+		Results: []ArticleSearchHit{},
+	}
+
+	var matched []search.Hit
+	for _, hit := range index.index.Search(query, 0) {
+		doc := index.docs[hit.ID]
+		if doc == nil || doc.date < since {
+			continue
+		}
+		if filter.MatchesRanked(article.RankedArticle{Article: doc.article, Score: doc.score}) {
+			matched = append(matched, hit)
+		}
+	}
+	results.Total = len(matched)
+
+	if filter.Offset >= len(matched) {
+		matched = nil
+	} else {
+		matched = matched[filter.Offset:]
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+
+	terms := search.Terms(query)
+	for _, hit := range matched {
+		doc := index.docs[hit.ID]
+		results.Results = append(results.Results, ArticleSearchHit{
+			Article:    doc.article,
+			Date:       doc.date,
+			Relevance:  hit.Score,
+			Score:      doc.score,
+			Rank:       doc.rank,
+			Highlights: highlightArticle(doc.article, terms),
+		})
+	}
+
+	logger.Info("Article search completed", map[string]interface{}{
+		"query":    query,
+		"days":     len(dates),
+		"indexed":  index.index.Len(),
+		"prebuilt": prebuilt,
+		"total":    results.Total,
+	})
+	return results, nil
+}
+
+// ArticleSearchIndex is the BM25 index over the articles collected in the Days days up to
+// EndDate, with what the digests said about each one
+type ArticleSearchIndex struct {
+	EndDate string
+	Days    int
+
+	index *search.Index
+	keys  []string // Insertion order, so the stored form is stable
+	docs  map[string]*searchDoc
+}
+
+// storedSearchIndex is the JSON form of an ArticleSearchIndex
+type storedSearchIndex struct {
+	EndDate string            `json:"endDate"`
+	Days    int               `json:"days"`
+	Docs    []storedSearchDoc `json:"docs"`
+	Index   json.RawMessage   `json:"index"` // search.Index snapshot
+}
+
+type storedSearchDoc struct {
+	Key     string              `json:"key"`
+	Article article.ArticleData `json:"article"`
+	Date    string              `json:"date"`
+	Score   float64             `json:"score,omitempty"`
+	Rank    int                 `json:"rank,omitempty"`
+}
+
+// articleSearchConfig is the index configuration for articles; a stored index must be read
+// back with the tokenizer it was built with
+func articleSearchConfig() search.Config {
+	config := search.DefaultConfig()
+	config.Boosts = articleSearchBoosts
+	return config
+}
+
+// BuildArticleSearchIndex loads the articles collected in the days days up to endDate and
+// indexes them. Scraped bodies are indexed but not kept, so hits and stored indexes stay small.
+func BuildArticleSearchIndex(ctx context.Context, store DigestStore, endDate string, days int) (*ArticleSearchIndex, error) {
+	dates, err := windowDates(endDate, days, ArticleSearchDays)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := loadSearchCorpus(ctx, store, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &ArticleSearchIndex{
+		EndDate: dates[0],
+		Days:    len(dates),
+		index:   search.NewIndex(articleSearchConfig()),
+		docs:    make(map[string]*searchDoc, len(docs)),
+	}
+	for _, doc := range docs {
+		key := articleKey(doc.article)
+		ix.index.Add(searchDocument(key, doc.article))
+		doc.article.FullContent = ""
+		ix.keys = append(ix.keys, key)
+		ix.docs[key] = doc
+	}
+	return ix, nil
+}
+
+// SaveArticleSearchIndex builds the index over the MaxArticleSearchDays days up to date and
+// stores it, so searches ending on date don't rebuild it. The digest pipeline calls it once
+// the day's articles are stored; stores that can't keep an index are skipped.
+func SaveArticleSearchIndex(ctx context.Context, store DigestStore, date string) error {
+	indexes, ok := store.(SearchIndexStore)
+	if !ok {
+		return nil
+	}
+
+	index, err := BuildArticleSearchIndex(ctx, store, date, MaxArticleSearchDays)
+	if err != nil {
+		return fmt.Errorf("failed to build article search index: %w", err)
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := indexes.SaveSearchIndex(ctx, index.EndDate, data); err != nil {
+		return fmt.Errorf("failed to store article search index: %w", err)
+	}
+
+	logger.Info("Stored article search index", map[string]interface{}{
+		"date":    index.EndDate,
+		"indexed": index.index.Len(),
+		"bytes":   len(data),
+	})
+	return nil
+}
+
+// MarshalJSON stores the index as its snapshot plus the article behind each document
+func (ix *ArticleSearchIndex) MarshalJSON() ([]byte, error) {
+	var snapshot bytes.Buffer
+	if err := ix.index.WriteSnapshot(&snapshot); err != nil {
+		return nil, err
+	}
+
+	stored := storedSearchIndex{
+		EndDate: ix.EndDate,
+		Days:    ix.Days,
+		Docs:    make([]storedSearchDoc, 0, len(ix.keys)),
+		Index:   snapshot.Bytes(),
+	}
+	for _, key := range ix.keys {
+		doc := ix.docs[key]
+		stored.Docs = append(stored.Docs, storedSearchDoc{
+			Key:     key,
+			Article: doc.article,
+			Date:    doc.date,
+			Score:   doc.score,
+			Rank:    doc.rank,
+		})
+	}
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON rebuilds an index written by MarshalJSON
+func (ix *ArticleSearchIndex) UnmarshalJSON(data []byte) error {
+	var stored storedSearchIndex
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to decode article search index: %w", err)
+	}
+	index, err := search.ReadSnapshot(bytes.NewReader(stored.Index), articleSearchConfig())
+	if err != nil {
+		return err
+	}
+	if index.Len() != len(stored.Docs) {
+		return fmt.Errorf("article search index has %d documents but %d articles", index.Len(), len(stored.Docs))
+	}
+
+	*ix = ArticleSearchIndex{
+		EndDate: stored.EndDate,
+		Days:    stored.Days,
+		index:   index,
+		keys:    make([]string, 0, len(stored.Docs)),
+		docs:    make(map[string]*searchDoc, len(stored.Docs)),
+	}
+	for _, doc := range stored.Docs {
+		ix.keys = append(ix.keys, doc.Key)
+		ix.docs[doc.Key] = &searchDoc{article: doc.Article, date: doc.Date, score: doc.Score, rank: doc.Rank}
+	}
+	return nil
+}
+
+// storedArticleSearchIndex returns the stored index covering dates (newest first), or nil.
+// Before the day's digest is out, the index stored the day before still covers the window.
+func storedArticleSearchIndex(ctx context.Context, store DigestStore, dates []string) *ArticleSearchIndex {
+	indexes, ok := store.(SearchIndexStore)
+	if !ok {
+		return nil
+	}
+	end, err := time.Parse("2006-01-02", dates[0])
+	if err != nil {
+		return nil
+	}
+	oldest := dates[len(dates)-1]
+
+	for i, date := range []string{dates[0], end.AddDate(0, 0, -1).Format("2006-01-02")} {
+		data, err := indexes.GetSearchIndex(ctx, date)
+		if err != nil {
+			logger.Warn("Failed to load article search index", map[string]interface{}{
+				"date":  date,
+				"error": err.Error(),
+			})
+			continue
+		}
+		if data == nil {
+			if i == 0 {
+				// Today's articles are stored without an index only if the index stage failed
+				if digest, err := store.GetDigest(ctx, date); err != nil || digest != nil {
+					return nil
+				}
+			}
+			continue
+		}
+
+		var index ArticleSearchIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			logger.Warn("Ignoring unreadable article search index", map[string]interface{}{
+				"date":  date,
+				"error": err.Error(),
+			})
+			continue
+		}
+		covered, err := windowDates(index.EndDate, index.Days, MaxArticleSearchDays)
+		if err == nil && index.EndDate == date && covered[len(covered)-1] <= oldest {
+			return &index
+		}
+	}
+	return nil
+}
+
+// loadSearchCorpus loads the collected articles and digests for dates (newest first) and
+// merges them into one list without repeats. Articles only found in a digest are kept too.
+func loadSearchCorpus(ctx context.Context, store DigestStore, dates []string) ([]*searchDoc, error) {
+	articles := make([][]article.ArticleData, len(dates))
+	digests := make([]*article.DailyDigest, len(dates))
+	errs := make([]error, 2*len(dates))
+	var wg sync.WaitGroup
+	for i, date := range dates {
+		wg.Add(2)
+		go func(i int, date string) {
+			defer wg.Done()
+			articles[i], errs[2*i] = store.GetArticles(ctx, date)
+		}(i, date)
+		go func(i int, date string) {
+			defer wg.Done()
+			digests[i], errs[2*i+1] = store.GetDigest(ctx, date)
+		}(i, date)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			logger.Warn("Failed to load articles for search", map[string]interface{}{
+				"date":  dates[i/2],
+				"error": err.Error(),
+			})
+		}
+	}
+	if failed == len(errs) {
+		return nil, fmt.Errorf("failed to load any articles: %w", errs[0])
+	}
+
+	var docs []*searchDoc
+	seen := make(map[string]*searchDoc)
+	for i, date := range dates {
+		ranked := make(map[string]article.RankedArticle)
+		candidates := append([]article.ArticleData{}, articles[i]...)
+		if digests[i] != nil {
+			for _, r := range digests[i].Articles {
+				ranked[articleKey(r.Article)] = r
+				candidates = append(candidates, r.Article)
+			}
+		}
+
+		for _, a := range candidates {
+			key := articleKey(a)
+			r, isRanked := ranked[key]
+			if existing, ok := seen[key]; ok {
+				// A newer day collected it again without ranking it; keep the older digest score
+				if isRanked && existing.rank == 0 {
+					existing.score, existing.rank = r.Score, r.Rank
+				}
+				continue
+			}
+
+			doc := &searchDoc{article: a, date: date}
+			if isRanked {
+				// The digest copy carries the AI summary
+				doc.article, doc.score, doc.rank = r.Article, r.Score, r.Rank
+			}
+			seen[key] = doc
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// articleKey identifies an article across days: its URL, else its ID, else its title
+func articleKey(a article.ArticleData) string {
+	switch {
+	case a.URL != "":
+		return a.URL
+	case a.ID != "":
+		return a.ID
+	}
+	return strings.ToLower(strings.TrimSpace(a.Title))
+}
+
+// searchDocument is the indexed form of an article, see articleSearchBoosts
+func searchDocument(key string, a article.ArticleData) search.Document {
+	return search.Document{ID: key, Fields: map[string]string{
+		"title":      a.Title,
+		"summary":    a.Summary,
+		"excerpt":    a.OriginalSum,
+		"categories": strings.Join(a.Categories, " "),
+		"source":     a.SourceName,
+		"content":    a.FullContent,
+	}}
+}
+
+// highlightArticle marks the query terms in the title and picks the summary snippet
+func highlightArticle(a article.ArticleData, terms []string) ArticleHighlights {
+	title, _ := search.Highlight(a.Title, terms, 0)
+	highlights := ArticleHighlights{Title: title}
+
+	summary, ok := search.Highlight(a.Summary, terms, snippetLength)
+	if !ok && a.OriginalSum != "" {
+		if excerpt, excerptOK := search.Highlight(a.OriginalSum, terms, snippetLength); excerptOK || a.Summary == "" {
+			summary = excerpt
+		}
+	}
+	highlights.Summary = summary
+	return highlights
+}
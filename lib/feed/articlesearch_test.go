@@ -0,0 +1,173 @@
+package feed
+
+import (
+	"context"
+	"main/lib/article"
+	"strings"
+	"testing"
+	"time"
+)
+
+func seedSearchStore(t *testing.T) *MemoryDigestStore {
+	store := NewMemoryDigestStore()
+	ctx := context.Background()
+
+	ukgc := article.ArticleData{
+		URL:         "https://news.test/ukgc-fine",
+		Title:       "UKGC fines operator £2m",
+		OriginalSum: "The Gambling Commission fined the operator over social responsibility failures.",
+		SourceName:  "iGamingBusiness",
+		Categories:  []string{"Regulations"},
+	}
+	slots := article.ArticleData{
+		URL:         "https://news.test/slots",
+		Title:       "Studio launches Megaways slots",
+		OriginalSum: "New slot titles arrive with bonus buy features.",
+		SourceName:  "eGaming Review",
+		Categories:  []string{"Technology"},
+	}
+	ontario := article.ArticleData{
+		URL:         "https://news.test/ontario",
+		Title:       "Ontario operator count grows",
+		OriginalSum: "AGCO approves two more operators, the regulator confirmed.",
+		SourceName:  "Gambling Insider",
+		Categories:  []string{"Regulations"},
+	}
+
+	mustSave := func(err error) {
+		if err != nil {
+			t.Fatalf("Seeding store: %v", err)
+		}
+	}
+	mustSave(store.SaveArticles(ctx, "2025-01-15", []article.ArticleData{ukgc, slots}))
+	ranked := ukgc
+	ranked.Summary = "The UKGC fined an operator for failing affordability checks."
+	mustSave(store.SaveDigest(ctx, &article.DailyDigest{Date: "2025-01-15", Created: time.Now(), Articles: []article.RankedArticle{
+		{Article: ranked, Score: 0.9, Rank: 1},
+	}}))
+	// Collected again the day before; must be searched once, as of the newest day
+	mustSave(store.SaveArticles(ctx, "2025-01-14", []article.ArticleData{ontario, ukgc}))
+	return store
+}
+
+func TestSearchArticles(t *testing.T) {
+	store := seedSearchStore(t)
+	ctx := context.Background()
+
+	results, err := SearchArticles(ctx, store, ArticleSearchOptions{
+		Filter:  article.ArticleFilter{Search: "operator fines"},
+		EndDate: "2025-01-15",
+		Days:    2,
+	})
+	if err != nil {
+		t.Fatalf("SearchArticles() error = %v", err)
+	}
+	if results.Total != 2 || len(results.Results) != 2 {
+		t.Fatalf("SearchArticles() = %+v; expected the UKGC and Ontario stories", results)
+	}
+	top := results.Results[0]
+	if top.Article.URL != "https://news.test/ukgc-fine" || top.Date != "2025-01-15" || top.Score != 0.9 || top.Rank != 1 {
+		t.Errorf("Top hit = %+v; expected the ranked UKGC story from 2025-01-15", top)
+	}
+	if top.Relevance <= results.Results[1].Relevance {
+		t.Errorf("Relevance = %v, %v; expected descending", top.Relevance, results.Results[1].Relevance)
+	}
+	if top.Highlights.Title != "UKGC <mark>fines</mark> <mark>operator</mark> £2m" {
+		t.Errorf("Title highlight = %q", top.Highlights.Title)
+	}
+	if !strings.Contains(top.Highlights.Summary, "<mark>operator</mark>") {
+		t.Errorf("Summary highlight = %q; expected the digest summary", top.Highlights.Summary)
+	}
+
+	// Filters narrow the hits; MinScore keeps only digest-ranked articles
+	for name, tt := range map[string]struct {
+		filter article.ArticleFilter
+		want   string
+	}{
+		"Jurisdiction": {article.ArticleFilter{Search: "operator", Jurisdictions: []string{"ca"}}, "https://news.test/ontario"},
+		"Min score":    {article.ArticleFilter{Search: "operator", MinScore: 0.5}, "https://news.test/ukgc-fine"},
+		"Page":         {article.ArticleFilter{Search: "operator", Offset: 1, Limit: 1}, "https://news.test/ontario"},
+	} {
+		results, err := SearchArticles(ctx, store, ArticleSearchOptions{Filter: tt.filter, EndDate: "2025-01-15", Days: 2})
+		if err != nil {
+			t.Fatalf("%s: SearchArticles() error = %v", name, err)
+		}
+		if len(results.Results) != 1 || results.Results[0].Article.URL != tt.want {
+			t.Errorf("%s: SearchArticles() = %+v; expected only %s", name, results.Results, tt.want)
+		}
+	}
+
+	results, _ = SearchArticles(ctx, store, ArticleSearchOptions{Filter: article.ArticleFilter{Search: "bonus buy"}, EndDate: "2025-01-15"})
+	if results.Total != 1 || results.Results[0].Highlights.Summary != "New slot titles arrive with <mark>bonus</mark> <mark>buy</mark> features." {
+		t.Errorf("SearchArticles() = %+v; expected the excerpt to be highlighted when there's no summary", results.Results)
+	}
+}
+
+func TestSearchArticlesUsesStoredIndex(t *testing.T) {
+	seeded := seedSearchStore(t)
+	ctx := context.Background()
+	if err := SaveArticleSearchIndex(ctx, seeded, "2025-01-15"); err != nil {
+		t.Fatalf("SaveArticleSearchIndex() error = %v", err)
+	}
+
+	// Only the index is copied over, so any hit must come from it
+	data, err := seeded.GetSearchIndex(ctx, "2025-01-15")
+	if err != nil || data == nil {
+		t.Fatalf("GetSearchIndex() = %d bytes, %v", len(data), err)
+	}
+	store := NewMemoryDigestStore()
+	if err := store.SaveSearchIndex(ctx, "2025-01-15", data); err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(endDate string, days int) *ArticleSearchResults {
+		results, err := SearchArticles(ctx, store, ArticleSearchOptions{
+			Filter:  article.ArticleFilter{Search: "operator fines"},
+			EndDate: endDate,
+			Days:    days,
+		})
+		if err != nil {
+			t.Fatalf("SearchArticles(%s, %d) error = %v", endDate, days, err)
+		}
+		return results
+	}
+
+	results := find("2025-01-15", 2)
+	if results.Total != 2 {
+		t.Fatalf("SearchArticles() = %+v; expected the UKGC and Ontario stories", results)
+	}
+	top := results.Results[0]
+	if top.Article.URL != "https://news.test/ukgc-fine" || top.Date != "2025-01-15" || top.Score != 0.9 || top.Rank != 1 {
+		t.Errorf("Top hit = %+v; expected the ranked UKGC story from 2025-01-15", top)
+	}
+	if !strings.Contains(top.Highlights.Summary, "<mark>operator</mark>") {
+		t.Errorf("Summary highlight = %q; expected the digest summary", top.Highlights.Summary)
+	}
+
+	// The stored index spans 60 days; hits outside the requested window are dropped
+	if results := find("2025-01-15", 1); results.Total != 1 || results.Results[0].Date != "2025-01-15" {
+		t.Errorf("One day: SearchArticles() = %+v; expected only the 2025-01-15 story", results.Results)
+	}
+	// Until the next digest is out, the previous day's index is used
+	if results := find("2025-01-16", 3); results.Total != 2 {
+		t.Errorf("Next day: SearchArticles() = %+v; expected yesterday's index", results.Results)
+	}
+	// Further out there's no index and nothing stored to build one from
+	if results := find("2025-01-17", 3); results.Total != 0 {
+		t.Errorf("Two days on: SearchArticles() = %+v; expected no hits", results.Results)
+	}
+}
+
+func TestSearchArticlesErrors(t *testing.T) {
+	if _, err := SearchArticles(context.Background(), failingDigestStore{NewMemoryDigestStore()}, ArticleSearchOptions{EndDate: "15-01-2025"}); err == nil {
+		t.Error("Expected an error for an invalid end date")
+	}
+
+	results, err := SearchArticles(context.Background(), failingDigestStore{NewMemoryDigestStore()}, ArticleSearchOptions{
+		Filter:  article.ArticleFilter{Search: "slots"},
+		EndDate: "2025-01-15",
+	})
+	if err != nil || results.Total != 0 {
+		t.Errorf("SearchArticles() = %+v, %v; expected stored articles to be searched when digests fail", results, err)
+	}
+}
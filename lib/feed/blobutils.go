@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"main/lib/logger"
+	"main/lib/rss"
 	"net/http"
 	"os"
 	"sort"
@@ -68,7 +69,7 @@ func listBlobsManually(prefix string) (*VercelListResponse, error) {
 }
 
 // GetLatestTldrFeed fetches the most recent TLDR feed from Vercel Blob storage.
-func GetLatestTldrFeed() (*RssFeed, error) {
+func GetLatestTldrFeed() (*rss.RssFeed, error) {
 	listResponse, err := listBlobsManually(tldrFeedsPrefix)
 	if err != nil {
 		return nil, fmt.Errorf("could not list tldr feeds from blob: %w", err)
@@ -104,7 +105,7 @@ func GetLatestTldrFeed() (*RssFeed, error) {
 		return nil, fmt.Errorf("non-200 status when fetching latest feed blob: %s", resp.Status)
 	}
 
-	var feed RssFeed
+	var feed rss.RssFeed
 	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
 		return nil, fmt.Errorf("failed to decode latest feed content: %w", err)
 	}
@@ -112,7 +113,7 @@ func GetLatestTldrFeed() (*RssFeed, error) {
 	return &feed, nil
 }
 
-func StoreTldrFeed(feed *RssFeed) error {
+func StoreTldrFeed(feed *rss.RssFeed) error {
 	token := os.Getenv("BLOB_READ_WRITE_TOKEN")
 	if token == "" {
 		logger.Error("BLOB_READ_WRITE_TOKEN environment variable not set", nil, nil)
@@ -0,0 +1,146 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"main/lib/article"
+	"main/lib/logger"
+	"main/lib/rss"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DigestFeedDays is how many daily digests a filtered digest feed spans by default
+const DigestFeedDays = 7
+
+// DigestFeedOptions selects the digests and articles of a filtered digest feed
+type DigestFeedOptions struct {
+	Filter  article.ArticleFilter
+	EndDate string // YYYY-MM-DD, default today UTC
+	Days    int    // Digests to include, counting back from EndDate (default DigestFeedDays)
+	Link    string // Page the feed describes
+	FeedURL string // Where the feed is served, for the self link
+}
+
+// BuildDigestFeed collects the ranked articles matching opts.Filter from the last Days
+// digests, newest digest first. Entries use the article URL as their ID, so an article keeps
+// the same GUID in every filtered feed and on every day it's ranked; repeats are kept once.
+// Filter.Limit caps the entries across all days and Filter.Offset is ignored.
+func BuildDigestFeed(ctx context.Context, store DigestStore, opts DigestFeedOptions) (*rss.Document, error) {
+	dates, err := windowDates(opts.EndDate, opts.Days, DigestFeedDays)
+	if err != nil {
+		return nil, err
+	}
+
+	// Digests are independent blobs, so fetch the window concurrently
+	digests := make([]*article.DailyDigest, len(dates))
+	errs := make([]error, len(dates))
+	var wg sync.WaitGroup
+	for i, date := range dates {
+		wg.Add(1)
+		go func(i int, date string) {
+			defer wg.Done()
+			digests[i], errs[i] = store.GetDigest(ctx, date)
+		}(i, date)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			logger.Warn("Failed to load digest for filtered feed", map[string]interface{}{
+				"date":  dates[i],
+				"error": err.Error(),
+			})
+		}
+	}
+	if failed == len(dates) {
+		return nil, fmt.Errorf("failed to load any digest: %w", errs[0])
+	}
+
+	doc := &rss.Document{
+		Title:       DigestFeedTitle(opts.Filter),
+		Description: "Top-ranked iGaming news from the daily digest",
+		Link:        opts.Link,
+		FeedURL:     opts.FeedURL,
+		Entries:     []rss.Entry{},
+	}
+
+	seen := map[string]bool{}
+	for _, digest := range digests {
+		if digest == nil {
+			continue
+		}
+		if digest.Created.After(doc.Updated) {
+			doc.Updated = digest.Created
+		}
+
+		filtered := *digest
+		filtered.Articles = nil
+		for _, ranked := range digest.Articles {
+			if opts.Filter.MatchesRanked(ranked) {
+				filtered.Articles = append(filtered.Articles, ranked)
+			}
+		}
+
+		for _, entry := range rss.DigestDocument(&filtered, opts.Link).Entries {
+			if seen[entry.ID] || (opts.Filter.Limit > 0 && len(doc.Entries) >= opts.Filter.Limit) {
+				continue
+			}
+			seen[entry.ID] = true
+			doc.Entries = append(doc.Entries, entry)
+		}
+	}
+
+	return doc, nil
+}
+
+// windowDates lists the days dates counting back from endDate (YYYY-MM-DD, default today UTC),
+// newest first. days <= 0 means fallback days.
+func windowDates(endDate string, days, fallback int) ([]string, error) {
+	if days <= 0 {
+		days = fallback
+	}
+	end := time.Now().UTC()
+	if endDate != "" {
+		parsed, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end date %q: %w", endDate, err)
+		}
+		end = parsed
+	}
+
+	dates := make([]string, days)
+	for i := range dates {
+		dates[i] = end.AddDate(0, 0, -i).Format("2006-01-02")
+	}
+	return dates, nil
+}
+
+// DigestFeedTitle names a filtered feed after its criteria,
+// e.g. "iGaming TLDR: Regulations, Payments | UK | score >= 0.5"
+func DigestFeedTitle(filter article.ArticleFilter) string {
+	var parts []string
+	for _, values := range [][]string{filter.Categories, filter.SourceNames} {
+		if len(values) > 0 {
+			parts = append(parts, strings.Join(values, ", "))
+		}
+	}
+	if len(filter.Jurisdictions) > 0 {
+		codes := make([]string, len(filter.Jurisdictions))
+		for i, value := range filter.Jurisdictions {
+			codes[i] = strings.ToUpper(article.NormalizeJurisdiction(value))
+		}
+		parts = append(parts, strings.Join(codes, ", "))
+	}
+	if filter.MinScore > 0 {
+		parts = append(parts, fmt.Sprintf("score >= %g", filter.MinScore))
+	}
+
+	if len(parts) == 0 {
+		return "iGaming TLDR"
+	}
+	return "iGaming TLDR: " + strings.Join(parts, " | ")
+}
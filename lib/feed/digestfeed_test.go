@@ -0,0 +1,102 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"main/lib/article"
+	"testing"
+	"time"
+)
+
+func seedDigestStore(t *testing.T) *MemoryDigestStore {
+	store := NewMemoryDigestStore()
+	ranked := func(url, category string, score float64) article.RankedArticle {
+		return article.RankedArticle{Score: score, Article: article.ArticleData{
+			Title:      category + " story",
+			URL:        url,
+			SourceName: "iGamingBusiness",
+			Categories: []string{category},
+		}}
+	}
+
+	digests := []*article.DailyDigest{
+		{Date: "2025-01-15", Created: time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC), Articles: []article.RankedArticle{
+			ranked("https://news.test/ukgc", "Regulations", 0.9),
+			ranked("https://news.test/slots", "Technology", 0.8),
+			ranked("https://news.test/psp", "Payments", 0.3),
+		}},
+		{Date: "2025-01-14", Created: time.Date(2025, 1, 14, 6, 0, 0, 0, time.UTC), Articles: []article.RankedArticle{
+			// Still ranked the day before; must not appear twice
+			ranked("https://news.test/ukgc", "Regulations", 0.7),
+			ranked("https://news.test/mga", "Regulations", 0.6),
+		}},
+	}
+	for _, digest := range digests {
+		if err := store.SaveDigest(context.Background(), digest); err != nil {
+			t.Fatalf("SaveDigest() error = %v", err)
+		}
+	}
+	return store
+}
+
+func TestBuildDigestFeed(t *testing.T) {
+	store := seedDigestStore(t)
+
+	doc, err := BuildDigestFeed(context.Background(), store, DigestFeedOptions{
+		Filter:  article.ArticleFilter{Categories: []string{"regulations", "payments"}},
+		EndDate: "2025-01-15",
+		FeedURL: "https://tldr.takara.ai/api/feed?category=payments&category=regulations",
+	})
+	if err != nil {
+		t.Fatalf("BuildDigestFeed() error = %v", err)
+	}
+
+	var ids []string
+	for _, entry := range doc.Entries {
+		ids = append(ids, entry.ID)
+	}
+	want := []string{"https://news.test/ukgc", "https://news.test/psp", "https://news.test/mga"}
+	if len(ids) != len(want) {
+		t.Fatalf("Entries = %v; expected %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("Entries = %v; expected %v", ids, want)
+			break
+		}
+	}
+	if !doc.Updated.Equal(time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Updated = %v; expected the newest digest's time", doc.Updated)
+	}
+	if doc.Title != "iGaming TLDR: regulations, payments" {
+		t.Errorf("Title = %q", doc.Title)
+	}
+
+	doc, _ = BuildDigestFeed(context.Background(), store, DigestFeedOptions{
+		Filter:  article.ArticleFilter{MinScore: 0.65, Limit: 1},
+		EndDate: "2025-01-15",
+	})
+	if len(doc.Entries) != 1 || doc.Entries[0].ID != "https://news.test/ukgc" {
+		t.Errorf("Entries = %+v; expected the limit to keep only the top match", doc.Entries)
+	}
+}
+
+type failingDigestStore struct{ *MemoryDigestStore }
+
+func (failingDigestStore) GetDigest(ctx context.Context, date string) (*article.DailyDigest, error) {
+	return nil, errors.New("blob unavailable")
+}
+
+func TestBuildDigestFeedErrors(t *testing.T) {
+	if _, err := BuildDigestFeed(context.Background(), failingDigestStore{NewMemoryDigestStore()}, DigestFeedOptions{Days: 2}); err == nil {
+		t.Error("Expected an error when no digest can be loaded")
+	}
+	if _, err := BuildDigestFeed(context.Background(), NewMemoryDigestStore(), DigestFeedOptions{EndDate: "15-01-2025"}); err == nil {
+		t.Error("Expected an error for an invalid end date")
+	}
+
+	doc, err := BuildDigestFeed(context.Background(), NewMemoryDigestStore(), DigestFeedOptions{EndDate: "2025-01-15"})
+	if err != nil || len(doc.Entries) != 0 {
+		t.Errorf("BuildDigestFeed() with no digests = %+v, %v; expected an empty feed", doc, err)
+	}
+}
@@ -0,0 +1,438 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"main/lib/article"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	digestsPrefix     = "digests/"
+	articlesPrefix    = "articles/"
+	searchIndexPrefix = "search/articles-"
+)
+
+// Digest run statuses recorded in the ledger
+const (
+	DigestRunPending   = "pending"
+	DigestRunCompleted = "completed"
+	DigestRunFailed    = "failed"
+)
+
+// ErrDigestLocked is returned when another run holds the lease for a date
+var ErrDigestLocked = errors.New("digest generation already in progress for this date")
+
+// DigestStore persists generated digests and the articles they were built from
+// GetDigest returns nil, nil when no digest exists for the date
+type DigestStore interface {
+	GetDigest(ctx context.Context, date string) (*article.DailyDigest, error)
+	SaveDigest(ctx context.Context, digest *article.DailyDigest) error
+	SaveArticles(ctx context.Context, date string, articles []article.ArticleData) error
+	// GetArticles returns nil, nil when no articles were stored for the date
+	GetArticles(ctx context.Context, date string) ([]article.ArticleData, error)
+}
+
+// SearchIndexStore is implemented by digest stores that also keep the prebuilt article
+// search index (see SaveArticleSearchIndex)
+// GetSearchIndex returns nil, nil when no index was stored for the date
+type SearchIndexStore interface {
+	SaveSearchIndex(ctx context.Context, date string, index []byte) error
+	GetSearchIndex(ctx context.Context, date string) ([]byte, error)
+}
+
+// DigestRun is the ledger record for one digest date
+type DigestRun struct {
+	Date         string     `json:"date"`
+	Status       string     `json:"status"`
+	ArticleCount int        `json:"articleCount"`
+	BroadcastID  string     `json:"broadcastId,omitempty"`
+	BroadcastAt  *time.Time `json:"broadcastAt,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"startedAt"`
+	CompletedAt  *time.Time `json:"completedAt,omitempty"`
+}
+
+// DigestLedger serializes digest runs per date and remembers what each run did
+type DigestLedger interface {
+	// Acquire takes the lease for a date and returns the run as last recorded
+	// Returns ErrDigestLocked if another holder's lease hasn't expired
+	Acquire(ctx context.Context, date, holder string, lease time.Duration) (*DigestRun, error)
+	// RecordBroadcast stores the broadcast ID as soon as it's sent, so a crash
+	// before Release can't lead to a second send
+	RecordBroadcast(ctx context.Context, date, holder, broadcastID string) error
+	// Release records the final state and frees the lease
+	Release(ctx context.Context, holder string, run *DigestRun) error
+}
+
+// BlobDigestStore stores digests in Vercel Blob at the paths the Next.js digest routes read:
+// digests/{date}.json and articles/{date}.json
+type BlobDigestStore struct {
+	client *http.Client
+}
+
+// NewBlobDigestStore creates a blob-backed digest store
+func NewBlobDigestStore() *BlobDigestStore {
+	return &BlobDigestStore{client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// GetDigest fetches the digest for a date, or nil if none was stored
+func (s *BlobDigestStore) GetDigest(ctx context.Context, date string) (*article.DailyDigest, error) {
+	var digest article.DailyDigest
+	found, err := s.get(ctx, digestsPrefix+date+".json", &digest)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+// GetArticles fetches the articles collected for a date, or nil if none were stored
+func (s *BlobDigestStore) GetArticles(ctx context.Context, date string) ([]article.ArticleData, error) {
+	var articles []article.ArticleData
+	if _, err := s.get(ctx, articlesPrefix+date+".json", &articles); err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
+
+// SaveDigest writes the digest, replacing any earlier one for the date
+func (s *BlobDigestStore) SaveDigest(ctx context.Context, digest *article.DailyDigest) error {
+	return s.put(ctx, digestsPrefix+digest.Date+".json", digest)
+}
+
+// SaveArticles writes the deduplicated articles a digest was built from
+func (s *BlobDigestStore) SaveArticles(ctx context.Context, date string, articles []article.ArticleData) error {
+	return s.put(ctx, articlesPrefix+date+".json", articles)
+}
+
+// SaveSearchIndex writes the article search index built for a date
+func (s *BlobDigestStore) SaveSearchIndex(ctx context.Context, date string, index []byte) error {
+	return s.put(ctx, searchIndexPrefix+date+".json", json.RawMessage(index))
+}
+
+// GetSearchIndex returns the article search index built for a date, or nil
+func (s *BlobDigestStore) GetSearchIndex(ctx context.Context, date string) ([]byte, error) {
+	var index json.RawMessage
+	found, err := s.get(ctx, searchIndexPrefix+date+".json", &index)
+	if err != nil || !found {
+		return nil, err
+	}
+	return index, nil
+}
+
+// get decodes the blob at pathname into value and reports whether it exists
+func (s *BlobDigestStore) get(ctx context.Context, pathname string, value interface{}) (bool, error) {
+	listResponse, err := listBlobsManually(pathname)
+	if err != nil {
+		return false, fmt.Errorf("could not list blobs for %s: %w", pathname, err)
+	}
+
+	var blobURL string
+	for _, blob := range listResponse.Blobs {
+		if blob.Pathname == pathname {
+			blobURL = blob.URL
+			break
+		}
+	}
+	if blobURL == "" {
+		return false, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request for %s: %w", pathname, err)
+	}
+	// Digests can be regenerated, so skip any CDN copy of an older version
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch %s: %w", pathname, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("non-200 status when fetching %s: %s", pathname, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", pathname, err)
+	}
+	return true, nil
+}
+
+func (s *BlobDigestStore) put(ctx context.Context, pathname string, value interface{}) error {
+	token := os.Getenv("BLOB_READ_WRITE_TOKEN")
+	if token == "" {
+		return fmt.Errorf("BLOB_READ_WRITE_TOKEN environment variable not set")
+	}
+
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", pathname, err)
+	}
+
+	putURL := fmt.Sprintf("%s/%s", vercelBlobAPIURL, pathname)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, putURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create PUT request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-add-random-suffix", "0")
+	req.Header.Set("x-allow-overwrite", "1")
+	req.Header.Set("x-cache-control-max-age", "300")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute PUT request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("blob store PUT returned non-200 status: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// MemoryDigestStore keeps digests in memory (local runs and tests)
+type MemoryDigestStore struct {
+	mu       sync.RWMutex
+	digests  map[string]*article.DailyDigest
+	articles map[string][]article.ArticleData
+	indexes  map[string][]byte
+}
+
+// NewMemoryDigestStore creates an empty in-memory digest store
+func NewMemoryDigestStore() *MemoryDigestStore {
+	return &MemoryDigestStore{
+		digests:  make(map[string]*article.DailyDigest),
+		articles: make(map[string][]article.ArticleData),
+		indexes:  make(map[string][]byte),
+	}
+}
+
+// GetDigest returns the stored digest for a date, or nil
+func (s *MemoryDigestStore) GetDigest(ctx context.Context, date string) (*article.DailyDigest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.digests[date], nil
+}
+
+// SaveDigest stores a digest
+func (s *MemoryDigestStore) SaveDigest(ctx context.Context, digest *article.DailyDigest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.digests[digest.Date] = digest
+	return nil
+}
+
+// SaveArticles stores the articles for a date
+func (s *MemoryDigestStore) SaveArticles(ctx context.Context, date string, articles []article.ArticleData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.articles[date] = articles
+	return nil
+}
+
+// GetArticles returns the articles stored for a date
+func (s *MemoryDigestStore) GetArticles(ctx context.Context, date string) ([]article.ArticleData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.articles[date], nil
+}
+
+// SaveSearchIndex stores the article search index for a date
+func (s *MemoryDigestStore) SaveSearchIndex(ctx context.Context, date string, index []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexes[date] = index
+	return nil
+}
+
+// GetSearchIndex returns the article search index stored for a date
+func (s *MemoryDigestStore) GetSearchIndex(ctx context.Context, date string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.indexes[date], nil
+}
+
+// PostgresDigestLedger keeps digest runs in the digest_runs table (migration 0004)
+// The lease is a holder/lease_until pair rather than an advisory lock, because
+// serverless invocations can't hold a session open for the whole run
+type PostgresDigestLedger struct {
+	db *sql.DB
+}
+
+// NewPostgresDigestLedger creates a ledger on an open database
+func NewPostgresDigestLedger(db *sql.DB) *PostgresDigestLedger {
+	return &PostgresDigestLedger{db: db}
+}
+
+// Acquire inserts or takes over the row for a date if its lease is free
+// The lease update leaves the recorded state intact and returns it in the same statement,
+// so a run can't miss a broadcast another run recorded just before
+func (l *PostgresDigestLedger) Acquire(ctx context.Context, date, holder string, lease time.Duration) (*DigestRun, error) {
+	var run DigestRun
+	var broadcastID, errMsg sql.NullString
+	var broadcastAt, completedAt sql.NullTime
+
+	err := l.db.QueryRowContext(ctx, `
+		INSERT INTO digest_runs (date, status, holder, lease_until, started_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4), NOW())
+		ON CONFLICT (date) DO UPDATE SET
+			holder = EXCLUDED.holder,
+			lease_until = EXCLUDED.lease_until,
+			started_at = NOW()
+		WHERE digest_runs.lease_until IS NULL OR digest_runs.lease_until < NOW()
+		RETURNING date, status, article_count, broadcast_id, broadcast_at, error, started_at, completed_at`,
+		date, DigestRunPending, holder, lease.Seconds(),
+	).Scan(&run.Date, &run.Status, &run.ArticleCount, &broadcastID, &broadcastAt, &errMsg, &run.StartedAt, &completedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrDigestLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire digest lease: %w", err)
+	}
+
+	run.BroadcastID = broadcastID.String
+	run.Error = errMsg.String
+	if broadcastAt.Valid {
+		run.BroadcastAt = &broadcastAt.Time
+	}
+	if completedAt.Valid {
+		run.CompletedAt = &completedAt.Time
+	}
+	return &run, nil
+}
+
+// RecordBroadcast stores the broadcast ID while the lease is still held
+func (l *PostgresDigestLedger) RecordBroadcast(ctx context.Context, date, holder, broadcastID string) error {
+	_, err := l.db.ExecContext(ctx, `
+		UPDATE digest_runs SET broadcast_id = $3, broadcast_at = NOW()
+		WHERE date = $1 AND holder = $2`,
+		date, holder, broadcastID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record digest broadcast: %w", err)
+	}
+	return nil
+}
+
+// Release records the final state of the run and clears the lease
+func (l *PostgresDigestLedger) Release(ctx context.Context, holder string, run *DigestRun) error {
+	_, err := l.db.ExecContext(ctx, `
+		UPDATE digest_runs SET
+			status = $3,
+			article_count = $4,
+			error = NULLIF($5, ''),
+			completed_at = $6,
+			holder = NULL,
+			lease_until = NULL
+		WHERE date = $1 AND holder = $2`,
+		run.Date, holder, run.Status, run.ArticleCount, run.Error, run.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release digest lease: %w", err)
+	}
+	return nil
+}
+
+// MemoryDigestLedger is an in-process DigestLedger for local runs and tests
+// It only protects against duplicates within one process
+type MemoryDigestLedger struct {
+	mu     sync.Mutex
+	runs   map[string]*DigestRun
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	holder string
+	until  time.Time
+}
+
+// NewMemoryDigestLedger creates an empty in-memory ledger
+func NewMemoryDigestLedger() *MemoryDigestLedger {
+	return &MemoryDigestLedger{
+		runs:   make(map[string]*DigestRun),
+		leases: make(map[string]memoryLease),
+	}
+}
+
+// Acquire takes the lease for a date if it is free or expired
+func (l *MemoryDigestLedger) Acquire(ctx context.Context, date, holder string, lease time.Duration) (*DigestRun, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if current, ok := l.leases[date]; ok && now.Before(current.until) {
+		return nil, ErrDigestLocked
+	}
+	l.leases[date] = memoryLease{holder: holder, until: now.Add(lease)}
+
+	run, ok := l.runs[date]
+	if !ok {
+		run = &DigestRun{Date: date, Status: DigestRunPending}
+		l.runs[date] = run
+	}
+	run.StartedAt = now
+
+	copied := *run
+	return &copied, nil
+}
+
+// RecordBroadcast stores the broadcast ID if holder still owns the lease
+func (l *MemoryDigestLedger) RecordBroadcast(ctx context.Context, date, holder, broadcastID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.leases[date].holder != holder {
+		return nil
+	}
+	now := time.Now()
+	l.runs[date].BroadcastID = broadcastID
+	l.runs[date].BroadcastAt = &now
+	return nil
+}
+
+// Release records the run and frees the lease if holder still owns it
+func (l *MemoryDigestLedger) Release(ctx context.Context, holder string, run *DigestRun) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.leases[run.Date].holder != holder {
+		return nil
+	}
+	stored := l.runs[run.Date]
+	stored.Status = run.Status
+	stored.ArticleCount = run.ArticleCount
+	stored.Error = run.Error
+	stored.CompletedAt = run.CompletedAt
+	delete(l.leases, run.Date)
+	return nil
+}
+
+// Run returns a copy of the recorded run for a date, or nil
+func (l *MemoryDigestLedger) Run(date string) *DigestRun {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	run, ok := l.runs[date]
+	if !ok {
+		return nil
+	}
+	copied := *run
+	return &copied
+}
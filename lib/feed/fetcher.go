@@ -2,11 +2,11 @@ package feed
 
 import (
 	"context"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"main/lib/article"
 	"main/lib/logger"
+	"main/lib/rss"
 	"net/http"
 	"strings"
 	"time"
@@ -92,7 +92,7 @@ func (af *ArticleFetcher) fetchFromRSS(ctx context.Context, source *NewsSource)
 }
 
 // fetchRSSFeed fetches RSS feed with retry logic
-func (af *ArticleFetcher) fetchRSSFeed(ctx context.Context, feedURL string) (*RssFeed, error) {
+func (af *ArticleFetcher) fetchRSSFeed(ctx context.Context, feedURL string) (*rss.RssFeed, error) {
 	var lastErr error
 
 	for attempt := 0; attempt < af.config.RetryAttempts; attempt++ {
@@ -121,7 +121,7 @@ func (af *ArticleFetcher) fetchRSSFeed(ctx context.Context, feedURL string) (*Rs
 }
 
 // fetchRSSFeedAttempt attempts to fetch RSS feed once
-func (af *ArticleFetcher) fetchRSSFeedAttempt(ctx context.Context, feedURL string) (*RssFeed, error) {
+func (af *ArticleFetcher) fetchRSSFeedAttempt(ctx context.Context, feedURL string) (*rss.RssFeed, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -145,56 +145,16 @@ func (af *ArticleFetcher) fetchRSSFeedAttempt(ctx context.Context, feedURL strin
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Parse RSS feed
-	var rssData struct {
-		Channel struct {
-			Title         string `xml:"title"`
-			Description   string `xml:"description"`
-			Link          string `xml:"link"`
-			LastBuildDate string `xml:"lastBuildDate"`
-			Items         []struct {
-				Title       string `xml:"title"`
-				Link        string `xml:"link"`
-				Description string `xml:"description"`
-				PubDate     string `xml:"pubDate"`
-				GUID        string `xml:"guid"`
-				// Additional fields for news content
-				Content     string `xml:"content"`
-				Image       string `xml:"image"`
-				Author      string `xml:"author"`
-				Categories  []string `xml:"category"`
-			} `xml:"item"`
-		} `xml:"channel"`
-	}
-
-	if err := xml.Unmarshal(body, &rssData); err != nil {
-		return nil, fmt.Errorf("failed to parse RSS XML: %w", err)
-	}
-
-	// Convert to our feed format
-	feed := &RssFeed{
-		Title:         rssData.Channel.Title,
-		Description:   rssData.Channel.Description,
-		Link:          rssData.Channel.Link,
-		LastBuildDate: rssData.Channel.LastBuildDate,
-		Items:         make([]FeedItem, 0, len(rssData.Channel.Items)),
-	}
-
-	for _, item := range rssData.Channel.Items {
-		feed.Items = append(feed.Items, FeedItem{
-			Title:       item.Title,
-			Link:        item.Link,
-			Description: item.Description,
-			PubDate:     item.PubDate,
-			GUID:        GUIDString(item.GUID),
-		})
+	feed, err := rss.Parse(body)
+	if err != nil {
+		return nil, err
 	}
 
 	return feed, nil
 }
 
 // parseRSSFeed converts RSS feed items to article data
-func (af *ArticleFetcher) parseRSSFeed(feed *RssFeed, source *NewsSource) []article.ArticleData {
+func (af *ArticleFetcher) parseRSSFeed(feed *rss.RssFeed, source *NewsSource) []article.ArticleData {
 	articles := make([]article.ArticleData, 0, len(feed.Items))
 
 	for _, item := range feed.Items {
@@ -208,7 +168,7 @@ func (af *ArticleFetcher) parseRSSFeed(feed *RssFeed, source *NewsSource) []arti
 }
 
 // parseRSSItem converts a single RSS item to article data
-func (af *ArticleFetcher) parseRSSItem(item FeedItem, source *NewsSource) *article.ArticleData {
+func (af *ArticleFetcher) parseRSSItem(item rss.FeedItem, source *NewsSource) *article.ArticleData {
 	if item.Title == "" || item.Link == "" {
 		logger.Warn("Skipping incomplete RSS item", map[string]interface{}{
 			"title": item.Title,
@@ -238,7 +198,9 @@ func (af *ArticleFetcher) parseRSSItem(item FeedItem, source *NewsSource) *artic
 		SourceName:    source.Name,
 		SourceID:      source.ID,
 		PublishedDate: pubDate.Format(time.RFC3339),
+		ImageURL:      imageEnclosure(item.Enclosures),
 		Categories:    []string{source.Category},
+		Authors:       item.Authors,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -246,6 +208,16 @@ func (af *ArticleFetcher) parseRSSItem(item FeedItem, source *NewsSource) *artic
 	return articleData
 }
 
+// imageEnclosure returns the first image enclosure's URL, if any
+func imageEnclosure(enclosures []rss.Enclosure) string {
+	for _, enclosure := range enclosures {
+		if strings.HasPrefix(enclosure.Type, "image") {
+			return enclosure.URL
+		}
+	}
+	return ""
+}
+
 // FetchFromSources fetches articles from multiple sources
 func (af *ArticleFetcher) FetchFromSources(ctx context.Context, sources []*NewsSource) ([]article.ArticleData, error) {
 	if len(sources) == 0 {
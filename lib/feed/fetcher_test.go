@@ -2,6 +2,7 @@ package feed
 
 import (
 	"context"
+	"main/lib/rss"
 	"testing"
 	"time"
 )
@@ -256,12 +257,17 @@ func TestParseRSSItem(t *testing.T) {
 		Category: "Business",
 	}
 
-	item := FeedItem{
+	item := rss.FeedItem{
 		Title:       "Test Article",
 		Link:        "https://example.com/article",
 		Description: "<p>Test description</p>",
 		PubDate:     "Wed, 02 Jun 2026 15:30:00 +0000",
 		GUID:        "guid-123",
+		Authors:     []string{"Jane Reporter"},
+		Enclosures: []rss.Enclosure{
+			{URL: "https://example.com/episode.mp3", Type: "audio/mpeg"},
+			{URL: "https://example.com/lead.jpg", Type: "image/jpeg"},
+		},
 	}
 
 	article := fetcher.parseRSSItem(item, source)
@@ -285,6 +291,14 @@ func TestParseRSSItem(t *testing.T) {
 	if len(article.Categories) == 0 {
 		t.Error("Article should have at least one category")
 	}
+
+	if article.ImageURL != "https://example.com/lead.jpg" {
+		t.Errorf("Expected the image enclosure as ImageURL, got '%s'", article.ImageURL)
+	}
+
+	if len(article.Authors) != 1 || article.Authors[0] != "Jane Reporter" {
+		t.Errorf("Expected item authors to carry over, got %v", article.Authors)
+	}
 }
 
 // TestParseRSSItemWithMissingFields tests handling of incomplete items
@@ -296,7 +310,7 @@ func TestParseRSSItemWithMissingFields(t *testing.T) {
 	}
 
 	// Item missing title
-	item1 := FeedItem{
+	item1 := rss.FeedItem{
 		Link: "https://example.com/article",
 	}
 
@@ -306,7 +320,7 @@ func TestParseRSSItemWithMissingFields(t *testing.T) {
 	}
 
 	// Item missing link
-	item2 := FeedItem{
+	item2 := rss.FeedItem{
 		Title: "Test Article",
 	}
 
@@ -325,9 +339,9 @@ func TestParseRSSFeed(t *testing.T) {
 		Category: "Business",
 	}
 
-	feed := &RssFeed{
+	feed := &rss.RssFeed{
 		Title: "Test Feed",
-		Items: []FeedItem{
+		Items: []rss.FeedItem{
 			{
 				Title: "Article 1",
 				Link:  "https://example.com/1",
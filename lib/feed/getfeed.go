@@ -1,16 +1,18 @@
 package feed
 
 import (
+	"context"
 	"fmt"
 	"main/lib/analytics"
 	"main/lib/logger"
+	"main/lib/rss"
 	"os"
 	"time"
 )
 
 // GetFeedRawResult holds the result of fetching the feed.
 type GetFeedRawResult struct {
-	Data   *RssFeed
+	Data   *rss.RssFeed
 	Source string
 }
 
@@ -20,7 +22,7 @@ func GetFeedRaw() (*GetFeedRawResult, error) {
 	disableBlob := os.Getenv("DISABLE_BLOB_CACHE") == "1" || os.Getenv("DISABLE_BLOB_CACHE") == "true"
 
 	// 1. First try to get cached feed from blob storage (unless disabled)
-	var feed *RssFeed
+	var feed *rss.RssFeed
 	var err error
 	if !disableBlob {
 		feed, err = GetLatestTldrFeed()
@@ -64,7 +66,7 @@ func GetFeedRaw() (*GetFeedRawResult, error) {
 	}
 
 	// 2. If no cached feed, fetch fresh data
-	feed, err = ParseRssFeed()
+	feed, err = rss.FetchTldr(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse fresh RSS feed: %w", err)
 	}
@@ -0,0 +1,492 @@
+package feed
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"main/lib/article"
+	"main/lib/logger"
+	"main/lib/pipeline"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// Candidates summarized by the LLM before the final digest ranking
+	defaultSummarizeTop = 15
+	// Articles older than this before the end of the digest date are dropped
+	defaultDigestWindow = 48 * time.Hour
+	// Lease held on the date while the pipeline runs (longer than the 300s function limit)
+	defaultDigestLease = 10 * time.Minute
+)
+
+// DigestPipeline is the run ledger name for RunDigestPipeline
+const DigestPipeline = "digest"
+
+// Digest pipeline outcomes reported in DigestPipelineResult.Status
+const (
+	DigestGenerated = "generated" // built and stored by this run
+	DigestExisting  = "existing"  // already stored; reused without refetching
+)
+
+// ArticleSource fetches articles from news sources (ArticleFetcher in production)
+type ArticleSource interface {
+	FetchFromSources(ctx context.Context, sources []*NewsSource) ([]article.ArticleData, error)
+}
+
+// DigestBroadcaster sends a digest to subscribers and returns the broadcast ID
+type DigestBroadcaster func(ctx context.Context, digest *article.DailyDigest) (string, error)
+
+// DigestPipelineOptions configures one run of the daily iGaming digest pipeline
+type DigestPipelineOptions struct {
+	// Date is the digest date (YYYY-MM-DD, default today UTC)
+	Date string
+	// Force regenerates the digest even if one is already stored for the date
+	Force bool
+	// Broadcast sends the digest once per date via Broadcaster
+	Broadcast bool
+
+	Sources     []*NewsSource
+	Fetcher     ArticleSource
+	Ranker      *RankingEngine
+	Summarizer  *ArticleSummarizer // nil skips LLM summaries and uses fallbacks
+	Store       DigestStore
+	Ledger      DigestLedger
+	Broadcaster DigestBroadcaster
+
+	// SummarizeTop is how many pre-ranked candidates get LLM summaries (default 15)
+	SummarizeTop int
+	// Window keeps articles published within this long before the end of Date (default 48h)
+	Window time.Duration
+	// Lease is how long the date stays locked if the run dies without releasing (default 10m)
+	Lease time.Duration
+
+	// Runs records each stage in the pipeline run ledger (nil keeps the record in memory only)
+	Runs *pipeline.Runner
+	// ResumeRunID regenerates from ResumeFrom onwards, reusing that run's earlier stage outputs
+	ResumeRunID string
+	ResumeFrom  string
+}
+
+// DigestPipelineResult summarizes a pipeline run
+type DigestPipelineResult struct {
+	RunID       string               `json:"runId,omitempty"`
+	Date        string               `json:"date"`
+	Status      string               `json:"status"`
+	Digest      *article.DailyDigest `json:"digest,omitempty"`
+	Fetched     int                  `json:"fetched"`
+	Duplicates  int                  `json:"duplicates"`
+	Candidates  int                  `json:"candidates"`
+	BroadcastID string               `json:"broadcastId,omitempty"`
+	// Broadcasted is true only when this run sent the broadcast
+	Broadcasted bool          `json:"broadcasted"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// RunDigestPipeline fetches, dedupes, summarizes and ranks articles, then builds,
+// stores and optionally broadcasts the DailyDigest for a date
+// Runs are idempotent per date: a stored digest is reused unless Force is set, and a
+// digest is broadcast at most once. The ledger lease makes concurrent runs for the same
+// date fail fast with ErrDigestLocked instead of doing the work (and sending) twice
+func RunDigestPipeline(ctx context.Context, opts DigestPipelineOptions) (*DigestPipelineResult, error) {
+	if opts.Date == "" {
+		opts.Date = time.Now().UTC().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", opts.Date); err != nil {
+		return nil, fmt.Errorf("invalid date format: expected YYYY-MM-DD, got %s", opts.Date)
+	}
+	if opts.Fetcher == nil || opts.Ranker == nil || opts.Store == nil || opts.Ledger == nil {
+		return nil, fmt.Errorf("digest pipeline requires a fetcher, ranker, store and ledger")
+	}
+	if opts.Broadcast && opts.Broadcaster == nil {
+		return nil, fmt.Errorf("broadcast requested but no broadcaster configured")
+	}
+	if opts.SummarizeTop <= 0 {
+		opts.SummarizeTop = defaultSummarizeTop
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultDigestWindow
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultDigestLease
+	}
+	if opts.Runs == nil {
+		opts.Runs = pipeline.NewRunner(nil)
+	}
+
+	startTime := time.Now()
+	holder := newRunHolder()
+	logCtx := map[string]interface{}{
+		"date":      opts.Date,
+		"holder":    holder,
+		"force":     opts.Force,
+		"broadcast": opts.Broadcast,
+	}
+
+	run, err := opts.Ledger.Acquire(ctx, opts.Date, holder, opts.Lease)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DigestPipelineResult{Date: opts.Date, BroadcastID: run.BroadcastID}
+	runErr := runDigestStages(ctx, opts, holder, run, result, logCtx)
+
+	now := time.Now()
+	run.CompletedAt = &now
+	if runErr != nil {
+		run.Status = DigestRunFailed
+		run.Error = runErr.Error()
+	} else {
+		run.Status = DigestRunCompleted
+		run.Error = ""
+	}
+	if result.Digest != nil {
+		run.ArticleCount = len(result.Digest.Articles)
+	}
+
+	// Release even if the request was cancelled, so the next delivery isn't blocked for the full lease
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := opts.Ledger.Release(releaseCtx, holder, run); err != nil {
+		logger.Warn("Failed to release digest lease", map[string]interface{}{
+			"date":  opts.Date,
+			"error": err.Error(),
+		})
+	}
+
+	result.Duration = time.Since(startTime)
+	if runErr != nil {
+		return result, runErr
+	}
+
+	logCtx["status"] = result.Status
+	logCtx["articles"] = run.ArticleCount
+	logCtx["broadcasted"] = result.Broadcasted
+	logCtx["duration_ms"] = result.Duration.Milliseconds()
+	logger.Info("Digest pipeline completed", logCtx)
+
+	return result, nil
+}
+
+// runDigestStages does the work while the lease is held
+func runDigestStages(ctx context.Context, opts DigestPipelineOptions, holder string, run *DigestRun, result *DigestPipelineResult, logCtx map[string]interface{}) error {
+	if !opts.Force && opts.ResumeRunID == "" && run.Status == DigestRunCompleted {
+		existing, err := opts.Store.GetDigest(ctx, opts.Date)
+		if err != nil {
+			return fmt.Errorf("failed to load existing digest: %w", err)
+		}
+		if existing != nil {
+			logger.Info("Digest already generated, reusing it", logCtx)
+			result.Status = DigestExisting
+			result.Digest = existing
+		}
+	}
+
+	var stages []pipeline.Stage
+	if result.Digest == nil {
+		stages = digestStages(opts, result)
+	}
+	if opts.Broadcast {
+		stages = append(stages, broadcastStage(opts, holder, run, result))
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+
+	var pipelineRun *pipeline.Run
+	var err error
+	if opts.ResumeRunID != "" {
+		pipelineRun, err = opts.Runs.Resume(ctx, opts.ResumeRunID, opts.ResumeFrom, stages)
+	} else {
+		params := map[string]string{"date": opts.Date, "broadcast": fmt.Sprint(opts.Broadcast)}
+		pipelineRun, err = opts.Runs.Start(ctx, DigestPipeline, params, stages)
+	}
+	if pipelineRun != nil {
+		result.RunID = pipelineRun.ID
+		logCtx["run_id"] = pipelineRun.ID
+	}
+	if err != nil {
+		return err
+	}
+
+	// A resume that started after the store stage never loaded the digest
+	if result.Digest == nil {
+		digest, err := opts.Store.GetDigest(ctx, opts.Date)
+		if err != nil {
+			return fmt.Errorf("failed to load digest: %w", err)
+		}
+		result.Digest = digest
+	}
+	if result.Status == "" {
+		result.Status = DigestGenerated
+	}
+	return nil
+}
+
+// digestStageData is passed between the digest stages as JSON
+type digestStageData struct {
+	Articles   []article.ArticleData `json:"articles"`
+	Candidates []article.ArticleData `json:"candidates,omitempty"`
+	Digest     *article.DailyDigest  `json:"digest,omitempty"`
+}
+
+// digestStages splits generation into resumable stages: fetch -> select -> summarize -> build -> store -> index-search
+func digestStages(opts DigestPipelineOptions, result *DigestPipelineResult) []pipeline.Stage {
+	return []pipeline.Stage{
+		{
+			Name:    "fetch",
+			Retries: 1,
+			Run: func(ctx context.Context, _ []byte) ([]byte, error) {
+				fetched, err := opts.Fetcher.FetchFromSources(ctx, opts.Sources)
+				if err != nil {
+					return nil, fmt.Errorf("failed to fetch articles: %w", err)
+				}
+				result.Fetched = len(fetched)
+				return json.Marshal(digestStageData{Articles: fetched})
+			},
+		},
+		{
+			Name: "select",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				data, err := decodeDigestStage(input)
+				if err != nil {
+					return nil, err
+				}
+
+				articles := DedupeArticles(data.Articles)
+				result.Duplicates = len(data.Articles) - len(articles)
+				articles = filterDigestWindow(articles, opts.Date, opts.Window)
+				if len(articles) == 0 {
+					return nil, fmt.Errorf("no articles available for %s", opts.Date)
+				}
+
+				// Pre-rank so only the strongest candidates cost an LLM call
+				preRanked, err := opts.Ranker.RankArticles(articles)
+				if err != nil {
+					return nil, fmt.Errorf("failed to rank articles: %w", err)
+				}
+				if len(preRanked) > opts.SummarizeTop {
+					preRanked = preRanked[:opts.SummarizeTop]
+				}
+				candidates := make([]article.ArticleData, len(preRanked))
+				for i, ranked := range preRanked {
+					candidates[i] = ranked.Article
+				}
+				result.Candidates = len(candidates)
+
+				return json.Marshal(digestStageData{Articles: articles, Candidates: candidates})
+			},
+		},
+		{
+			Name: "summarize",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				data, err := decodeDigestStage(input)
+				if err != nil {
+					return nil, err
+				}
+
+				if opts.Summarizer != nil {
+					if err := opts.Summarizer.SummarizeBatch(ctx, data.Candidates); err != nil {
+						return nil, fmt.Errorf("failed to summarize articles: %w", err)
+					}
+				}
+				for i := range data.Candidates {
+					if data.Candidates[i].Summary == "" {
+						data.Candidates[i].Summary = data.Candidates[i].OriginalSum
+					}
+				}
+				return json.Marshal(data)
+			},
+		},
+		{
+			Name: "build",
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				data, err := decodeDigestStage(input)
+				if err != nil {
+					return nil, err
+				}
+
+				builder := NewDigestBuilder(nil, opts.Ranker, opts.Summarizer)
+				digest, err := builder.BuildDigestFromArticles(data.Candidates, nil, opts.Date)
+				if err != nil {
+					return nil, fmt.Errorf("failed to build digest: %w", err)
+				}
+				data.Digest = digest
+				return json.Marshal(data)
+			},
+		},
+		{
+			Name:    "store",
+			Retries: 1,
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				data, err := decodeDigestStage(input)
+				if err != nil {
+					return nil, err
+				}
+				if data.Digest == nil {
+					return nil, fmt.Errorf("build stage produced no digest")
+				}
+
+				if err := opts.Store.SaveArticles(ctx, opts.Date, data.Articles); err != nil {
+					// The digest is what readers see; raw articles are only kept for reference
+					logger.Warn("Failed to store digest articles", map[string]interface{}{
+						"date":  opts.Date,
+						"error": err.Error(),
+					})
+				}
+				if err := opts.Store.SaveDigest(ctx, data.Digest); err != nil {
+					return nil, fmt.Errorf("failed to store digest: %w", err)
+				}
+
+				result.Status = DigestGenerated
+				result.Digest = data.Digest
+				return json.Marshal(data.Digest)
+			},
+		},
+		{
+			// Searches fall back to indexing the stored days themselves, so this can fail
+			Name:     "index-search",
+			Optional: true,
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				if err := SaveArticleSearchIndex(ctx, opts.Store, opts.Date); err != nil {
+					return nil, err
+				}
+				return input, nil
+			},
+		},
+	}
+}
+
+// broadcastStage sends the digest once per date; the date ledger, not the run ledger,
+// decides whether it was already sent, so forced regenerations and resumes never re-send
+func broadcastStage(opts DigestPipelineOptions, holder string, run *DigestRun, result *DigestPipelineResult) pipeline.Stage {
+	return pipeline.Stage{
+		Name: "broadcast",
+		Run: func(ctx context.Context, input []byte) ([]byte, error) {
+			if result.Digest == nil && len(input) > 0 {
+				var digest article.DailyDigest
+				if err := json.Unmarshal(input, &digest); err != nil {
+					return nil, fmt.Errorf("failed to decode digest: %w", err)
+				}
+				result.Digest = &digest
+			}
+			if result.Digest == nil {
+				return nil, fmt.Errorf("no digest to broadcast for %s", opts.Date)
+			}
+
+			if run.BroadcastID != "" {
+				logger.Info("Digest already broadcast for this date, not sending again", map[string]interface{}{
+					"date":        opts.Date,
+					"broadcastId": run.BroadcastID,
+				})
+				return []byte(run.BroadcastID), nil
+			}
+			if len(result.Digest.Articles) == 0 {
+				return nil, fmt.Errorf("refusing to broadcast an empty digest")
+			}
+
+			broadcastID, err := opts.Broadcaster(ctx, result.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to broadcast digest: %w", err)
+			}
+			run.BroadcastID = broadcastID
+			result.BroadcastID = broadcastID
+			result.Broadcasted = true
+
+			// Record immediately: a second send is worse than a lost status update
+			recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			if err := opts.Ledger.RecordBroadcast(recordCtx, opts.Date, holder, broadcastID); err != nil {
+				logger.Error("Digest broadcast sent but not recorded", err, map[string]interface{}{
+					"date":        opts.Date,
+					"broadcastId": broadcastID,
+				})
+			}
+			return []byte(broadcastID), nil
+		},
+	}
+}
+
+func decodeDigestStage(input []byte) (*digestStageData, error) {
+	var data digestStageData
+	if err := json.Unmarshal(input, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode digest stage input: %w", err)
+	}
+	return &data, nil
+}
+
+// DedupeArticles drops repeated articles, keeping the first occurrence
+// Articles match on canonical URL (no query, fragment or trailing slash) or on
+// normalized title, since syndicated stories appear on several feeds
+func DedupeArticles(articles []article.ArticleData) []article.ArticleData {
+	seenURLs := make(map[string]bool, len(articles))
+	seenTitles := make(map[string]bool, len(articles))
+	deduped := make([]article.ArticleData, 0, len(articles))
+
+	for _, art := range articles {
+		urlKey := canonicalArticleURL(art.URL)
+		titleKey := normalizeTitle(art.Title)
+
+		if (urlKey != "" && seenURLs[urlKey]) || (titleKey != "" && seenTitles[titleKey]) {
+			continue
+		}
+		if urlKey != "" {
+			seenURLs[urlKey] = true
+		}
+		if titleKey != "" {
+			seenTitles[titleKey] = true
+		}
+		deduped = append(deduped, art)
+	}
+
+	return deduped
+}
+
+// canonicalArticleURL lowercases the host and strips scheme, www, query, fragment and trailing slash
+func canonicalArticleURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSpace(rawURL)
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	return host + strings.TrimSuffix(parsed.EscapedPath(), "/")
+}
+
+// normalizeTitle keeps lowercase letters and digits separated by single spaces
+func normalizeTitle(title string) string {
+	fields := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// filterDigestWindow keeps articles published within window before the end of date
+// Articles with an unparseable date are kept; the ranker scores them as not recent
+func filterDigestWindow(articles []article.ArticleData, date string, window time.Duration) []article.ArticleData {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return articles
+	}
+	end := day.Add(24 * time.Hour)
+	start := end.Add(-window)
+
+	kept := make([]article.ArticleData, 0, len(articles))
+	for _, art := range articles {
+		published, err := time.Parse(time.RFC3339, art.PublishedDate)
+		if err != nil || (published.After(start) && !published.After(end)) {
+			kept = append(kept, art)
+		}
+	}
+	return kept
+}
+
+// newRunHolder returns a random ID identifying this run's lease
+func newRunHolder() string {
+	bytes := make([]byte, 8)
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
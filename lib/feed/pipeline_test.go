@@ -0,0 +1,301 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/lib/article"
+	"main/lib/pipeline"
+	"testing"
+	"time"
+)
+
+// fakeArticleSource returns a fixed set of articles and counts fetches
+type fakeArticleSource struct {
+	articles []article.ArticleData
+	calls    int
+}
+
+func (f *fakeArticleSource) FetchFromSources(ctx context.Context, sources []*NewsSource) ([]article.ArticleData, error) {
+	f.calls++
+	return f.articles, nil
+}
+
+// fakeBroadcaster records every digest it is asked to send
+type fakeBroadcaster struct {
+	sent []string
+}
+
+func (f *fakeBroadcaster) send(ctx context.Context, digest *article.DailyDigest) (string, error) {
+	f.sent = append(f.sent, digest.Date)
+	return fmt.Sprintf("bc-%d", len(f.sent)), nil
+}
+
+// flakyDigestStore fails SaveDigest while failures > 0
+type flakyDigestStore struct {
+	*MemoryDigestStore
+	failures int
+}
+
+func (s *flakyDigestStore) SaveDigest(ctx context.Context, digest *article.DailyDigest) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("blob store unavailable")
+	}
+	return s.MemoryDigestStore.SaveDigest(ctx, digest)
+}
+
+func pipelineArticles(date string) []article.ArticleData {
+	day, _ := time.Parse("2006-01-02", date)
+	published := day.Add(10 * time.Hour).Format(time.RFC3339)
+	return []article.ArticleData{
+		{ID: "1", Title: "UKGC fines operator over AML failures", URL: "https://www.example.com/ukgc-fine", OriginalSum: "Regulator fine", SourceID: "igamingbusiness", PublishedDate: published},
+		{ID: "2", Title: "New sports betting market opens", URL: "https://example.com/sports-betting", OriginalSum: "Market launch", SourceID: "igamingbusiness", PublishedDate: published},
+		{ID: "3", Title: "UKGC fines operator over AML failures", URL: "https://other.com/syndicated", OriginalSum: "Syndicated copy", SourceID: "igamingbusiness", PublishedDate: published},
+		{ID: "4", Title: "Operator posts record revenue", URL: "https://example.com/ukgc-fine/?utm_source=rss", OriginalSum: "Same story, tracking URL", SourceID: "igamingbusiness", PublishedDate: published},
+		{ID: "5", Title: "Last month's casino news", URL: "https://example.com/old", OriginalSum: "Too old", SourceID: "igamingbusiness", PublishedDate: day.AddDate(0, -1, 0).Format(time.RFC3339)},
+	}
+}
+
+func newTestPipeline(date string) (DigestPipelineOptions, *fakeArticleSource, *fakeBroadcaster, *MemoryDigestStore, *MemoryDigestLedger) {
+	source := &fakeArticleSource{articles: pipelineArticles(date)}
+	broadcaster := &fakeBroadcaster{}
+	store := NewMemoryDigestStore()
+	ledger := NewMemoryDigestLedger()
+
+	opts := DigestPipelineOptions{
+		Date:        date,
+		Fetcher:     source,
+		Ranker:      NewRankingEngine(article.NewRankingCriteria(), nil),
+		Store:       store,
+		Ledger:      ledger,
+		Broadcaster: broadcaster.send,
+	}
+	return opts, source, broadcaster, store, ledger
+}
+
+func TestDedupeArticles(t *testing.T) {
+	articles := pipelineArticles("2025-01-15")
+	deduped := DedupeArticles(articles)
+
+	if len(deduped) != 3 {
+		t.Fatalf("DedupeArticles() kept %d articles; expected 3", len(deduped))
+	}
+	for _, art := range deduped {
+		if art.ID == "3" || art.ID == "4" {
+			t.Errorf("Expected duplicate article %s to be dropped", art.ID)
+		}
+	}
+}
+
+func TestRunDigestPipelineGeneratesThenReuses(t *testing.T) {
+	date := "2025-01-15"
+	opts, source, _, store, ledger := newTestPipeline(date)
+
+	result, err := RunDigestPipeline(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("RunDigestPipeline() error = %v", err)
+	}
+	if result.Status != DigestGenerated {
+		t.Errorf("Status = %q; expected %q", result.Status, DigestGenerated)
+	}
+	if result.Fetched != 5 || result.Duplicates != 2 || result.Candidates != 2 {
+		t.Errorf("Fetched/Duplicates/Candidates = %d/%d/%d; expected 5/2/2", result.Fetched, result.Duplicates, result.Candidates)
+	}
+	if result.Digest == nil || result.Digest.Date != date || len(result.Digest.Articles) != 2 {
+		t.Fatalf("Unexpected digest: %+v", result.Digest)
+	}
+	if stored, _ := store.GetDigest(context.Background(), date); stored == nil {
+		t.Error("Expected digest to be stored")
+	}
+	if stored, _ := store.GetArticles(context.Background(), date); len(stored) != 2 {
+		t.Errorf("Stored %d articles; expected 2 after dedupe and window filter", len(stored))
+	}
+	if run := ledger.Run(date); run == nil || run.Status != DigestRunCompleted || run.ArticleCount != 2 {
+		t.Errorf("Unexpected ledger run: %+v", run)
+	}
+
+	result, err = RunDigestPipeline(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Second RunDigestPipeline() error = %v", err)
+	}
+	if result.Status != DigestExisting {
+		t.Errorf("Second run status = %q; expected %q", result.Status, DigestExisting)
+	}
+	if source.calls != 1 {
+		t.Errorf("Fetched %d times; expected the stored digest to be reused", source.calls)
+	}
+
+	opts.Force = true
+	result, err = RunDigestPipeline(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Forced RunDigestPipeline() error = %v", err)
+	}
+	if result.Status != DigestGenerated || source.calls != 2 {
+		t.Errorf("Force should regenerate: status = %q, fetches = %d", result.Status, source.calls)
+	}
+}
+
+func TestRunDigestPipelineBroadcastsOnce(t *testing.T) {
+	date := "2025-01-15"
+	opts, _, broadcaster, _, ledger := newTestPipeline(date)
+	opts.Broadcast = true
+
+	result, err := RunDigestPipeline(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("RunDigestPipeline() error = %v", err)
+	}
+	if !result.Broadcasted || result.BroadcastID != "bc-1" {
+		t.Errorf("Expected first run to broadcast, got broadcasted=%v id=%q", result.Broadcasted, result.BroadcastID)
+	}
+	if run := ledger.Run(date); run.BroadcastID != "bc-1" {
+		t.Errorf("Ledger broadcast ID = %q; expected bc-1", run.BroadcastID)
+	}
+
+	// A duplicate cron delivery and a forced regeneration must not send again
+	for _, force := range []bool{false, true} {
+		opts.Force = force
+		result, err := RunDigestPipeline(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("RunDigestPipeline(force=%v) error = %v", force, err)
+		}
+		if result.Broadcasted {
+			t.Errorf("RunDigestPipeline(force=%v) broadcast again", force)
+		}
+		if result.BroadcastID != "bc-1" {
+			t.Errorf("RunDigestPipeline(force=%v) broadcast ID = %q; expected bc-1", force, result.BroadcastID)
+		}
+	}
+
+	if len(broadcaster.sent) != 1 {
+		t.Errorf("Sent %d broadcasts; expected exactly 1", len(broadcaster.sent))
+	}
+}
+
+func TestRunDigestPipelineLocked(t *testing.T) {
+	date := "2025-01-15"
+	opts, source, _, _, ledger := newTestPipeline(date)
+
+	if _, err := ledger.Acquire(context.Background(), date, "other-run", time.Minute); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	_, err := RunDigestPipeline(context.Background(), opts)
+	if !errors.Is(err, ErrDigestLocked) {
+		t.Fatalf("RunDigestPipeline() error = %v; expected ErrDigestLocked", err)
+	}
+	if source.calls != 0 {
+		t.Error("Expected a locked run not to fetch")
+	}
+
+	// An expired lease can be taken over
+	if _, err := ledger.Acquire(context.Background(), "2025-01-16", "other-run", -time.Second); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	opts.Date = "2025-01-16"
+	source.articles = pipelineArticles(opts.Date)
+	if _, err := RunDigestPipeline(context.Background(), opts); err != nil {
+		t.Errorf("RunDigestPipeline() after expired lease error = %v", err)
+	}
+}
+
+func TestRunDigestPipelineRefusesEmptyBroadcast(t *testing.T) {
+	date := "2025-01-15"
+	opts, _, broadcaster, store, ledger := newTestPipeline(date)
+	opts.Broadcast = true
+
+	if err := store.SaveDigest(context.Background(), &article.DailyDigest{Date: date}); err != nil {
+		t.Fatalf("SaveDigest() error = %v", err)
+	}
+	if _, err := ledger.Acquire(context.Background(), date, "seed", time.Minute); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if err := ledger.Release(context.Background(), "seed", &DigestRun{Date: date, Status: DigestRunCompleted}); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	_, err := RunDigestPipeline(context.Background(), opts)
+	if err == nil {
+		t.Fatal("Expected an empty digest broadcast to be refused")
+	}
+	if len(broadcaster.sent) != 0 {
+		t.Errorf("Sent %d broadcasts; expected none", len(broadcaster.sent))
+	}
+	if run := ledger.Run(date); run.Status != DigestRunFailed {
+		t.Errorf("Ledger status = %q; expected %q", run.Status, DigestRunFailed)
+	}
+}
+
+func TestRunDigestPipelineInvalidOptions(t *testing.T) {
+	opts, _, _, _, _ := newTestPipeline("15-01-2025")
+	if _, err := RunDigestPipeline(context.Background(), opts); err == nil {
+		t.Error("Expected invalid date to fail")
+	}
+
+	opts, _, _, _, _ = newTestPipeline("2025-01-15")
+	opts.Broadcast = true
+	opts.Broadcaster = nil
+	if _, err := RunDigestPipeline(context.Background(), opts); err == nil {
+		t.Error("Expected broadcast without a broadcaster to fail")
+	}
+}
+
+func TestRunDigestPipelineResumesFailedStage(t *testing.T) {
+	date := "2025-01-15"
+	opts, source, _, memory, _ := newTestPipeline(date)
+	store := &flakyDigestStore{MemoryDigestStore: memory, failures: 2}
+	runner := pipeline.NewRunner(pipeline.NewMemoryStore())
+	runner.RetryBackoff = time.Millisecond
+	opts.Store = store
+	opts.Runs = runner
+
+	result, err := RunDigestPipeline(context.Background(), opts)
+	if err == nil {
+		t.Fatal("Expected the store stage to fail")
+	}
+	failed, err := runner.Store().GetRun(context.Background(), result.RunID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if failed.FailedStage() != "store" || failed.Stage("store").Attempts != 2 {
+		t.Errorf("Failed run = %+v; expected store to fail after 2 attempts", failed.Stages)
+	}
+	if failed.Params["date"] != date {
+		t.Errorf("Params = %v; expected the date", failed.Params)
+	}
+
+	opts.ResumeRunID = failed.ID
+	result, err = RunDigestPipeline(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Resumed RunDigestPipeline() error = %v", err)
+	}
+	if source.calls != 1 {
+		t.Errorf("Fetched %d times; expected the resume to reuse the fetch output", source.calls)
+	}
+	if result.Status != DigestGenerated || result.Digest == nil || len(result.Digest.Articles) != 2 {
+		t.Errorf("Resumed result = %+v; expected the generated digest", result)
+	}
+	if stored, _ := memory.GetDigest(context.Background(), date); stored == nil {
+		t.Error("Expected the resumed run to store the digest")
+	}
+}
+
+func TestFilterDigestWindow(t *testing.T) {
+	articles := []article.ArticleData{
+		{ID: "in", PublishedDate: "2025-01-15T08:00:00Z"},
+		{ID: "yesterday", PublishedDate: "2025-01-14T08:00:00Z"},
+		{ID: "too-old", PublishedDate: "2025-01-12T08:00:00Z"},
+		{ID: "future", PublishedDate: "2025-01-16T08:00:00Z"},
+		{ID: "undated", PublishedDate: "not a date"},
+	}
+
+	kept := filterDigestWindow(articles, "2025-01-15", 48*time.Hour)
+	var ids []string
+	for _, art := range kept {
+		ids = append(ids, art.ID)
+	}
+	expected := []string{"in", "yesterday", "undated"}
+	if fmt.Sprint(ids) != fmt.Sprint(expected) {
+		t.Errorf("filterDigestWindow() kept %v; expected %v", ids, expected)
+	}
+}
@@ -0,0 +1,194 @@
+// Package jobs wires the scheduled pipelines (TLDR cache update, daily broadcast,
+// iGaming digest) to the pipeline run ledger, so API handlers and scripts start,
+// inspect and resume them the same way
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"main/lib/article"
+	"main/lib/broadcast"
+	"main/lib/feed"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/paper"
+	"main/lib/pipeline"
+	"main/lib/rss"
+	"main/lib/subscribe"
+	"main/lib/summary"
+	"os"
+	"sync"
+)
+
+const defaultSummarizerModel = "claude-3-5-sonnet-20241022"
+
+var (
+	runStore     pipeline.Store
+	runStoreOnce sync.Once
+
+	sendLedger     broadcast.SendLedger
+	sendLedgerOnce sync.Once
+)
+
+// RunStore returns the process-wide run ledger: Postgres when the vector database is
+// reachable, else Vercel Blob when BLOB_READ_WRITE_TOKEN is set, else memory
+func RunStore() pipeline.Store {
+	runStoreOnce.Do(func() {
+		err := paper.InitDB()
+		switch {
+		case err == nil:
+			runStore = pipeline.NewPostgresStore(paper.GetDB())
+		case os.Getenv("BLOB_READ_WRITE_TOKEN") != "":
+			logger.Warn("Database unavailable, recording pipeline runs in blob storage", map[string]interface{}{
+				"error": err.Error(),
+			})
+			runStore = pipeline.NewBlobStore()
+		default:
+			logger.Warn("No database or blob storage, pipeline runs are kept in memory only", map[string]interface{}{
+				"error": err.Error(),
+			})
+			runStore = pipeline.NewMemoryStore()
+		}
+	})
+	return runStore
+}
+
+// Runner returns a runner recording into RunStore
+func Runner() *pipeline.Runner {
+	return pipeline.NewRunner(RunStore())
+}
+
+// SendLedger returns the process-wide broadcast send ledger: Postgres when the database is
+// reachable, else memory, which only stops duplicate sends within one instance
+func SendLedger() broadcast.SendLedger {
+	sendLedgerOnce.Do(func() {
+		if err := paper.InitDB(); err != nil {
+			logger.Warn("Database unavailable, broadcast sends are not deduplicated across instances", map[string]interface{}{
+				"error": err.Error(),
+			})
+			sendLedger = broadcast.NewMemorySendLedger()
+			return
+		}
+		sendLedger = broadcast.NewPostgresSendLedger(paper.GetDB())
+	})
+	return sendLedger
+}
+
+// BroadcastOptions builds the production options for the daily and weekly broadcasts:
+// the SendLedger, and the freshness policy from the environment regenerating a stale feed
+// through the update-cache pipeline. Callers set Force and DryRun
+func BroadcastOptions() broadcast.SendOptions {
+	freshness := broadcast.FreshnessPolicyFromEnv()
+	freshness.Regenerate = func(ctx context.Context) error {
+		_, err := summary.NewService().UpdateCache(ctx, Runner(), rss.BaseURL()+"/api/tldr")
+		return err
+	}
+	return broadcast.SendOptions{Ledger: SendLedger(), Freshness: &freshness}
+}
+
+// DigestOptions builds the production digest pipeline: default sources, the Claude
+// summarizer when CLAUDE_API_KEY is set, blob digest storage, the Postgres date ledger
+// and the digest broadcaster (mail.Default(), with personal digests for the subscribers in
+// subscribe.DefaultStore()). Callers set Date, Force and Broadcast
+func DigestOptions() feed.DigestPipelineOptions {
+	sourceMgr := feed.NewSourceManager()
+	sourceMgr.LoadDefaultSources()
+
+	return feed.DigestPipelineOptions{
+		Sources:     sourceMgr.GetActiveSources(),
+		Fetcher:     feed.NewArticleFetcher(feed.DefaultFetcherConfig()),
+		Ranker:      feed.NewRankingEngine(article.NewRankingCriteria(), sourceMgr),
+		Summarizer:  newSummarizer(),
+		Store:       feed.NewBlobDigestStore(),
+		Ledger:      newDigestLedger(),
+		Broadcaster: broadcast.NewDigestBroadcaster(mail.Default(), subscribe.DefaultStore()),
+		Runs:        Runner(),
+	}
+}
+
+// Resume re-runs a recorded run from a stage (empty: its first failed stage) with the
+// same parameters, returning the new run
+func Resume(ctx context.Context, runID, fromStage string) (*pipeline.Run, error) {
+	runner := Runner()
+	parent, err := runner.Store().GetRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent.Pipeline {
+	case summary.UpdateCachePipeline:
+		return runner.Resume(ctx, runID, fromStage, summary.NewService().UpdateCacheStages(parent.Params["requestURL"]))
+
+	case broadcast.DailyBroadcastPipeline:
+		opts := BroadcastOptions()
+		opts.Force, opts.DryRun = broadcast.SendFlagsFromParams(parent.Params)
+		return runner.Resume(ctx, runID, fromStage, broadcast.DailyBroadcastStages(mail.Default(), opts))
+
+	case broadcast.WeeklyBroadcastPipeline:
+		opts := BroadcastOptions()
+		opts.Force, opts.DryRun = broadcast.SendFlagsFromParams(parent.Params)
+		return runner.Resume(ctx, runID, fromStage, broadcast.WeeklyBroadcastStages(mail.Default(), parent.Params["weekEnding"], opts))
+
+	case feed.DigestPipeline:
+		// Digest runs resume through RunDigestPipeline so they hold the date lease
+		opts := DigestOptions()
+		opts.Runs = runner
+		opts.Date = parent.Params["date"]
+		opts.Broadcast = parent.Params["broadcast"] == "true"
+		opts.ResumeRunID = runID
+		opts.ResumeFrom = fromStage
+
+		result, runErr := feed.RunDigestPipeline(ctx, opts)
+		if result == nil || result.RunID == "" {
+			return nil, runErr
+		}
+		run, err := runner.Store().GetRun(ctx, result.RunID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load resumed run: %w", err)
+		}
+		return run, runErr
+
+	default:
+		return nil, fmt.Errorf("unknown pipeline %q", parent.Pipeline)
+	}
+}
+
+// newDigestLedger returns the Postgres date ledger, or an in-process one when the database is unavailable
+// The in-process ledger only guards a single instance, so duplicate deliveries may both broadcast
+func newDigestLedger() feed.DigestLedger {
+	if err := paper.InitDB(); err != nil {
+		logger.Warn("Database unavailable, digest runs are not deduplicated across instances", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return feed.NewMemoryDigestLedger()
+	}
+	return feed.NewPostgresDigestLedger(paper.GetDB())
+}
+
+// newSummarizer returns the Claude summarizer, or nil when CLAUDE_API_KEY is unset
+func newSummarizer() *feed.ArticleSummarizer {
+	apiKey := os.Getenv("CLAUDE_API_KEY")
+	if apiKey == "" {
+		logger.Warn("CLAUDE_API_KEY not set, digest will use fallback summaries", nil)
+		return nil
+	}
+
+	model := os.Getenv("CLAUDE_MODEL")
+	if model == "" {
+		model = defaultSummarizerModel
+	}
+	summarizer, err := feed.NewArticleSummarizer(&feed.SummarizerConfig{
+		APIKey:      apiKey,
+		Model:       model,
+		MaxTokens:   150,
+		Temperature: 0.7,
+		TimeoutSec:  30,
+	})
+	if err != nil {
+		logger.Warn("Failed to initialize summarizer", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return summarizer
+}
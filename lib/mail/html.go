@@ -0,0 +1,261 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// MaxHTMLSize is where Gmail clips a message behind "[Message clipped]", hiding the
+// rest of it, unsubscribe link included
+const MaxHTMLSize = 102 * 1024
+
+// LintError lists the ways an HTML body breaks email client constraints
+type LintError struct {
+	Problems []string
+}
+
+func (e *LintError) Error() string {
+	return "email HTML fails client checks: " + strings.Join(e.Problems, "; ")
+}
+
+var (
+	// externalFontRegex matches the usual ways of loading a web font
+	externalFontRegex = regexp.MustCompile(`(?i)@font-face|@import|fonts\.googleapis\.com|fonts\.gstatic\.com|use\.typekit\.net|\.(woff2?|ttf|otf|eot)\b`)
+	// anchorRegex matches opening <a> tags
+	anchorRegex = regexp.MustCompile(`(?i)<a(\s[^>]*)?>`)
+	// styleAttrRegex matches a style attribute
+	styleAttrRegex = regexp.MustCompile(`(?i)\sstyle\s*=`)
+)
+
+// Lint checks an email body against what clients actually render: CSS must be inline
+// (Gmail and Outlook drop or mangle <style> blocks and stylesheets), no web fonts, alt
+// text on every image, no scripts, and no more than MaxHTMLSize bytes. It returns a
+// *LintError listing every problem, or nil.
+func Lint(body string) error {
+	var problems []string
+	seen := make(map[string]bool)
+	report := func(format string, args ...interface{}) {
+		problem := fmt.Sprintf(format, args...)
+		if !seen[problem] {
+			seen[problem] = true
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(body) > MaxHTMLSize {
+		report("%d bytes is over Gmail's %d byte clipping limit", len(body), MaxHTMLSize)
+	}
+
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	inStyle := false
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "style":
+				report("<style> block: CSS must be inline")
+				inStyle = tt == html.StartTagToken
+			case "link":
+				href := attr(token, "href")
+				if externalFontRegex.MatchString(href) {
+					report("external font %s", href)
+				} else if strings.EqualFold(attr(token, "rel"), "stylesheet") {
+					report("external stylesheet %s", href)
+				}
+			case "script":
+				report("<script> is stripped by email clients")
+			case "img":
+				if !hasAttr(token, "alt") {
+					report("image without alt text: %s", attr(token, "src"))
+				}
+			}
+			if style := attr(token, "style"); externalFontRegex.MatchString(style) {
+				report("external font in inline style on <%s>", token.Data)
+			}
+		case html.EndTagToken:
+			if token.Data == "style" {
+				inStyle = false
+			}
+		case html.TextToken:
+			if inStyle && externalFontRegex.MatchString(token.Data) {
+				report("external font in <style> block")
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &LintError{Problems: problems}
+	}
+	return nil
+}
+
+// StyleLinks adds style to every <a> in an HTML fragment that has no style of its own.
+// Feed content comes with bare links, and there is no stylesheet to color them.
+func StyleLinks(fragment, style string) string {
+	return anchorRegex.ReplaceAllStringFunc(fragment, func(tag string) string {
+		if styleAttrRegex.MatchString(tag) {
+			return tag
+		}
+		return tag[:2] + ` style="` + html.EscapeString(style) + `"` + tag[2:]
+	})
+}
+
+// blockElements start and end on their own paragraph in the plain-text rendering
+var blockElements = map[string]bool{
+	"p": true, "div": true, "table": true, "tr": true, "ul": true, "ol": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "footer": true, "section": true, "article": true,
+}
+
+// PlainText renders an HTML email as its plain-text alternative: paragraphs and table
+// rows become blank-line separated blocks, list items become "- " lines, and links keep
+// their URL in parentheses after the text
+func PlainText(body string) string {
+	var out bytes.Buffer
+	// breakLines ends the output with at least n newlines (none at the very start)
+	breakLines := func(n int) {
+		if out.Len() == 0 {
+			return
+		}
+		trailing := len(out.Bytes()) - len(bytes.TrimRight(out.Bytes(), "\n"))
+		for ; trailing < n; trailing++ {
+			out.WriteByte('\n')
+		}
+	}
+
+	type openLink struct {
+		href  string
+		start int
+	}
+	var links []openLink
+	skip := 0 // depth inside elements whose text isn't content
+
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch name := token.Data; {
+			case name == "head" || name == "style" || name == "script" || name == "title":
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case name == "br":
+				out.WriteByte('\n')
+			case name == "hr":
+				breakLines(2)
+				out.WriteString("--")
+				breakLines(2)
+			case name == "li":
+				breakLines(1)
+				out.WriteString("- ")
+			case name == "td" || name == "th":
+				if b := out.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' && b[len(b)-1] != ' ' {
+					out.WriteByte(' ')
+				}
+			case name == "a" && tt == html.StartTagToken:
+				links = append(links, openLink{href: attr(token, "href"), start: out.Len()})
+			case blockElements[name]:
+				breakLines(2)
+			}
+		case html.EndTagToken:
+			switch name := token.Data; {
+			case name == "head" || name == "style" || name == "script" || name == "title":
+				if skip > 0 {
+					skip--
+				}
+			case name == "a" && len(links) > 0:
+				link := links[len(links)-1]
+				links = links[:len(links)-1]
+				text := strings.TrimSpace(out.String()[link.start:])
+				href := strings.TrimPrefix(link.href, "mailto:")
+				if text != "" && href != "" && !strings.HasPrefix(href, "#") && !sameLink(text, href) {
+					fmt.Fprintf(&out, " (%s)", href)
+				}
+			case name == "li":
+				breakLines(1)
+			case blockElements[name]:
+				breakLines(2)
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := strings.Join(strings.Fields(token.Data), " ")
+			if text == "" {
+				// Whitespace between inline elements still separates words
+				if b := out.Bytes(); len(token.Data) > 0 && len(b) > 0 && b[len(b)-1] != '\n' && b[len(b)-1] != ' ' {
+					out.WriteByte(' ')
+				}
+				continue
+			}
+			b := out.Bytes()
+			atLineStart := len(b) == 0 || b[len(b)-1] == '\n' || b[len(b)-1] == ' '
+			if !atLineStart && startsWithSpace(token.Data) {
+				out.WriteByte(' ')
+			}
+			out.WriteString(text)
+			if endsWithSpace(token.Data) {
+				out.WriteByte(' ')
+			}
+		}
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text := strings.Join(lines, "\n")
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	return strings.TrimSpace(text) + "\n"
+}
+
+// sameLink reports whether a link's text already spells out its URL, scheme aside
+func sameLink(text, href string) bool {
+	trim := func(s string) string {
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "http://")
+		return strings.TrimSuffix(s, "/")
+	}
+	return trim(text) == trim(href)
+}
+
+func startsWithSpace(s string) bool {
+	return s != "" && strings.TrimLeft(s, " \t\r\n") != s
+}
+
+func endsWithSpace(s string) bool {
+	return s != "" && strings.TrimRight(s, " \t\r\n") != s
+}
+
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(token html.Token, name string) bool {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return true
+		}
+	}
+	return false
+}
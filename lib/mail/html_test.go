@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPlainText(t *testing.T) {
+	body := `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8" /><title>Ignored</title></head>
+<body>
+    <table><tr>
+        <td><a href="https://tldr.takara.ai"><span>tldr.</span><span>takara.ai</span></a></td>
+    </tr></table>
+    <h1>
+        TLDR: January 15, 2025
+    </h1>
+    <p>Small models <b>beat</b> larger ones &amp; cost less.</p>
+    <hr />
+    <ul>
+        <li><a href="https://tldr.takara.ai/p/2501.00001">Small Models</a> win.</li>
+        <li>Agents plan.</li>
+    </ul>
+    <a href="https://takara.ai"><img src="https://tldr.takara.ai/icon.svg" alt="Logo" /></a>
+    <p>Line one<br />line two. <a href="mailto:hi@takara.ai">hi@takara.ai</a></p>
+</body>
+</html>`
+
+	want := `tldr.takara.ai
+
+TLDR: January 15, 2025
+
+Small models beat larger ones & cost less.
+
+--
+
+- Small Models (https://tldr.takara.ai/p/2501.00001) win.
+- Agents plan.
+
+Line one
+line two. hi@takara.ai
+`
+	if got := PlainText(body); got != want {
+		t.Errorf("PlainText() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLint(t *testing.T) {
+	clean := `<html><head><meta charset="utf-8" /></head><body style="font-family: 'Lato', sans-serif;">` +
+		`<a href="https://tldr.takara.ai" style="color: rgb(217, 16, 9);">TLDR</a><img src="a.png" alt="" /></body></html>`
+	if err := Lint(clean); err != nil {
+		t.Errorf("Lint(clean) = %v", err)
+	}
+
+	bad := `<html><head>` +
+		`<link rel="stylesheet" href="https://fonts.googleapis.com/css?family=Lato" />` +
+		`<link rel="stylesheet" href="https://example.com/mail.css" />` +
+		`<style>@import url(https://example.com/x.css); a { color: red; }</style>` +
+		`<script>track()</script></head>` +
+		`<body><img src="https://example.com/a.png" /><img src="https://example.com/a.png" /></body></html>`
+	err := Lint(bad)
+	var lintErr *LintError
+	if !errors.As(err, &lintErr) {
+		t.Fatalf("Lint(bad) = %v, want a *LintError", err)
+	}
+	want := []string{
+		"external font https://fonts.googleapis.com/css?family=Lato",
+		"external stylesheet https://example.com/mail.css",
+		"<style> block: CSS must be inline",
+		"external font in <style> block",
+		"<script> is stripped by email clients",
+		"image without alt text: https://example.com/a.png",
+	}
+	if strings.Join(lintErr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Problems =\n%s\nwant\n%s", strings.Join(lintErr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := Lint(clean + strings.Repeat(" ", MaxHTMLSize)); err == nil || !strings.Contains(err.Error(), "clipping limit") {
+		t.Errorf("Lint(oversized) = %v, want the size problem", err)
+	}
+}
+
+func TestStyleLinks(t *testing.T) {
+	got := StyleLinks(`<p><a href="https://a.test">A</a>, <A HREF="https://b.test" style="color: blue">B</A>, <abbr>C</abbr></p>`, "color: red;")
+	want := `<p><a style="color: red;" href="https://a.test">A</a>, <A HREF="https://b.test" style="color: blue">B</A>, <abbr>C</abbr></p>`
+	if got != want {
+		t.Errorf("StyleLinks() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// localAudiences keeps audiences and broadcasts in memory for the backends without
+// an audience API of their own (SMTP and the outbox). Broadcasts become one message
+// per subscribed contact, sent with the backend's Send, each with the contact's own
+// unsubscribe link when the broadcast has one.
+type localAudiences struct {
+	mu         sync.Mutex
+	contacts   map[string]map[string]Contact // audience -> lowercased email -> contact
+	broadcasts map[string]*localBroadcast
+	seq        int
+}
+
+type localBroadcast struct {
+	Broadcast
+	sent      bool
+	sending   bool
+	delivered map[string]bool // Recipients already sent to, skipped when a failed send is retried
+}
+
+func newLocalAudiences() *localAudiences {
+	return &localAudiences{
+		contacts:   make(map[string]map[string]Contact),
+		broadcasts: make(map[string]*localBroadcast),
+	}
+}
+
+// CreateBroadcast stores the broadcast
+func (l *localAudiences) CreateBroadcast(ctx context.Context, broadcast Broadcast) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	id := fmt.Sprintf("broadcast-%d", l.seq)
+	l.broadcasts[id] = &localBroadcast{Broadcast: broadcast, delivered: make(map[string]bool)}
+	return id, nil
+}
+
+// sendBroadcast delivers one message per subscribed contact. The broadcast is only marked
+// sent once every contact has it; after a failure, sending again picks up where it stopped.
+func (l *localAudiences) sendBroadcast(ctx context.Context, broadcastID string, send func(context.Context, Message) (string, error)) error {
+	l.mu.Lock()
+	broadcast, ok := l.broadcasts[broadcastID]
+	if !ok {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrBroadcastNotFound, broadcastID)
+	}
+	if broadcast.sent {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrBroadcastSent, broadcastID)
+	}
+	if broadcast.sending {
+		l.mu.Unlock()
+		return fmt.Errorf("broadcast %s is already being sent", broadcastID)
+	}
+	broadcast.sending = true
+	var recipients []string
+	for _, contact := range l.sortedContacts(broadcast.AudienceID) {
+		if !contact.Unsubscribed && !broadcast.delivered[strings.ToLower(contact.Email)] {
+			recipients = append(recipients, contact.Email)
+		}
+	}
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		broadcast.sending = false
+		l.mu.Unlock()
+	}()
+
+	for _, to := range recipients {
+		msg := Message{
+			From:    broadcast.From,
+			To:      []string{to},
+			Subject: broadcast.Subject,
+			HTML:    broadcast.HTML,
+			Text:    broadcast.Text,
+		}
+		if broadcast.UnsubscribeURL != nil {
+			link, err := broadcast.UnsubscribeURL(to)
+			if err != nil {
+				return fmt.Errorf("failed to create unsubscribe link for %s: %w", to, err)
+			}
+			msg = WithUnsubscribeLink(msg, link)
+		}
+		if _, err := send(ctx, msg); err != nil {
+			return fmt.Errorf("failed to send broadcast %s to %s: %w", broadcastID, to, err)
+		}
+		l.mu.Lock()
+		broadcast.delivered[strings.ToLower(to)] = true
+		l.mu.Unlock()
+	}
+
+	l.mu.Lock()
+	broadcast.sent = true
+	l.mu.Unlock()
+	return nil
+}
+
+// UpsertContact adds or updates a contact
+func (l *localAudiences) UpsertContact(ctx context.Context, audienceID string, contact Contact) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	audience, ok := l.contacts[audienceID]
+	if !ok {
+		audience = make(map[string]Contact)
+		l.contacts[audienceID] = audience
+	}
+	key := strings.ToLower(contact.Email)
+	if _, exists := audience[key]; !exists && contact.Unsubscribed {
+		return fmt.Errorf("contact %s is not in audience %s", contact.Email, audienceID)
+	}
+	audience[key] = contact
+	return nil
+}
+
+// ListContacts returns the audience's contacts sorted by address
+func (l *localAudiences) ListContacts(ctx context.Context, audienceID string) ([]Contact, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sortedContacts(audienceID), nil
+}
+
+// sortedContacts lists an audience; the caller holds mu
+func (l *localAudiences) sortedContacts(audienceID string) []Contact {
+	contacts := make([]Contact, 0, len(l.contacts[audienceID]))
+	for _, contact := range l.contacts[audienceID] {
+		contacts = append(contacts, contact)
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].Email < contacts[j].Email })
+	return contacts
+}
@@ -0,0 +1,146 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"html"
+	"main/lib/logger"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResendUnsubscribePlaceholder is the Resend merge tag for its managed unsubscribe link.
+// Resend fills it in per contact; the local backends put in Broadcast.UnsubscribeURL.
+const ResendUnsubscribePlaceholder = "{{{RESEND_UNSUBSCRIBE_URL}}}"
+
+var (
+	// ErrBroadcastNotFound is returned when sending a broadcast that was never created
+	ErrBroadcastNotFound = errors.New("broadcast not found")
+	// ErrBroadcastSent is returned when sending a broadcast a second time
+	ErrBroadcastSent = errors.New("broadcast already sent")
+)
+
+// Message is a single email
+type Message struct {
+	From    string            `json:"from"`
+	To      []string          `json:"to"`
+	Subject string            `json:"subject"`
+	HTML    string            `json:"html"`
+	Text    string            `json:"text,omitempty"`    // Plain-text alternative
+	Headers map[string]string `json:"headers,omitempty"` // Extra headers, e.g. List-Unsubscribe
+}
+
+// Broadcast is one email sent to every subscribed contact of an audience
+type Broadcast struct {
+	Name       string `json:"name,omitempty"`
+	AudienceID string `json:"audienceId"`
+	From       string `json:"from"`
+	Subject    string `json:"subject"`
+	HTML       string `json:"html"`
+	Text       string `json:"text,omitempty"`
+
+	// UnsubscribeURL returns a contact's own unsubscribe link. The backends that send one
+	// message per contact put it in place of ResendUnsubscribePlaceholder and in the
+	// List-Unsubscribe headers; Resend uses its managed link instead.
+	UnsubscribeURL func(email string) (string, error) `json:"-"`
+}
+
+// UnsubscribeHeaders returns the RFC 2369 List-Unsubscribe and RFC 8058 List-Unsubscribe-Post
+// headers for an unsubscribe link, so mail clients can offer one-click unsubscribe
+func UnsubscribeHeaders(unsubscribeURL string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// WithUnsubscribeLink returns msg, sent to one reader, with their unsubscribe link in place
+// of ResendUnsubscribePlaceholder and the one-click unsubscribe headers set
+func WithUnsubscribeLink(msg Message, unsubscribeURL string) Message {
+	msg.HTML = strings.ReplaceAll(msg.HTML, ResendUnsubscribePlaceholder, html.EscapeString(unsubscribeURL))
+	msg.Text = strings.ReplaceAll(msg.Text, ResendUnsubscribePlaceholder, unsubscribeURL)
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	for name, value := range UnsubscribeHeaders(unsubscribeURL) {
+		headers[name] = value
+	}
+	msg.Headers = headers
+	return msg
+}
+
+// Contact is an address in an audience
+type Contact struct {
+	Email        string `json:"email"`
+	Unsubscribed bool   `json:"unsubscribed"`
+}
+
+// Mailer sends email and manages the audiences broadcasts go to
+type Mailer interface {
+	// Send sends a single message and returns its ID
+	Send(ctx context.Context, msg Message) (string, error)
+	// CreateBroadcast stores a broadcast without sending it and returns its ID
+	CreateBroadcast(ctx context.Context, broadcast Broadcast) (string, error)
+	// SendBroadcast sends a created broadcast to the audience's subscribed contacts
+	SendBroadcast(ctx context.Context, broadcastID string) error
+	// UpsertContact adds the contact to the audience or updates its unsubscribed flag.
+	// Unsubscribing an address that isn't in the audience is an error.
+	UpsertContact(ctx context.Context, audienceID string, contact Contact) error
+	// ListContacts returns the audience's contacts
+	ListContacts(ctx context.Context, audienceID string) ([]Contact, error)
+}
+
+var (
+	defaultMailer     Mailer
+	defaultMailerOnce sync.Once
+)
+
+// Default returns the process-wide mailer chosen by MAIL_BACKEND:
+//
+//	resend (default)  Resend, with RESEND_API_KEY
+//	smtp              any SMTP server (SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD), e.g. MailHog
+//	outbox            nothing is sent; messages are kept in memory and written to MAIL_OUTBOX_DIR if set
+//
+// The smtp and outbox backends keep audiences in memory, so a local server keeps them
+// for as long as it runs.
+func Default() Mailer {
+	defaultMailerOnce.Do(func() {
+		backend := os.Getenv("MAIL_BACKEND")
+		switch backend {
+		case "smtp":
+			port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+			if err != nil {
+				port = 587
+			}
+			host := os.Getenv("SMTP_HOST")
+			if host == "" {
+				host = "localhost"
+			}
+			defaultMailer = NewSMTP(SMTPConfig{
+				Host:     host,
+				Port:     port,
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+			})
+		case "outbox":
+			defaultMailer = NewOutbox(os.Getenv("MAIL_OUTBOX_DIR"))
+		default:
+			if backend != "" && backend != "resend" {
+				logger.Warn("Unknown MAIL_BACKEND, using Resend", map[string]interface{}{"backend": backend})
+			}
+			defaultMailer = NewResend(os.Getenv("RESEND_API_KEY"))
+		}
+	})
+	return defaultMailer
+}
+
+// FromAddress returns the sender for our emails: MAIL_FROM, else RESEND_FROM_EMAIL
+func FromAddress() string {
+	if from := os.Getenv("MAIL_FROM"); from != "" {
+		return from
+	}
+	return os.Getenv("RESEND_FROM_EMAIL")
+}
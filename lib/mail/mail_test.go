@@ -0,0 +1,292 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMessage(t *testing.T) {
+	msg := Message{
+		From:    "TLDR <news@example.com>",
+		To:      []string{"reader@example.com"},
+		Subject: "Héllo\r\nBcc: evil@example.com",
+		HTML:    "<p>Hello</p>",
+		Headers: map[string]string{"list-unsubscribe": "<https://example.com/u>"},
+	}
+
+	data, err := buildMessage(msg, "<id@example.com>", time.Now())
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if got := parsed.Header.Get("Bcc"); got != "" {
+		t.Errorf("header injected through subject: Bcc = %q", got)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); !strings.HasPrefix(subject, "Héllo") {
+		t.Errorf("Subject = %q", subject)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<https://example.com/u>" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+	if got := parsed.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+
+	// With a plain-text part both alternatives are sent, HTML last
+	msg.Text = "Hello"
+	data, err = buildMessage(msg, "<id@example.com>", time.Now())
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+	parsed, err = netmail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", parsed.Header.Get("Content-Type"))
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		body, _ := io.ReadAll(part) // Decodes quoted-printable
+		types = append(types, strings.SplitN(part.Header.Get("Content-Type"), ";", 2)[0])
+		bodies = append(bodies, string(body))
+	}
+	if strings.Join(types, ",") != "text/plain,text/html" {
+		t.Errorf("parts = %v, want text/plain then text/html", types)
+	}
+	if len(bodies) == 2 && (bodies[0] != "Hello" || bodies[1] != "<p>Hello</p>") {
+		t.Errorf("bodies = %q", bodies)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	outbox := NewOutbox(dir)
+
+	if err := outbox.UpsertContact(ctx, "aud", Contact{Email: "gone@example.com", Unsubscribed: true}); err == nil {
+		t.Error("unsubscribing an unknown contact succeeded")
+	}
+	outbox.UpsertContact(ctx, "aud", Contact{Email: "b@example.com"})
+	outbox.UpsertContact(ctx, "aud", Contact{Email: "a@example.com"})
+	outbox.UpsertContact(ctx, "aud", Contact{Email: "c@example.com"})
+	outbox.UpsertContact(ctx, "aud", Contact{Email: "c@example.com", Unsubscribed: true})
+	outbox.UpsertContact(ctx, "other", Contact{Email: "d@example.com"})
+
+	contacts, _ := outbox.ListContacts(ctx, "aud")
+	if len(contacts) != 3 || contacts[0].Email != "a@example.com" || !contacts[2].Unsubscribed {
+		t.Fatalf("ListContacts() = %+v", contacts)
+	}
+
+	if err := outbox.SendBroadcast(ctx, "missing"); !errors.Is(err, ErrBroadcastNotFound) {
+		t.Errorf("SendBroadcast(missing) error = %v, want ErrBroadcastNotFound", err)
+	}
+	id, err := outbox.CreateBroadcast(ctx, Broadcast{AudienceID: "aud", From: "news@example.com", Subject: "Daily", HTML: "<p>Daily</p>"})
+	if err != nil {
+		t.Fatalf("CreateBroadcast() error = %v", err)
+	}
+	if len(outbox.Messages()) != 0 {
+		t.Fatal("CreateBroadcast() sent messages")
+	}
+	if err := outbox.SendBroadcast(ctx, id); err != nil {
+		t.Fatalf("SendBroadcast() error = %v", err)
+	}
+	if err := outbox.SendBroadcast(ctx, id); !errors.Is(err, ErrBroadcastSent) {
+		t.Errorf("second SendBroadcast() error = %v, want ErrBroadcastSent", err)
+	}
+
+	// Only the subscribed contacts of the audience receive it
+	var recipients []string
+	for _, msg := range outbox.Messages() {
+		recipients = append(recipients, msg.To...)
+	}
+	if strings.Join(recipients, ",") != "a@example.com,b@example.com" {
+		t.Errorf("recipients = %v", recipients)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 2 {
+		t.Fatalf("outbox wrote %d .eml files, want 2", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if parsed, err := netmail.ReadMessage(strings.NewReader(string(data))); err != nil || parsed.Header.Get("Subject") != "Daily" {
+		t.Errorf(".eml file is not a readable message: %v", err)
+	}
+}
+
+func TestLocalBroadcastResumesAfterFailedSend(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewOutbox("")
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		outbox.UpsertContact(ctx, "aud", Contact{Email: email})
+	}
+	id, _ := outbox.CreateBroadcast(ctx, Broadcast{AudienceID: "aud", From: "news@example.com", Subject: "Daily", HTML: "<p>Daily</p>"})
+
+	// The second recipient fails: the broadcast isn't sent, and the first isn't sent to again
+	var delivered []string
+	send := func(fail string) func(context.Context, Message) (string, error) {
+		return func(ctx context.Context, msg Message) (string, error) {
+			if msg.To[0] == fail {
+				return "", errors.New("mailbox unavailable")
+			}
+			delivered = append(delivered, msg.To[0])
+			return "sent", nil
+		}
+	}
+	if err := outbox.sendBroadcast(ctx, id, send("b@example.com")); err == nil {
+		t.Fatal("sendBroadcast() with a failing recipient succeeded")
+	}
+	if err := outbox.sendBroadcast(ctx, id, send("")); err != nil {
+		t.Fatalf("retried sendBroadcast() error = %v", err)
+	}
+	if strings.Join(delivered, ",") != "a@example.com,b@example.com,c@example.com" {
+		t.Errorf("delivered = %v, want each recipient once", delivered)
+	}
+	if err := outbox.sendBroadcast(ctx, id, send("")); !errors.Is(err, ErrBroadcastSent) {
+		t.Errorf("third sendBroadcast() error = %v, want ErrBroadcastSent", err)
+	}
+}
+
+func TestOutboxBroadcastUnsubscribeLinks(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewOutbox("")
+	outbox.UpsertContact(ctx, "aud", Contact{Email: "a@example.com"})
+	outbox.UpsertContact(ctx, "aud", Contact{Email: "b@example.com"})
+
+	id, _ := outbox.CreateBroadcast(ctx, Broadcast{
+		AudienceID: "aud",
+		From:       "news@example.com",
+		Subject:    "Daily",
+		HTML:       `<a href="` + ResendUnsubscribePlaceholder + `">Unsubscribe</a>`,
+		Text:       "Unsubscribe: " + ResendUnsubscribePlaceholder,
+		UnsubscribeURL: func(email string) (string, error) {
+			return "https://example.com/unsubscribe?token=" + email + "&x=1", nil
+		},
+	})
+	if err := outbox.SendBroadcast(ctx, id); err != nil {
+		t.Fatalf("SendBroadcast() error = %v", err)
+	}
+
+	// Each contact gets its own link in the body and the one-click headers
+	messages := outbox.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(messages))
+	}
+	for _, msg := range messages {
+		link := "https://example.com/unsubscribe?token=" + msg.To[0] + "&x=1"
+		if msg.HTML != `<a href="https://example.com/unsubscribe?token=`+msg.To[0]+`&amp;x=1">Unsubscribe</a>` {
+			t.Errorf("HTML for %s = %q", msg.To[0], msg.HTML)
+		}
+		if msg.Text != "Unsubscribe: "+link {
+			t.Errorf("Text for %s = %q", msg.To[0], msg.Text)
+		}
+		if msg.Headers["List-Unsubscribe"] != "<"+link+">" || msg.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+			t.Errorf("Headers for %s = %v", msg.To[0], msg.Headers)
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	addr := listener.Addr().(*net.TCPAddr)
+	mailer := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: addr.Port})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, err := mailer.Send(ctx, Message{
+		From:    "news@example.com",
+		To:      []string{"Reader <reader@example.com>"},
+		Subject: "Welcome",
+		HTML:    "<p>Welcome</p>",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Send() id = %q", id)
+	}
+
+	commands := <-received
+	want := []string{"MAIL FROM:<news@example.com>", "RCPT TO:<reader@example.com>"}
+	for _, w := range want {
+		if !containsPrefix(commands, w) {
+			t.Errorf("server never got %q; got %q", w, commands)
+		}
+	}
+}
+
+// serveSMTP answers one session with the bare minimum of SMTP and reports the commands it got
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+
+	var commands []string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			break
+		}
+		commands = append(commands, line)
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			bufio.NewReader(text.DotReader()).WriteTo(io.Discard)
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			received <- commands
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+	received <- commands
+}
+
+func containsPrefix(lines []string, prefix string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
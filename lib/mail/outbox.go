@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outbox is a mailer that sends nothing. Messages are kept in memory and, when it has
+// a directory, written there as .eml files that any mail client opens.
+type Outbox struct {
+	*localAudiences
+	dir string
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewOutbox creates an outbox, writing to dir unless it is empty
+func NewOutbox(dir string) *Outbox {
+	return &Outbox{localAudiences: newLocalAudiences(), dir: dir}
+}
+
+// Send records the message
+func (o *Outbox) Send(ctx context.Context, msg Message) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	id := fmt.Sprintf("outbox-%d", len(o.messages)+1)
+	if o.dir != "" {
+		data, err := buildMessage(msg, "<"+id+"@outbox>", time.Now())
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(o.dir, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create outbox directory: %w", err)
+		}
+		name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), id)
+		if err := os.WriteFile(filepath.Join(o.dir, name), data, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write outbox message: %w", err)
+		}
+	}
+	o.messages = append(o.messages, msg)
+	return id, nil
+}
+
+// SendBroadcast records one message per subscribed contact
+func (o *Outbox) SendBroadcast(ctx context.Context, broadcastID string) error {
+	return o.sendBroadcast(ctx, broadcastID, o.Send)
+}
+
+// Messages returns everything sent so far, oldest first
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/resend/resend-go/v2"
+)
+
+// ResendMailer sends through the Resend API; audiences are Resend audiences
+type ResendMailer struct {
+	client *resend.Client
+}
+
+// NewResend creates a Resend mailer. With an empty apiKey every call fails.
+func NewResend(apiKey string) *ResendMailer {
+	if apiKey == "" {
+		return &ResendMailer{}
+	}
+	return &ResendMailer{client: resend.NewClient(apiKey)}
+}
+
+func (m *ResendMailer) ready() error {
+	if m.client == nil {
+		return fmt.Errorf("missing RESEND_API_KEY environment variable")
+	}
+	return nil
+}
+
+// Send sends a single email
+func (m *ResendMailer) Send(ctx context.Context, msg Message) (string, error) {
+	if err := m.ready(); err != nil {
+		return "", err
+	}
+	sent, err := m.client.Emails.SendWithContext(ctx, &resend.SendEmailRequest{
+		From:    msg.From,
+		To:      msg.To,
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+		Text:    msg.Text,
+		Headers: msg.Headers,
+	})
+	if err != nil {
+		return "", fmt.Errorf("resend email send failed: %w", err)
+	}
+	return sent.Id, nil
+}
+
+// CreateBroadcast creates a Resend broadcast
+func (m *ResendMailer) CreateBroadcast(ctx context.Context, broadcast Broadcast) (string, error) {
+	if err := m.ready(); err != nil {
+		return "", err
+	}
+	created, err := m.client.Broadcasts.CreateWithContext(ctx, &resend.CreateBroadcastRequest{
+		Name:       broadcast.Name,
+		AudienceId: broadcast.AudienceID,
+		From:       broadcast.From,
+		Subject:    broadcast.Subject,
+		Html:       broadcast.HTML,
+		Text:       broadcast.Text,
+	})
+	if err != nil {
+		return "", fmt.Errorf("resend broadcast creation failed: %w", err)
+	}
+	if created.Id == "" {
+		return "", fmt.Errorf("resend broadcast creation returned no data")
+	}
+	return created.Id, nil
+}
+
+// SendBroadcast sends a Resend broadcast now
+func (m *ResendMailer) SendBroadcast(ctx context.Context, broadcastID string) error {
+	if err := m.ready(); err != nil {
+		return err
+	}
+	if _, err := m.client.Broadcasts.SendWithContext(ctx, &resend.SendBroadcastRequest{BroadcastId: broadcastID}); err != nil {
+		return fmt.Errorf("resend broadcast send failed: %w", err)
+	}
+	return nil
+}
+
+// UpsertContact updates the contact's unsubscribed flag, creating the contact if a
+// subscribe fails because it isn't there yet (Resend's errors don't say which it was)
+func (m *ResendMailer) UpsertContact(ctx context.Context, audienceID string, contact Contact) error {
+	if err := m.ready(); err != nil {
+		return err
+	}
+	params := &resend.UpdateContactRequest{Email: contact.Email, AudienceId: audienceID}
+	params.SetUnsubscribed(contact.Unsubscribed)
+	_, err := m.client.Contacts.UpdateWithContext(ctx, params)
+	if err == nil {
+		return nil
+	}
+	if contact.Unsubscribed {
+		return fmt.Errorf("failed to unsubscribe Resend contact: %w", err)
+	}
+
+	_, err = m.client.Contacts.CreateWithContext(ctx, &resend.CreateContactRequest{
+		Email:      contact.Email,
+		AudienceId: audienceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add contact to Resend audience: %w", err)
+	}
+	return nil
+}
+
+// ListContacts lists the audience's contacts
+func (m *ResendMailer) ListContacts(ctx context.Context, audienceID string) ([]Contact, error) {
+	if err := m.ready(); err != nil {
+		return nil, err
+	}
+	listed, err := m.client.Contacts.ListWithContext(ctx, audienceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Resend contacts: %w", err)
+	}
+	contacts := make([]Contact, len(listed.Data))
+	for i, c := range listed.Data {
+		contacts[i] = Contact{Email: c.Email, Unsubscribed: c.Unsubscribed}
+	}
+	return contacts, nil
+}
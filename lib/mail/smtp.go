@@ -0,0 +1,190 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig is how to reach an SMTP server. Without a username no AUTH is attempted,
+// which is what local catchers like MailHog expect.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// SMTPMailer sends through an SMTP server; audiences are kept in memory
+type SMTPMailer struct {
+	*localAudiences
+	config SMTPConfig
+}
+
+// NewSMTP creates an SMTP mailer
+func NewSMTP(config SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{localAudiences: newLocalAudiences(), config: config}
+}
+
+// Send delivers the message, upgrading to TLS when the server offers STARTTLS
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) (string, error) {
+	from, err := netmail.ParseAddress(msg.From)
+	if err != nil {
+		return "", fmt.Errorf("invalid from address %q: %w", msg.From, err)
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+	id := newMessageID(domain)
+	data, err := buildMessage(msg, id, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.config.Host}); err != nil {
+			return "", fmt.Errorf("SMTP STARTTLS failed: %w", err)
+		}
+	}
+	if m.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return "", fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return "", fmt.Errorf("SMTP MAIL FROM failed: %w", err)
+	}
+	for _, to := range msg.To {
+		rcpt, err := netmail.ParseAddress(to)
+		if err != nil {
+			return "", fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return "", fmt.Errorf("SMTP RCPT TO %s failed: %w", rcpt.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return "", fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write SMTP message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("SMTP server rejected message: %w", err)
+	}
+	if err := client.Quit(); err != nil {
+		return "", fmt.Errorf("SMTP QUIT failed: %w", err)
+	}
+	return id, nil
+}
+
+// SendBroadcast sends one message per subscribed contact
+func (m *SMTPMailer) SendBroadcast(ctx context.Context, broadcastID string) error {
+	return m.sendBroadcast(ctx, broadcastID, m.Send)
+}
+
+// newMessageID returns a unique Message-ID at domain
+func newMessageID(domain string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// buildMessage renders msg as an RFC 5322 message: HTML only, or multipart/alternative
+// when it has a plain-text part. Bodies are quoted-printable.
+func buildMessage(msg Message, id string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	// Line breaks in a value would start new headers
+	oneLine := strings.NewReplacer("\r", "", "\n", "")
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, oneLine.Replace(value))
+	}
+
+	header("From", msg.From)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", id)
+	header("MIME-Version", "1.0")
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header(textproto.CanonicalMIMEHeaderKey(name), msg.Headers[name])
+	}
+
+	if msg.Text == "" {
+		header("Content-Type", "text/html; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.HTML); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	// Clients show the last alternative they can render, so HTML goes last
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create message part: %w", err)
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return fmt.Errorf("failed to encode message body: %w", err)
+	}
+	return qp.Close()
+}
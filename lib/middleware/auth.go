@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CronAuthorized checks the Vercel Cron bearer token ("Authorization: Bearer <CRON_SECRET>")
+// in constant time. Without CRON_SECRET nothing is authorized.
+func CronAuthorized(r *http.Request) bool {
+	expected := os.Getenv("CRON_SECRET")
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if expected == "" || !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
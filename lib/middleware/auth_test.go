@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCronAuthorized(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		want   bool
+	}{
+		{"Matching token", "s3cret", "Bearer s3cret", true},
+		{"Wrong token", "s3cret", "Bearer other", false},
+		{"Missing scheme", "s3cret", "s3cret", false},
+		{"No header", "s3cret", "", false},
+		{"No secret configured", "", "Bearer ", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CRON_SECRET", tt.secret)
+			r := httptest.NewRequest("GET", "/api/cron/x", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if got := CronAuthorized(r); got != tt.want {
+				t.Errorf("CronAuthorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -26,14 +27,17 @@ func GenerateETag(payload []byte, identifier string) string {
 }
 
 // CheckETagMatch checks if the given ETag is present in the If-None-Match header value.
-// The header can contain a comma-separated list of ETags.
+// The header can contain a comma-separated list of ETags. Uses weak comparison as
+// If-None-Match requires, so W/"x" matches "x", and "*" matches any ETag.
 func CheckETagMatch(etag string, ifNoneMatchHeader string) bool {
-	if ifNoneMatchHeader == "" {
+	if ifNoneMatchHeader == "" || etag == "" {
 		return false
 	}
+	etag = strings.TrimPrefix(etag, "W/")
 	tags := strings.Split(ifNoneMatchHeader, ",")
 	for _, tag := range tags {
-		if strings.TrimSpace(tag) == etag {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
 			return true
 		}
 	}
@@ -41,9 +45,11 @@ func CheckETagMatch(etag string, ifNoneMatchHeader string) bool {
 }
 
 // CreateCommonHeaders generates caching headers based on the provided configuration.
+// An empty etag omits the ETag header.
 func CreateCommonHeaders(etag string, config CacheConfig) map[string]string {
-	headers := map[string]string{
-		"ETag": etag,
+	headers := map[string]string{}
+	if etag != "" {
+		headers["ETag"] = etag
 	}
 
 	// Build Cache-Control header
@@ -87,11 +93,14 @@ func CreateCommonHeaders(etag string, config CacheConfig) map[string]string {
 }
 
 // ResponseCapture wraps http.ResponseWriter to capture response data
+// Calling Flush sends what has been captured and switches to pass-through,
+// so handlers that stream (and flush) are never fully buffered
 type ResponseCapture struct {
 	http.ResponseWriter
-	statusCode int
-	body       *bytes.Buffer
-	written    bool
+	statusCode  int
+	body        *bytes.Buffer
+	written     bool
+	passthrough bool
 }
 
 // NewResponseCapture creates a new ResponseCapture
@@ -106,30 +115,45 @@ func NewResponseCapture(w http.ResponseWriter) *ResponseCapture {
 
 // WriteHeader captures the status code
 func (rc *ResponseCapture) WriteHeader(code int) {
+	if rc.passthrough {
+		return // Already sent
+	}
 	rc.statusCode = code
 }
 
 // Write captures the response body
 func (rc *ResponseCapture) Write(data []byte) (int, error) {
 	rc.written = true
+	if rc.passthrough {
+		return rc.ResponseWriter.Write(data)
+	}
 	return rc.body.Write(data)
 }
 
-// Flush sends the captured response to the original ResponseWriter
+// Flush sends the captured response to the original ResponseWriter and
+// passes all later writes straight through
 func (rc *ResponseCapture) Flush() {
-	if !rc.written {
-		rc.WriteHeader(rc.statusCode)
-		return
+	if !rc.passthrough {
+		rc.passthrough = true
+		rc.ResponseWriter.WriteHeader(rc.statusCode)
+		// Ignore error as per Go best practices for HTTP response writing
+		_, _ = rc.ResponseWriter.Write(rc.body.Bytes())
+		rc.body.Reset()
 	}
 
-	// Copy all headers
-	for key, values := range rc.Header() {
-		rc.Header()[key] = values
+	if flusher, ok := rc.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
 	}
+}
 
-	rc.WriteHeader(rc.statusCode)
-	// Ignore error as per Go best practices for HTTP response writing
-	_, _ = rc.Write(rc.body.Bytes())
+// Unwrap returns the original ResponseWriter (used by http.ResponseController)
+func (rc *ResponseCapture) Unwrap() http.ResponseWriter {
+	return rc.ResponseWriter
+}
+
+// Streamed reports whether the response was flushed and has already been sent
+func (rc *ResponseCapture) Streamed() bool {
+	return rc.passthrough
 }
 
 // GetBody returns the captured response body
@@ -142,7 +166,20 @@ func (rc *ResponseCapture) GetStatusCode() int {
 	return rc.statusCode
 }
 
-// CachingMiddleware creates middleware that sets cache headers and ETags
+// setCacheHeaders sets the caching headers, leaving any the handler already set
+func setCacheHeaders(w http.ResponseWriter, etag string, config CacheConfig) {
+	for key, value := range CreateCommonHeaders(etag, config) {
+		if w.Header().Get(key) == "" {
+			w.Header().Set(key, value)
+		}
+	}
+}
+
+// CachingMiddleware creates middleware that sets cache headers and content-derived ETags
+// The handler output is buffered and hashed into a strong ETag; a matching If-None-Match
+// gets 304 Not Modified with no body. Only 2xx responses get cache headers, so errors
+// are never cached at the CDN. Routes with an empty ETagKey, or Streaming set, skip
+// buffering and only get Cache-Control headers
 func CachingMiddleware(cacheOpts CacheOptions) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -151,23 +188,62 @@ func CachingMiddleware(cacheOpts CacheOptions) func(http.HandlerFunc) http.Handl
 				return
 			}
 
-			// Generate ETag from the route identifier
-			etag := GenerateETag([]byte(cacheOpts.ETagKey), cacheOpts.ETagKey)
+			// No ETag wanted: set headers up front and let the response stream through
+			if cacheOpts.Streaming || cacheOpts.ETagKey == "" {
+				setCacheHeaders(w, "", cacheOpts.Config)
+				next(w, r)
+				return
+			}
+
+			// Execute the handler into a buffer
+			capture := NewResponseCapture(w)
+			next(capture, r)
+
+			// The handler flushed, so the response has already been sent as-is
+			if capture.Streamed() {
+				return
+			}
 
-			// Set cache headers with ETag
-			commonHeaders := CreateCommonHeaders(etag, cacheOpts.Config)
-			for key, value := range commonHeaders {
-				w.Header().Set(key, value)
+			statusCode := capture.GetStatusCode()
+			body := capture.GetBody()
+
+			if statusCode >= 200 && statusCode < 300 {
+				// Generate ETag from the response body
+				etag := GenerateETag(body, cacheOpts.ETagKey)
+				setCacheHeaders(w, etag, cacheOpts.Config)
+
+				if CheckETagMatch(etag, r.Header.Get("If-None-Match")) {
+					// 304 carries the validators and cache headers but no body
+					w.Header().Del("Content-Length")
+					w.Header().Del("Content-Type")
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
 			}
 
-			// Execute the handler
-			next(w, r)
+			if w.Header().Get("Content-Length") == "" {
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			}
+			w.WriteHeader(statusCode)
+			if r.Method != http.MethodHead {
+				// Ignore error as per Go best practices for HTTP response writing
+				_, _ = w.Write(body)
+			}
 		}
 	}
 }
 
 // MethodAndCache combines method validation and caching
+// With cacheOpts.Server set, the server-side response cache sits inside the header
+// middleware, so cached bodies still get ETags and 304s
 func MethodAndCache(method string, cacheOpts CacheOptions) func(http.HandlerFunc) http.HandlerFunc {
+	if cacheOpts.Enabled && cacheOpts.Server != nil {
+		return CombineMiddlewares(
+			MethodValidator(method),
+			CachingMiddleware(cacheOpts),
+			ResponseCache(serverCacheOptions(*cacheOpts.Server, cacheOpts.Config)),
+		)
+	}
 	return CombineMiddlewares(
 		MethodValidator(method),
 		CachingMiddleware(cacheOpts),
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testCacheOptions() CacheOptions {
+	return CacheOptions{
+		Config: CacheConfig{
+			MaxAge:  0,
+			SMaxAge: 3600,
+		},
+		ETagKey: "test",
+		Enabled: true,
+	}
+}
+
+func bodyHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestCachingMiddlewareETagFromBody(t *testing.T) {
+	opts := testCacheOptions()
+
+	recA := httptest.NewRecorder()
+	CachingMiddleware(opts)(bodyHandler("alpha"))(recA, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	recB := httptest.NewRecorder()
+	CachingMiddleware(opts)(bodyHandler("beta"))(recB, httptest.NewRequest(http.MethodGet, "/b", nil))
+
+	etagA := recA.Header().Get("ETag")
+	etagB := recB.Header().Get("ETag")
+
+	if etagA == "" || etagB == "" {
+		t.Fatal("Expected ETag headers on both responses")
+	}
+	if etagA == etagB {
+		t.Errorf("Different bodies should get different ETags, both got %s", etagA)
+	}
+	if etagA != GenerateETag([]byte("alpha"), "test") {
+		t.Errorf("ETag %s does not match body hash", etagA)
+	}
+	if strings.HasPrefix(etagA, "W/") {
+		t.Errorf("Expected a strong ETag, got %s", etagA)
+	}
+	if recA.Body.String() != "alpha" {
+		t.Errorf("Expected body 'alpha', got %q", recA.Body.String())
+	}
+	if recA.Header().Get("Content-Length") != "5" {
+		t.Errorf("Expected Content-Length 5, got %q", recA.Header().Get("Content-Length"))
+	}
+}
+
+func TestCachingMiddlewareNotModified(t *testing.T) {
+	opts := testCacheOptions()
+	etag := GenerateETag([]byte("alpha"), "test")
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		expected    int
+	}{
+		{name: "Matching ETag", ifNoneMatch: etag, expected: http.StatusNotModified},
+		{name: "Weak form of matching ETag", ifNoneMatch: "W/" + etag, expected: http.StatusNotModified},
+		{name: "ETag in list", ifNoneMatch: `"other", ` + etag, expected: http.StatusNotModified},
+		{name: "Wildcard", ifNoneMatch: "*", expected: http.StatusNotModified},
+		{name: "Stale ETag", ifNoneMatch: `"test-stale"`, expected: http.StatusOK},
+		{name: "No header", ifNoneMatch: "", expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/a", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			CachingMiddleware(opts)(bodyHandler("alpha"))(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+			if tt.expected == http.StatusNotModified {
+				if rec.Body.Len() != 0 {
+					t.Errorf("304 response should have no body, got %q", rec.Body.String())
+				}
+				if rec.Header().Get("ETag") != etag {
+					t.Errorf("304 response should carry the ETag, got %q", rec.Header().Get("ETag"))
+				}
+			}
+		})
+	}
+}
+
+func TestCachingMiddlewareSkipsErrors(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		WriteJSONError(w, http.StatusNotFound, "not found")
+	}
+
+	rec := httptest.NewRecorder()
+	CachingMiddleware(testCacheOptions())(handler)(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+	if rec.Header().Get("ETag") != "" || rec.Header().Get("CDN-Cache-Control") != "" {
+		t.Error("Error responses should not get ETag or CDN cache headers")
+	}
+}
+
+func TestCachingMiddlewareStreamingOptOut(t *testing.T) {
+	opts := testCacheOptions()
+	opts.Streaming = true
+
+	rec := httptest.NewRecorder()
+	CachingMiddleware(opts)(bodyHandler("stream"))(rec, httptest.NewRequest(http.MethodPost, "/ds1", nil))
+
+	if rec.Header().Get("ETag") != "" {
+		t.Error("Streaming routes should not get an ETag")
+	}
+	if rec.Header().Get("Cache-Control") == "" {
+		t.Error("Streaming routes should still get Cache-Control")
+	}
+	if rec.Body.String() != "stream" {
+		t.Errorf("Expected body 'stream', got %q", rec.Body.String())
+	}
+}
+
+func TestCachingMiddlewareFlushPassesThrough(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first "))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush failed: %v", err)
+		}
+		_, _ = w.Write([]byte("second"))
+	}
+
+	rec := httptest.NewRecorder()
+	CachingMiddleware(testCacheOptions())(handler)(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	if rec.Body.String() != "first second" {
+		t.Errorf("Expected full streamed body, got %q", rec.Body.String())
+	}
+	if !rec.Flushed {
+		t.Error("Expected the underlying writer to be flushed")
+	}
+	if rec.Header().Get("ETag") != "" {
+		t.Error("Flushed responses should not get an ETag")
+	}
+}
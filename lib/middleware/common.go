@@ -3,7 +3,10 @@ package middleware
 import (
 	"encoding/json"
 	"main/lib/response"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 )
 
 // WriteJSONError is a helper for common JSON error responses
@@ -28,3 +31,84 @@ func ParseJSONBody(r *http.Request, v interface{}) error {
 	}()
 	return json.NewDecoder(r.Body).Decode(v)
 }
+
+// ClientIP returns the caller's IP. Forwarded headers are only believed from a proxy we run
+// behind, since any client can send them: on Vercel, whose edge overwrites X-Forwarded-For,
+// or when the connection comes from an address in TRUSTED_PROXIES (comma-separated IPs or
+// CIDRs, e.g. a load balancer in front of cmd/server). There X-Forwarded-For is read right to
+// left, skipping trusted hops, because entries left of them came from the client. Otherwise
+// it's the connection's address.
+func ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+
+	if os.Getenv("VERCEL") != "" {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+		return remote
+	}
+
+	trusted := trustedProxies()
+	if !trusted.contains(remote) {
+		return remote
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hop := strings.TrimSpace(hops[i]); hop != "" && !trusted.contains(hop) {
+			return hop
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	return remote
+}
+
+// proxyList is the parsed TRUSTED_PROXIES
+type proxyList []*net.IPNet
+
+// trustedProxies parses TRUSTED_PROXIES, skipping entries that aren't IPs or CIDRs
+func trustedProxies() proxyList {
+	var proxies proxyList
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			// A single address
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
+			}
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+		}
+	}
+	return proxies
+}
+
+// contains reports whether ip is one of the proxies
+func (p proxyList) contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		vercel    string
+		trusted   string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"Direct connection ignores forwarded headers", "", "", "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"Untrusted proxy ignored", "", "10.0.0.0/8", "203.0.113.7:5000", "198.51.100.1", "", "203.0.113.7"},
+		{"Trusted proxy", "", "10.0.0.0/8", "10.0.0.2:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"Spoofed entries left of the client", "", "10.0.0.0/8", "10.0.0.2:5000", "1.2.3.4, 198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"Trusted single address", "", "192.0.2.10, ::1", "[::1]:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"Trusted proxy without X-Forwarded-For", "", "10.0.0.0/8", "10.0.0.2:5000", "", "198.51.100.2", "198.51.100.2"},
+		{"Vercel edge", "1", "", "127.0.0.1:5000", "198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"Vercel without headers", "1", "", "127.0.0.1:5000", "", "", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VERCEL", tt.vercel)
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			r := httptest.NewRequest("POST", "/api/subscribe", nil)
+			r.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -39,8 +39,14 @@ type SuccessResponse struct {
 // CacheOptions defines caching configuration for middleware
 type CacheOptions struct {
 	Config  CacheConfig
-	ETagKey string
+	ETagKey string // ETag prefix; empty disables ETags (and response buffering)
 	Enabled bool
+	// Streaming opts out of response buffering for handlers that stream or
+	// write large binary bodies (e.g. ds1); cache headers are set but no ETag
+	Streaming bool
+	// Server enables the server-side response cache for the route; TTLs
+	// default to Config.SMaxAge and Config.StaleWhileRevalidate
+	Server *ResponseCacheOptions
 }
 
 // DefaultCacheOptions returns sensible default cache options
@@ -0,0 +1,355 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"main/lib/logger"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// Header reporting how the server-side cache answered: HIT, STALE or MISS
+	cacheStatusHeader = "X-Cache"
+	// Upper bound for a background stale-while-revalidate refresh
+	defaultRefreshTimeout = 60 * time.Second
+)
+
+// ResponseCacheOptions configures the server-side response cache for a route
+// It sits behind the CDN so local runs, other hosts and cold edges don't
+// recompute expensive responses (SageMaker, HuggingFace, LLM calls)
+type ResponseCacheOptions struct {
+	// Store holds cached responses; nil uses the process-wide DefaultResponseStore
+	Store ResponseStore
+	// TTL is how long a response is served without revalidating (default: CacheConfig.SMaxAge)
+	TTL time.Duration
+	// StaleTTL is how long after TTL a stale response is served while it refreshes
+	// in the background (default: CacheConfig.StaleWhileRevalidate)
+	StaleTTL time.Duration
+	// Vary lists request headers that select different responses, e.g. Accept
+	Vary []string
+	// KeyPrefix namespaces keys, so routes sharing a store can't collide
+	KeyPrefix string
+	// RefreshTimeout bounds background refreshes (default 60s)
+	RefreshTimeout time.Duration
+}
+
+// CachedEntry is a stored response with its freshness window
+type CachedEntry struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"storedAt"`
+	FreshUntil time.Time   `json:"freshUntil"`
+	StaleUntil time.Time   `json:"staleUntil"`
+}
+
+// IsFresh reports whether the entry can be served without revalidating
+func (e *CachedEntry) IsFresh(now time.Time) bool {
+	return now.Before(e.FreshUntil)
+}
+
+// IsUsable reports whether the entry can be served at all (fresh or stale)
+func (e *CachedEntry) IsUsable(now time.Time) bool {
+	return now.Before(e.StaleUntil)
+}
+
+// responseCacheGroup collapses concurrent fetches of the same key
+// It is process-wide because Vercel handlers build their middleware per request
+var responseCacheGroup singleflight.Group
+
+// Headers that describe the connection or the cache layers rather than the
+// response itself, so they aren't stored with it
+var uncachedHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Trailer":           true,
+	"Set-Cookie":        true,
+	"Content-Length":    true,
+	"Date":              true,
+	"Age":               true,
+	cacheStatusHeader:   true,
+}
+
+// ResponseCacheKey builds the cache key for a request: method, path, the query with
+// keys sorted (value order is kept, since it can matter) and the Vary header values
+func ResponseCacheKey(r *http.Request, prefix string, vary []string) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(normalizeQuery(r.URL.Query()))
+
+	for _, name := range sortedHeaderNames(vary) {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	hash := sha256.Sum256([]byte(b.String()))
+	key := hex.EncodeToString(hash[:])
+	if prefix != "" {
+		key = prefix + ":" + key
+	}
+	return key
+}
+
+// normalizeQuery encodes a query with sorted keys, dropping empty parameters
+func normalizeQuery(query url.Values) string {
+	for key, values := range query {
+		kept := values[:0]
+		for _, v := range values {
+			if v != "" {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) == 0 {
+			delete(query, key)
+		} else {
+			query[key] = kept
+		}
+	}
+	// Encode sorts by key
+	return query.Encode()
+}
+
+// sortedHeaderNames canonicalizes and sorts header names so Vary order doesn't matter
+func sortedHeaderNames(names []string) []string {
+	sorted := make([]string, len(names))
+	for i, name := range names {
+		sorted[i] = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// bufferedResponse is an http.ResponseWriter that records a full response in memory
+// It deliberately doesn't implement http.Flusher: cached routes must not stream
+type bufferedResponse struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}, statusCode: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(code int) { b.statusCode = code }
+
+func (b *bufferedResponse) Write(data []byte) (int, error) { return b.body.Write(data) }
+
+// cacheable reports whether a recorded response may be stored
+// Only 200s are kept, and handlers can opt out per response with no-store or private
+func (b *bufferedResponse) cacheable() bool {
+	if b.statusCode != http.StatusOK {
+		return false
+	}
+	if len(b.header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	cacheControl := strings.ToLower(b.header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-store") && !strings.Contains(cacheControl, "private")
+}
+
+// entry converts the recorded response into a cache entry
+func (b *bufferedResponse) entry(now time.Time, ttl, staleTTL time.Duration) *CachedEntry {
+	header := http.Header{}
+	for key, values := range b.header {
+		if !uncachedHeaders[http.CanonicalHeaderKey(key)] {
+			header[key] = append([]string(nil), values...)
+		}
+	}
+	return &CachedEntry{
+		Status:     b.statusCode,
+		Header:     header,
+		Body:       append([]byte(nil), b.body.Bytes()...),
+		StoredAt:   now,
+		FreshUntil: now.Add(ttl),
+		StaleUntil: now.Add(ttl + staleTTL),
+	}
+}
+
+// writeCachedEntry sends a cached entry, leaving headers the outer middleware already set
+func writeCachedEntry(w http.ResponseWriter, r *http.Request, entry *CachedEntry, status string, now time.Time) {
+	for key, values := range entry.Header {
+		if w.Header().Get(key) == "" {
+			w.Header()[key] = values
+		}
+	}
+	if !entry.StoredAt.IsZero() {
+		age := int(now.Sub(entry.StoredAt).Seconds())
+		if age < 0 {
+			age = 0
+		}
+		w.Header().Set("Age", strconv.Itoa(age))
+	}
+	w.Header().Set(cacheStatusHeader, status)
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		// Ignore error as per Go best practices for HTTP response writing
+		_, _ = w.Write(entry.Body)
+	}
+}
+
+// writeRecorded sends a response the handler just produced, with all of its own headers:
+// Set-Cookie and Trailer included, since it is for this request only. Trailer values the
+// handler set after its body are sent as trailers again.
+func writeRecorded(w http.ResponseWriter, r *http.Request, recorder *bufferedResponse, status string) {
+	trailers := make(map[string]bool)
+	for _, declared := range recorder.header.Values("Trailer") {
+		for _, name := range strings.Split(declared, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+				trailers[name] = true
+			}
+		}
+	}
+	for key, values := range recorder.header {
+		if !trailers[http.CanonicalHeaderKey(key)] {
+			w.Header()[key] = append([]string(nil), values...)
+		}
+	}
+	w.Header().Set(cacheStatusHeader, status)
+	w.WriteHeader(recorder.statusCode)
+	if r.Method != http.MethodHead {
+		// Ignore error as per Go best practices for HTTP response writing
+		_, _ = w.Write(recorder.body.Bytes())
+	}
+	for name := range trailers {
+		if values := recorder.header.Values(name); len(values) > 0 {
+			w.Header()[name] = append([]string(nil), values...)
+		}
+	}
+}
+
+// fetchResult is what one handler call for a key produced
+type fetchResult struct {
+	entry     *CachedEntry
+	recorder  *bufferedResponse
+	cacheable bool
+}
+
+// ResponseCache creates middleware that serves GET and HEAD responses from a ResponseStore
+// Fresh entries are served directly. Stale entries are served immediately while one
+// background request refreshes them. Concurrent misses for the same key are collapsed
+// into a single handler call whose response every waiter receives, when it is cacheable;
+// a response that isn't is only sent to the request that produced it, as recorded, and the
+// other waiters run the handler themselves
+// Note on Vercel the instance may be frozen once the response is sent, so background
+// refreshes are best effort there; the next request after a thaw retries them
+func ResponseCache(opts ResponseCacheOptions) func(http.HandlerFunc) http.HandlerFunc {
+	if opts.RefreshTimeout <= 0 {
+		opts.RefreshTimeout = defaultRefreshTimeout
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		// fetch runs the handler once for a key and stores a cacheable result. ran reports
+		// whether this caller's handler call produced the result rather than another waiter's
+		// ctx is detached from the client, so one caller disconnecting doesn't fail
+		// the shared call for everyone else waiting on it
+		fetch := func(ctx context.Context, r *http.Request, store ResponseStore, key string) (result *fetchResult, ran bool) {
+			v, _, _ := responseCacheGroup.Do(key, func() (interface{}, error) {
+				ran = true
+				recorder := newBufferedResponse()
+				next(recorder, r.WithContext(ctx))
+
+				entry := recorder.entry(time.Now(), opts.TTL, opts.StaleTTL)
+				cacheable := recorder.cacheable()
+				if cacheable && opts.TTL+opts.StaleTTL > 0 {
+					storeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+					defer cancel()
+					if err := store.Set(storeCtx, key, entry); err != nil {
+						logger.Warn("Failed to store cached response", map[string]interface{}{
+							"key":   key,
+							"error": err.Error(),
+						})
+					}
+				}
+				return &fetchResult{entry: entry, recorder: recorder, cacheable: cacheable}, nil
+			})
+			return v.(*fetchResult), ran
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			store := opts.Store
+			if store == nil {
+				store = DefaultResponseStore(nil)
+			}
+			if store == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				next(w, r)
+				return
+			}
+
+			// HEAD shares GET's entry; the body is just not written
+			keyReq := r
+			if r.Method == http.MethodHead {
+				keyReq = r.Clone(r.Context())
+				keyReq.Method = http.MethodGet
+			}
+			key := ResponseCacheKey(keyReq, opts.KeyPrefix, opts.Vary)
+			now := time.Now()
+
+			entry, err := store.Get(r.Context(), key)
+			if err != nil {
+				// A store may still return a stale local copy alongside the error
+				logger.Warn("Failed to read cached response", map[string]interface{}{
+					"key":   key,
+					"error": err.Error(),
+				})
+			}
+
+			if entry != nil && entry.IsFresh(now) {
+				writeCachedEntry(w, r, entry, "HIT", now)
+				return
+			}
+
+			if entry != nil && entry.IsUsable(now) {
+				writeCachedEntry(w, r, entry, "STALE", now)
+
+				refreshReq := keyReq.Clone(context.WithoutCancel(r.Context()))
+				go func() {
+					ctx, cancel := context.WithTimeout(refreshReq.Context(), opts.RefreshTimeout)
+					defer cancel()
+					fetch(ctx, refreshReq, store, key)
+				}()
+				return
+			}
+
+			result, ran := fetch(context.WithoutCancel(r.Context()), keyReq, store, key)
+			switch {
+			case result.cacheable:
+				writeCachedEntry(w, r, result.entry, "MISS", now)
+			case ran:
+				writeRecorded(w, r, result.recorder, "MISS")
+			default:
+				// Another request's private response (cookies and all) isn't ours to send
+				next(w, r)
+			}
+		}
+	}
+}
+
+// serverCacheOptions fills ResponseCacheOptions defaults from the route's CacheConfig
+func serverCacheOptions(opts ResponseCacheOptions, config CacheConfig) ResponseCacheOptions {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Duration(config.SMaxAge) * time.Second
+	}
+	if opts.StaleTTL <= 0 {
+		opts.StaleTTL = time.Duration(config.StaleWhileRevalidate) * time.Second
+	}
+	return opts
+}
@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		same bool
+		vary []string
+		hdrA map[string]string
+		hdrB map[string]string
+	}{
+		{name: "Query keys are sorted", a: "/api/paper?id=1&v=2", b: "/api/paper?v=2&id=1", same: true},
+		{name: "Empty parameters are dropped", a: "/api/paper?id=1&x=", b: "/api/paper?id=1", same: true},
+		{name: "Value order matters", a: "/api/search?text=a&text=b", b: "/api/search?text=b&text=a", same: false},
+		{name: "Different paths", a: "/api/paper?id=1", b: "/api/papers?id=1", same: false},
+		{
+			name: "Vary header selects entry",
+			a:    "/api/tldr", b: "/api/tldr",
+			vary: []string{"accept"},
+			hdrA: map[string]string{"Accept": "application/json"},
+			hdrB: map[string]string{"Accept": "application/rss+xml"},
+			same: false,
+		},
+		{
+			name: "Unlisted headers are ignored",
+			a:    "/api/tldr", b: "/api/tldr",
+			hdrA: map[string]string{"User-Agent": "a"},
+			hdrB: map[string]string{"User-Agent": "b"},
+			same: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqA := httptest.NewRequest(http.MethodGet, tt.a, nil)
+			for k, v := range tt.hdrA {
+				reqA.Header.Set(k, v)
+			}
+			reqB := httptest.NewRequest(http.MethodGet, tt.b, nil)
+			for k, v := range tt.hdrB {
+				reqB.Header.Set(k, v)
+			}
+
+			keyA := ResponseCacheKey(reqA, "p", tt.vary)
+			keyB := ResponseCacheKey(reqB, "p", tt.vary)
+			if (keyA == keyB) != tt.same {
+				t.Errorf("ResponseCacheKey(%q) == ResponseCacheKey(%q) is %v; expected %v", tt.a, tt.b, keyA == keyB, tt.same)
+			}
+		})
+	}
+}
+
+func TestMemoryResponseStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryResponseStore(2, 10)
+	entry := func(body string) *CachedEntry {
+		return &CachedEntry{Status: http.StatusOK, Body: []byte(body), StaleUntil: time.Now().Add(time.Hour)}
+	}
+
+	_ = store.Set(ctx, "a", entry("aaa"))
+	_ = store.Set(ctx, "b", entry("bbb"))
+	// Touch a so b is the least recently used
+	if got, _ := store.Get(ctx, "a"); got == nil {
+		t.Fatal("Expected a to be stored")
+	}
+	_ = store.Set(ctx, "c", entry("ccc"))
+
+	if got, _ := store.Get(ctx, "b"); got != nil {
+		t.Error("Expected b to be evicted by entry limit")
+	}
+	if got, _ := store.Get(ctx, "a"); got == nil {
+		t.Error("Expected a to survive eviction")
+	}
+
+	// 3 + 3 + 8 bytes exceeds the 10 byte limit, leaving only the newest entry
+	_ = store.Set(ctx, "d", entry("dddddddd"))
+	if store.Len() != 1 {
+		t.Errorf("Expected 1 entry after size eviction, got %d", store.Len())
+	}
+
+	// Entries past their stale window are dropped on read
+	_ = store.Set(ctx, "e", &CachedEntry{Body: []byte("e"), StaleUntil: time.Now().Add(-time.Second)})
+	if got, _ := store.Get(ctx, "e"); got != nil {
+		t.Error("Expected expired entry to be a miss")
+	}
+}
+
+func TestResponseCacheHitAndMiss(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "response %d", n)
+	}
+
+	opts := ResponseCacheOptions{Store: NewMemoryResponseStore(0, 0), TTL: time.Minute, KeyPrefix: t.Name()}
+	cached := ResponseCache(opts)(handler)
+
+	for i, expected := range []string{"MISS", "HIT", "HIT"} {
+		rec := httptest.NewRecorder()
+		cached(rec, httptest.NewRequest(http.MethodGet, "/api/paper?id=1", nil))
+
+		if rec.Header().Get("X-Cache") != expected {
+			t.Errorf("Request %d: expected X-Cache %s, got %s", i, expected, rec.Header().Get("X-Cache"))
+		}
+		if rec.Body.String() != "response 1" {
+			t.Errorf("Request %d: expected cached body, got %q", i, rec.Body.String())
+		}
+		if rec.Header().Get("Content-Type") != "text/plain" {
+			t.Errorf("Request %d: expected stored Content-Type, got %q", i, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	// HEAD shares the GET entry but writes no body
+	rec := httptest.NewRecorder()
+	cached(rec, httptest.NewRequest(http.MethodHead, "/api/paper?id=1", nil))
+	if rec.Header().Get("X-Cache") != "HIT" || rec.Body.Len() != 0 {
+		t.Errorf("HEAD: expected bodiless HIT, got %s with %d bytes", rec.Header().Get("X-Cache"), rec.Body.Len())
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls.Load())
+	}
+}
+
+func TestResponseCacheSkipsUncacheable(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+	}{
+		{
+			name:   "Error status",
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				WriteJSONError(w, http.StatusBadGateway, "upstream failed")
+			},
+		},
+		{
+			name:   "No-store",
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "no-store")
+				_, _ = w.Write([]byte("secret"))
+			},
+		},
+		{
+			name:   "POST",
+			method: http.MethodPost,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("created"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				tt.handler(w, r)
+			}
+			cached := ResponseCache(ResponseCacheOptions{Store: NewMemoryResponseStore(0, 0), TTL: time.Minute})(handler)
+
+			for i := 0; i < 2; i++ {
+				cached(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/api/x", nil))
+			}
+			if calls.Load() != 2 {
+				t.Errorf("Expected handler to run for every request, ran %d times", calls.Load())
+			}
+		})
+	}
+}
+
+func TestResponseCacheKeepsHeadersOfUncacheableMiss(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Trailer", "X-Checksum")
+		_, _ = w.Write([]byte("hello"))
+		w.Header().Set("X-Checksum", "5d41402a")
+	}
+	store := NewMemoryResponseStore(0, 0)
+	cached := ResponseCache(ResponseCacheOptions{Store: store, TTL: time.Minute})(handler)
+
+	rec := httptest.NewRecorder()
+	cached(rec, httptest.NewRequest(http.MethodGet, "/api/x", nil))
+	resp := rec.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	if got := resp.Header.Get("Set-Cookie"); got != "session=abc" {
+		t.Errorf("Set-Cookie = %q, want the handler's cookie", got)
+	}
+	if got := resp.Trailer.Get("X-Checksum"); got != "5d41402a" {
+		t.Errorf("X-Checksum trailer = %q, want it sent as a trailer", got)
+	}
+	if resp.Header.Get(cacheStatusHeader) != "MISS" || string(body) != "hello" {
+		t.Errorf("response = %s %q, want a MISS with the body", resp.Header.Get(cacheStatusHeader), body)
+	}
+	if entry, _ := store.Get(context.Background(), ResponseCacheKey(httptest.NewRequest(http.MethodGet, "/api/x", nil), "", nil)); entry != nil {
+		t.Error("response with Set-Cookie was stored")
+	}
+}
+
+func TestResponseCacheStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryResponseStore(0, 0)
+	opts := ResponseCacheOptions{Store: store, TTL: time.Minute, StaleTTL: time.Hour, KeyPrefix: t.Name()}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/paper?id=1", nil)
+	key := ResponseCacheKey(req, opts.KeyPrefix, nil)
+	past := time.Now().Add(-2 * time.Minute)
+	_ = store.Set(ctx, key, &CachedEntry{
+		Status:     http.StatusOK,
+		Body:       []byte("old"),
+		StoredAt:   past,
+		FreshUntil: past.Add(time.Minute),
+		StaleUntil: past.Add(time.Hour),
+	})
+
+	refreshed := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new"))
+		close(refreshed)
+	}
+
+	rec := httptest.NewRecorder()
+	ResponseCache(opts)(handler)(rec, req)
+
+	if rec.Header().Get("X-Cache") != "STALE" || rec.Body.String() != "old" {
+		t.Errorf("Expected stale body served first, got %s %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+	if rec.Header().Get("Age") != "120" {
+		t.Errorf("Expected Age 120, got %q", rec.Header().Get("Age"))
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a background refresh")
+	}
+
+	// The refresh stores after the handler returns, so poll briefly
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if entry, _ := store.Get(ctx, key); entry != nil && string(entry.Body) == "new" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("Expected refreshed entry to replace the stale one")
+}
+
+func TestResponseCacheSingleFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = w.Write([]byte("shared"))
+	}
+
+	cached := ResponseCache(ResponseCacheOptions{Store: NewMemoryResponseStore(0, 0), TTL: time.Minute, KeyPrefix: t.Name()})(handler)
+
+	const clients = 8
+	var wg sync.WaitGroup
+	bodies := make([]string, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			cached(rec, httptest.NewRequest(http.MethodGet, "/api/search?q=slow", nil))
+			bodies[idx] = rec.Body.String()
+		}(i)
+	}
+
+	// Give every client time to join the in-flight call before it completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected concurrent misses to collapse into 1 call, got %d", calls.Load())
+	}
+	for i, body := range bodies {
+		if body != "shared" {
+			t.Errorf("Client %d: expected shared body, got %q", i, body)
+		}
+	}
+}
+
+func TestMethodAndCacheWithServerCache(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte("paper"))
+	}
+
+	opts := testCacheOptions()
+	opts.Server = &ResponseCacheOptions{Store: NewMemoryResponseStore(0, 0), KeyPrefix: t.Name()}
+
+	first := httptest.NewRecorder()
+	MethodAndCache(http.MethodGet, opts)(handler)(first, httptest.NewRequest(http.MethodGet, "/api/paper?id=1", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/paper?id=1", nil)
+	req.Header.Set("If-None-Match", first.Header().Get("ETag"))
+	second := httptest.NewRecorder()
+	MethodAndCache(http.MethodGet, opts)(handler)(second, req)
+
+	if second.Code != http.StatusNotModified {
+		t.Errorf("Expected cached response to revalidate with 304, got %d", second.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls.Load())
+	}
+}
@@ -0,0 +1,362 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"main/lib/logger"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Defaults for the in-memory LRU
+	defaultMemoryStoreEntries = 512
+	defaultMemoryStoreBytes   = 64 << 20 // 64MB
+	// Postgres rows past stale_until are pruned once every this many writes
+	postgresPruneInterval = 100
+
+	vercelBlobAPIURL    = "https://blob.vercel-storage.com"
+	vercelBlobBaseURL   = "https://l0m9dfhwc2c0qq2u.public.blob.vercel-storage.com"
+	responseCachePrefix = "response-cache/"
+)
+
+// ResponseStore persists cached responses for ResponseCache
+// Get returns nil, nil on a miss; stores may drop entries at any time
+type ResponseStore interface {
+	Get(ctx context.Context, key string) (*CachedEntry, error)
+	Set(ctx context.Context, key string, entry *CachedEntry) error
+}
+
+var (
+	defaultResponseStore     ResponseStore
+	defaultResponseStoreOnce sync.Once
+)
+
+// DefaultResponseStore returns the process-wide store selected by RESPONSE_CACHE_STORE:
+// "memory" (default), "postgres", "blob" or "off" (returns nil, disabling the cache)
+// Remote stores are fronted by the in-memory LRU. db is only used for "postgres"
+// and only on the first call; without it the store falls back to memory
+func DefaultResponseStore(db *sql.DB) ResponseStore {
+	defaultResponseStoreOnce.Do(func() {
+		backend := strings.ToLower(os.Getenv("RESPONSE_CACHE_STORE"))
+		memory := NewMemoryResponseStore(0, 0)
+
+		switch backend {
+		case "off", "none", "false", "0":
+			defaultResponseStore = nil
+		case "postgres":
+			if db == nil {
+				logger.Warn("RESPONSE_CACHE_STORE=postgres without a database, using memory", nil)
+				defaultResponseStore = memory
+				return
+			}
+			defaultResponseStore = NewTieredResponseStore(memory, NewPostgresResponseStore(db))
+		case "blob":
+			defaultResponseStore = NewTieredResponseStore(memory, NewBlobResponseStore(""))
+		case "", "memory":
+			defaultResponseStore = memory
+		default:
+			logger.Warn("Unknown RESPONSE_CACHE_STORE, using memory", map[string]interface{}{
+				"value": backend,
+			})
+			defaultResponseStore = memory
+		}
+	})
+
+	return defaultResponseStore
+}
+
+// MemoryResponseStore is an in-process LRU bounded by entry count and total body size
+type MemoryResponseStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	size       int
+	order      *list.List // front = most recently used
+	items      map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	entry *CachedEntry
+}
+
+// NewMemoryResponseStore creates an LRU store; zero limits use the defaults (512 entries, 64MB)
+func NewMemoryResponseStore(maxEntries, maxBytes int) *MemoryResponseStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryStoreEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultMemoryStoreBytes
+	}
+	return &MemoryResponseStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns a usable entry and marks it recently used; expired entries are evicted
+func (m *MemoryResponseStore) Get(ctx context.Context, key string) (*CachedEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := elem.Value.(*memoryItem)
+	if !item.entry.IsUsable(time.Now()) {
+		m.remove(elem)
+		return nil, nil
+	}
+	m.order.MoveToFront(elem)
+	return item.entry, nil
+}
+
+// Set stores an entry, evicting least recently used entries to stay within limits
+// Entries larger than the whole store are ignored
+func (m *MemoryResponseStore) Set(ctx context.Context, key string, entry *CachedEntry) error {
+	if len(entry.Body) > m.maxBytes {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	m.items[key] = m.order.PushFront(&memoryItem{key: key, entry: entry})
+	m.size += len(entry.Body)
+
+	for m.order.Len() > m.maxEntries || m.size > m.maxBytes {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Len returns the number of stored entries
+func (m *MemoryResponseStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *MemoryResponseStore) remove(elem *list.Element) {
+	item := m.order.Remove(elem).(*memoryItem)
+	delete(m.items, item.key)
+	m.size -= len(item.entry.Body)
+}
+
+// PostgresResponseStore keeps entries in the response_cache table (migration 0003)
+// so they survive cold starts and are shared between instances
+type PostgresResponseStore struct {
+	db     *sql.DB
+	writes atomic.Int64
+}
+
+// NewPostgresResponseStore creates a store on an open database
+func NewPostgresResponseStore(db *sql.DB) *PostgresResponseStore {
+	return &PostgresResponseStore{db: db}
+}
+
+// Get loads an entry that hasn't passed its stale window
+func (p *PostgresResponseStore) Get(ctx context.Context, key string) (*CachedEntry, error) {
+	var entry CachedEntry
+	var headers []byte
+	err := p.db.QueryRowContext(ctx, `
+		SELECT status, headers, body, stored_at, fresh_until, stale_until
+		FROM response_cache
+		WHERE key = $1 AND stale_until > NOW()`,
+		key,
+	).Scan(&entry.Status, &headers, &entry.Body, &entry.StoredAt, &entry.FreshUntil, &entry.StaleUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached response: %w", err)
+	}
+	if err := json.Unmarshal(headers, &entry.Header); err != nil {
+		return nil, fmt.Errorf("failed to decode cached headers: %w", err)
+	}
+	return &entry, nil
+}
+
+// Set upserts an entry and periodically prunes expired rows
+func (p *PostgresResponseStore) Set(ctx context.Context, key string, entry *CachedEntry) error {
+	headers, err := json.Marshal(entry.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached headers: %w", err)
+	}
+
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO response_cache (key, status, headers, body, stored_at, fresh_until, stale_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (key) DO UPDATE SET
+			status = EXCLUDED.status,
+			headers = EXCLUDED.headers,
+			body = EXCLUDED.body,
+			stored_at = EXCLUDED.stored_at,
+			fresh_until = EXCLUDED.fresh_until,
+			stale_until = EXCLUDED.stale_until`,
+		key, entry.Status, string(headers), entry.Body, entry.StoredAt, entry.FreshUntil, entry.StaleUntil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store cached response: %w", err)
+	}
+
+	if p.writes.Add(1)%postgresPruneInterval == 0 {
+		if _, err := p.Prune(ctx); err != nil {
+			logger.Warn("Failed to prune response cache", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+	return nil
+}
+
+// Prune deletes entries past their stale window
+func (p *PostgresResponseStore) Prune(ctx context.Context) (int64, error) {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM response_cache WHERE stale_until <= NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune response cache: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// BlobResponseStore keeps entries as JSON in Vercel Blob storage under response-cache/
+// Expired blobs are simply overwritten on the next refresh
+type BlobResponseStore struct {
+	prefix string
+	client *http.Client
+}
+
+// NewBlobResponseStore creates a blob store; an empty prefix uses "response-cache/"
+func NewBlobResponseStore(prefix string) *BlobResponseStore {
+	if prefix == "" {
+		prefix = responseCachePrefix
+	}
+	return &BlobResponseStore{
+		prefix: prefix,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// blobPath maps a key to a blob pathname (keys may contain ':' from the route prefix)
+func (b *BlobResponseStore) blobPath(key string) string {
+	return b.prefix + strings.ReplaceAll(key, ":", "/") + ".json"
+}
+
+// Get fetches an entry by its deterministic public URL
+func (b *BlobResponseStore) Get(ctx context.Context, key string) (*CachedEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", vercelBlobBaseURL, b.blobPath(key)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob request: %w", err)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch cached response blob: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cached response blob returned status: %s", resp.Status)
+	}
+
+	var entry CachedEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cached response blob: %w", err)
+	}
+	if !entry.IsUsable(time.Now()) {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// Set writes an entry to blob storage
+func (b *BlobResponseStore) Set(ctx context.Context, key string, entry *CachedEntry) error {
+	token := os.Getenv("BLOB_READ_WRITE_TOKEN")
+	if token == "" {
+		return fmt.Errorf("BLOB_READ_WRITE_TOKEN not set")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/%s", vercelBlobAPIURL, b.blobPath(key)), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create PUT request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-add-random-suffix", "0")
+	req.Header.Set("x-allow-overwrite", "1")
+	// Blob CDN copies must not outlive the refresh that replaces them
+	req.Header.Set("x-cache-control-max-age", "60")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute PUT request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("blob store PUT returned non-200 status: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+// TieredResponseStore checks a fast local store before a shared remote one
+// Remote hits are copied into the local store; writes go to both
+type TieredResponseStore struct {
+	local  ResponseStore
+	remote ResponseStore
+}
+
+// NewTieredResponseStore combines a local and a remote store
+func NewTieredResponseStore(local, remote ResponseStore) *TieredResponseStore {
+	return &TieredResponseStore{local: local, remote: remote}
+}
+
+// Get reads through the local store to the remote one
+func (t *TieredResponseStore) Get(ctx context.Context, key string) (*CachedEntry, error) {
+	entry, err := t.local.Get(ctx, key)
+	if err == nil && entry != nil && entry.IsFresh(time.Now()) {
+		return entry, nil
+	}
+
+	remote, err := t.remote.Get(ctx, key)
+	if err != nil {
+		// Stale local data is better than nothing while the remote is down
+		return entry, err
+	}
+	if remote == nil {
+		return entry, nil
+	}
+	_ = t.local.Set(ctx, key, remote)
+	return remote, nil
+}
+
+// Set writes to the local store, then the remote one
+func (t *TieredResponseStore) Set(ctx context.Context, key string, entry *CachedEntry) error {
+	_ = t.local.Set(ctx, key, entry)
+	return t.remote.Set(ctx, key, entry)
+}
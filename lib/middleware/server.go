@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"main/lib/logger"
+	"net/http"
+	"time"
+)
+
+// statusRecorder records the status code written through it
+// Flush and Unwrap keep streaming handlers (ds1) working behind it
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.statusCode = code
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(data)
+}
+
+// Flush forwards to the underlying writer if it supports flushing
+func (s *statusRecorder) Flush() {
+	s.wroteHeader = true
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the original ResponseWriter (used by http.ResponseController)
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// RequestID makes sure every request carries an X-Request-ID, so the handler's
+// logger.Log.WithRequest context and the access log share one ID
+// The ID is echoed back in the response headers
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			bytes := make([]byte, 8)
+			_, _ = rand.Read(bytes)
+			requestID = hex.EncodeToString(bytes)
+			r.Header.Set("X-Request-ID", requestID)
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		next(w, r)
+	}
+}
+
+// RequestLogger logs each completed request with its status and duration
+func RequestLogger(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next(recorder, r)
+		logger.LogRequestComplete(r, recorder.statusCode, time.Since(start))
+	}
+}
+
+// Recoverer turns a handler panic into a 500 instead of killing the server
+// Vercel isolates each invocation, but a self-hosted server runs every route in one process
+func Recoverer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				logger.LogRequestError(r, fmt.Errorf("panic: %v", rec), http.StatusInternalServerError)
+				WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+			}
+		}()
+		next(w, r)
+	}
+}
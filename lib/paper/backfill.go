@@ -0,0 +1,545 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/lib/logger"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	hfDailyPapersURL = "https://huggingface.co/api/daily_papers"
+	// Blobs listed per page when walking papers/
+	backfillBlobPageSize = 100
+	// Concurrent blob downloads per page
+	backfillFetchConcurrency = 8
+)
+
+// BackfillItem is a paper and the text to embed for it
+type BackfillItem struct {
+	PaperID string `json:"paperId"`
+	Text    string `json:"text"`
+}
+
+// BackfillSource yields papers page by page
+// cursor is opaque to the caller; an empty next cursor means the source is exhausted
+type BackfillSource interface {
+	Name() string
+	Next(ctx context.Context, cursor string) (items []BackfillItem, next string, err error)
+}
+
+// BackfillCheckpoint records progress so an interrupted backfill can resume
+// Papers that failed to embed or store are kept in Pending and retried by the next run,
+// since the cursor has already moved past their page
+type BackfillCheckpoint struct {
+	Source string `json:"source"`
+	Model  string `json:"model"`
+	Cursor string `json:"cursor"`
+	// Exhausted is set once the source has no more pages
+	Exhausted bool `json:"exhausted"`
+	// Done is set once the source is exhausted and nothing is pending
+	Done     bool `json:"done"`
+	Seen     int  `json:"seen"`
+	Embedded int  `json:"embedded"`
+	Existing int  `json:"existing"`
+	// Failed is the number of papers in Pending
+	Failed    int            `json:"failed"`
+	Pending   []BackfillItem `json:"pending,omitempty"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// LoadBackfillCheckpoint reads a checkpoint file, returning nil if it doesn't exist
+func LoadBackfillCheckpoint(path string) (*BackfillCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint BackfillCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// Save writes the checkpoint atomically (temp file + rename) so a crash never leaves it half-written
+func (c *BackfillCheckpoint) Save(path string) error {
+	c.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint: %w", err)
+	}
+	return nil
+}
+
+// BackfillProgress is reported after every page
+type BackfillProgress struct {
+	Checkpoint BackfillCheckpoint
+	Elapsed    time.Duration
+	// Papers embedded per second over the whole run
+	Rate float64
+}
+
+// BackfillStore is where backfilled embeddings are looked up and written; *VectorDBCache implements it
+type BackfillStore interface {
+	Model() EmbeddingModel
+	GetResultEmbeddingsBatch(ctx context.Context, paperIDs []string) (map[string][]float32, error)
+	AddResultEmbeddingsBatch(embeddings map[string][]float32) error
+}
+
+// BackfillEmbedder embeds texts in order; *EmbeddingService implements it
+type BackfillEmbedder interface {
+	GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// BackfillOptions configures RunBackfill
+type BackfillOptions struct {
+	Source  BackfillSource
+	Cache   BackfillStore
+	Service BackfillEmbedder
+	// CheckpointPath is where progress is saved after each page (empty disables checkpointing)
+	CheckpointPath string
+	// Limit stops after this many papers have been seen (0 means no limit)
+	Limit int
+	// OnProgress is called after each page
+	OnProgress func(BackfillProgress)
+}
+
+// RunBackfill embeds every paper from the source that the vector store doesn't have yet
+// Embeddings go through GenerateEmbeddings (so the service's batching and concurrency limits apply)
+// and are written with AddResultEmbeddingsBatch. Progress resumes from CheckpointPath if present,
+// starting with the papers that failed in earlier runs
+func RunBackfill(ctx context.Context, opts BackfillOptions) (*BackfillCheckpoint, error) {
+	if opts.Source == nil || opts.Cache == nil || opts.Service == nil {
+		return nil, fmt.Errorf("backfill requires a source, vector cache and embedding service")
+	}
+	if cache, ok := opts.Cache.(*VectorDBCache); ok && (!cache.dbEnabled || !IsDBEnabled()) {
+		return nil, fmt.Errorf("vector database is not available")
+	}
+
+	model := opts.Cache.Model().String()
+	checkpoint := &BackfillCheckpoint{Source: opts.Source.Name(), Model: model}
+
+	if opts.CheckpointPath != "" {
+		saved, err := LoadBackfillCheckpoint(opts.CheckpointPath)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			if saved.Source != checkpoint.Source || saved.Model != model {
+				return nil, fmt.Errorf("checkpoint %s is for %s/%s, not %s/%s (delete it to start over)",
+					opts.CheckpointPath, saved.Source, saved.Model, checkpoint.Source, model)
+			}
+			checkpoint = saved
+			logger.Info("Resuming backfill from checkpoint", map[string]interface{}{
+				"source": checkpoint.Source,
+				"cursor": checkpoint.Cursor,
+				"seen":   checkpoint.Seen,
+			})
+		}
+	}
+
+	if checkpoint.Done {
+		return checkpoint, nil
+	}
+
+	startTime := time.Now()
+	startEmbedded := checkpoint.Embedded
+
+	// report saves the checkpoint and reports progress after each page
+	report := func() error {
+		checkpoint.Failed = len(checkpoint.Pending)
+		checkpoint.Done = checkpoint.Exhausted && len(checkpoint.Pending) == 0
+		if opts.CheckpointPath != "" {
+			if err := checkpoint.Save(opts.CheckpointPath); err != nil {
+				return err
+			}
+		}
+		if opts.OnProgress != nil {
+			elapsed := time.Since(startTime)
+			rate := 0.0
+			if elapsed > 0 {
+				rate = float64(checkpoint.Embedded-startEmbedded) / elapsed.Seconds()
+			}
+			opts.OnProgress(BackfillProgress{Checkpoint: *checkpoint, Elapsed: elapsed, Rate: rate})
+		}
+		return nil
+	}
+
+	// Papers that failed in an earlier run go first; those failing again stay pending
+	if len(checkpoint.Pending) > 0 {
+		logger.Info("Retrying papers that failed in an earlier backfill run", map[string]interface{}{
+			"count": len(checkpoint.Pending),
+		})
+		embedded, existing, failed := backfillPage(ctx, opts, checkpoint.Pending)
+		checkpoint.Embedded += embedded
+		checkpoint.Existing += existing
+		checkpoint.Pending = failed
+		if err := report(); err != nil {
+			return checkpoint, err
+		}
+	}
+
+	for !checkpoint.Exhausted {
+		if err := ctx.Err(); err != nil {
+			return checkpoint, err
+		}
+		if opts.Limit > 0 && checkpoint.Seen >= opts.Limit {
+			break
+		}
+
+		items, next, err := opts.Source.Next(ctx, checkpoint.Cursor)
+		if err != nil {
+			return checkpoint, fmt.Errorf("failed to read %s page at cursor %q: %w", opts.Source.Name(), checkpoint.Cursor, err)
+		}
+
+		embedded, existing, failed := backfillPage(ctx, opts, items)
+		checkpoint.Seen += len(items)
+		checkpoint.Embedded += embedded
+		checkpoint.Existing += existing
+		checkpoint.Pending = append(checkpoint.Pending, failed...)
+
+		checkpoint.Cursor = next
+		checkpoint.Exhausted = next == ""
+		if err := report(); err != nil {
+			return checkpoint, err
+		}
+	}
+
+	return checkpoint, nil
+}
+
+// backfillPage embeds and stores the items not already in the vector store
+// Returns counts of embedded and already-present papers, and the papers that failed
+func backfillPage(ctx context.Context, opts BackfillOptions, items []BackfillItem) (int, int, []BackfillItem) {
+	if len(items) == 0 {
+		return 0, 0, nil
+	}
+
+	// Dedupe within the page (HF history repeats papers across days)
+	unique := make(map[string]string, len(items))
+	paperIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.PaperID == "" || item.Text == "" {
+			continue
+		}
+		if _, ok := unique[item.PaperID]; !ok {
+			unique[item.PaperID] = item.Text
+			paperIDs = append(paperIDs, item.PaperID)
+		}
+	}
+
+	existingEmbeddings, err := opts.Cache.GetResultEmbeddingsBatch(ctx, paperIDs)
+	if err != nil {
+		logger.Warn("Failed to check existing embeddings, embedding whole page", map[string]interface{}{
+			"error": err.Error(),
+		})
+		existingEmbeddings = nil
+	}
+
+	missingIDs := make([]string, 0, len(paperIDs))
+	missingTexts := make([]string, 0, len(paperIDs))
+	for _, paperID := range paperIDs {
+		if _, ok := existingEmbeddings[paperID]; ok {
+			continue
+		}
+		missingIDs = append(missingIDs, paperID)
+		missingTexts = append(missingTexts, unique[paperID])
+	}
+
+	existing := len(paperIDs) - len(missingIDs)
+	if len(missingIDs) == 0 {
+		return 0, existing, nil
+	}
+	failed := make([]BackfillItem, len(missingIDs))
+	for i, paperID := range missingIDs {
+		failed[i] = BackfillItem{PaperID: paperID, Text: missingTexts[i]}
+	}
+
+	embeddings, err := opts.Service.GenerateEmbeddings(ctx, missingTexts)
+	if err != nil || len(embeddings) != len(missingIDs) {
+		logger.Error("Failed to embed backfill page", err, map[string]interface{}{
+			"count": len(missingIDs),
+		})
+		return 0, existing, failed
+	}
+
+	toStore := make(map[string][]float32, len(missingIDs))
+	for i, paperID := range missingIDs {
+		toStore[paperID] = embeddings[i]
+	}
+
+	if err := opts.Cache.AddResultEmbeddingsBatch(toStore); err != nil {
+		var partial *StoreEmbeddingsError
+		if !errors.As(err, &partial) {
+			logger.Error("Failed to store backfill embeddings", err, map[string]interface{}{
+				"count": len(toStore),
+			})
+			return 0, existing, failed
+		}
+
+		// Only the papers in the failed chunks stay pending
+		unstored := make(map[string]bool, len(partial.PaperIDs))
+		for _, paperID := range partial.PaperIDs {
+			unstored[paperID] = true
+		}
+		var stillFailed []BackfillItem
+		for _, item := range failed {
+			if unstored[item.PaperID] {
+				stillFailed = append(stillFailed, item)
+			}
+		}
+		logger.Error("Failed to store some backfill embeddings", err, map[string]interface{}{
+			"count":  len(toStore),
+			"failed": len(stillFailed),
+		})
+		return len(missingIDs) - len(stillFailed), existing, stillFailed
+	}
+
+	return len(missingIDs), existing, nil
+}
+
+// BlobPaperSource walks every paper cached under papers/ in Vercel Blob storage
+// The cursor is the blob list API's pagination cursor
+type BlobPaperSource struct {
+	client *http.Client
+	token  string
+}
+
+// NewBlobPaperSource creates a source over the papers/ blob prefix
+func NewBlobPaperSource() (*BlobPaperSource, error) {
+	token := os.Getenv("BLOB_READ_WRITE_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("BLOB_READ_WRITE_TOKEN not set")
+	}
+	return &BlobPaperSource{
+		client: &http.Client{Timeout: 30 * time.Second},
+		token:  token,
+	}, nil
+}
+
+// Name identifies the source in checkpoints
+func (s *BlobPaperSource) Name() string {
+	return "blob"
+}
+
+// blobListPage is the paginated blob list API response
+type blobListPage struct {
+	Blobs []struct {
+		URL      string `json:"url"`
+		Pathname string `json:"pathname"`
+	} `json:"blobs"`
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"hasMore"`
+}
+
+// Next lists one page of paper blobs and downloads them
+func (s *BlobPaperSource) Next(ctx context.Context, cursor string) ([]BackfillItem, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, vercelBlobAPIURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create list request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	q := req.URL.Query()
+	q.Add("prefix", papersPrefix)
+	q.Add("limit", strconv.Itoa(backfillBlobPageSize))
+	if cursor != "" {
+		q.Add("cursor", cursor)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list paper blobs: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("blob list API returned non-200: %s", resp.Status)
+	}
+
+	var page blobListPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, "", fmt.Errorf("failed to decode blob list response: %w", err)
+	}
+
+	items := make([]BackfillItem, len(page.Blobs))
+	semaphore := make(chan struct{}, backfillFetchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, blob := range page.Blobs {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(idx int, blobURL string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			paperData, err := s.fetchPaper(ctx, blobURL)
+			if err != nil {
+				logger.Debug("Skipping unreadable paper blob", map[string]interface{}{
+					"url":   blobURL,
+					"error": err.Error(),
+				})
+				return
+			}
+			items[idx] = BackfillItem{
+				PaperID: paperData.ArxivID,
+				Text:    embeddingText(paperData.Title, paperData.Abstract),
+			}
+		}(i, blob.URL)
+	}
+	wg.Wait()
+
+	next := ""
+	if page.HasMore {
+		next = page.Cursor
+	}
+
+	return items, next, nil
+}
+
+// fetchPaper downloads a single paper JSON blob
+func (s *BlobPaperSource) fetchPaper(ctx context.Context, blobURL string) (*PaperData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+
+	var paperData PaperData
+	if err := json.NewDecoder(resp.Body).Decode(&paperData); err != nil {
+		return nil, err
+	}
+	if paperData.ArxivID == "" || paperData.Title == "" {
+		return nil, fmt.Errorf("paper blob missing id or title")
+	}
+
+	return &paperData, nil
+}
+
+// DailyPapersSource walks the HuggingFace daily papers history one day at a time,
+// newest first, from Until back to Since
+// The cursor is the next date to fetch (YYYY-MM-DD)
+type DailyPapersSource struct {
+	Since  time.Time
+	Until  time.Time
+	client *http.Client
+}
+
+// NewDailyPapersSource creates a source over the HF daily papers between two dates (inclusive)
+func NewDailyPapersSource(since, until time.Time) *DailyPapersSource {
+	return &DailyPapersSource{
+		Since:  since.UTC().Truncate(24 * time.Hour),
+		Until:  until.UTC().Truncate(24 * time.Hour),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name identifies the source (and its date range) in checkpoints
+func (s *DailyPapersSource) Name() string {
+	return fmt.Sprintf("hf:%s..%s", s.Since.Format("2006-01-02"), s.Until.Format("2006-01-02"))
+}
+
+// hfDailyPaperItem is the subset of the daily_papers response needed for embedding
+type hfDailyPaperItem struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Paper   struct {
+		ID      string `json:"id"`
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	} `json:"paper"`
+}
+
+// Next fetches the daily papers for the cursor date (or Until on the first call)
+func (s *DailyPapersSource) Next(ctx context.Context, cursor string) ([]BackfillItem, string, error) {
+	date := s.Until
+	if cursor != "" {
+		parsed, err := time.Parse("2006-01-02", cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid cursor %q: %w", cursor, err)
+		}
+		date = parsed
+	}
+
+	if date.Before(s.Since) {
+		return nil, "", nil
+	}
+
+	next := ""
+	if prev := date.AddDate(0, 0, -1); !prev.Before(s.Since) {
+		next = prev.Format("2006-01-02")
+	}
+
+	pageURL := hfDailyPapersURL + "?date=" + url.QueryEscape(date.Format("2006-01-02"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Takara-TLDR/1.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch daily papers: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("daily papers returned status %d", resp.StatusCode)
+	}
+
+	var response []hfDailyPaperItem
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, "", fmt.Errorf("failed to decode daily papers: %w", err)
+	}
+
+	items := make([]BackfillItem, 0, len(response))
+	for _, item := range response {
+		// Prefer the top-level title/summary like the summary scraper, fall back to paper-level
+		title := item.Title
+		if title == "" {
+			title = item.Paper.Title
+		}
+		summary := item.Summary
+		if summary == "" {
+			summary = item.Paper.Summary
+		}
+		if item.Paper.ID == "" || title == "" {
+			continue
+		}
+		items = append(items, BackfillItem{
+			PaperID: item.Paper.ID,
+			Text:    embeddingText(title, summary),
+		})
+	}
+
+	return items, next, nil
+}
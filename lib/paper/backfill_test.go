@@ -0,0 +1,205 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestBackfillCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	missing, err := LoadBackfillCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadBackfillCheckpoint on missing file failed: %v", err)
+	}
+	if missing != nil {
+		t.Fatal("Expected nil checkpoint for missing file")
+	}
+
+	checkpoint := &BackfillCheckpoint{
+		Source:   "hf:2025-01-01..2025-01-31",
+		Model:    "ds1@512",
+		Cursor:   "2025-01-15",
+		Seen:     120,
+		Embedded: 100,
+		Existing: 18,
+		Failed:   2,
+	}
+	if err := checkpoint.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := LoadBackfillCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadBackfillCheckpoint failed: %v", err)
+	}
+	if loaded.Cursor != "2025-01-15" || loaded.Seen != 120 || loaded.Embedded != 100 || loaded.Failed != 2 {
+		t.Errorf("Loaded checkpoint does not match saved: %+v", loaded)
+	}
+	if loaded.UpdatedAt.IsZero() {
+		t.Error("Expected UpdatedAt to be set on save")
+	}
+}
+
+func TestEmbeddingText(t *testing.T) {
+	if got := embeddingText("Title", "Summary"); got != "Title. Summary" {
+		t.Errorf("embeddingText = %q; expected %q", got, "Title. Summary")
+	}
+	if got := embeddingText("Title", ""); got != "Title" {
+		t.Errorf("embeddingText without summary = %q; expected %q", got, "Title")
+	}
+}
+
+// fakeBackfillSource serves fixed pages; the cursor is the page index
+type fakeBackfillSource struct {
+	pages [][]BackfillItem
+}
+
+func (s *fakeBackfillSource) Name() string { return "fake" }
+
+func (s *fakeBackfillSource) Next(ctx context.Context, cursor string) ([]BackfillItem, string, error) {
+	page := 0
+	if cursor != "" {
+		page, _ = strconv.Atoi(cursor)
+	}
+	next := ""
+	if page+1 < len(s.pages) {
+		next = strconv.Itoa(page + 1)
+	}
+	return s.pages[page], next, nil
+}
+
+// fakeBackfillStore keeps embeddings in a map, dropping those in drop like a failed insert chunk
+type fakeBackfillStore struct {
+	stored map[string][]float32
+	drop   map[string]bool
+}
+
+func (s *fakeBackfillStore) Model() EmbeddingModel {
+	return EmbeddingModel{ID: "fake", Dimension: 2}
+}
+
+func (s *fakeBackfillStore) GetResultEmbeddingsBatch(ctx context.Context, paperIDs []string) (map[string][]float32, error) {
+	found := make(map[string][]float32)
+	for _, id := range paperIDs {
+		if embedding, ok := s.stored[id]; ok {
+			found[id] = embedding
+		}
+	}
+	return found, nil
+}
+
+func (s *fakeBackfillStore) AddResultEmbeddingsBatch(embeddings map[string][]float32) error {
+	var dropped []string
+	for id, embedding := range embeddings {
+		if s.drop[id] {
+			dropped = append(dropped, id)
+			continue
+		}
+		s.stored[id] = embedding
+	}
+	if len(dropped) > 0 {
+		return &StoreEmbeddingsError{PaperIDs: dropped, Err: errors.New("chunk insert failed")}
+	}
+	return nil
+}
+
+// fakeEmbedder fails any batch containing a text in failOn
+type fakeEmbedder struct {
+	failOn map[string]bool
+}
+
+func (e *fakeEmbedder) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		if e.failOn[text] {
+			return nil, errors.New("endpoint unavailable")
+		}
+		embeddings[i] = []float32{1, 0}
+	}
+	return embeddings, nil
+}
+
+func TestRunBackfillRetriesFailedPapersOnResume(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	source := &fakeBackfillSource{pages: [][]BackfillItem{
+		{{PaperID: "p1", Text: "one"}, {PaperID: "p2", Text: "two"}},
+		{{PaperID: "p3", Text: "three"}, {PaperID: "p4", Text: "four"}},
+		{{PaperID: "p5", Text: "five"}},
+	}}
+	store := &fakeBackfillStore{stored: map[string][]float32{"p2": {0, 1}}}
+	embedder := &fakeEmbedder{failOn: map[string]bool{"three": true}}
+	opts := BackfillOptions{Source: source, Cache: store, Service: embedder, CheckpointPath: path}
+
+	// The second page fails; the run goes on and keeps its papers pending
+	checkpoint, err := RunBackfill(ctx, opts)
+	if err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	if !checkpoint.Exhausted || checkpoint.Done || checkpoint.Failed != 2 {
+		t.Fatalf("first run checkpoint = %+v, want exhausted, not done, 2 failed", checkpoint)
+	}
+	if checkpoint.Seen != 5 || checkpoint.Embedded != 2 || checkpoint.Existing != 1 {
+		t.Errorf("first run counts = %+v", checkpoint)
+	}
+	if _, ok := store.stored["p3"]; ok {
+		t.Fatal("p3 stored despite the failed page")
+	}
+
+	saved, err := LoadBackfillCheckpoint(path)
+	if err != nil || len(saved.Pending) != 2 || saved.Pending[0].PaperID != "p3" || saved.Pending[1].Text != "four" {
+		t.Fatalf("saved pending = %+v, %v", saved, err)
+	}
+
+	// Resuming retries only the pending papers, without re-reading the source
+	embedder.failOn = nil
+	source.pages = nil
+	checkpoint, err = RunBackfill(ctx, opts)
+	if err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	if !checkpoint.Done || checkpoint.Failed != 0 || len(checkpoint.Pending) != 0 || checkpoint.Embedded != 4 {
+		t.Fatalf("resumed checkpoint = %+v, want done with 4 embedded", checkpoint)
+	}
+	for _, id := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		if _, ok := store.stored[id]; !ok {
+			t.Errorf("%s not stored after resume", id)
+		}
+	}
+}
+
+func TestRunBackfillKeepsDroppedChunkPending(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeBackfillSource{pages: [][]BackfillItem{
+		{{PaperID: "p1", Text: "one"}, {PaperID: "p2", Text: "two"}, {PaperID: "p3", Text: "three"}},
+	}}
+	store := &fakeBackfillStore{stored: map[string][]float32{}, drop: map[string]bool{"p2": true}}
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	opts := BackfillOptions{Source: source, Cache: store, Service: &fakeEmbedder{}, CheckpointPath: path}
+
+	// The insert chunk holding p2 fails; the others are stored
+	checkpoint, err := RunBackfill(ctx, opts)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if checkpoint.Embedded != 2 || checkpoint.Failed != 1 || checkpoint.Done {
+		t.Fatalf("checkpoint = %+v, want 2 embedded, 1 failed, not done", checkpoint)
+	}
+	if len(checkpoint.Pending) != 1 || checkpoint.Pending[0].PaperID != "p2" || checkpoint.Pending[0].Text != "two" {
+		t.Fatalf("pending = %+v, want only p2", checkpoint.Pending)
+	}
+
+	// Resuming retries it
+	store.drop = nil
+	checkpoint, err = RunBackfill(ctx, opts)
+	if err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	if _, ok := store.stored["p2"]; !ok || !checkpoint.Done || checkpoint.Embedded != 3 {
+		t.Errorf("resumed checkpoint = %+v, stored p2 = %v", checkpoint, ok)
+	}
+}
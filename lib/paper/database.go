@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"main/lib/logger"
+	"main/lib/middleware"
 	"os"
 	"strings"
 	"sync"
@@ -28,7 +29,7 @@ func IsVectorDBEnabled() bool {
 	return true // Default to enabled
 }
 
-// InitDB initializes the database connection
+// InitDB initializes the database connection and applies pending schema migrations
 func InitDB() error {
 	dbOnce.Do(func() {
 		// Check if vector DB is enabled
@@ -36,62 +37,10 @@ func InitDB() error {
 			dbInitErr = fmt.Errorf("vector DB disabled via VECTOR_DB_ENABLED environment variable")
 			return
 		}
-		
-		// Get connection string - always use VECTOR_DB_ prefix
-		connStr := os.Getenv("VECTOR_DB_DATABASE_URL")
-		
-		// If no full connection string, try to construct from individual parts
-		if connStr == "" {
-			// Try unpooled first (for serverless), then regular
-			host := os.Getenv("VECTOR_DB_PGHOST_UNPOOLED")
-			if host == "" {
-				host = os.Getenv("VECTOR_DB_PGHOST")
-			}
-			
-			port := os.Getenv("VECTOR_DB_PGPORT")
-			user := os.Getenv("VECTOR_DB_PGUSER")
-			password := os.Getenv("VECTOR_DB_PGPASSWORD")
-			database := os.Getenv("VECTOR_DB_PGDATABASE")
-			
-			if host != "" && user != "" && password != "" && database != "" {
-				if port == "" {
-					port = "5432"
-				}
-				connStr = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=require", user, password, host, port, database)
-			}
-		}
-		if connStr == "" {
-			dbInitErr = fmt.Errorf("VECTOR_DB_DATABASE_URL or VECTOR_DB_PG* environment variables are not set")
-			return
-		}
-
-		// Configure connection pool for serverless (avoid prepared statement conflicts)
-		// Add connection parameters to disable prepared statements for serverless compatibility
-		if !strings.Contains(connStr, "prefer_simple_protocol") {
-			if strings.Contains(connStr, "?") {
-				connStr += "&prefer_simple_protocol=1"
-			} else {
-				connStr += "?prefer_simple_protocol=1"
-			}
-		}
 
-		db, err := sql.Open("pgx", connStr)
+		db, err := OpenDB()
 		if err != nil {
-			dbInitErr = fmt.Errorf("failed to open database connection: %w", err)
-			logger.Error("Failed to open database connection", err, nil)
-			return
-		}
-
-		// Configure connection pool settings for serverless
-		db.SetMaxOpenConns(1)  // Single connection for serverless
-		db.SetMaxIdleConns(1)  // Single idle connection
-		db.SetConnMaxLifetime(0) // Don't close connections based on time
-
-		// Test connection
-		if err := db.Ping(); err != nil {
-			dbInitErr = fmt.Errorf("failed to ping database: %w", err)
-			logger.Error("Failed to ping database", err, nil)
-			_ = db.Close()
+			dbInitErr = err
 			return
 		}
 
@@ -105,6 +54,67 @@ func InitDB() error {
 	return dbInitErr
 }
 
+// OpenDB opens and pings a new connection from the VECTOR_DB_* environment
+// without touching the schema (used by InitDB and the migrate CLI)
+func OpenDB() (*sql.DB, error) {
+	// Get connection string - always use VECTOR_DB_ prefix
+	connStr := os.Getenv("VECTOR_DB_DATABASE_URL")
+	
+	// If no full connection string, try to construct from individual parts
+	if connStr == "" {
+		// Try unpooled first (for serverless), then regular
+		host := os.Getenv("VECTOR_DB_PGHOST_UNPOOLED")
+		if host == "" {
+			host = os.Getenv("VECTOR_DB_PGHOST")
+		}
+		
+		port := os.Getenv("VECTOR_DB_PGPORT")
+		user := os.Getenv("VECTOR_DB_PGUSER")
+		password := os.Getenv("VECTOR_DB_PGPASSWORD")
+		database := os.Getenv("VECTOR_DB_PGDATABASE")
+		
+		if host != "" && user != "" && password != "" && database != "" {
+			if port == "" {
+				port = "5432"
+			}
+			connStr = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=require", user, password, host, port, database)
+		}
+	}
+	if connStr == "" {
+		return nil, fmt.Errorf("VECTOR_DB_DATABASE_URL or VECTOR_DB_PG* environment variables are not set")
+	}
+
+	// Configure connection pool for serverless (avoid prepared statement conflicts)
+	// Add connection parameters to disable prepared statements for serverless compatibility
+	if !strings.Contains(connStr, "prefer_simple_protocol") {
+		if strings.Contains(connStr, "?") {
+			connStr += "&prefer_simple_protocol=1"
+		} else {
+			connStr += "?prefer_simple_protocol=1"
+		}
+	}
+
+	db, err := sql.Open("pgx", connStr)
+	if err != nil {
+		logger.Error("Failed to open database connection", err, nil)
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+
+	// Configure connection pool settings for serverless
+	db.SetMaxOpenConns(1)  // Single connection for serverless
+	db.SetMaxIdleConns(1)  // Single idle connection
+	db.SetConnMaxLifetime(0) // Don't close connections based on time
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		logger.Error("Failed to ping database", err, nil)
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
 // GetDB returns the global database connection
 // Returns nil if database is not initialized or unavailable
 func GetDB() *sql.DB {
@@ -127,3 +137,12 @@ func IsDBEnabled() bool {
 	return dbInitialized && globalDB != nil
 }
 
+
+// ResponseStore returns the server-side response cache store for API handlers,
+// backed by the response_cache table when RESPONSE_CACHE_STORE=postgres
+func ResponseStore() middleware.ResponseStore {
+	if strings.EqualFold(os.Getenv("RESPONSE_CACHE_STORE"), "postgres") && InitDB() == nil {
+		return middleware.DefaultResponseStore(GetDB())
+	}
+	return middleware.DefaultResponseStore(nil)
+}
@@ -80,6 +80,12 @@ func NewEmbeddingService() (*EmbeddingService, error) {
 		endpointName = defaultEndpointName
 	}
 
+	return NewEmbeddingServiceForEndpoint(endpointName)
+}
+
+// NewEmbeddingServiceForEndpoint creates an embedding service for a specific SageMaker endpoint
+// Used when re-embedding with a model other than the active one
+func NewEmbeddingServiceForEndpoint(endpointName string) (*EmbeddingService, error) {
 	region := os.Getenv("AWS_REGION")
 	if region == "" {
 		region = defaultRegion
@@ -350,6 +356,60 @@ func (e *EmbeddingService) GenerateEmbeddings(ctx context.Context, texts []strin
 	return result, nil
 }
 
+// MaxBatchSize returns the largest number of texts sent to the endpoint in one request
+func (e *EmbeddingService) MaxBatchSize() int {
+	return maxBatchSize
+}
+
+// StreamEmbeddings generates embeddings for any number of texts and passes them to emit
+// in input order, one maxBatchSize chunk at a time (start is the index of the chunk's first text)
+// Texts are processed in windows of maxBatchSize*maxConcurrency through GenerateEmbeddings so the
+// semaphore limits still apply; the next window is generated while the current one is emitted
+// On failure, every chunk before the failing window has already been emitted
+func (e *EmbeddingService) StreamEmbeddings(ctx context.Context, texts []string, emit func(start int, embeddings [][]float32) error) error {
+	if len(texts) == 0 {
+		return fmt.Errorf("no texts provided")
+	}
+
+	window := maxBatchSize * maxConcurrency
+
+	type windowResult struct {
+		embeddings [][]float32
+		err        error
+	}
+
+	// Buffered so an abandoned prefetch never blocks its goroutine
+	next := make(chan windowResult, 1)
+	generate := func(start int) {
+		end := min(start+window, len(texts))
+		go func() {
+			embeddings, err := e.GenerateEmbeddings(ctx, texts[start:end])
+			next <- windowResult{embeddings: embeddings, err: err}
+		}()
+	}
+
+	generate(0)
+	for start := 0; start < len(texts); start += window {
+		result := <-next
+		if result.err != nil {
+			return fmt.Errorf("failed to embed texts %d-%d: %w", start, min(start+window, len(texts))-1, result.err)
+		}
+
+		if start+window < len(texts) {
+			generate(start + window)
+		}
+
+		for offset := 0; offset < len(result.embeddings); offset += maxBatchSize {
+			end := min(offset+maxBatchSize, len(result.embeddings))
+			if err := emit(start+offset, result.embeddings[offset:end]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // generateEmbeddingsBatch generates embeddings for a single batch (max maxBatchSize)
 // Checks cache and handles both cached and uncached texts
 func (e *EmbeddingService) generateEmbeddingsBatch(ctx context.Context, texts []string) ([][]float32, error) {
@@ -2,10 +2,31 @@ package paper
 
 import (
 	"context"
+	"database/sql"
+	"embed"
 	"fmt"
+	"io/fs"
 	"main/lib/logger"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+)
+
+// Migrations live in lib/paper/migrations as NNNN_name.up.sql / NNNN_name.down.sql
+// and are embedded so serverless functions and the CLI ship the same schema
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const (
+	migrationsDir = "migrations"
+
+	// migrationLockKey is the pg_advisory_lock key guarding schema changes so
+	// concurrent cold starts don't race each other applying the same migration
+	migrationLockKey int64 = 0x746c6472 // "tldr"
 )
 
 var (
@@ -14,7 +35,22 @@ var (
 	schemaInitErr     error
 )
 
-// InitSchema initializes the database schema (tables, indexes, extensions)
+// Migration is a single numbered schema change with its up and down SQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// InitSchema brings the database schema up to the latest embedded migration
 // This should be called once when the database is first set up
 // Uses sync.Once to ensure it only runs once per process
 func InitSchema(ctx context.Context) error {
@@ -25,53 +61,17 @@ func InitSchema(ctx context.Context) error {
 			return
 		}
 
-		// Quick check if schema already exists (faster than running all CREATE IF NOT EXISTS)
-		var exists int
-		err := db.QueryRowContext(ctx, `
-			SELECT 1 FROM information_schema.tables 
-			WHERE table_name = 'result_embeddings' 
-			LIMIT 1
-		`).Scan(&exists)
-		
-		if err == nil {
-			schemaInitialized = true
+		applied, err := MigrateUp(ctx, db, 0)
+		if err != nil {
+			logger.Error("Failed to apply schema migrations", err, nil)
+			schemaInitErr = fmt.Errorf("failed to migrate schema: %w", err)
 			return
 		}
 
-		// Execute schema statements individually
-		statements := []string{
-			"CREATE EXTENSION IF NOT EXISTS vector",
-			`CREATE TABLE IF NOT EXISTS query_embeddings (
-				id SERIAL PRIMARY KEY,
-				query_hash TEXT UNIQUE NOT NULL,
-				query_text TEXT,
-				embedding VECTOR(512) NOT NULL,
-				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-			)`,
-			`CREATE TABLE IF NOT EXISTS result_embeddings (
-				id SERIAL PRIMARY KEY,
-				paper_id TEXT NOT NULL UNIQUE,
-				embedding VECTOR(512) NOT NULL,
-				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-			)`,
-			"CREATE INDEX IF NOT EXISTS query_embeddings_embedding_idx ON query_embeddings USING hnsw (embedding vector_ip_ops)",
-			"CREATE INDEX IF NOT EXISTS result_embeddings_embedding_idx ON result_embeddings USING hnsw (embedding vector_ip_ops)",
-			"CREATE INDEX IF NOT EXISTS result_embeddings_paper_id_idx ON result_embeddings (paper_id)",
-		}
-
-		for _, stmt := range statements {
-			_, err := db.ExecContext(ctx, stmt)
-			if err != nil {
-				stmtPreview := strings.TrimSpace(stmt)
-				if len(stmtPreview) > 100 {
-					stmtPreview = stmtPreview[:100]
-				}
-				logger.Error("Failed to execute schema statement", err, map[string]interface{}{
-					"statement": stmtPreview,
-				})
-				schemaInitErr = fmt.Errorf("failed to execute schema: %w", err)
-				return
-			}
+		if applied > 0 {
+			logger.Info("Applied schema migrations", map[string]interface{}{
+				"applied": applied,
+			})
 		}
 
 		schemaInitialized = true
@@ -80,4 +80,301 @@ func InitSchema(ctx context.Context) error {
 	return schemaInitErr
 }
 
+// LoadMigrations returns the embedded migrations sorted by version
+func LoadMigrations() ([]Migration, error) {
+	return loadMigrationsFrom(migrationFiles, migrationsDir)
+}
+
+// loadMigrationsFrom reads NNNN_name.(up|down).sql files from dir and pairs them by version
+func loadMigrationsFrom(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		version, name, direction, err := parseMigrationFilename(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %04d has conflicting names %q and %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// parseMigrationFilename splits "0001_initial_schema.up.sql" into its version, name and direction
+func parseMigrationFilename(filename string) (int, string, string, error) {
+	base := strings.TrimSuffix(filename, ".sql")
+
+	var direction string
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migration %s must end in .up.sql or .down.sql", filename)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	versionStr, name, found := strings.Cut(base, "_")
+	if !found || name == "" {
+		return 0, "", "", fmt.Errorf("migration %s must be named NNNN_name.%s.sql", filename, direction)
+	}
+
+	version, err := strconv.Atoi(versionStr)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s has invalid version %q", filename, versionStr)
+	}
+
+	return version, name, direction, nil
+}
+
+// MigrateUp applies pending migrations up to and including target (0 means latest)
+// Returns the number of migrations applied
+func MigrateUp(ctx context.Context, db *sql.DB, target int) (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if target > 0 && m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			if err := runMigration(ctx, conn, m, true); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// MigrateDown rolls back the most recently applied migrations, newest first
+// Returns the number of migrations rolled back
+func MigrateDown(ctx context.Context, db *sql.DB, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, nil
+	}
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(m.Down) == "" {
+				return fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+			}
+
+			if err := runMigration(ctx, conn, m, false); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// GetMigrationStatus lists every embedded migration and whether it has been applied
+// It only reads: no lock is taken, and a missing schema_migrations table means nothing is applied
+func GetMigrationStatus(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var table sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations')::text").Scan(&table); err != nil {
+		return nil, fmt.Errorf("failed to look up schema_migrations: %w", err)
+	}
+	applied := make(map[int]time.Time)
+	if table.Valid {
+		if applied, err = appliedMigrations(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: m,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// withMigrationLock pins a single connection, takes the advisory lock on it and
+// makes sure schema_migrations exists before running fn
+// Session-level advisory locks belong to the connection, so all work must go through conn
+func withMigrationLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so a cancelled request still releases the lock
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			logger.Warn("Failed to release migration lock", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// queryer is the query side of *sql.DB and *sql.Conn
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// appliedMigrations returns applied versions mapped to when they were applied
+func appliedMigrations(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
 
+	return applied, rows.Err()
+}
+
+// runMigration executes one direction of a migration and records it in a single transaction
+func runMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) error {
+	direction := "up"
+	script := m.Up
+	if !up {
+		direction = "down"
+		script = m.Down
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		logger.Error("Failed to execute migration", err, map[string]interface{}{
+			"version":   m.Version,
+			"name":      m.Name,
+			"direction": direction,
+		})
+		return fmt.Errorf("migration %04d_%s (%s) failed: %w", m.Version, m.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	logger.Info("Applied migration", map[string]interface{}{
+		"version":   m.Version,
+		"name":      m.Name,
+		"direction": direction,
+	})
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package paper
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseMigrationFilename(t *testing.T) {
+	tests := []struct {
+		filename  string
+		version   int
+		name      string
+		direction string
+		wantErr   bool
+	}{
+		{filename: "0001_initial_schema.up.sql", version: 1, name: "initial_schema", direction: "up"},
+		{filename: "0012_add_model_id.down.sql", version: 12, name: "add_model_id", direction: "down"},
+		{filename: "0001_initial_schema.sql", wantErr: true},
+		{filename: "initial_schema.up.sql", wantErr: true},
+		{filename: "0000_zero.up.sql", wantErr: true},
+		{filename: "0003.up.sql", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			version, name, direction, err := parseMigrationFilename(tt.filename)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseMigrationFilename(%q) expected error", tt.filename)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMigrationFilename(%q) unexpected error: %v", tt.filename, err)
+			}
+			if version != tt.version || name != tt.name || direction != tt.direction {
+				t.Errorf("parseMigrationFilename(%q) = (%d, %q, %q); expected (%d, %q, %q)",
+					tt.filename, version, name, direction, tt.version, tt.name, tt.direction)
+			}
+		})
+	}
+}
+
+func TestLoadMigrationsFromSortsAndPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":  {Data: []byte("CREATE TABLE b ();")},
+		"m/0001_first.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"m/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+		"m/README.md":           {Data: []byte("ignored")},
+	}
+
+	migrations, err := loadMigrationsFrom(fsys, "m")
+	if err != nil {
+		t.Fatalf("loadMigrationsFrom failed: %v", err)
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Errorf("Migrations not sorted by version: %d, %d", migrations[0].Version, migrations[1].Version)
+	}
+	if migrations[0].Down != "DROP TABLE a;" {
+		t.Errorf("Expected down script to be paired with version 1, got %q", migrations[0].Down)
+	}
+	if migrations[1].Down != "" {
+		t.Errorf("Expected no down script for version 2, got %q", migrations[1].Down)
+	}
+}
+
+func TestLoadMigrationsFromRejectsMissingUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+
+	if _, err := loadMigrationsFrom(fsys, "m"); err == nil {
+		t.Error("Expected error for migration without an up script")
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations failed: %v", err)
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected contiguous versions, got %04d at position %d", m.Version, i)
+		}
+		if m.Down == "" {
+			t.Errorf("Migration %04d_%s is missing a down script", m.Version, m.Name)
+		}
+	}
+}
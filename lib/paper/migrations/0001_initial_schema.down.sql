@@ -0,0 +1,3 @@
+-- The vector extension is left installed since other schemas may depend on it
+DROP TABLE IF EXISTS result_embeddings;
+DROP TABLE IF EXISTS query_embeddings;
@@ -0,0 +1,21 @@
+-- Initial embedding schema (query and result embeddings with HNSW indexes)
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE IF NOT EXISTS query_embeddings (
+	id SERIAL PRIMARY KEY,
+	query_hash TEXT UNIQUE NOT NULL,
+	query_text TEXT,
+	embedding VECTOR(512) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS result_embeddings (
+	id SERIAL PRIMARY KEY,
+	paper_id TEXT NOT NULL UNIQUE,
+	embedding VECTOR(512) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS query_embeddings_embedding_idx ON query_embeddings USING hnsw (embedding vector_ip_ops);
+CREATE INDEX IF NOT EXISTS result_embeddings_embedding_idx ON result_embeddings USING hnsw (embedding vector_ip_ops);
+CREATE INDEX IF NOT EXISTS result_embeddings_paper_id_idx ON result_embeddings (paper_id);
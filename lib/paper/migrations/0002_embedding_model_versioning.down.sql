@@ -0,0 +1,37 @@
+-- Collapse back to the single 512-dimension default model.
+-- Embeddings from any other model are discarded.
+DO $$
+DECLARE
+	idx RECORD;
+BEGIN
+	FOR idx IN
+		SELECT indexname FROM pg_indexes
+		WHERE tablename IN ('query_embeddings', 'result_embeddings')
+		  AND indexname LIKE '%\_hnsw\_%'
+	LOOP
+		EXECUTE format('DROP INDEX IF EXISTS %I', idx.indexname);
+	END LOOP;
+END $$;
+
+DROP INDEX IF EXISTS query_embeddings_model_id_idx;
+DROP INDEX IF EXISTS result_embeddings_model_id_idx;
+
+DELETE FROM query_embeddings WHERE model_id <> 'ds1-serverless-1762961395' OR dimension <> 512;
+DELETE FROM result_embeddings WHERE model_id <> 'ds1-serverless-1762961395' OR dimension <> 512;
+
+ALTER TABLE query_embeddings DROP CONSTRAINT IF EXISTS query_embeddings_dimension_check;
+ALTER TABLE query_embeddings DROP CONSTRAINT IF EXISTS query_embeddings_query_hash_model_id_key;
+ALTER TABLE query_embeddings ADD CONSTRAINT query_embeddings_query_hash_key UNIQUE (query_hash);
+ALTER TABLE query_embeddings DROP COLUMN model_id;
+ALTER TABLE query_embeddings DROP COLUMN dimension;
+ALTER TABLE query_embeddings ALTER COLUMN embedding TYPE VECTOR(512);
+
+ALTER TABLE result_embeddings DROP CONSTRAINT IF EXISTS result_embeddings_dimension_check;
+ALTER TABLE result_embeddings DROP CONSTRAINT IF EXISTS result_embeddings_paper_id_model_id_key;
+ALTER TABLE result_embeddings ADD CONSTRAINT result_embeddings_paper_id_key UNIQUE (paper_id);
+ALTER TABLE result_embeddings DROP COLUMN model_id;
+ALTER TABLE result_embeddings DROP COLUMN dimension;
+ALTER TABLE result_embeddings ALTER COLUMN embedding TYPE VECTOR(512);
+
+CREATE INDEX IF NOT EXISTS query_embeddings_embedding_idx ON query_embeddings USING hnsw (embedding vector_ip_ops);
+CREATE INDEX IF NOT EXISTS result_embeddings_embedding_idx ON result_embeddings USING hnsw (embedding vector_ip_ops);
@@ -0,0 +1,28 @@
+-- Record which model produced each embedding and allow any dimension per model.
+-- Existing rows came from the default ds1 endpoint at 512 dimensions.
+-- HNSW indexes are now partial expression indexes per (model, dimension) and are
+-- built by EnsureModelIndexes (scripts/migrate up or indexes, and scripts/reembed),
+-- since a plain vector column has no fixed dimension to index.
+DROP INDEX IF EXISTS query_embeddings_embedding_idx;
+DROP INDEX IF EXISTS result_embeddings_embedding_idx;
+
+ALTER TABLE query_embeddings ALTER COLUMN embedding TYPE vector;
+ALTER TABLE query_embeddings ADD COLUMN model_id TEXT NOT NULL DEFAULT 'ds1-serverless-1762961395';
+ALTER TABLE query_embeddings ADD COLUMN dimension INTEGER NOT NULL DEFAULT 512;
+ALTER TABLE query_embeddings ALTER COLUMN model_id DROP DEFAULT;
+ALTER TABLE query_embeddings ALTER COLUMN dimension DROP DEFAULT;
+ALTER TABLE query_embeddings DROP CONSTRAINT IF EXISTS query_embeddings_query_hash_key;
+ALTER TABLE query_embeddings ADD CONSTRAINT query_embeddings_query_hash_model_id_key UNIQUE (query_hash, model_id);
+ALTER TABLE query_embeddings ADD CONSTRAINT query_embeddings_dimension_check CHECK (vector_dims(embedding) = dimension);
+
+ALTER TABLE result_embeddings ALTER COLUMN embedding TYPE vector;
+ALTER TABLE result_embeddings ADD COLUMN model_id TEXT NOT NULL DEFAULT 'ds1-serverless-1762961395';
+ALTER TABLE result_embeddings ADD COLUMN dimension INTEGER NOT NULL DEFAULT 512;
+ALTER TABLE result_embeddings ALTER COLUMN model_id DROP DEFAULT;
+ALTER TABLE result_embeddings ALTER COLUMN dimension DROP DEFAULT;
+ALTER TABLE result_embeddings DROP CONSTRAINT IF EXISTS result_embeddings_paper_id_key;
+ALTER TABLE result_embeddings ADD CONSTRAINT result_embeddings_paper_id_model_id_key UNIQUE (paper_id, model_id);
+ALTER TABLE result_embeddings ADD CONSTRAINT result_embeddings_dimension_check CHECK (vector_dims(embedding) = dimension);
+
+CREATE INDEX IF NOT EXISTS query_embeddings_model_id_idx ON query_embeddings (model_id);
+CREATE INDEX IF NOT EXISTS result_embeddings_model_id_idx ON result_embeddings (model_id);
@@ -0,0 +1,12 @@
+-- Server-side response cache shared by warm instances (see lib/middleware/responsestore.go)
+CREATE TABLE IF NOT EXISTS response_cache (
+    key TEXT PRIMARY KEY,
+    status INTEGER NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    body BYTEA NOT NULL,
+    stored_at TIMESTAMPTZ NOT NULL,
+    fresh_until TIMESTAMPTZ NOT NULL,
+    stale_until TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS response_cache_stale_until_idx ON response_cache (stale_until);
@@ -0,0 +1,14 @@
+-- One row per iGaming digest date, written by /api/cron/generate-digest
+-- holder/lease_until form a lease lock so duplicate cron deliveries don't run (or send) twice
+CREATE TABLE IF NOT EXISTS digest_runs (
+    date TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending',
+    article_count INTEGER NOT NULL DEFAULT 0,
+    broadcast_id TEXT,
+    broadcast_at TIMESTAMPTZ,
+    error TEXT,
+    holder TEXT,
+    lease_until TIMESTAMPTZ,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
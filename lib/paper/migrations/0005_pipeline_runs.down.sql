@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pipeline_artifacts;
+DROP TABLE IF EXISTS pipeline_runs;
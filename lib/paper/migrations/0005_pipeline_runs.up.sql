@@ -0,0 +1,24 @@
+-- Run ledger for multi-stage jobs (see lib/pipeline): one row per run, stages as JSONB
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+    id TEXT PRIMARY KEY,
+    pipeline TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL,
+    parent_id TEXT,
+    resumed_from TEXT,
+    stages JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS pipeline_runs_pipeline_started_at_idx ON pipeline_runs (pipeline, started_at DESC);
+
+-- Stage outputs kept so a run can be resumed from the stage after them
+CREATE TABLE IF NOT EXISTS pipeline_artifacts (
+    run_id TEXT NOT NULL REFERENCES pipeline_runs (id) ON DELETE CASCADE,
+    stage TEXT NOT NULL,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, stage)
+);
@@ -0,0 +1,10 @@
+-- Newsletter subscription state for double opt-in (see lib/subscribe)
+CREATE TABLE IF NOT EXISTS subscribers (
+    email TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS subscribers_status_requested_at_idx ON subscribers (status, requested_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_events;
+ALTER TABLE subscribers DROP COLUMN IF EXISTS unsubscribed_at;
@@ -0,0 +1,12 @@
+-- Unsubscribes and the audit trail of subscription changes (see lib/subscribe)
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS unsubscribed_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    event TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS subscription_events_email_created_at_idx ON subscription_events (email, created_at);
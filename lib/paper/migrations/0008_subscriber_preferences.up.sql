@@ -0,0 +1,2 @@
+-- Per-subscriber preferences (see subscribe.Preferences); NULL means the defaults
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS preferences JSONB;
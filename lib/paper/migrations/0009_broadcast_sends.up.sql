@@ -0,0 +1,15 @@
+-- Send ledger for the daily and weekly broadcasts (see broadcast.SendLedger): one row per
+-- audience and feed date, so retried crons and manual calls can't send an issue twice.
+-- state moves created -> sending -> sent; a row left in sending means the outcome is unknown
+CREATE TABLE IF NOT EXISTS broadcast_sends (
+    audience_id TEXT NOT NULL,
+    feed_date TEXT NOT NULL,
+    pipeline TEXT NOT NULL,
+    state TEXT NOT NULL,
+    broadcast_id TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (audience_id, feed_date)
+);
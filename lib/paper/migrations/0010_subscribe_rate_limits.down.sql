@@ -0,0 +1 @@
+DROP TABLE IF EXISTS subscribe_rate_limits;
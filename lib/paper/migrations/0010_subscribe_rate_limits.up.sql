@@ -0,0 +1,10 @@
+-- Fixed-window hit counters for /api/subscribe (see subscribe.RateLimiter), keyed by
+-- client IP or email domain. Windows that have ended are pruned by the cleanup cron
+CREATE TABLE IF NOT EXISTS subscribe_rate_limits (
+    key TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS subscribe_rate_limits_window_start_idx ON subscribe_rate_limits (window_start);
@@ -0,0 +1,143 @@
+package paper
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"main/lib/logger"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EmbeddingModel identifies the vector space an embedding belongs to
+// Embeddings from different models are never compared with each other
+type EmbeddingModel struct {
+	ID        string `json:"modelId"`
+	Dimension int    `json:"dimension"`
+}
+
+// ActiveEmbeddingModel returns the model new embeddings are written with and queries are restricted to
+// EMBEDDING_MODEL_ID defaults to the SageMaker endpoint name, EMBEDDING_DIMENSION to 512
+func ActiveEmbeddingModel() EmbeddingModel {
+	modelID := os.Getenv("EMBEDDING_MODEL_ID")
+	if modelID == "" {
+		modelID = os.Getenv("SAGEMAKER_ENDPOINT_NAME")
+	}
+	if modelID == "" {
+		modelID = defaultEndpointName
+	}
+
+	dimension := defaultDimension
+	if dimStr := os.Getenv("EMBEDDING_DIMENSION"); dimStr != "" {
+		if dim, err := strconv.Atoi(dimStr); err == nil && dim > 0 {
+			dimension = dim
+		} else {
+			logger.Warn("Invalid EMBEDDING_DIMENSION, using default", map[string]interface{}{
+				"value":   dimStr,
+				"default": defaultDimension,
+			})
+		}
+	}
+
+	return EmbeddingModel{ID: modelID, Dimension: dimension}
+}
+
+// String returns a log-friendly "model@dimension" identifier
+func (m EmbeddingModel) String() string {
+	return fmt.Sprintf("%s@%d", m.ID, m.Dimension)
+}
+
+// vectorType returns the typed pgvector cast for this model, e.g. vector(512)
+// Casting to a fixed dimension lets queries use the model's partial HNSW index
+func (m EmbeddingModel) vectorType() string {
+	return fmt.Sprintf("vector(%d)", m.Dimension)
+}
+
+// indexName returns the HNSW index name for a table and this model
+// Model IDs can contain anything, so they're hashed to keep the name a valid identifier
+func (m EmbeddingModel) indexName(table string) string {
+	hash := sha256.Sum256([]byte(m.ID))
+	return fmt.Sprintf("%s_hnsw_%s_%d", table, hex.EncodeToString(hash[:])[:12], m.Dimension)
+}
+
+// quoteLiteral escapes a string for use as a SQL literal in DDL, where placeholders aren't allowed
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// EnsureModelIndexes creates the partial HNSW indexes for a model if they don't exist yet
+// Each model gets its own index over embedding::vector(dim) filtered to its rows. Indexes are
+// built CONCURRENTLY so writes carry on during the build, which can take minutes; that is
+// why only scripts/migrate and the re-embedding job call this, never the request path.
+// An index left invalid by an interrupted build is dropped and built again.
+func EnsureModelIndexes(ctx context.Context, db *sql.DB, model EmbeddingModel) error {
+	if model.ID == "" || model.Dimension <= 0 {
+		return fmt.Errorf("invalid embedding model %s", model)
+	}
+
+	for _, table := range []string{"query_embeddings", "result_embeddings"} {
+		name := model.indexName(table)
+
+		var valid sql.NullBool
+		err := db.QueryRowContext(ctx, `
+			SELECT i.indisvalid FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indexrelid
+			WHERE c.relname = $1 AND pg_table_is_visible(c.oid)`, name).Scan(&valid)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to check %s index for %s: %w", table, model, err)
+		}
+		if valid.Valid && valid.Bool {
+			continue
+		}
+		if valid.Valid {
+			logger.Warn("Dropping invalid embedding index left by an interrupted build", map[string]interface{}{"index": name})
+			if _, err := db.ExecContext(ctx, fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %s`, name)); err != nil {
+				return fmt.Errorf("failed to drop invalid %s index for %s: %w", table, model, err)
+			}
+		}
+
+		stmt := fmt.Sprintf(
+			`CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s USING hnsw ((embedding::%s) vector_ip_ops) WHERE model_id = %s`,
+			name, table, model.vectorType(), quoteLiteral(model.ID),
+		)
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create %s index for %s: %w", table, model, err)
+		}
+	}
+
+	return nil
+}
+
+// EmbeddingModelCount is the number of stored embeddings for one model in one table
+type EmbeddingModelCount struct {
+	Table string `json:"table"`
+	EmbeddingModel
+	Count int64 `json:"count"`
+}
+
+// CountEmbeddingsByModel reports how many embeddings each model has in each table
+func CountEmbeddingsByModel(ctx context.Context, db *sql.DB) ([]EmbeddingModelCount, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT 'query_embeddings', model_id, dimension, COUNT(*) FROM query_embeddings GROUP BY model_id, dimension
+		UNION ALL
+		SELECT 'result_embeddings', model_id, dimension, COUNT(*) FROM result_embeddings GROUP BY model_id, dimension
+		ORDER BY 1, 2`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []EmbeddingModelCount
+	for rows.Next() {
+		var c EmbeddingModelCount
+		if err := rows.Scan(&c.Table, &c.ID, &c.Dimension, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
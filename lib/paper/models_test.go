@@ -0,0 +1,79 @@
+package paper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestActiveEmbeddingModel(t *testing.T) {
+	tests := []struct {
+		name      string
+		modelID   string
+		endpoint  string
+		dimension string
+		expected  EmbeddingModel
+	}{
+		{
+			name:     "Defaults to the default endpoint",
+			expected: EmbeddingModel{ID: defaultEndpointName, Dimension: defaultDimension},
+		},
+		{
+			name:     "Falls back to the SageMaker endpoint name",
+			endpoint: "ds2-serverless",
+			expected: EmbeddingModel{ID: "ds2-serverless", Dimension: defaultDimension},
+		},
+		{
+			name:      "Explicit model ID and dimension",
+			modelID:   "ds2-v1",
+			endpoint:  "ds2-serverless",
+			dimension: "1024",
+			expected:  EmbeddingModel{ID: "ds2-v1", Dimension: 1024},
+		},
+		{
+			name:      "Invalid dimension uses default",
+			dimension: "abc",
+			expected:  EmbeddingModel{ID: defaultEndpointName, Dimension: defaultDimension},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EMBEDDING_MODEL_ID", tt.modelID)
+			t.Setenv("SAGEMAKER_ENDPOINT_NAME", tt.endpoint)
+			t.Setenv("EMBEDDING_DIMENSION", tt.dimension)
+
+			result := ActiveEmbeddingModel()
+			if result != tt.expected {
+				t.Errorf("ActiveEmbeddingModel() = %+v; expected %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmbeddingModelIndexName(t *testing.T) {
+	a := EmbeddingModel{ID: "ds1-serverless-1762961395", Dimension: 512}
+	b := EmbeddingModel{ID: "model with 'quotes'", Dimension: 1024}
+
+	nameA := a.indexName("result_embeddings")
+	nameB := b.indexName("result_embeddings")
+
+	if nameA == nameB {
+		t.Errorf("Expected different index names for different models, both got %q", nameA)
+	}
+	if !strings.HasPrefix(nameA, "result_embeddings_hnsw_") || !strings.HasSuffix(nameA, "_512") {
+		t.Errorf("Unexpected index name format: %q", nameA)
+	}
+	// Postgres truncates identifiers over 63 bytes
+	if len(nameA) > 63 || len(nameB) > 63 {
+		t.Errorf("Index names must fit in 63 bytes: %q, %q", nameA, nameB)
+	}
+	if a.indexName("result_embeddings") != nameA {
+		t.Error("Index name should be deterministic")
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	if got := quoteLiteral("it's"); got != "'it''s'" {
+		t.Errorf("quoteLiteral(%q) = %q; expected %q", "it's", got, "'it''s'")
+	}
+}
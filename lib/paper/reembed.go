@@ -0,0 +1,346 @@
+package paper
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"main/lib/logger"
+	"sync"
+	"time"
+)
+
+const (
+	// Rows fetched per page while re-embedding
+	defaultReembedBatchSize = 256
+	// Concurrent paper lookups when rebuilding result texts
+	reembedTextConcurrency = 8
+)
+
+// ReembedOptions configures a migration of stored embeddings from one model to another
+type ReembedOptions struct {
+	// Source is the model whose rows are re-embedded
+	Source EmbeddingModel
+	// Target is the model the new rows are written as
+	Target EmbeddingModel
+	// Service generates Target embeddings (normally the new SageMaker endpoint)
+	Service *EmbeddingService
+	// BatchSize is the number of rows read per page (default 256)
+	BatchSize int
+	// ResultText rebuilds the embedded text for a paper (default: title + abstract from blob/arXiv)
+	ResultText func(ctx context.Context, paperID string) (string, error)
+}
+
+// ReembedStats summarizes a re-embedding run
+type ReembedStats struct {
+	Queries  int           `json:"queries"`
+	Results  int           `json:"results"`
+	Skipped  int           `json:"skipped"`
+	Failed   int           `json:"failed"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ReembedModel copies every query and result embedding of the source model into the
+// target model, re-generating vectors with the target service
+// Source rows are left untouched so search keeps serving from the active model
+// while this runs; switch EMBEDDING_MODEL_ID once it finishes, then prune the source
+// Safe to re-run: rows that already exist for the target are skipped
+func ReembedModel(ctx context.Context, db *sql.DB, opts ReembedOptions) (*ReembedStats, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not available")
+	}
+	if opts.Service == nil {
+		return nil, fmt.Errorf("embedding service for target model is required")
+	}
+	if opts.Source.ID == opts.Target.ID {
+		return nil, fmt.Errorf("source and target model are the same (%s)", opts.Source.ID)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultReembedBatchSize
+	}
+	if opts.ResultText == nil {
+		opts.ResultText = paperEmbeddingText
+	}
+
+	startTime := time.Now()
+	stats := &ReembedStats{}
+
+	if err := EnsureModelIndexes(ctx, db, opts.Target); err != nil {
+		return nil, err
+	}
+
+	if err := reembedQueries(ctx, db, opts, stats); err != nil {
+		return stats, err
+	}
+	if err := reembedResults(ctx, db, opts, stats); err != nil {
+		return stats, err
+	}
+
+	stats.Duration = time.Since(startTime)
+	logger.Info("Re-embedding completed", map[string]interface{}{
+		"source":      opts.Source.String(),
+		"target":      opts.Target.String(),
+		"queries":     stats.Queries,
+		"results":     stats.Results,
+		"skipped":     stats.Skipped,
+		"failed":      stats.Failed,
+		"duration_ms": stats.Duration.Milliseconds(),
+	})
+
+	return stats, nil
+}
+
+// reembedQueries re-embeds stored query texts page by page
+// Queries stored without text can't be re-embedded and are regenerated by search traffic instead
+func reembedQueries(ctx context.Context, db *sql.DB, opts ReembedOptions, stats *ReembedStats) error {
+	lastID := 0
+	for {
+		rows, err := db.QueryContext(ctx, `
+			SELECT s.id, s.query_hash, s.query_text
+			FROM query_embeddings s
+			WHERE s.model_id = $1 AND s.id > $2
+			  AND s.query_text IS NOT NULL AND s.query_text <> ''
+			  AND NOT EXISTS (
+				SELECT 1 FROM query_embeddings t WHERE t.query_hash = s.query_hash AND t.model_id = $3
+			  )
+			ORDER BY s.id
+			LIMIT $4`,
+			opts.Source.ID, lastID, opts.Target.ID, opts.BatchSize,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to read query embeddings: %w", err)
+		}
+
+		var hashes, texts []string
+		for rows.Next() {
+			var hash, text string
+			if err := rows.Scan(&lastID, &hash, &text); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan query embedding: %w", err)
+			}
+			hashes = append(hashes, hash)
+			texts = append(texts, text)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating query embeddings: %w", err)
+		}
+
+		if len(texts) == 0 {
+			return nil
+		}
+
+		embeddings, err := generateTargetEmbeddings(ctx, opts, texts)
+		if err != nil {
+			logger.Warn("Failed to re-embed query page", map[string]interface{}{
+				"after_id": lastID,
+				"count":    len(texts),
+				"error":    err.Error(),
+			})
+			stats.Failed += len(texts)
+			continue
+		}
+
+		vectorStrs := make([]string, len(embeddings))
+		for i, embedding := range embeddings {
+			vectorStrs[i] = float32SliceToVectorString(embedding)
+		}
+
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO query_embeddings (query_hash, query_text, embedding, model_id, dimension)
+			SELECT unnest($1::text[]), unnest($2::text[]), unnest($3::vector[]), $4, $5
+			ON CONFLICT (query_hash, model_id) DO NOTHING`,
+			hashes, texts, vectorStrs, opts.Target.ID, opts.Target.Dimension,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store re-embedded queries: %w", err)
+		}
+
+		stats.Queries += len(texts)
+	}
+}
+
+// reembedResults re-embeds stored result (paper) embeddings page by page
+func reembedResults(ctx context.Context, db *sql.DB, opts ReembedOptions, stats *ReembedStats) error {
+	lastID := 0
+	for {
+		rows, err := db.QueryContext(ctx, `
+			SELECT s.id, s.paper_id
+			FROM result_embeddings s
+			WHERE s.model_id = $1 AND s.id > $2
+			  AND NOT EXISTS (
+				SELECT 1 FROM result_embeddings t WHERE t.paper_id = s.paper_id AND t.model_id = $3
+			  )
+			ORDER BY s.id
+			LIMIT $4`,
+			opts.Source.ID, lastID, opts.Target.ID, opts.BatchSize,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to read result embeddings: %w", err)
+		}
+
+		var paperIDs []string
+		for rows.Next() {
+			var paperID string
+			if err := rows.Scan(&lastID, &paperID); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan result embedding: %w", err)
+			}
+			paperIDs = append(paperIDs, paperID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating result embeddings: %w", err)
+		}
+
+		if len(paperIDs) == 0 {
+			return nil
+		}
+
+		ids, texts := loadResultTexts(ctx, opts, paperIDs)
+		stats.Skipped += len(paperIDs) - len(ids)
+		if len(texts) == 0 {
+			continue
+		}
+
+		embeddings, err := generateTargetEmbeddings(ctx, opts, texts)
+		if err != nil {
+			logger.Warn("Failed to re-embed result page", map[string]interface{}{
+				"after_id": lastID,
+				"count":    len(texts),
+				"error":    err.Error(),
+			})
+			stats.Failed += len(texts)
+			continue
+		}
+
+		vectorStrs := make([]string, len(embeddings))
+		for i, embedding := range embeddings {
+			vectorStrs[i] = float32SliceToVectorString(embedding)
+		}
+
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO result_embeddings (paper_id, embedding, model_id, dimension)
+			SELECT unnest($1::text[]), unnest($2::vector[]), $3, $4
+			ON CONFLICT (paper_id, model_id) DO NOTHING`,
+			ids, vectorStrs, opts.Target.ID, opts.Target.Dimension,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store re-embedded results: %w", err)
+		}
+
+		stats.Results += len(ids)
+	}
+}
+
+// loadResultTexts rebuilds the embedded text for each paper concurrently
+// Returns the paper IDs that produced text, in input order, alongside their texts
+func loadResultTexts(ctx context.Context, opts ReembedOptions, paperIDs []string) ([]string, []string) {
+	texts := make([]string, len(paperIDs))
+	semaphore := make(chan struct{}, reembedTextConcurrency)
+	var wg sync.WaitGroup
+
+	for i, paperID := range paperIDs {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(idx int, id string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			text, err := opts.ResultText(ctx, id)
+			if err != nil {
+				logger.Debug("Failed to load paper text for re-embedding", map[string]interface{}{
+					"paper_id": id,
+					"error":    err.Error(),
+				})
+				return
+			}
+			texts[idx] = text
+		}(i, paperID)
+	}
+	wg.Wait()
+
+	ids := make([]string, 0, len(paperIDs))
+	found := make([]string, 0, len(paperIDs))
+	for i, text := range texts {
+		if text != "" {
+			ids = append(ids, paperIDs[i])
+			found = append(found, text)
+		}
+	}
+
+	return ids, found
+}
+
+// generateTargetEmbeddings embeds texts with the target service and checks the dimension
+func generateTargetEmbeddings(ctx context.Context, opts ReembedOptions, texts []string) ([][]float32, error) {
+	embeddings, err := opts.Service.GenerateEmbeddings(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+	}
+	for _, embedding := range embeddings {
+		if len(embedding) != opts.Target.Dimension {
+			return nil, fmt.Errorf("target model returned %d dimensions, expected %d", len(embedding), opts.Target.Dimension)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// paperEmbeddingText rebuilds the text a paper was embedded from during search
+// Checks the blob cache first, then falls back to fetching from HuggingFace/arXiv
+func paperEmbeddingText(ctx context.Context, paperID string) (string, error) {
+	paperData, err := GetPaper(paperID)
+	if err != nil {
+		return "", err
+	}
+
+	if paperData == nil {
+		raw, err := GetPaperRaw(paperID)
+		if err != nil {
+			return "", err
+		}
+		paperData = raw.Data
+	}
+
+	if paperData == nil {
+		return "", fmt.Errorf("paper %s not found", paperID)
+	}
+
+	return embeddingText(paperData.Title, paperData.Abstract), nil
+}
+
+// embeddingText builds the text embedded for a paper: "Title. Summary"
+func embeddingText(title, summary string) string {
+	text := title
+	if summary != "" {
+		text += ". " + summary
+	}
+	return text
+}
+
+// PruneEmbeddingModel deletes every stored embedding and HNSW index for a model
+// Run after the active model has been switched away from it
+func PruneEmbeddingModel(ctx context.Context, db *sql.DB, model EmbeddingModel) (int64, error) {
+	if model.ID == ActiveEmbeddingModel().ID {
+		return 0, fmt.Errorf("refusing to prune the active embedding model %s", model)
+	}
+
+	var total int64
+	for _, table := range []string{"query_embeddings", "result_embeddings"} {
+		result, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE model_id = $1", table), model.ID)
+		if err != nil {
+			return total, fmt.Errorf("failed to prune %s: %w", table, err)
+		}
+		deleted, _ := result.RowsAffected()
+		total += deleted
+
+		if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS "+model.indexName(table)); err != nil {
+			return total, fmt.Errorf("failed to drop %s index: %w", table, err)
+		}
+	}
+
+	return total, nil
+}
@@ -1,30 +1,146 @@
+-- Reference snapshot of the schema after all migrations.
+-- The source of truth is lib/paper/migrations (applied by InitSchema / scripts/migrate);
+-- update this file alongside new migrations.
+
 -- Enable pgvector extension
 CREATE EXTENSION IF NOT EXISTS vector;
 
--- Create query_embeddings table
+-- Applied migration versions
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create query_embeddings table (one row per query per embedding model)
 CREATE TABLE IF NOT EXISTS query_embeddings (
     id SERIAL PRIMARY KEY,
-    query_hash TEXT UNIQUE NOT NULL,
+    query_hash TEXT NOT NULL,
     query_text TEXT,
-    embedding VECTOR(512) NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    embedding VECTOR NOT NULL,
+    model_id TEXT NOT NULL,
+    dimension INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT query_embeddings_query_hash_model_id_key UNIQUE (query_hash, model_id),
+    CONSTRAINT query_embeddings_dimension_check CHECK (vector_dims(embedding) = dimension)
 );
 
--- Create result_embeddings table
+-- Create result_embeddings table (one row per paper per embedding model)
 CREATE TABLE IF NOT EXISTS result_embeddings (
     id SERIAL PRIMARY KEY,
     paper_id TEXT NOT NULL,
-    embedding VECTOR(512) NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    embedding VECTOR NOT NULL,
+    model_id TEXT NOT NULL,
+    dimension INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT result_embeddings_paper_id_model_id_key UNIQUE (paper_id, model_id),
+    CONSTRAINT result_embeddings_dimension_check CHECK (vector_dims(embedding) = dimension)
 );
 
--- Create HNSW indexes for efficient similarity search (dot product)
-CREATE INDEX IF NOT EXISTS query_embeddings_embedding_idx ON query_embeddings 
-    USING hnsw (embedding vector_ip_ops);
+CREATE INDEX IF NOT EXISTS query_embeddings_model_id_idx ON query_embeddings (model_id);
+CREATE INDEX IF NOT EXISTS result_embeddings_model_id_idx ON result_embeddings (model_id);
+CREATE INDEX IF NOT EXISTS result_embeddings_paper_id_idx ON result_embeddings (paper_id);
 
-CREATE INDEX IF NOT EXISTS result_embeddings_embedding_idx ON result_embeddings 
-    USING hnsw (embedding vector_ip_ops);
+-- HNSW indexes are per model, built CONCURRENTLY by EnsureModelIndexes (scripts/migrate), e.g. for the 512-dim default:
+-- CREATE INDEX CONCURRENTLY result_embeddings_hnsw_<model hash>_512 ON result_embeddings
+--     USING hnsw ((embedding::vector(512)) vector_ip_ops) WHERE model_id = 'ds1-serverless-1762961395';
 
--- Create index on paper_id for faster lookups
-CREATE INDEX IF NOT EXISTS result_embeddings_paper_id_idx ON result_embeddings (paper_id);
+-- Server-side response cache (rows expire at stale_until and are pruned opportunistically)
+CREATE TABLE IF NOT EXISTS response_cache (
+    key TEXT PRIMARY KEY,
+    status INTEGER NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    body BYTEA NOT NULL,
+    stored_at TIMESTAMPTZ NOT NULL,
+    fresh_until TIMESTAMPTZ NOT NULL,
+    stale_until TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS response_cache_stale_until_idx ON response_cache (stale_until);
+
+-- iGaming digest runs: per-date lease lock plus completion and broadcast state
+CREATE TABLE IF NOT EXISTS digest_runs (
+    date TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending',
+    article_count INTEGER NOT NULL DEFAULT 0,
+    broadcast_id TEXT,
+    broadcast_at TIMESTAMPTZ,
+    error TEXT,
+    holder TEXT,
+    lease_until TIMESTAMPTZ,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+-- Pipeline run ledger: stages, timings, hashes and errors per run, plus stage outputs for resuming
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+    id TEXT PRIMARY KEY,
+    pipeline TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL,
+    parent_id TEXT,
+    resumed_from TEXT,
+    stages JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS pipeline_runs_pipeline_started_at_idx ON pipeline_runs (pipeline, started_at DESC);
+
+CREATE TABLE IF NOT EXISTS pipeline_artifacts (
+    run_id TEXT NOT NULL REFERENCES pipeline_runs (id) ON DELETE CASCADE,
+    stage TEXT NOT NULL,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (run_id, stage)
+);
+
+-- Newsletter subscribers: pending until the emailed confirmation link is followed
+CREATE TABLE IF NOT EXISTS subscribers (
+    email TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    unsubscribed_at TIMESTAMPTZ,
+    preferences JSONB,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS subscribers_status_requested_at_idx ON subscribers (status, requested_at);
+
+-- Audit trail of subscription changes; kept when the subscriber row is removed
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    event TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS subscription_events_email_created_at_idx ON subscription_events (email, created_at);
+
+-- Send ledger for the daily and weekly broadcasts: one row per audience and feed date,
+-- state moves created -> sending -> sent
+CREATE TABLE IF NOT EXISTS broadcast_sends (
+    audience_id TEXT NOT NULL,
+    feed_date TEXT NOT NULL,
+    pipeline TEXT NOT NULL,
+    state TEXT NOT NULL,
+    broadcast_id TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (audience_id, feed_date)
+);
+
+-- Fixed-window hit counters for /api/subscribe, keyed by client IP or email domain
+CREATE TABLE IF NOT EXISTS subscribe_rate_limits (
+    key TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (key, window_start)
+);
 
+CREATE INDEX IF NOT EXISTS subscribe_rate_limits_window_start_idx ON subscribe_rate_limits (window_start);
@@ -205,10 +205,7 @@ func tryRerankWithEmbeddings(ctx context.Context, query string, results []Search
 				missingResults := make([]SearchResult, 0)
 				for _, result := range results {
 					if _, exists := existingEmbeddings[result.ID]; !exists {
-						text := result.Title
-						if result.Summary != "" {
-							text += ". " + result.Summary
-						}
+						text := embeddingText(result.Title, result.Summary)
 						if text != "" {
 							missingTexts = append(missingTexts, text)
 							missingResults = append(missingResults, result)
@@ -278,10 +275,7 @@ func SearchPapersOnHuggingFaceWithRerank(
 		if resultEmbeddings == nil && queryEmbedding != nil {
 			resultTexts := make([]string, len(results))
 			for i, result := range results {
-				resultTexts[i] = result.Title
-				if result.Summary != "" {
-					resultTexts[i] += ". " + result.Summary
-				}
+				resultTexts[i] = embeddingText(result.Title, result.Summary)
 			}
 			if re, err := embeddingService.GenerateEmbeddings(ctx, resultTexts); err == nil {
 				resultEmbeddings = re
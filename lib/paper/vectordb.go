@@ -31,15 +31,19 @@ type VectorDBCache struct {
 	// Dimension for embeddings
 	dimension int
 	
+	// Model the embeddings belong to (reads and writes are restricted to it)
+	model EmbeddingModel
+	
 	// Database enabled flag
 	dbEnabled bool
 }
 
-// NewVectorDBCache creates a new vector database cache instance
-func NewVectorDBCache(dimension int) *VectorDBCache {
-	if dimension <= 0 {
-		dimension = defaultDimension
+// NewVectorDBCache creates a new vector database cache instance for an embedding model
+func NewVectorDBCache(model EmbeddingModel) *VectorDBCache {
+	if model.Dimension <= 0 {
+		model.Dimension = defaultDimension
 	}
+	dimension := model.Dimension
 	
 	// Initialize database connection
 	_ = InitDB()
@@ -59,10 +63,16 @@ func NewVectorDBCache(dimension int) *VectorDBCache {
 	return &VectorDBCache{
 		vectorDB: inMemoryDB,
 		dimension: dimension,
+		model: model,
 		dbEnabled: dbEnabled,
 	}
 }
 
+// Model returns the embedding model this cache reads and writes
+func (v *VectorDBCache) Model() EmbeddingModel {
+	return v.model
+}
+
 // HashQuery creates a SHA256 hash of the query for cache key
 func (v *VectorDBCache) HashQuery(query string) string {
 	hash := sha256.Sum256([]byte(query))
@@ -102,8 +112,8 @@ func (v *VectorDBCache) GetQueryEmbedding(ctx context.Context, queryHash string)
 	var vectorStr string
 	err := db.QueryRowContext(
 		ctx,
-		`SELECT embedding::text FROM query_embeddings WHERE query_hash = $1`,
-		queryHash,
+		`SELECT embedding::text FROM query_embeddings WHERE query_hash = $1 AND model_id = $2`,
+		queryHash, v.model.ID,
 	).Scan(&vectorStr)
 	
 	if err == sql.ErrNoRows {
@@ -135,10 +145,10 @@ func (v *VectorDBCache) AddEmbeddingWithText(queryHash string, queryText string,
 			
 			_, err := db.ExecContext(
 				context.Background(),
-				`INSERT INTO query_embeddings (query_hash, query_text, embedding) 
-				 VALUES ($1, $2, $3::vector) 
-				 ON CONFLICT (query_hash) DO NOTHING`,
-				queryHash, queryText, vectorStr,
+				`INSERT INTO query_embeddings (query_hash, query_text, embedding, model_id, dimension) 
+				 VALUES ($1, $2, $3::vector, $4, $5) 
+				 ON CONFLICT (query_hash, model_id) DO NOTHING`,
+				queryHash, queryText, vectorStr, v.model.ID, v.dimension,
 			)
 			
 			if err == nil {
@@ -180,8 +190,8 @@ func (v *VectorDBCache) GetResultEmbedding(ctx context.Context, paperID string)
 	var vectorStr string
 	err := db.QueryRowContext(
 		ctx,
-		`SELECT embedding::text FROM result_embeddings WHERE paper_id = $1`,
-		paperID,
+		`SELECT embedding::text FROM result_embeddings WHERE paper_id = $1 AND model_id = $2`,
+		paperID, v.model.ID,
 	).Scan(&vectorStr)
 	
 	if err == sql.ErrNoRows {
@@ -231,9 +241,9 @@ func (v *VectorDBCache) GetResultEmbeddingsBatch(ctx context.Context, paperIDs [
 	
 	// Use ANY(array) for better performance - single query, no chunking needed
 	// PostgreSQL handles arrays efficiently up to very large sizes
-	query := `SELECT paper_id, embedding::text FROM result_embeddings WHERE paper_id = ANY($1::text[])`
+	query := `SELECT paper_id, embedding::text FROM result_embeddings WHERE paper_id = ANY($1::text[]) AND model_id = $2`
 	
-	rows, err := db.QueryContext(ctx, query, paperIDs)
+	rows, err := db.QueryContext(ctx, query, paperIDs, v.model.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query embeddings: %w", err)
 	}
@@ -288,8 +298,24 @@ func (v *VectorDBCache) AddResultEmbedding(paperID string, embedding []float32)
 	return v.AddResultEmbeddingsBatch(map[string][]float32{paperID: embedding})
 }
 
+// StoreEmbeddingsError lists the papers whose embeddings weren't stored when a batch
+// insert fell back to chunks and some of them failed; the other papers were stored
+type StoreEmbeddingsError struct {
+	PaperIDs []string
+	Err      error
+}
+
+func (e *StoreEmbeddingsError) Error() string {
+	return fmt.Sprintf("failed to store %d result embeddings: %v", len(e.PaperIDs), e.Err)
+}
+
+func (e *StoreEmbeddingsError) Unwrap() error {
+	return e.Err
+}
+
 // AddResultEmbeddingsBatch stores multiple result embeddings in a single batch insert
 // Uses UNNEST for efficient bulk inserts (faster than multiple VALUES)
+// If the fallback chunks only partly fail, the error is a *StoreEmbeddingsError
 func (v *VectorDBCache) AddResultEmbeddingsBatch(embeddings map[string][]float32) error {
 	if len(embeddings) == 0 {
 		return nil
@@ -318,11 +344,11 @@ func (v *VectorDBCache) AddResultEmbeddingsBatch(embeddings map[string][]float32
 			
 			// Use UNNEST for efficient bulk insert
 			query := `
-				INSERT INTO result_embeddings (paper_id, embedding)
-				SELECT unnest($1::text[]), unnest($2::vector[])
-				ON CONFLICT (paper_id) DO NOTHING`
+				INSERT INTO result_embeddings (paper_id, embedding, model_id, dimension)
+				SELECT unnest($1::text[]), unnest($2::vector[]), $3, $4
+				ON CONFLICT (paper_id, model_id) DO NOTHING`
 			
-			_, err := db.ExecContext(context.Background(), query, paperIDs, vectorStrs)
+			_, err := db.ExecContext(context.Background(), query, paperIDs, vectorStrs, v.model.ID, v.dimension)
 			if err == nil {
 				return nil
 			}
@@ -337,6 +363,8 @@ func (v *VectorDBCache) AddResultEmbeddingsBatch(embeddings map[string][]float32
 			}
 			
 			totalStored := int64(0)
+			var failedIDs []string
+			var chunkErr error
 			for i := 0; i < len(allPaperIDs); i += maxBatchSize {
 				end := i + maxBatchSize
 				if end > len(allPaperIDs) {
@@ -346,20 +374,22 @@ func (v *VectorDBCache) AddResultEmbeddingsBatch(embeddings map[string][]float32
 				chunkPaperIDs := allPaperIDs[i:end]
 				chunkVectorStrs := allVectorStrs[i:end]
 				
-				args := make([]interface{}, 0, len(chunkPaperIDs)*2)
+				// Model ID and dimension are shared by every row, so they go first as $1 and $2
+				args := make([]interface{}, 0, len(chunkPaperIDs)*2+2)
+				args = append(args, v.model.ID, v.dimension)
 				placeholders := make([]string, 0, len(chunkPaperIDs))
 				
-				argIdx := 1
+				argIdx := 3
 				for j := range chunkPaperIDs {
 					args = append(args, chunkPaperIDs[j], chunkVectorStrs[j])
-					placeholders = append(placeholders, fmt.Sprintf("($%d, $%d::vector)", argIdx, argIdx+1))
+					placeholders = append(placeholders, fmt.Sprintf("($%d, $%d::vector, $1, $2)", argIdx, argIdx+1))
 					argIdx += 2
 				}
 				
 				chunkQuery := fmt.Sprintf(
-					`INSERT INTO result_embeddings (paper_id, embedding) 
+					`INSERT INTO result_embeddings (paper_id, embedding, model_id, dimension) 
 					 VALUES %s 
-					 ON CONFLICT (paper_id) DO NOTHING`,
+					 ON CONFLICT (paper_id, model_id) DO NOTHING`,
 					strings.Join(placeholders, ", "),
 				)
 				
@@ -370,6 +400,8 @@ func (v *VectorDBCache) AddResultEmbeddingsBatch(embeddings map[string][]float32
 						"chunk_end":   end,
 						"chunk_size":  len(chunkPaperIDs),
 					})
+					failedIDs = append(failedIDs, chunkPaperIDs...)
+					chunkErr = err
 					continue
 				}
 				
@@ -377,6 +409,9 @@ func (v *VectorDBCache) AddResultEmbeddingsBatch(embeddings map[string][]float32
 				totalStored += rowsAffected
 			}
 			
+			if len(failedIDs) > 0 {
+				return &StoreEmbeddingsError{PaperIDs: failedIDs, Err: chunkErr}
+			}
 			return nil
 		}
 	}
@@ -443,14 +478,16 @@ func (v *VectorDBCache) SearchSimilarInDB(
 	
 	queryVectorStr := float32SliceToVectorString(queryEmbedding)
 	
-	// Use HNSW index for fast similarity search - get top K by dot product
-	query := `
+	// Use the model's partial HNSW index for fast similarity search - get top K by dot product
+	// The typed casts must match the index expression for the planner to use it
+	query := fmt.Sprintf(`
 		SELECT paper_id 
 		FROM result_embeddings 
-		ORDER BY embedding <#> $1::vector 
-		LIMIT $2`
+		WHERE model_id = $3
+		ORDER BY embedding::%[1]s <#> $1::%[1]s 
+		LIMIT $2`, v.model.vectorType())
 	
-	rows, err := db.QueryContext(ctx, query, queryVectorStr, limit)
+	rows, err := db.QueryContext(ctx, query, queryVectorStr, limit, v.model.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query database for similarity search: %w", err)
 	}
@@ -497,13 +534,13 @@ func (v *VectorDBCache) rerankWithDB(
 	queryVectorStr := float32SliceToVectorString(queryEmbedding)
 	
 	// Single SQL query: filter by paper IDs, calculate dot product, order by similarity
-	query := `
-		SELECT paper_id, (embedding <#> $1::vector) * -1.0 as similarity 
+	query := fmt.Sprintf(`
+		SELECT paper_id, (embedding::%[1]s <#> $1::%[1]s) * -1.0 as similarity 
 		FROM result_embeddings 
-		WHERE paper_id = ANY($2::text[])
-		ORDER BY similarity DESC`
+		WHERE paper_id = ANY($2::text[]) AND model_id = $3
+		ORDER BY similarity DESC`, v.model.vectorType())
 	
-	rows, err := db.QueryContext(ctx, query, queryVectorStr, paperIDs)
+	rows, err := db.QueryContext(ctx, query, queryVectorStr, paperIDs, v.model.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query database for reranking: %w", err)
 	}
@@ -651,10 +688,10 @@ func (v *VectorDBCache) Size() int {
 var globalCache *VectorDBCache
 var cacheOnce sync.Once
 
-// GetVectorDBCache returns the global vector DB cache instance
+// GetVectorDBCache returns the global vector DB cache instance for the active embedding model
 func GetVectorDBCache() *VectorDBCache {
 	cacheOnce.Do(func() {
-		globalCache = NewVectorDBCache(defaultDimension)
+		globalCache = NewVectorDBCache(ActiveEmbeddingModel())
 	})
 	return globalCache
 }
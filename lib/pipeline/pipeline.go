@@ -0,0 +1,408 @@
+// Package pipeline records multi-stage jobs (cache updates, broadcasts, digests) in a
+// run ledger, so a failed run shows which stage broke and can be resumed from any stage
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"main/lib/logger"
+	"time"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// Stage statuses
+const (
+	StagePending   = "pending"
+	StageRunning   = "running"
+	StageSucceeded = "succeeded"
+	StageFailed    = "failed"
+	// StageReused marks a stage a resumed run took from its parent instead of running
+	StageReused = "reused"
+)
+
+const defaultRetryBackoff = 2 * time.Second
+
+var (
+	// ErrRunNotFound is returned by stores for an unknown run ID
+	ErrRunNotFound = errors.New("pipeline run not found")
+	// ErrArtifactNotFound is returned by stores when a stage output wasn't kept
+	ErrArtifactNotFound = errors.New("pipeline stage output not found")
+)
+
+// StageFunc runs one stage: it gets the previous stage's output (nil for the first stage)
+// and returns its own, which is stored so later runs can resume from the next stage
+type StageFunc func(ctx context.Context, input []byte) ([]byte, error)
+
+// Stage is one step of a pipeline
+type Stage struct {
+	Name string
+	Run  StageFunc
+	// Retries is how many extra attempts a failing stage gets
+	Retries int
+	// Optional stages don't fail the run; on error their input is passed through
+	Optional bool
+	// Once stages (e.g. sending email) are never re-run by a resume once they succeeded
+	Once bool
+}
+
+// StageRecord is the ledger entry for one stage of a run
+type StageRecord struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Optional    bool       `json:"optional,omitempty"`
+	Attempts    int        `json:"attempts"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	DurationMs  int64      `json:"durationMs"`
+	InputHash   string     `json:"inputHash,omitempty"`
+	InputBytes  int        `json:"inputBytes"`
+	OutputHash  string     `json:"outputHash,omitempty"`
+	OutputBytes int        `json:"outputBytes"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Run is the ledger entry for one pipeline execution
+type Run struct {
+	ID       string            `json:"id"`
+	Pipeline string            `json:"pipeline"`
+	Params   map[string]string `json:"params,omitempty"`
+	Status   string            `json:"status"`
+	// ParentID and ResumedFrom are set when the run resumed an earlier one
+	ParentID    string        `json:"parentId,omitempty"`
+	ResumedFrom string        `json:"resumedFrom,omitempty"`
+	Stages      []StageRecord `json:"stages"`
+	Error       string        `json:"error,omitempty"`
+	StartedAt   time.Time     `json:"startedAt"`
+	CompletedAt *time.Time    `json:"completedAt,omitempty"`
+}
+
+// Stage returns the record for a stage name, or nil
+func (r *Run) Stage(name string) *StageRecord {
+	for i := range r.Stages {
+		if r.Stages[i].Name == name {
+			return &r.Stages[i]
+		}
+	}
+	return nil
+}
+
+// FailedStage returns the first required stage that failed or never finished, or "" if all succeeded
+func (r *Run) FailedStage() string {
+	for _, stage := range r.Stages {
+		if !stage.completed() {
+			return stage.Name
+		}
+	}
+	return ""
+}
+
+// completed reports whether later stages can build on this stage's output
+func (s StageRecord) completed() bool {
+	return s.Status == StageSucceeded || s.Status == StageReused || (s.Optional && s.Status == StageFailed)
+}
+
+// Runner executes stages and records every step in a Store
+type Runner struct {
+	store Store
+	// RetryBackoff is the wait before the first retry; later retries wait longer (default 2s)
+	RetryBackoff time.Duration
+}
+
+// NewRunner creates a runner; a nil store keeps runs in memory only
+func NewRunner(store Store) *Runner {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Runner{store: store, RetryBackoff: defaultRetryBackoff}
+}
+
+// Store returns the runner's ledger
+func (r *Runner) Store() Store {
+	return r.store
+}
+
+// Start runs every stage in order as a new run of pipelineName
+// The run is returned even on error so callers can report its ID
+func (r *Runner) Start(ctx context.Context, pipelineName string, params map[string]string, stages []Stage) (*Run, error) {
+	if err := validateStages(stages); err != nil {
+		return nil, err
+	}
+	run := newRun(pipelineName, params, stages)
+	return run, r.execute(ctx, run, stages, 0, nil)
+}
+
+// Resume starts a new run that reuses parentID's outputs up to fromStage and runs the rest
+// An empty fromStage resumes at the parent's first failed or unfinished stage. stages must be
+// the same pipeline definition the parent ran with
+func (r *Runner) Resume(ctx context.Context, parentID, fromStage string, stages []Stage) (*Run, error) {
+	if err := validateStages(stages); err != nil {
+		return nil, err
+	}
+
+	parent, err := r.store.GetRun(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if fromStage == "" {
+		fromStage = parent.FailedStage()
+		if fromStage == "" {
+			return nil, fmt.Errorf("run %s has no failed stage to resume; name a stage to re-run", parentID)
+		}
+	}
+
+	from := -1
+	for i, stage := range stages {
+		if stage.Name == fromStage {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		return nil, fmt.Errorf("pipeline %s has no stage %q", parent.Pipeline, fromStage)
+	}
+
+	for _, stage := range stages[from:] {
+		if record := parent.Stage(stage.Name); stage.Once && record != nil && (record.Status == StageSucceeded || record.Status == StageReused) {
+			return nil, fmt.Errorf("stage %s already succeeded in run %s and must not run twice", stage.Name, parentID)
+		}
+	}
+
+	run := newRun(parent.Pipeline, parent.Params, stages)
+	run.ParentID = parent.ID
+	run.ResumedFrom = fromStage
+	r.save(ctx, run)
+
+	// Carry the reused outputs forward so this run can itself be resumed later
+	input, err := r.reuseStages(ctx, parent, run, stages[:from])
+	if err != nil {
+		err = fmt.Errorf("cannot resume from %s: %w", fromStage, err)
+		r.finish(ctx, run, err)
+		return run, err
+	}
+
+	return run, r.execute(ctx, run, stages, from, input)
+}
+
+// reuseStages copies the parent's records and outputs for stages and returns the last output
+func (r *Runner) reuseStages(ctx context.Context, parent, run *Run, stages []Stage) ([]byte, error) {
+	var output []byte
+	for i, stage := range stages {
+		record := parent.Stage(stage.Name)
+		if record == nil || !record.completed() {
+			return nil, fmt.Errorf("stage %s did not succeed in run %s", stage.Name, parent.ID)
+		}
+		data, err := r.store.GetArtifact(ctx, parent.ID, stage.Name)
+		if err != nil {
+			return nil, fmt.Errorf("stage %s output: %w", stage.Name, err)
+		}
+		if err := r.store.SaveArtifact(ctx, run.ID, stage.Name, data); err != nil {
+			return nil, fmt.Errorf("failed to copy %s output: %w", stage.Name, err)
+		}
+
+		reused := *record
+		if reused.Status != StageFailed {
+			reused.Status = StageReused
+		}
+		reused.Attempts = 0
+		run.Stages[i] = reused
+		output = data
+	}
+	return output, nil
+}
+
+// execute runs stages[from:] and keeps the ledger updated after every step
+func (r *Runner) execute(ctx context.Context, run *Run, stages []Stage, from int, input []byte) error {
+	logCtx := map[string]interface{}{
+		"run_id":   run.ID,
+		"pipeline": run.Pipeline,
+	}
+	if run.ParentID != "" {
+		logCtx["parent_id"] = run.ParentID
+		logCtx["resumed_from"] = run.ResumedFrom
+	}
+	logger.Info("Pipeline run started", logCtx)
+	r.save(ctx, run)
+
+	for i := from; i < len(stages); i++ {
+		stage := stages[i]
+		record := &run.Stages[i]
+		output, err := r.runStage(ctx, run, stage, record, input)
+		if err != nil {
+			if stage.Optional {
+				logger.Warn("Optional pipeline stage failed, continuing", map[string]interface{}{
+					"run_id": run.ID,
+					"stage":  stage.Name,
+					"error":  err.Error(),
+				})
+				// The input passes through, so a resume past this stage still has it
+				r.saveArtifact(ctx, run, stage.Name, input)
+				continue
+			}
+			r.finish(ctx, run, fmt.Errorf("stage %s failed: %w", stage.Name, err))
+			return fmt.Errorf("stage %s failed: %w", stage.Name, err)
+		}
+		input = output
+	}
+
+	r.finish(ctx, run, nil)
+	return nil
+}
+
+// runStage runs one stage with retries and stores its output
+func (r *Runner) runStage(ctx context.Context, run *Run, stage Stage, record *StageRecord, input []byte) ([]byte, error) {
+	started := time.Now()
+	record.Status = StageRunning
+	record.StartedAt = &started
+	record.InputHash = hashBytes(input)
+	record.InputBytes = len(input)
+	record.Error = ""
+	r.save(ctx, run)
+
+	var output []byte
+	var err error
+	for attempt := 0; ; attempt++ {
+		record.Attempts++
+		output, err = stage.Run(ctx, input)
+		if err == nil || attempt >= stage.Retries || ctx.Err() != nil {
+			break
+		}
+
+		logger.Warn("Retrying pipeline stage", map[string]interface{}{
+			"run_id":  run.ID,
+			"stage":   stage.Name,
+			"attempt": attempt + 2,
+			"error":   err.Error(),
+		})
+		timer := time.NewTimer(r.RetryBackoff * time.Duration(attempt+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	completed := time.Now()
+	record.CompletedAt = &completed
+	record.DurationMs = completed.Sub(started).Milliseconds()
+
+	if err != nil {
+		record.Status = StageFailed
+		record.Error = err.Error()
+		r.save(ctx, run)
+		return nil, err
+	}
+
+	record.Status = StageSucceeded
+	record.OutputHash = hashBytes(output)
+	record.OutputBytes = len(output)
+	r.saveArtifact(ctx, run, stage.Name, output)
+	r.save(ctx, run)
+	return output, nil
+}
+
+// saveArtifact keeps a stage output for resumes; on failure the run still finishes,
+// only resuming after this stage is lost
+func (r *Runner) saveArtifact(ctx context.Context, run *Run, stage string, output []byte) {
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err := r.store.SaveArtifact(saveCtx, run.ID, stage, output); err != nil {
+		logger.Warn("Failed to store pipeline stage output", map[string]interface{}{
+			"run_id": run.ID,
+			"stage":  stage,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// finish records the run's final status
+func (r *Runner) finish(ctx context.Context, run *Run, err error) {
+	now := time.Now()
+	run.CompletedAt = &now
+	logCtx := map[string]interface{}{
+		"run_id":      run.ID,
+		"pipeline":    run.Pipeline,
+		"duration_ms": now.Sub(run.StartedAt).Milliseconds(),
+	}
+	if err != nil {
+		run.Status = RunFailed
+		run.Error = err.Error()
+		logger.Error("Pipeline run failed", err, logCtx)
+	} else {
+		run.Status = RunSucceeded
+		run.Error = ""
+		logger.Info("Pipeline run succeeded", logCtx)
+	}
+	r.save(ctx, run)
+}
+
+// save writes the run, logging rather than failing: the ledger must never break the job itself
+func (r *Runner) save(ctx context.Context, run *Run) {
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := r.store.SaveRun(saveCtx, run); err != nil {
+		logger.Warn("Failed to record pipeline run", map[string]interface{}{
+			"run_id": run.ID,
+			"error":  err.Error(),
+		})
+	}
+}
+
+func newRun(pipelineName string, params map[string]string, stages []Stage) *Run {
+	run := &Run{
+		ID:        newRunID(),
+		Pipeline:  pipelineName,
+		Params:    params,
+		Status:    RunRunning,
+		Stages:    make([]StageRecord, len(stages)),
+		StartedAt: time.Now().UTC(),
+	}
+	for i, stage := range stages {
+		run.Stages[i] = StageRecord{Name: stage.Name, Status: StagePending, Optional: stage.Optional}
+	}
+	return run
+}
+
+func validateStages(stages []Stage) error {
+	if len(stages) == 0 {
+		return fmt.Errorf("pipeline has no stages")
+	}
+	seen := make(map[string]bool, len(stages))
+	for _, stage := range stages {
+		if stage.Name == "" || stage.Run == nil {
+			return fmt.Errorf("pipeline stages need a name and a run function")
+		}
+		if seen[stage.Name] {
+			return fmt.Errorf("duplicate pipeline stage %q", stage.Name)
+		}
+		seen[stage.Name] = true
+	}
+	return nil
+}
+
+// newRunID returns a time-ordered ID, so listing IDs in reverse is newest first
+func newRunID() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+func hashBytes(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
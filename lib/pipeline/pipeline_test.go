@@ -0,0 +1,275 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStages builds fetch -> upper -> publish, counting calls per stage and failing
+// any stage whose name is in failing
+func testStages(calls map[string]int, failing map[string]bool) []Stage {
+	stage := func(name string, run func(input []byte) []byte) Stage {
+		return Stage{
+			Name: name,
+			Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				calls[name]++
+				if failing[name] {
+					return nil, errors.New(name + " unavailable")
+				}
+				return run(input), nil
+			},
+		}
+	}
+
+	return []Stage{
+		stage("fetch", func([]byte) []byte { return []byte("papers") }),
+		stage("upper", func(input []byte) []byte { return []byte(strings.ToUpper(string(input))) }),
+		stage("publish", func(input []byte) []byte { return append([]byte("published:"), input...) }),
+	}
+}
+
+func newTestRunner() *Runner {
+	runner := NewRunner(NewMemoryStore())
+	runner.RetryBackoff = time.Millisecond
+	return runner
+}
+
+func TestRunnerStartRecordsStages(t *testing.T) {
+	runner := newTestRunner()
+	calls := map[string]int{}
+
+	run, err := runner.Start(context.Background(), "test", map[string]string{"date": "2025-01-15"}, testStages(calls, nil))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	stored, err := runner.Store().GetRun(context.Background(), run.ID)
+	if err != nil {
+		t.Fatalf("GetRun() error = %v", err)
+	}
+	if stored.Status != RunSucceeded || stored.CompletedAt == nil {
+		t.Errorf("Run status = %q; expected %q with a completion time", stored.Status, RunSucceeded)
+	}
+	if stored.Params["date"] != "2025-01-15" {
+		t.Errorf("Params = %v; expected the date to be recorded", stored.Params)
+	}
+	if len(stored.Stages) != 3 {
+		t.Fatalf("Recorded %d stages; expected 3", len(stored.Stages))
+	}
+
+	for i, stage := range stored.Stages {
+		if stage.Status != StageSucceeded || stage.Attempts != 1 || stage.StartedAt == nil {
+			t.Errorf("Stage %s = %+v; expected one successful attempt", stage.Name, stage)
+		}
+		if stage.OutputHash == "" {
+			t.Errorf("Stage %s has no output hash", stage.Name)
+		}
+		if i > 0 && stage.InputHash != stored.Stages[i-1].OutputHash {
+			t.Errorf("Stage %s input hash doesn't match %s output hash", stage.Name, stored.Stages[i-1].Name)
+		}
+	}
+	if stored.Stages[0].InputHash != "" {
+		t.Error("Expected the first stage to have no input hash")
+	}
+
+	output, err := runner.Store().GetArtifact(context.Background(), run.ID, "publish")
+	if err != nil || string(output) != "published:PAPERS" {
+		t.Errorf("publish output = %q, %v; expected published:PAPERS", output, err)
+	}
+}
+
+func TestRunnerRetries(t *testing.T) {
+	runner := newTestRunner()
+	attempts := 0
+	stages := []Stage{{
+		Name:    "flaky",
+		Retries: 2,
+		Run: func(ctx context.Context, input []byte) ([]byte, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, errors.New("timeout")
+			}
+			return []byte("ok"), nil
+		},
+	}}
+
+	run, err := runner.Start(context.Background(), "test", nil, stages)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := run.Stage("flaky").Attempts; got != 3 {
+		t.Errorf("Attempts = %d; expected 3", got)
+	}
+
+	attempts = -10
+	run, err = runner.Start(context.Background(), "test", nil, stages)
+	if err == nil {
+		t.Fatal("Expected the stage to fail once retries are exhausted")
+	}
+	record := run.Stage("flaky")
+	if record.Status != StageFailed || record.Attempts != 3 || record.Error != "timeout" {
+		t.Errorf("Stage record = %+v; expected 3 failed attempts with the error", record)
+	}
+	if run.Status != RunFailed || !strings.Contains(run.Error, "flaky") {
+		t.Errorf("Run = %q (%s); expected failed at flaky", run.Status, run.Error)
+	}
+}
+
+func TestRunnerResumeFromFailedStage(t *testing.T) {
+	runner := newTestRunner()
+	calls := map[string]int{}
+
+	failed, err := runner.Start(context.Background(), "test", map[string]string{"date": "2025-01-15"}, testStages(calls, map[string]bool{"upper": true}))
+	if err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	if failed.FailedStage() != "upper" {
+		t.Errorf("FailedStage() = %q; expected upper", failed.FailedStage())
+	}
+	if failed.Stage("publish").Status != StagePending {
+		t.Error("Expected stages after the failure to stay pending")
+	}
+
+	resumed, err := runner.Resume(context.Background(), failed.ID, "", testStages(calls, nil))
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if calls["fetch"] != 1 {
+		t.Errorf("fetch ran %d times; expected its output to be reused", calls["fetch"])
+	}
+	if resumed.ParentID != failed.ID || resumed.ResumedFrom != "upper" || resumed.Params["date"] != "2025-01-15" {
+		t.Errorf("Resumed run = %+v; expected parent, stage and params to carry over", resumed)
+	}
+	if got := resumed.Stage("fetch").Status; got != StageReused {
+		t.Errorf("fetch status = %q; expected %q", got, StageReused)
+	}
+	output, _ := runner.Store().GetArtifact(context.Background(), resumed.ID, "publish")
+	if string(output) != "published:PAPERS" {
+		t.Errorf("publish output = %q; expected published:PAPERS", output)
+	}
+
+	// Resuming a resumed run still finds the reused outputs
+	if _, err := runner.Resume(context.Background(), resumed.ID, "publish", testStages(calls, nil)); err != nil {
+		t.Errorf("Resume() of a resumed run error = %v", err)
+	}
+	if calls["fetch"] != 1 || calls["upper"] != 2 {
+		t.Errorf("Calls = %v; expected only publish to re-run", calls)
+	}
+}
+
+func TestRunnerResumeErrors(t *testing.T) {
+	runner := newTestRunner()
+	calls := map[string]int{}
+	succeeded, _ := runner.Start(context.Background(), "test", nil, testStages(calls, nil))
+	failed, _ := runner.Start(context.Background(), "test", nil, testStages(calls, map[string]bool{"fetch": true}))
+
+	tests := []struct {
+		name  string
+		runID string
+		from  string
+	}{
+		{name: "Unknown run", runID: "missing"},
+		{name: "Nothing failed", runID: succeeded.ID},
+		{name: "Unknown stage", runID: succeeded.ID, from: "deploy"},
+		{name: "Earlier stage never succeeded", runID: failed.ID, from: "publish"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runner.Resume(context.Background(), tt.runID, tt.from, testStages(calls, nil)); err == nil {
+				t.Error("Expected Resume() to fail")
+			}
+		})
+	}
+}
+
+func TestRunnerOnceStageNeverReruns(t *testing.T) {
+	runner := newTestRunner()
+	sends := 0
+	stages := func(failRender bool) []Stage {
+		return []Stage{
+			{Name: "render", Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				if failRender {
+					return nil, errors.New("template error")
+				}
+				return []byte("<html>"), nil
+			}},
+			{Name: "send", Once: true, Run: func(ctx context.Context, input []byte) ([]byte, error) {
+				sends++
+				return []byte("broadcast-1"), nil
+			}},
+		}
+	}
+
+	sent, err := runner.Start(context.Background(), "test", nil, stages(false))
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := runner.Resume(context.Background(), sent.ID, "render", stages(false)); err == nil {
+		t.Error("Expected resume before a sent Once stage to be refused")
+	}
+	if sends != 1 {
+		t.Errorf("Sent %d times; expected 1", sends)
+	}
+
+	failed, _ := runner.Start(context.Background(), "test", nil, stages(true))
+	if _, err := runner.Resume(context.Background(), failed.ID, "", stages(false)); err != nil {
+		t.Errorf("Resume() of an unsent run error = %v", err)
+	}
+	if sends != 2 {
+		t.Errorf("Sent %d times; expected the unsent run to send once", sends)
+	}
+}
+
+func TestRunnerOptionalStage(t *testing.T) {
+	runner := newTestRunner()
+	calls := map[string]int{}
+
+	run, err := runner.Start(context.Background(), "test", nil, func() []Stage {
+		stages := testStages(calls, map[string]bool{"upper": true})
+		stages[1].Optional = true
+		return stages
+	}())
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if run.Stage("upper").Status != StageFailed || run.Status != RunSucceeded {
+		t.Errorf("Run = %q, upper = %q; expected the run to succeed past the optional failure", run.Status, run.Stage("upper").Status)
+	}
+	if run.FailedStage() != "" {
+		t.Errorf("FailedStage() = %q; expected optional failures to be ignored", run.FailedStage())
+	}
+	output, _ := runner.Store().GetArtifact(context.Background(), run.ID, "publish")
+	if string(output) != "published:papers" {
+		t.Errorf("publish output = %q; expected the optional stage input to pass through", output)
+	}
+}
+
+func TestMemoryStoreListRuns(t *testing.T) {
+	store := NewMemoryStore()
+	base := time.Date(2025, 1, 15, 7, 0, 0, 0, time.UTC)
+	for i, name := range []string{"digest", "update-cache", "digest"} {
+		run := &Run{ID: name + string(rune('a'+i)), Pipeline: name, StartedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := store.SaveRun(context.Background(), run); err != nil {
+			t.Fatalf("SaveRun() error = %v", err)
+		}
+	}
+
+	runs, _ := store.ListRuns(context.Background(), "", 0)
+	if len(runs) != 3 || runs[0].ID != "digestc" {
+		t.Errorf("ListRuns() = %d runs starting %q; expected newest first", len(runs), runs[0].ID)
+	}
+	runs, _ = store.ListRuns(context.Background(), "digest", 1)
+	if len(runs) != 1 || runs[0].ID != "digestc" {
+		t.Errorf("ListRuns(digest, 1) = %v; expected only digestc", runs)
+	}
+	if _, err := store.GetRun(context.Background(), "missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("GetRun(missing) error = %v; expected ErrRunNotFound", err)
+	}
+	if _, err := store.GetArtifact(context.Background(), "digesta", "fetch"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("GetArtifact(missing) error = %v; expected ErrArtifactNotFound", err)
+	}
+}
@@ -0,0 +1,452 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+
+	vercelBlobAPIURL   = "https://blob.vercel-storage.com"
+	vercelBlobBaseURL  = "https://l0m9dfhwc2c0qq2u.public.blob.vercel-storage.com"
+	pipelineRunsPrefix = "pipeline-runs/"
+)
+
+// Store persists runs and the stage outputs needed to resume them
+type Store interface {
+	SaveRun(ctx context.Context, run *Run) error
+	// GetRun returns ErrRunNotFound for an unknown ID
+	GetRun(ctx context.Context, id string) (*Run, error)
+	// ListRuns returns the newest runs first; an empty pipeline lists every pipeline
+	ListRuns(ctx context.Context, pipeline string, limit int) ([]*Run, error)
+	SaveArtifact(ctx context.Context, runID, stage string, data []byte) error
+	// GetArtifact returns ErrArtifactNotFound if the output wasn't kept
+	GetArtifact(ctx context.Context, runID, stage string) ([]byte, error)
+}
+
+// MemoryStore keeps runs in process (local runs and tests)
+type MemoryStore struct {
+	mu        sync.RWMutex
+	runs      map[string]*Run
+	artifacts map[string][]byte
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		runs:      make(map[string]*Run),
+		artifacts: make(map[string][]byte),
+	}
+}
+
+// SaveRun stores a copy of the run
+func (m *MemoryStore) SaveRun(ctx context.Context, run *Run) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[run.ID] = copyRun(run)
+	return nil
+}
+
+// GetRun returns a copy of a stored run
+func (m *MemoryStore) GetRun(ctx context.Context, id string) (*Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	run, ok := m.runs[id]
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	return copyRun(run), nil
+}
+
+// ListRuns returns the newest runs first
+func (m *MemoryStore) ListRuns(ctx context.Context, pipeline string, limit int) ([]*Run, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	runs := make([]*Run, 0, len(m.runs))
+	for _, run := range m.runs {
+		if pipeline == "" || run.Pipeline == pipeline {
+			runs = append(runs, copyRun(run))
+		}
+	}
+	sortRuns(runs)
+	return limitRuns(runs, limit), nil
+}
+
+// SaveArtifact stores a stage output
+func (m *MemoryStore) SaveArtifact(ctx context.Context, runID, stage string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.artifacts[runID+"/"+stage] = append([]byte(nil), data...)
+	return nil
+}
+
+// GetArtifact returns a stored stage output
+func (m *MemoryStore) GetArtifact(ctx context.Context, runID, stage string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.artifacts[runID+"/"+stage]
+	if !ok {
+		return nil, ErrArtifactNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// PostgresStore keeps runs in pipeline_runs and outputs in pipeline_artifacts (migration 0005)
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store on an open database
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// SaveRun upserts the run
+func (p *PostgresStore) SaveRun(ctx context.Context, run *Run) error {
+	params, err := json.Marshal(run.Params)
+	if err != nil {
+		return fmt.Errorf("failed to encode run params: %w", err)
+	}
+	stages, err := json.Marshal(run.Stages)
+	if err != nil {
+		return fmt.Errorf("failed to encode run stages: %w", err)
+	}
+
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO pipeline_runs (id, pipeline, params, status, parent_id, resumed_from, stages, error, started_at, completed_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''), $9, $10)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			stages = EXCLUDED.stages,
+			error = EXCLUDED.error,
+			completed_at = EXCLUDED.completed_at`,
+		run.ID, run.Pipeline, string(params), run.Status, run.ParentID, run.ResumedFrom,
+		string(stages), run.Error, run.StartedAt, run.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save pipeline run: %w", err)
+	}
+	return nil
+}
+
+// GetRun loads a run by ID
+func (p *PostgresStore) GetRun(ctx context.Context, id string) (*Run, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, pipeline, params, status, COALESCE(parent_id, ''), COALESCE(resumed_from, ''),
+			stages, COALESCE(error, ''), started_at, completed_at
+		FROM pipeline_runs WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pipeline run: %w", err)
+	}
+	runs, err := scanRuns(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, ErrRunNotFound
+	}
+	return runs[0], nil
+}
+
+// ListRuns returns the newest runs first
+func (p *PostgresStore) ListRuns(ctx context.Context, pipeline string, limit int) ([]*Run, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, pipeline, params, status, COALESCE(parent_id, ''), COALESCE(resumed_from, ''),
+			stages, COALESCE(error, ''), started_at, completed_at
+		FROM pipeline_runs
+		WHERE $1 = '' OR pipeline = $1
+		ORDER BY started_at DESC
+		LIMIT $2`, pipeline, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pipeline runs: %w", err)
+	}
+	return scanRuns(rows)
+}
+
+// SaveArtifact upserts a stage output
+func (p *PostgresStore) SaveArtifact(ctx context.Context, runID, stage string, data []byte) error {
+	if data == nil {
+		data = []byte{}
+	}
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO pipeline_artifacts (run_id, stage, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (run_id, stage) DO UPDATE SET data = EXCLUDED.data, created_at = NOW()`,
+		runID, stage, data,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save stage output: %w", err)
+	}
+	return nil
+}
+
+// GetArtifact loads a stage output
+func (p *PostgresStore) GetArtifact(ctx context.Context, runID, stage string) ([]byte, error) {
+	var data []byte
+	err := p.db.QueryRowContext(ctx,
+		"SELECT data FROM pipeline_artifacts WHERE run_id = $1 AND stage = $2", runID, stage,
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stage output: %w", err)
+	}
+	return data, nil
+}
+
+func scanRuns(rows *sql.Rows) ([]*Run, error) {
+	defer func() { _ = rows.Close() }()
+
+	var runs []*Run
+	for rows.Next() {
+		var run Run
+		var params, stages []byte
+		if err := rows.Scan(&run.ID, &run.Pipeline, &params, &run.Status, &run.ParentID, &run.ResumedFrom,
+			&stages, &run.Error, &run.StartedAt, &run.CompletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pipeline run: %w", err)
+		}
+		if err := json.Unmarshal(params, &run.Params); err != nil {
+			return nil, fmt.Errorf("failed to decode run params: %w", err)
+		}
+		if err := json.Unmarshal(stages, &run.Stages); err != nil {
+			return nil, fmt.Errorf("failed to decode run stages: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pipeline runs: %w", err)
+	}
+	return runs, nil
+}
+
+// BlobStore keeps runs as JSON in Vercel Blob storage: pipeline-runs/{id}.json for the
+// run and pipeline-runs/{id}/{stage} for each stage output
+type BlobStore struct {
+	client *http.Client
+}
+
+// NewBlobStore creates a blob-backed store
+func NewBlobStore() *BlobStore {
+	return &BlobStore{client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// SaveRun writes the run JSON
+func (b *BlobStore) SaveRun(ctx context.Context, run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline run: %w", err)
+	}
+	return b.put(ctx, pipelineRunsPrefix+run.ID+".json", "application/json", data)
+}
+
+// GetRun fetches a run by its deterministic public URL
+func (b *BlobStore) GetRun(ctx context.Context, id string) (*Run, error) {
+	data, err := b.get(ctx, pipelineRunsPrefix+id+".json")
+	if err == ErrArtifactNotFound {
+		return nil, ErrRunNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to decode pipeline run: %w", err)
+	}
+	return &run, nil
+}
+
+// ListRuns lists run blobs newest first (IDs sort by start time) and loads them until limit
+func (b *BlobStore) ListRuns(ctx context.Context, pipeline string, limit int) ([]*Run, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	pathnames, err := b.list(ctx, pipelineRunsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, pathname := range pathnames {
+		name := strings.TrimPrefix(pathname, pipelineRunsPrefix)
+		if strings.HasSuffix(name, ".json") && !strings.Contains(name, "/") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	runs := make([]*Run, 0, limit)
+	for _, id := range ids {
+		if len(runs) == limit {
+			break
+		}
+		run, err := b.GetRun(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if pipeline == "" || run.Pipeline == pipeline {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// SaveArtifact writes a stage output
+func (b *BlobStore) SaveArtifact(ctx context.Context, runID, stage string, data []byte) error {
+	return b.put(ctx, pipelineRunsPrefix+runID+"/"+stage, "application/octet-stream", data)
+}
+
+// GetArtifact fetches a stage output
+func (b *BlobStore) GetArtifact(ctx context.Context, runID, stage string) ([]byte, error) {
+	return b.get(ctx, pipelineRunsPrefix+runID+"/"+stage)
+}
+
+func (b *BlobStore) get(ctx context.Context, pathname string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", vercelBlobBaseURL, pathname), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob request: %w", err)
+	}
+	// Runs are rewritten after every stage, so skip any CDN copy
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", pathname, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrArtifactNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-200 status when fetching %s: %s", pathname, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (b *BlobStore) put(ctx context.Context, pathname, contentType string, data []byte) error {
+	token := os.Getenv("BLOB_READ_WRITE_TOKEN")
+	if token == "" {
+		return fmt.Errorf("BLOB_READ_WRITE_TOKEN environment variable not set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/%s", vercelBlobAPIURL, pathname), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create PUT request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-add-random-suffix", "0")
+	req.Header.Set("x-allow-overwrite", "1")
+	req.Header.Set("x-cache-control-max-age", "60")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute PUT request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("blob store PUT returned non-200 status: %s - %s", resp.Status, string(body))
+	}
+	return nil
+}
+
+func (b *BlobStore) list(ctx context.Context, prefix string) ([]string, error) {
+	token := os.Getenv("BLOB_READ_WRITE_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("BLOB_READ_WRITE_TOKEN environment variable not set")
+	}
+
+	var pathnames []string
+	cursor := ""
+	for {
+		query := url.Values{"prefix": {prefix}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, vercelBlobAPIURL+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create list request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := b.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute list request: %w", err)
+		}
+		var listResponse struct {
+			Blobs []struct {
+				Pathname string `json:"pathname"`
+			} `json:"blobs"`
+			Cursor  string `json:"cursor"`
+			HasMore bool   `json:"hasMore"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("blob storage list API returned non-200 status: %s - %s", resp.Status, string(body))
+		}
+		err = json.NewDecoder(resp.Body).Decode(&listResponse)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode blob list response: %w", err)
+		}
+
+		for _, blob := range listResponse.Blobs {
+			pathnames = append(pathnames, blob.Pathname)
+		}
+		if !listResponse.HasMore || listResponse.Cursor == "" {
+			return pathnames, nil
+		}
+		cursor = listResponse.Cursor
+	}
+}
+
+func copyRun(run *Run) *Run {
+	clone := *run
+	clone.Stages = append([]StageRecord(nil), run.Stages...)
+	if run.Params != nil {
+		clone.Params = make(map[string]string, len(run.Params))
+		for k, v := range run.Params {
+			clone.Params[k] = v
+		}
+	}
+	return &clone
+}
+
+func sortRuns(runs []*Run) {
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].ID > runs[j].ID
+		}
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+}
+
+func limitRuns(runs []*Run, limit int) []*Run {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if len(runs) > limit {
+		return runs[:limit]
+	}
+	return runs
+}
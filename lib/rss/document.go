@@ -0,0 +1,146 @@
+package rss
+
+import (
+	"fmt"
+	"main/lib/article"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Document is the format-neutral feed that RSS 2.0, Atom 1.0 and JSON Feed 1.1 are rendered from
+type Document struct {
+	Title       string
+	Description string
+	Link        string // the page the feed describes
+	FeedURL     string // where the feed itself is served (self link)
+	Updated     time.Time
+	Authors     []string
+	Entries     []Entry
+}
+
+// Entry is one item of a Document
+type Entry struct {
+	ID          string
+	Title       string
+	Link        string
+	Summary     string // plain-text teaser
+	ContentHTML string
+	Published   time.Time
+	Authors     []string
+	Categories  []string
+	Enclosures  []Enclosure
+}
+
+// ParseDocument parses an RSS, Atom or JSON Feed document into a Document, keeping each
+// item whole (unlike ParseTldr, which splits the latest issue into sections)
+func ParseDocument(data []byte) (*Document, error) {
+	feed, err := gofeed.NewParser().ParseString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	doc := &Document{
+		Title:       feed.Title,
+		Description: feed.Description,
+		Link:        feed.Link,
+		FeedURL:     feed.FeedLink,
+	}
+	if feed.UpdatedParsed != nil {
+		doc.Updated = *feed.UpdatedParsed
+	} else if feed.PublishedParsed != nil {
+		doc.Updated = *feed.PublishedParsed
+	}
+
+	for _, item := range feed.Items {
+		parsed := NewItem(item)
+		entry := Entry{
+			ID:          string(parsed.GUID),
+			Title:       parsed.Title,
+			Link:        parsed.Link,
+			ContentHTML: item.Content,
+			Authors:     parsed.Authors,
+			Categories:  parsed.Categories,
+			Enclosures:  parsed.Enclosures,
+		}
+		// Without full content the description is the body; with it, the description is a teaser
+		if strings.TrimSpace(entry.ContentHTML) == "" {
+			entry.ContentHTML = item.Description
+		} else {
+			entry.Summary = item.Description
+		}
+		if entry.ID == "" {
+			entry.ID = entry.Link
+		}
+		if item.PublishedParsed != nil {
+			entry.Published = *item.PublishedParsed
+		} else if item.UpdatedParsed != nil {
+			entry.Published = *item.UpdatedParsed
+		}
+		if entry.Published.After(doc.Updated) {
+			doc.Updated = entry.Published
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return doc, nil
+}
+
+// DigestDocument maps an iGaming daily digest onto a Document with one entry per ranked article.
+// link is the digest's page; entries link to the original articles.
+func DigestDocument(digest *article.DailyDigest, link string) *Document {
+	doc := &Document{
+		Title:       "iGaming TLDR Daily Digest: " + digest.Date,
+		Description: digest.Headline,
+		Link:        link,
+		Updated:     digest.Created,
+		Entries:     make([]Entry, 0, len(digest.Articles)),
+	}
+	if doc.Description == "" {
+		doc.Description = digest.Summary
+	}
+
+	for _, ranked := range digest.Articles {
+		a := ranked.Article
+		entry := Entry{
+			ID:         a.URL,
+			Title:      a.Title,
+			Link:       a.URL,
+			Summary:    a.Summary,
+			Authors:    a.Authors,
+			Categories: a.Categories,
+		}
+		if entry.ID == "" {
+			entry.ID = fmt.Sprintf("digest-%s-%s", digest.Date, a.ID)
+		}
+		if entry.Summary == "" {
+			entry.Summary = a.OriginalSum
+		}
+		if a.SourceName != "" && len(entry.Authors) == 0 {
+			entry.Authors = []string{a.SourceName}
+		}
+		if a.ImageURL != "" {
+			entry.Enclosures = []Enclosure{{URL: a.ImageURL, Type: imageType(a.ImageURL)}}
+		}
+		if published, err := time.Parse(time.RFC3339, a.PublishedDate); err == nil {
+			entry.Published = published
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return doc
+}
+
+// imageType guesses an image URL's MIME type from its extension, defaulting to JPEG
+func imageType(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
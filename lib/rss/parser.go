@@ -0,0 +1,241 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// DefaultLink is the public site, used as the base URL of last resort and for the empty feed
+const DefaultLink = "https://tldr.takara.ai"
+
+var (
+	// Capture the inner content of the outermost div (non-greedy, dotall, allow attributes)
+	divRegex = regexp.MustCompile(`(?is)<div[^>]*>(.*?)</div>`)
+	// Capture the entire first paragraph following the Morning Headline h2 (including links/inline HTML)
+	headlineRegex = regexp.MustCompile(`(?is)<h2[^>]*>\s*Morning\s+Headline\s*</h2>\s*(<p[\s\S]*?>[\s\S]*?</p>)`)
+	// Find all h2 tags to split content into sections
+	h2TagRegex = regexp.MustCompile(`(?is)<h2[^>]*>.*?</h2>`)
+	// Capture the title inside a single h2 tag
+	h2TitleRegex = regexp.MustCompile(`(?is)<h2[^>]*>(.*?)</h2>`)
+)
+
+// BaseURL resolves this deployment's origin from BASE_URL, then the Vercel environment variables,
+// falling back to DefaultLink
+func BaseURL() string {
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	// Try Vercel environment variables in order of preference
+	for _, key := range []string{"VERCEL_PROJECT_PRODUCTION_URL", "VERCEL_URL", "VERCEL_BRANCH_URL"} {
+		if host := os.Getenv(key); host != "" {
+			return "https://" + host
+		}
+	}
+	return DefaultLink
+}
+
+// NewItem maps a gofeed item (RSS, Atom or JSON Feed) onto FeedItem, keeping its
+// categories, author names and enclosures
+func NewItem(item *gofeed.Item) FeedItem {
+	feedItem := FeedItem{
+		Title:       strings.TrimSpace(item.Title),
+		Link:        item.Link,
+		Description: item.Description,
+		PubDate:     item.Published,
+		GUID:        GUIDString(item.GUID),
+		Categories:  item.Categories,
+	}
+
+	for _, author := range item.Authors {
+		if author == nil {
+			continue
+		}
+		name := author.Name
+		if name == "" {
+			name = author.Email
+		}
+		if name != "" {
+			feedItem.Authors = append(feedItem.Authors, name)
+		}
+	}
+
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil || enclosure.URL == "" {
+			continue
+		}
+		feedItem.Enclosures = append(feedItem.Enclosures, Enclosure{
+			URL:    enclosure.URL,
+			Type:   enclosure.Type,
+			Length: enclosure.Length,
+		})
+	}
+	// Atom/JSON feeds carry the lead image separately; treat it as an image enclosure
+	if item.Image != nil && item.Image.URL != "" && len(feedItem.Enclosures) == 0 {
+		feedItem.Enclosures = append(feedItem.Enclosures, Enclosure{URL: item.Image.URL, Type: imageType(item.Image.URL)})
+	}
+
+	return feedItem
+}
+
+// SplitSections extracts the Morning Headline and one FeedItem per h2 section of a TLDR issue.
+// Sections inherit the issue's link, date, categories, authors and enclosures.
+func SplitSections(item *gofeed.Item) (string, []FeedItem) {
+	// Prefer full content when available
+	content := item.Content
+	if strings.TrimSpace(content) == "" {
+		content = item.Description
+	}
+
+	// Extract content from wrapper div if present
+	divMatches := divRegex.FindStringSubmatch(content)
+	if len(divMatches) >= 2 {
+		content = divMatches[1]
+	}
+
+	// Extract headline from Morning Headline paragraph
+	headline := ""
+	if m := headlineRegex.FindStringSubmatch(content); len(m) > 1 {
+		// Extract inner content from <p> tag
+		p := strings.TrimSpace(m[1])
+		if idx := strings.Index(p, ">"); idx != -1 {
+			inner := p[idx+1:]
+			if end := strings.LastIndex(inner, "</p>"); end != -1 {
+				headline = strings.TrimSpace(inner[:end])
+			}
+		}
+	}
+
+	// Split content by h2 sections
+	base := NewItem(item)
+	feedItems := []FeedItem{}
+	h2Positions := h2TagRegex.FindAllStringIndex(content, -1)
+
+	for i, pos := range h2Positions {
+		// Extract section title
+		h2Match := h2TitleRegex.FindStringSubmatch(content[pos[0]:pos[1]])
+		if len(h2Match) < 2 {
+			continue
+		}
+		title := strings.TrimSpace(h2Match[1])
+
+		// Skip Morning Headline section
+		if strings.EqualFold(title, "Morning Headline") {
+			continue
+		}
+
+		// Extract section content (from current h2 to next h2 or end)
+		endPos := len(content)
+		if i+1 < len(h2Positions) {
+			endPos = h2Positions[i+1][0]
+		}
+
+		section := base
+		section.Title = title
+		section.Description = strings.TrimSpace(content[pos[0]:endPos])
+		section.GUID = GUIDString(fmt.Sprintf("%s-section-%d", item.GUID, i))
+		feedItems = append(feedItems, section)
+	}
+
+	return headline, feedItems
+}
+
+// Parse parses any RSS, Atom or JSON Feed document item by item
+func Parse(data []byte) (*RssFeed, error) {
+	feed, err := gofeed.NewParser().ParseString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	result := &RssFeed{
+		Title:         feed.Title,
+		Description:   feed.Description,
+		Link:          feed.Link,
+		LastBuildDate: buildDate(feed, nil),
+		Items:         make([]FeedItem, 0, len(feed.Items)),
+	}
+	for _, item := range feed.Items {
+		result.Items = append(result.Items, NewItem(item))
+	}
+	return result, nil
+}
+
+// ParseTldr parses the /api/tldr RSS document: the latest issue's Morning Headline becomes the
+// feed description and each of its sections becomes an item
+func ParseTldr(data []byte) (*RssFeed, error) {
+	feed, err := gofeed.NewParser().ParseString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+	return tldrFeed(feed), nil
+}
+
+// FetchTldr fetches BaseURL()/api/tldr and parses it with ParseTldr
+func FetchTldr(ctx context.Context) (*RssFeed, error) {
+	fp := gofeed.NewParser()
+	fp.Client = &http.Client{Timeout: 30 * time.Second}
+
+	feed, err := fp.ParseURLWithContext(BaseURL()+"/api/tldr", ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+	return tldrFeed(feed), nil
+}
+
+// EmptyFeed is returned when the TLDR feed has no issues yet
+func EmptyFeed() *RssFeed {
+	return &RssFeed{
+		Title:         "Takara TLDR",
+		Description:   "Daily AI research summaries",
+		Link:          DefaultLink,
+		LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+		Items:         []FeedItem{},
+	}
+}
+
+func tldrFeed(feed *gofeed.Feed) *RssFeed {
+	if len(feed.Items) == 0 {
+		return EmptyFeed()
+	}
+
+	firstItem := feed.Items[0]
+	headline, feedItems := SplitSections(firstItem)
+
+	return &RssFeed{
+		Title:         feed.Title,
+		Description:   headline,
+		Link:          feed.Link,
+		LastBuildDate: buildDate(feed, firstItem),
+		Items:         feedItems,
+	}
+}
+
+// buildDate dates the feed by the given item's publish time, then the channel's
+// lastBuildDate/pubDate, formatted RFC1123Z in UTC. Unparseable dates are kept verbatim;
+// a feed with no date at all is dated now.
+func buildDate(feed *gofeed.Feed, item *gofeed.Item) string {
+	parsed := []*time.Time{feed.UpdatedParsed, feed.PublishedParsed}
+	raw := []string{feed.Updated, feed.Published}
+	if item != nil {
+		parsed = append([]*time.Time{item.PublishedParsed}, parsed...)
+		raw = append([]string{item.Published}, raw...)
+	}
+
+	for _, t := range parsed {
+		if t != nil {
+			return t.UTC().Format(time.RFC1123Z)
+		}
+	}
+	for _, s := range raw {
+		if s != "" {
+			return s
+		}
+	}
+	return time.Now().UTC().Format(time.RFC1123Z)
+}
@@ -0,0 +1,165 @@
+package rss
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const tldrFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+	<title>Takara TLDR</title>
+	<link>https://tldr.takara.ai</link>
+	<description>Daily AI research summaries</description>
+	<lastBuildDate>Tue, 14 Jan 2025 07:00:00 +0000</lastBuildDate>
+	<item>
+		<title>Research Summary 2025-01-15</title>
+		<link>https://tldr.takara.ai/p/2025-01-15</link>
+		<guid>tldr-2025-01-15</guid>
+		<pubDate>Wed, 15 Jan 2025 07:00:00 +0000</pubDate>
+		<author>editor@takara.ai (Takara Editors)</author>
+		<category>AI</category>
+		<category>Research</category>
+		<enclosure url="https://cdn.takara.ai/2025-01-15.mp3" type="audio/mpeg" length="1024"/>
+		<description>Short description</description>
+		<content:encoded><![CDATA[<div class="summary"><h2>Morning Headline</h2><p>Models get <a href="https://x.test">smaller</a></p><h2>Language Models</h2><p>Paper one</p><h2>Vision</h2><p>Paper two</p></div>]]></content:encoded>
+	</item>
+	<item>
+		<title>Research Summary 2025-01-14</title>
+		<link>https://tldr.takara.ai/p/2025-01-14</link>
+		<guid>tldr-2025-01-14</guid>
+		<description>Older issue</description>
+	</item>
+</channel>
+</rss>`
+
+func TestParseTldr(t *testing.T) {
+	feed, err := ParseTldr([]byte(tldrFixture))
+	if err != nil {
+		t.Fatalf("ParseTldr() error = %v", err)
+	}
+
+	if feed.Description != `Models get <a href="https://x.test">smaller</a>` {
+		t.Errorf("Description = %q; expected the Morning Headline paragraph", feed.Description)
+	}
+	if feed.LastBuildDate != "Wed, 15 Jan 2025 07:00:00 +0000" {
+		t.Errorf("LastBuildDate = %q; expected the latest issue's date", feed.LastBuildDate)
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("Parsed %d sections; expected 2", len(feed.Items))
+	}
+
+	first := feed.Items[0]
+	if first.Title != "Language Models" || first.GUID != "tldr-2025-01-15-section-1" {
+		t.Errorf("First section = %q (%s); expected Language Models with a section GUID", first.Title, first.GUID)
+	}
+	if first.Description != "<h2>Language Models</h2><p>Paper one</p>" {
+		t.Errorf("First section description = %q", first.Description)
+	}
+	if first.Link != "https://tldr.takara.ai/p/2025-01-15" || first.PubDate != "Wed, 15 Jan 2025 07:00:00 +0000" {
+		t.Errorf("First section = %+v; expected the issue's link and date", first)
+	}
+	if len(first.Categories) != 2 || len(first.Authors) != 1 || first.Authors[0] != "Takara Editors" {
+		t.Errorf("Sections should inherit categories and authors, got %v / %v", first.Categories, first.Authors)
+	}
+	if len(first.Enclosures) != 1 || first.Enclosures[0] != (Enclosure{URL: "https://cdn.takara.ai/2025-01-15.mp3", Type: "audio/mpeg", Length: "1024"}) {
+		t.Errorf("Enclosures = %+v", first.Enclosures)
+	}
+}
+
+func TestParseTldrEmpty(t *testing.T) {
+	feed, err := ParseTldr([]byte(`<rss version="2.0"><channel><title>Takara TLDR</title></channel></rss>`))
+	if err != nil {
+		t.Fatalf("ParseTldr() error = %v", err)
+	}
+	if feed.Link != DefaultLink || feed.LastBuildDate == "" || feed.Items == nil {
+		t.Errorf("Empty feed = %+v; expected the default feed dated now", feed)
+	}
+
+	if _, err := ParseTldr([]byte("not a feed")); err == nil {
+		t.Error("Expected an error for a non-feed document")
+	}
+}
+
+func TestParseKeepsItems(t *testing.T) {
+	atom := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>iGaming News</title>
+	<updated>2025-01-15T09:30:00+01:00</updated>
+	<entry>
+		<title> Operator fined </title>
+		<link href="https://news.test/fined"/>
+		<id>urn:news:1</id>
+		<published>2025-01-15T08:00:00Z</published>
+		<author><name>Jane Reporter</name></author>
+		<category term="Regulations"/>
+		<link rel="enclosure" href="https://news.test/fined.jpg" type="image/jpeg" length="2048"/>
+		<summary>UKGC fines operator</summary>
+	</entry>
+</feed>`
+
+	feed, err := Parse([]byte(atom))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if feed.LastBuildDate != "Wed, 15 Jan 2025 08:30:00 +0000" {
+		t.Errorf("LastBuildDate = %q; expected the channel's update time in UTC", feed.LastBuildDate)
+	}
+	if len(feed.Items) != 1 {
+		t.Fatalf("Parsed %d items; expected 1", len(feed.Items))
+	}
+
+	item := feed.Items[0]
+	if item.Title != "Operator fined" || item.Link != "https://news.test/fined" || item.GUID != "urn:news:1" {
+		t.Errorf("Item = %+v", item)
+	}
+	if len(item.Authors) != 1 || item.Authors[0] != "Jane Reporter" {
+		t.Errorf("Authors = %v", item.Authors)
+	}
+	if len(item.Categories) != 1 || item.Categories[0] != "Regulations" {
+		t.Errorf("Categories = %v", item.Categories)
+	}
+	if len(item.Enclosures) != 1 || item.Enclosures[0].Type != "image/jpeg" {
+		t.Errorf("Enclosures = %+v", item.Enclosures)
+	}
+}
+
+func TestGUIDStringUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want GUIDString
+	}{
+		{name: "String", json: `"tldr-1"`, want: "tldr-1"},
+		{name: "Object with text", json: `{"#text": "tldr-2", "isPermaLink": "false"}`, want: "tldr-2"},
+		{name: "Object with value", json: `{"value": "tldr-3"}`, want: "tldr-3"},
+		{name: "Number", json: `42`, want: "42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item FeedItem
+			if err := json.Unmarshal([]byte(`{"guid": `+tt.json+`}`), &item); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if item.GUID != tt.want {
+				t.Errorf("GUID = %q; expected %q", item.GUID, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	t.Setenv("BASE_URL", "")
+	t.Setenv("VERCEL_PROJECT_PRODUCTION_URL", "")
+	t.Setenv("VERCEL_URL", "preview.vercel.app")
+	t.Setenv("VERCEL_BRANCH_URL", "")
+	if got := BaseURL(); got != "https://preview.vercel.app" {
+		t.Errorf("BaseURL() = %q; expected the Vercel deployment URL", got)
+	}
+
+	t.Setenv("BASE_URL", "http://localhost:3000/")
+	if got := BaseURL(); got != "http://localhost:3000" {
+		t.Errorf("BaseURL() = %q; expected BASE_URL without a trailing slash", got)
+	}
+}
@@ -0,0 +1,451 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a syndication format a Document can be rendered as
+type Format string
+
+const (
+	FormatRSS  Format = "rss"  // RSS 2.0
+	FormatAtom Format = "atom" // Atom 1.0
+	FormatJSON Format = "json" // JSON Feed 1.1
+)
+
+// feedMediaTypes maps the media types a client can Accept onto formats. Generic types
+// (application/json, application/xml) are left out so browsers and API clients keep
+// getting each endpoint's default.
+var feedMediaTypes = map[string]Format{
+	"application/rss+xml":   FormatRSS,
+	"application/atom+xml":  FormatAtom,
+	"application/feed+json": FormatJSON,
+}
+
+// ContentType is the response Content-Type for the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatAtom:
+		return "application/atom+xml; charset=utf-8"
+	case FormatJSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return "application/rss+xml; charset=utf-8"
+	}
+}
+
+// ParseFormat reads a ?format= value: rss, atom or json (jsonfeed is accepted too)
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "rss":
+		return FormatRSS, nil
+	case "atom":
+		return FormatAtom, nil
+	case "json", "jsonfeed":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unknown feed format %q", value)
+}
+
+// NegotiateFormat picks the format from ?format=, then the Accept header (highest q wins,
+// ties go to the first listed). fallback is returned when neither names a feed format;
+// an empty fallback lets the caller serve its own default. Only an unknown ?format= errors.
+func NegotiateFormat(r *http.Request, fallback Format) (Format, error) {
+	if value := r.URL.Query().Get("format"); value != "" {
+		return ParseFormat(value)
+	}
+
+	best, bestQ := fallback, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		format, ok := feedMediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, nil
+}
+
+// Render renders the document in the given format
+func Render(doc *Document, format Format) ([]byte, error) {
+	switch format {
+	case FormatRSS:
+		return RenderRSS(doc)
+	case FormatAtom:
+		return RenderAtom(doc)
+	case FormatJSON:
+		return RenderJSONFeed(doc)
+	}
+	return nil, fmt.Errorf("unknown feed format %q", format)
+}
+
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      *atomLink `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link,omitempty"`
+	Description cdata          `xml:"description"`
+	Content     *cdata         `xml:"content:encoded,omitempty"`
+	PubDate     string         `xml:"pubDate,omitempty"`
+	GUID        rssGUID        `xml:"guid"`
+	Creators    []string       `xml:"dc:creator"`
+	Categories  []string       `xml:"category"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Text        string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// RenderRSS renders the document as RSS 2.0. Items carry the summary as description and the
+// full HTML as content:encoded; without a summary the HTML goes in description, as /api/tldr
+// has always served it.
+func RenderRSS(doc *Document) ([]byte, error) {
+	channel := rssChannel{
+		Title:       doc.Title,
+		Link:        doc.Link,
+		Description: doc.Description,
+		Items:       make([]rssItem, 0, len(doc.Entries)),
+	}
+	if !doc.Updated.IsZero() {
+		channel.LastBuildDate = doc.Updated.UTC().Format(time.RFC1123Z)
+	}
+	if doc.FeedURL != "" {
+		channel.AtomLink = &atomLink{Href: doc.FeedURL, Rel: "self", Type: "application/rss+xml"}
+	}
+
+	for _, entry := range doc.Entries {
+		item := rssItem{
+			Title:      entry.Title,
+			Link:       entry.Link,
+			GUID:       rssGUID{IsPermaLink: entry.ID == entry.Link && isAbsoluteURL(entry.ID), Text: entry.ID},
+			Creators:   entry.Authors,
+			Categories: entry.Categories,
+		}
+		switch {
+		case entry.Summary == "":
+			item.Description = cdata{Text: entry.ContentHTML}
+		case entry.ContentHTML == "":
+			item.Description = cdata{Text: entry.Summary}
+		default:
+			item.Description = cdata{Text: entry.Summary}
+			item.Content = &cdata{Text: entry.ContentHTML}
+		}
+		if !entry.Published.IsZero() {
+			item.PubDate = entry.Published.UTC().Format(time.RFC1123Z)
+		}
+		for _, enclosure := range entry.Enclosures {
+			length := enclosure.Length
+			if length == "" {
+				length = "0"
+			}
+			item.Enclosures = append(item.Enclosures, rssEnclosure{URL: enclosure.URL, Type: enclosure.Type, Length: length})
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	return marshalXML(rssDocument{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+type atomFeed struct {
+	XMLName  xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Subtitle string       `xml:"subtitle,omitempty"`
+	Updated  string       `xml:"updated"`
+	Links    []atomLink   `xml:"link"`
+	Authors  []atomPerson `xml:"author"`
+	Entries  []atomEntry  `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length string `xml:"length,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+// RenderAtom renders the document as Atom 1.0. Entries without a date use the feed's
+// updated time, since Atom requires one on every entry.
+func RenderAtom(doc *Document) ([]byte, error) {
+	updated := doc.Updated
+	if updated.IsZero() {
+		updated = latestPublished(doc)
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	feed := atomFeed{
+		ID:       atomID(firstNonEmpty(doc.FeedURL, doc.Link)),
+		Title:    doc.Title,
+		Subtitle: doc.Description,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Authors:  atomPeople(doc.Authors),
+		Entries:  make([]atomEntry, 0, len(doc.Entries)),
+	}
+	// Atom requires an author on the feed or on every entry
+	if len(feed.Authors) == 0 {
+		feed.Authors = []atomPerson{{Name: doc.Title}}
+	}
+	if doc.Link != "" {
+		feed.Links = append(feed.Links, atomLink{Href: doc.Link, Rel: "alternate", Type: "text/html"})
+	}
+	if doc.FeedURL != "" {
+		feed.Links = append(feed.Links, atomLink{Href: doc.FeedURL, Rel: "self", Type: "application/atom+xml"})
+	}
+
+	for _, entry := range doc.Entries {
+		item := atomEntry{
+			ID:      atomID(entry.ID),
+			Title:   entry.Title,
+			Updated: updated.UTC().Format(time.RFC3339),
+			Authors: atomPeople(entry.Authors),
+		}
+		if !entry.Published.IsZero() {
+			item.Published = entry.Published.UTC().Format(time.RFC3339)
+			item.Updated = item.Published
+		}
+		if entry.Link != "" {
+			item.Links = append(item.Links, atomLink{Href: entry.Link, Rel: "alternate", Type: "text/html"})
+		}
+		for _, enclosure := range entry.Enclosures {
+			item.Links = append(item.Links, atomLink{Href: enclosure.URL, Rel: "enclosure", Type: enclosure.Type, Length: enclosure.Length})
+		}
+		for _, category := range entry.Categories {
+			item.Categories = append(item.Categories, atomCategory{Term: category})
+		}
+		if entry.Summary != "" {
+			item.Summary = &atomText{Type: "text", Body: entry.Summary}
+		}
+		if entry.ContentHTML != "" {
+			item.Content = &atomText{Type: "html", Body: entry.ContentHTML}
+		}
+		feed.Entries = append(feed.Entries, item)
+	}
+
+	return marshalXML(feed)
+}
+
+// JSON Feed 1.1, https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html,omitempty"`
+	ContentText   string               `json:"content_text,omitempty"`
+	Summary       string               `json:"summary,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+// RenderJSONFeed renders the document as JSON Feed 1.1. Items without HTML content carry
+// their summary as content_text, since every item needs one or the other.
+func RenderJSONFeed(doc *Document) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       doc.Title,
+		HomePageURL: doc.Link,
+		FeedURL:     doc.FeedURL,
+		Description: doc.Description,
+		Authors:     jsonFeedAuthors(doc.Authors),
+		Items:       make([]jsonFeedItem, 0, len(doc.Entries)),
+	}
+
+	for _, entry := range doc.Entries {
+		item := jsonFeedItem{
+			ID:          entry.ID,
+			URL:         entry.Link,
+			Title:       entry.Title,
+			ContentHTML: entry.ContentHTML,
+			Summary:     entry.Summary,
+			Authors:     jsonFeedAuthors(entry.Authors),
+			Tags:        entry.Categories,
+		}
+		if item.ContentHTML == "" {
+			item.ContentText, item.Summary = entry.Summary, ""
+		}
+		if !entry.Published.IsZero() {
+			item.DatePublished = entry.Published.UTC().Format(time.RFC3339)
+		}
+		for _, enclosure := range entry.Enclosures {
+			if strings.HasPrefix(enclosure.Type, "image/") && item.Image == "" {
+				item.Image = enclosure.URL
+				continue
+			}
+			size, _ := strconv.ParseInt(enclosure.Length, 10, 64)
+			item.Attachments = append(item.Attachments, jsonFeedAttachment{URL: enclosure.URL, MimeType: enclosure.Type, SizeInBytes: size})
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return nil, fmt.Errorf("failed to encode JSON feed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	output, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode feed XML: %w", err)
+	}
+	return append([]byte(xml.Header), append(output, '\n')...), nil
+}
+
+// atomID returns id when it's already an absolute IRI, otherwise a urn under this site
+func atomID(id string) string {
+	if isAbsoluteURL(id) || strings.HasPrefix(id, "urn:") || strings.HasPrefix(id, "tag:") {
+		return id
+	}
+	return "urn:takara-tldr:" + id
+}
+
+func isAbsoluteURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func latestPublished(doc *Document) time.Time {
+	var latest time.Time
+	for _, entry := range doc.Entries {
+		if entry.Published.After(latest) {
+			latest = entry.Published
+		}
+	}
+	return latest
+}
+
+func atomPeople(names []string) []atomPerson {
+	people := make([]atomPerson, 0, len(names))
+	for _, name := range names {
+		people = append(people, atomPerson{Name: name})
+	}
+	return people
+}
+
+func jsonFeedAuthors(names []string) []jsonFeedAuthor {
+	if len(names) == 0 {
+		return nil
+	}
+	authors := make([]jsonFeedAuthor, 0, len(names))
+	for _, name := range names {
+		authors = append(authors, jsonFeedAuthor{Name: name})
+	}
+	return authors
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,189 @@
+package rss
+
+import (
+	"bytes"
+	"flag"
+	"main/lib/article"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// go test ./lib/rss -update rewrites the golden files in testdata from the current renderers
+var update = flag.Bool("update", false, "rewrite golden files")
+
+const summaryFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>Takara TLDR</title>
+    <link>https://tldr.takara.ai</link>
+    <description>Daily summaries of AI research papers from takara.ai</description>
+    <lastBuildDate>Wed, 15 Jan 2025 00:00:00 +0000</lastBuildDate>
+    <atom:link href="https://tldr.takara.ai/api/tldr" rel="self" type="application/rss+xml"></atom:link>
+    <item>
+      <title>AI Research Papers Summary for January 15, 2025</title>
+      <link>https://tldr.takara.ai</link>
+      <description><![CDATA[<div><h2>Morning Headline</h2><p>Small models & big wins</p><h2>Language Models</h2><p>Paper one</p></div>]]></description>
+      <pubDate>Wed, 15 Jan 2025 00:00:00 +0000</pubDate>
+      <guid isPermaLink="false">summary-2025-01-15</guid>
+    </item>
+  </channel>
+</rss>`
+
+func summaryDocument(t *testing.T) *Document {
+	doc, err := ParseDocument([]byte(summaryFixture))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	doc.FeedURL = "https://tldr.takara.ai/api/tldr"
+	return doc
+}
+
+func digestDocument() *Document {
+	digest := &article.DailyDigest{
+		Date:     "2025-01-15",
+		Headline: "UKGC tightens affordability checks",
+		Summary:  "Regulation led the day.",
+		Created:  time.Date(2025, 1, 15, 6, 0, 0, 0, time.UTC),
+		Articles: []article.RankedArticle{
+			{
+				Rank: 1,
+				Article: article.ArticleData{
+					ID:            "a1",
+					Title:         "UKGC announces affordability checks",
+					Summary:       "Operators must run checks above £150 net loss.",
+					URL:           "https://news.test/ukgc-affordability",
+					SourceName:    "iGamingBusiness",
+					PublishedDate: "2025-01-15T05:30:00Z",
+					ImageURL:      "https://news.test/ukgc.png",
+					Categories:    []string{"Regulations"},
+					Authors:       []string{"Jane Reporter"},
+				},
+			},
+			{
+				Rank: 2,
+				Article: article.ArticleData{
+					ID:            "a2",
+					Title:         "Operators merge <in> $2bn deal",
+					OriginalSum:   "Two operators combine.",
+					URL:           "https://news.test/merger",
+					SourceName:    "Gambling Insider",
+					PublishedDate: "2025-01-14T22:00:00Z",
+					Categories:    []string{"M&A", "Business"},
+				},
+			},
+		},
+	}
+	doc := DigestDocument(digest, "https://tldr.takara.ai/gaming/2025-01-15")
+	doc.FeedURL = "https://tldr.takara.ai/api/digest?date=2025-01-15"
+	return doc
+}
+
+func TestRenderGolden(t *testing.T) {
+	docs := map[string]func(t *testing.T) *Document{
+		"summary": summaryDocument,
+		"digest":  func(*testing.T) *Document { return digestDocument() },
+	}
+	extensions := map[Format]string{FormatRSS: "rss.xml", FormatAtom: "atom.xml", FormatJSON: "feed.json"}
+
+	for name, doc := range docs {
+		for format, ext := range extensions {
+			t.Run(name+"/"+string(format), func(t *testing.T) {
+				got, err := Render(doc(t), format)
+				if err != nil {
+					t.Fatalf("Render() error = %v", err)
+				}
+
+				path := filepath.Join("testdata", name+"."+ext)
+				if *update {
+					if err := os.WriteFile(path, got, 0o644); err != nil {
+						t.Fatalf("failed to write golden file: %v", err)
+					}
+				}
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s output differs from %s:\n%s", format, path, got)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	// Every format must parse back into the same entries
+	for _, format := range []Format{FormatRSS, FormatAtom, FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := Render(digestDocument(), format)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			doc, err := ParseDocument(data)
+			if err != nil {
+				t.Fatalf("ParseDocument() error = %v", err)
+			}
+			if len(doc.Entries) != 2 {
+				t.Fatalf("Parsed %d entries; expected 2", len(doc.Entries))
+			}
+			entry := doc.Entries[0]
+			if entry.Title != "UKGC announces affordability checks" || entry.Link != "https://news.test/ukgc-affordability" {
+				t.Errorf("Entry = %+v", entry)
+			}
+			if !entry.Published.Equal(time.Date(2025, 1, 15, 5, 30, 0, 0, time.UTC)) {
+				t.Errorf("Published = %v", entry.Published)
+			}
+			if doc.Entries[1].Title != "Operators merge <in> $2bn deal" {
+				t.Errorf("Title = %q; expected special characters to survive", doc.Entries[1].Title)
+			}
+		})
+	}
+
+	// The TLDR summary keeps its sections through a re-render
+	data, _ := RenderRSS(summaryDocument(t))
+	feed, err := ParseTldr(data)
+	if err != nil || feed.Description != "Small models & big wins" || len(feed.Items) != 1 {
+		t.Errorf("ParseTldr() of re-rendered RSS = %+v, %v", feed, err)
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		accept   string
+		fallback Format
+		want     Format
+		wantErr  bool
+	}{
+		{name: "Default", fallback: FormatRSS, want: FormatRSS},
+		{name: "No default", want: ""},
+		{name: "Query wins over Accept", query: "format=atom", accept: "application/feed+json", want: FormatAtom},
+		{name: "JSON Feed alias", query: "format=jsonfeed", want: FormatJSON},
+		{name: "Unknown query format", query: "format=csv", wantErr: true},
+		{name: "Accept", accept: "application/feed+json", fallback: FormatRSS, want: FormatJSON},
+		{name: "Accept q values", accept: "application/rss+xml;q=0.5, application/atom+xml;q=0.9", want: FormatAtom},
+		{name: "Accept tie keeps first", accept: "application/atom+xml, application/rss+xml", want: FormatAtom},
+		{name: "Browser Accept keeps default", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: ""},
+		{name: "Refused type", accept: "application/atom+xml;q=0", fallback: FormatRSS, want: FormatRSS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/tldr?"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			got, err := NegotiateFormat(r, tt.fallback)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NegotiateFormat() error = %v; wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NegotiateFormat() = %q; expected %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package broadcast
+package rss
 
 import (
 	"encoding/json"
@@ -47,16 +47,27 @@ func (g *GUIDString) UnmarshalJSON(data []byte) error {
 	}
 }
 
-// FeedItem corresponds to the TypeScript FeedItemType.
+// Enclosure is a media attachment on a feed item (podcast audio, lead image, ...).
+type Enclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Length string `json:"length,omitempty"`
+}
+
+// FeedItem corresponds to a single item in an RSS feed.
 type FeedItem struct {
-	Title       string     `json:"title"`
-	Link        string     `json:"link"`
-	Description string     `json:"description"`
-	PubDate     string     `json:"pubDate"`
-	GUID        GUIDString `json:"guid"`
+	Title       string      `json:"title"`
+	Link        string      `json:"link"`
+	Description string      `json:"description"`
+	PubDate     string      `json:"pubDate"`
+	GUID        GUIDString  `json:"guid"`
+	Categories  []string    `json:"categories,omitempty"`
+	Authors     []string    `json:"authors,omitempty"`
+	Enclosures  []Enclosure `json:"enclosures,omitempty"`
 }
 
-// RssFeed corresponds to the TypeScript RssFeed type.
+// RssFeed corresponds to the overall RSS feed structure.
+// It is the JSON shape served by /api/feed, archived under tldr-feeds/ and rendered into emails.
 type RssFeed struct {
 	Title         string     `json:"title"`
 	Description   string     `json:"description"`
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Highlight HTML-escapes text and wraps the words matching any of terms in <mark> tags.
+// If maxLen > 0 and text is longer, it returns a window of about maxLen bytes starting
+// shortly before the first match, cut on word boundaries and marked with ellipses.
+// The bool reports whether anything matched.
+func Highlight(text string, terms []string, maxLen int) (string, bool) {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	spans := wordRegex.FindAllStringIndex(text, -1)
+	matched := make([]bool, len(spans))
+	first := -1
+	for i, span := range spans {
+		if token := normalize(text[span[0]:span[1]]); token != "" && wanted[token] {
+			matched[i] = true
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+
+	start, end := 0, len(text)
+	if maxLen > 0 && len(text) > maxLen && len(spans) > 0 {
+		start, end = snippetWindow(spans, first, maxLen, len(text))
+	}
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("…")
+	}
+	pos := start
+	for i, span := range spans {
+		if span[0] < start || span[1] > end {
+			continue
+		}
+		if !matched[i] {
+			continue
+		}
+		builder.WriteString(html.EscapeString(text[pos:span[0]]))
+		builder.WriteString("<mark>")
+		builder.WriteString(html.EscapeString(text[span[0]:span[1]]))
+		builder.WriteString("</mark>")
+		pos = span[1]
+	}
+	builder.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		builder.WriteString("…")
+	}
+	return builder.String(), first >= 0
+}
+
+// snippetWindow picks the byte range of a snippet: it starts a quarter of maxLen before the
+// first matched word (or at the beginning) and both ends fall on word boundaries
+func snippetWindow(spans [][]int, first, maxLen, textLen int) (int, int) {
+	anchor := 0
+	if first >= 0 {
+		anchor = first
+	}
+
+	start := 0
+	if spans[anchor][0]-maxLen/4 > 0 {
+		start = spans[anchor][0]
+		for i := anchor - 1; i >= 0 && spans[anchor][0]-spans[i][0] <= maxLen/4; i-- {
+			start = spans[i][0]
+		}
+		if start == spans[0][0] {
+			start = 0
+		}
+	}
+
+	end := start + maxLen
+	if end >= textLen {
+		return start, textLen
+	}
+	last := spans[anchor][1]
+	for i := anchor; i < len(spans) && spans[i][1] <= end; i++ {
+		last = spans[i][1]
+	}
+	return start, last
+}
@@ -0,0 +1,279 @@
+// Package search is a small in-memory inverted index scored with Okapi BM25, shared by
+// the article search API and the summary link matcher. Documents have named fields that
+// can be boosted, can be added and removed at any time, and the index can be saved to
+// and restored from a snapshot.
+package search
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// Default BM25 parameters
+const (
+	DefaultK1 = 1.2
+	DefaultB  = 0.75
+)
+
+// IDFFunc computes the inverse document frequency of a term found in docFreq of docCount documents
+type IDFFunc func(docFreq, docCount int) float64
+
+// SmoothIDF is the +1 form of IDF (as in Lucene): terms found in most documents still
+// count a little rather than going negative. It's the default.
+func SmoothIDF(docFreq, docCount int) float64 {
+	n, N := float64(docFreq), float64(docCount)
+	return math.Log(1 + (N-n+0.5)/(n+0.5))
+}
+
+// ClassicIDF is the Robertson-Spärck Jones IDF. Terms in more than half the documents
+// score zero or below, so they count against a match rather than for it.
+func ClassicIDF(docFreq, docCount int) float64 {
+	n, N := float64(docFreq), float64(docCount)
+	return math.Log((N - n + 0.5) / (n + 0.5))
+}
+
+// Config holds the scoring parameters and text analysis of an index
+type Config struct {
+	K1       float64                    // Term frequency saturation
+	B        float64                    // Document length normalization
+	Boosts   map[string]float64         // Field weights, applied when documents are added (default 1)
+	Tokenize func(text string) []string // Text to terms, for documents and queries (default Tokenize)
+	IDF      IDFFunc                    // Default SmoothIDF
+}
+
+// DefaultConfig returns the default BM25 parameters with no field boosts
+func DefaultConfig() Config {
+	return Config{K1: DefaultK1, B: DefaultB, Tokenize: Tokenize, IDF: SmoothIDF}
+}
+
+// Document is a unit of search with named text fields, e.g. "title" and "abstract"
+type Document struct {
+	ID     string
+	Fields map[string]string
+}
+
+// Hit is a matching document and its BM25 score
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index maps terms to the documents containing them. It is not safe for concurrent writes.
+//
+// Field boosting weights each field's term counts and length before they're summed
+// (a simplified BM25F), so a title word boosted 3x counts like three occurrences.
+type Index struct {
+	config Config
+
+	docs     []indexedDoc // Slots in insertion order; removed documents leave an empty slot
+	ids      map[string]int
+	postings map[string]map[int]struct{}
+	totalLen float64
+}
+
+type indexedDoc struct {
+	id     string
+	terms  map[string]float64 // Weighted term frequencies
+	length float64            // Weighted length
+}
+
+// NewIndex creates an empty index. Zero K1, B, Tokenize and IDF take their defaults.
+func NewIndex(config Config) *Index {
+	defaults := DefaultConfig()
+	if config.K1 == 0 {
+		config.K1 = defaults.K1
+	}
+	if config.B == 0 {
+		config.B = defaults.B
+	}
+	if config.Tokenize == nil {
+		config.Tokenize = defaults.Tokenize
+	}
+	if config.IDF == nil {
+		config.IDF = defaults.IDF
+	}
+	return &Index{
+		config:   config,
+		ids:      make(map[string]int),
+		postings: make(map[string]map[int]struct{}),
+	}
+}
+
+// Config returns the index configuration
+func (ix *Index) Config() Config {
+	return ix.config
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	return len(ix.ids)
+}
+
+// Has reports whether a document is indexed
+func (ix *Index) Has(id string) bool {
+	_, ok := ix.ids[id]
+	return ok
+}
+
+// DocFreq returns the number of documents containing a term
+func (ix *Index) DocFreq(term string) int {
+	return len(ix.postings[term])
+}
+
+// AvgDocLen returns the average weighted document length
+func (ix *Index) AvgDocLen() float64 {
+	if len(ix.ids) == 0 {
+		return 0
+	}
+	return ix.totalLen / float64(len(ix.ids))
+}
+
+// Terms returns the distinct terms of a query under the index's tokenizer, in order
+func (ix *Index) Terms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range ix.config.Tokenize(query) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Add indexes a document, replacing any document with the same ID
+func (ix *Index) Add(doc Document) {
+	ix.Remove(doc.ID)
+
+	// Sum fields in a fixed order so float rounding can't vary between runs
+	fields := make([]string, 0, len(doc.Fields))
+	for field := range doc.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	terms := make(map[string]float64)
+	length := 0.0
+	for _, field := range fields {
+		boost := 1.0
+		if weight, ok := ix.config.Boosts[field]; ok {
+			boost = weight
+		}
+		for _, term := range ix.config.Tokenize(doc.Fields[field]) {
+			terms[term] += boost
+			length += boost
+		}
+	}
+	ix.insert(indexedDoc{id: doc.ID, terms: terms, length: length})
+}
+
+// Remove drops a document from the index and reports whether it was there
+func (ix *Index) Remove(id string) bool {
+	slot, ok := ix.ids[id]
+	if !ok {
+		return false
+	}
+
+	doc := ix.docs[slot]
+	for term := range doc.terms {
+		delete(ix.postings[term], slot)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	ix.totalLen -= doc.length
+	ix.docs[slot] = indexedDoc{}
+	delete(ix.ids, id)
+	return true
+}
+
+func (ix *Index) insert(doc indexedDoc) {
+	slot := len(ix.docs)
+	ix.docs = append(ix.docs, doc)
+	ix.ids[doc.id] = slot
+	ix.totalLen += doc.length
+	for term := range doc.terms {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[int]struct{})
+		}
+		ix.postings[term][slot] = struct{}{}
+	}
+}
+
+// Search returns the k best documents containing at least one query term, best first;
+// k <= 0 returns every match. Equal scores keep insertion order, so callers can add
+// documents in a meaningful order (e.g. newest first) and get it back for ties.
+func (ix *Index) Search(query string, k int) []Hit {
+	terms := ix.Terms(query)
+	if len(terms) == 0 || len(ix.ids) == 0 {
+		return []Hit{}
+	}
+
+	avgLen := ix.AvgDocLen()
+	scores := make(map[int]float64)
+	for _, term := range terms {
+		idf := ix.config.IDF(len(ix.postings[term]), len(ix.ids))
+		for slot := range ix.postings[term] {
+			doc := ix.docs[slot]
+			f := doc.terms[term]
+			norm := 1 - ix.config.B
+			if avgLen > 0 {
+				norm += ix.config.B * doc.length / avgLen
+			}
+			scores[slot] += idf * (f * (ix.config.K1 + 1)) / (f + ix.config.K1*norm)
+		}
+	}
+
+	var ranked []scoredDoc
+	if k > 0 && k < len(scores) {
+		// Keep the k best in a min-heap whose root is the weakest hit so far
+		top := make(hitHeap, 0, k+1)
+		for slot, score := range scores {
+			heap.Push(&top, scoredDoc{slot: slot, score: score})
+			if top.Len() > k {
+				heap.Pop(&top)
+			}
+		}
+		ranked = top
+	} else {
+		ranked = make([]scoredDoc, 0, len(scores))
+		for slot, score := range scores {
+			ranked = append(ranked, scoredDoc{slot: slot, score: score})
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[j].less(ranked[i]) })
+
+	hits := make([]Hit, len(ranked))
+	for i, scored := range ranked {
+		hits[i] = Hit{ID: ix.docs[scored.slot].id, Score: scored.score}
+	}
+	return hits
+}
+
+type scoredDoc struct {
+	slot  int
+	score float64
+}
+
+// less reports whether d ranks below other: a lower score, or added later on a tie
+func (d scoredDoc) less(other scoredDoc) bool {
+	if d.score != other.score {
+		return d.score < other.score
+	}
+	return d.slot > other.slot
+}
+
+// hitHeap is a min-heap of scored documents
+type hitHeap []scoredDoc
+
+func (h hitHeap) Len() int            { return len(h) }
+func (h hitHeap) Less(i, j int) bool  { return h[i].less(h[j]) }
+func (h hitHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hitHeap) Push(x interface{}) { *h = append(*h, x.(scoredDoc)) }
+func (h *hitHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,210 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("The UKGC's new licences for Lotteries, and sports-betting in 2025")
+	want := []string{"ukgc", "new", "licence", "lottery", "sport", "betting", "2025"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Tokenize() = %v; expected %v", got, want)
+	}
+
+	if got := Terms("slots Slot SLOTS bonus"); strings.Join(got, " ") != "slot bonus" {
+		t.Errorf("Terms() = %v; expected duplicates removed and 'bonus' left unstemmed", got)
+	}
+}
+
+func newsIndex() *Index {
+	ix := NewIndex(DefaultConfig())
+	ix.Add(Document{ID: "ukgc", Fields: map[string]string{"title": "UKGC fines operator over affordability checks"}})
+	ix.Add(Document{ID: "slots", Fields: map[string]string{"title": "Studio launches new slot games with bonus rounds"}})
+	ix.Add(Document{ID: "fines", Fields: map[string]string{"title": "Regulator fines two operators; fines total £2m"}})
+	return ix
+}
+
+func hitIDs(hits []Hit) string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := newsIndex()
+	if ix.Len() != 3 {
+		t.Fatalf("Len() = %d; expected 3", ix.Len())
+	}
+
+	hits := ix.Search("operator fines", 0)
+	if hitIDs(hits) != "fines,ukgc" {
+		t.Fatalf("Search() = %+v; expected the story repeating 'fines' first", hits)
+	}
+	if hits[0].Score <= hits[1].Score || hits[1].Score <= 0 {
+		t.Errorf("Search() scores = %v, %v; expected positive and descending", hits[0].Score, hits[1].Score)
+	}
+	if top := ix.Search("operator fines", 1); hitIDs(top) != "fines" || top[0].Score != hits[0].Score {
+		t.Errorf("Search(k=1) = %+v; expected the same best hit as a full search", top)
+	}
+
+	if hits := ix.Search("the and of", 0); len(hits) != 0 {
+		t.Errorf("Search() with only stopwords = %+v; expected no hits", hits)
+	}
+	if hits := NewIndex(Config{}).Search("slots", 0); len(hits) != 0 {
+		t.Errorf("Search() on an empty index = %+v", hits)
+	}
+}
+
+func TestIndexTopK(t *testing.T) {
+	ix := NewIndex(DefaultConfig())
+	for i := 0; i < 50; i++ {
+		// Equal-length documents where "casino" repeats i%7 times, so scores tie across groups
+		words := strings.Repeat("casino ", i%7) + strings.Repeat("filler ", 7-i%7)
+		ix.Add(Document{ID: fmt.Sprint(i), Fields: map[string]string{"body": words}})
+	}
+
+	all := ix.Search("casino", 0)
+	for _, k := range []int{1, 5, 13, 100} {
+		top := ix.Search("casino", k)
+		want := all
+		if k < len(all) {
+			want = all[:k]
+		}
+		if hitIDs(top) != hitIDs(want) {
+			t.Errorf("Search(k=%d) = %s; expected %s", k, hitIDs(top), hitIDs(want))
+		}
+	}
+	// Ties keep insertion order: 6, 13, 20... all repeat "casino" six times
+	if got := hitIDs(all[:3]); got != "6,13,20" {
+		t.Errorf("Search() best hits = %s; expected 6,13,20", got)
+	}
+}
+
+func TestIndexAddRemove(t *testing.T) {
+	ix := newsIndex()
+
+	if !ix.Remove("fines") || ix.Remove("fines") || ix.Has("fines") {
+		t.Error("Remove() should drop a document exactly once")
+	}
+	if got := hitIDs(ix.Search("operator fines", 0)); got != "ukgc" || ix.DocFreq("fine") != 1 {
+		t.Errorf("Search() after Remove() = %s, DocFreq = %d", got, ix.DocFreq("fine"))
+	}
+
+	// Re-adding an ID replaces the document
+	ix.Add(Document{ID: "ukgc", Fields: map[string]string{"title": "UKGC consults on slot stake limits"}})
+	if ix.Len() != 2 || len(ix.Search("affordability", 0)) != 0 || hitIDs(ix.Search("stake", 0)) != "ukgc" {
+		t.Errorf("Add() should replace the old text; Len() = %d", ix.Len())
+	}
+
+	// Matches the index built from scratch with the same documents
+	fresh := NewIndex(DefaultConfig())
+	fresh.Add(Document{ID: "slots", Fields: map[string]string{"title": "Studio launches new slot games with bonus rounds"}})
+	fresh.Add(Document{ID: "ukgc", Fields: map[string]string{"title": "UKGC consults on slot stake limits"}})
+	got, want := ix.Search("slot bonus", 0), fresh.Search("slot bonus", 0)
+	if hitIDs(got) != hitIDs(want) || math.Abs(got[0].Score-want[0].Score) > 1e-9 || ix.AvgDocLen() != fresh.AvgDocLen() {
+		t.Errorf("Incremental index = %+v; expected %+v", got, want)
+	}
+}
+
+func TestIndexBoosts(t *testing.T) {
+	config := DefaultConfig()
+	config.Boosts = map[string]float64{"title": 3, "abstract": 1}
+	ix := NewIndex(config)
+	ix.Add(Document{ID: "in-abstract", Fields: map[string]string{
+		"title":    "Responsible gambling tools",
+		"abstract": "Operators add deposit limits and a new sportsbook cooling-off period.",
+	}})
+	ix.Add(Document{ID: "in-title", Fields: map[string]string{
+		"title":    "Sportsbook launches in Ohio",
+		"abstract": "The operator went live with mobile wagering and retail kiosks this week.",
+	}})
+
+	if got := hitIDs(ix.Search("sportsbook", 0)); got != "in-title,in-abstract" {
+		t.Errorf("Search() = %s; expected the title match to outrank the abstract match", got)
+	}
+}
+
+func TestIndexClassicIDF(t *testing.T) {
+	config := DefaultConfig()
+	config.IDF = ClassicIDF
+	ix := NewIndex(config)
+	ix.Add(Document{ID: "a", Fields: map[string]string{"title": "Operator results"}})
+	ix.Add(Document{ID: "b", Fields: map[string]string{"title": "Operator licence"}})
+
+	if hits := ix.Search("operator", 0); len(hits) != 2 || hits[0].Score >= 0 {
+		t.Errorf("Search() = %+v; expected terms in every document to score below zero", hits)
+	}
+
+	smooth := NewIndex(DefaultConfig())
+	smooth.Add(Document{ID: "a", Fields: map[string]string{"title": "Operator results"}})
+	smooth.Add(Document{ID: "b", Fields: map[string]string{"title": "Operator licence"}})
+	if hits := smooth.Search("operator", 0); len(hits) != 2 || hits[0].Score <= 0 {
+		t.Errorf("Search() with SmoothIDF = %+v; expected a positive score", hits)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	config := DefaultConfig()
+	config.Boosts = map[string]float64{"title": 2}
+	ix := NewIndex(config)
+	ix.Add(Document{ID: "a", Fields: map[string]string{"title": "MGA licence renewals", "body": "Malta renews licences"}})
+	ix.Add(Document{ID: "b", Fields: map[string]string{"title": "Dutch regulator fines operator"}})
+	ix.Add(Document{ID: "c", Fields: map[string]string{"title": "Removed before saving"}})
+	ix.Remove("c")
+
+	var buf bytes.Buffer
+	if err := ix.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+	restored, err := ReadSnapshot(&buf, DefaultConfig())
+	if err != nil {
+		t.Fatalf("ReadSnapshot() error = %v", err)
+	}
+
+	if restored.Len() != 2 || restored.Has("c") || restored.Config().Boosts["title"] != 2 {
+		t.Errorf("Restored index has %d docs, boosts %v", restored.Len(), restored.Config().Boosts)
+	}
+	for _, query := range []string{"licence", "operator fines", "malta"} {
+		got, want := restored.Search(query, 0), ix.Search(query, 0)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Search(%q) = %v after restore; expected %v", query, got, want)
+		}
+	}
+
+	if _, err := ReadSnapshot(strings.NewReader(`{"version": 99}`), DefaultConfig()); err == nil {
+		t.Error("Expected an error for an unknown snapshot version")
+	}
+	if _, err := ReadSnapshot(strings.NewReader(`{"version": 1, "docs": [{"id": "a"}, {"id": "a"}]}`), DefaultConfig()); err == nil {
+		t.Error("Expected an error for duplicate documents")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	terms := Terms("UKGC fine")
+
+	got, ok := Highlight("UKGC <fines> operator", terms, 0)
+	if !ok || got != "<mark>UKGC</mark> &lt;<mark>fines</mark>&gt; operator" {
+		t.Errorf("Highlight() = %q, %v", got, ok)
+	}
+
+	long := "Operators across Europe reported results this week. " +
+		"Meanwhile the UKGC issued a fine to a remote operator for failures in its anti-money laundering controls."
+	got, ok = Highlight(long, terms, 60)
+	if !ok || !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Highlight() = %q; expected a snippet cut on both sides", got)
+	}
+	if !strings.Contains(got, "<mark>UKGC</mark> issued a <mark>fine</mark>") {
+		t.Errorf("Highlight() = %q; expected the snippet around the first match", got)
+	}
+
+	got, ok = Highlight(long, Terms("casino"), 30)
+	if ok || got != "Operators across Europe…" {
+		t.Errorf("Highlight() without a match = %q, %v; expected the leading words", got, ok)
+	}
+}
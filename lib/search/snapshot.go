@@ -0,0 +1,86 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// snapshotVersion is bumped whenever the snapshot layout or the default tokenizer changes,
+// since either makes older snapshots disagree with new queries
+const snapshotVersion = 1
+
+// Snapshot is the serializable state of an index: each document's weighted term
+// frequencies, from which the postings are rebuilt on load
+type Snapshot struct {
+	Version int                `json:"version"`
+	K1      float64            `json:"k1"`
+	B       float64            `json:"b"`
+	Boosts  map[string]float64 `json:"boosts,omitempty"`
+	Docs    []SnapshotDoc      `json:"docs"`
+}
+
+// SnapshotDoc is one document of a snapshot
+type SnapshotDoc struct {
+	ID     string             `json:"id"`
+	Terms  map[string]float64 `json:"terms"`
+	Length float64            `json:"length"`
+}
+
+// Snapshot captures the index, documents in insertion order
+func (ix *Index) Snapshot() *Snapshot {
+	snapshot := &Snapshot{
+		Version: snapshotVersion,
+		K1:      ix.config.K1,
+		B:       ix.config.B,
+		Boosts:  ix.config.Boosts,
+		Docs:    make([]SnapshotDoc, 0, len(ix.ids)),
+	}
+	for _, doc := range ix.docs {
+		if doc.terms == nil {
+			continue // Removed
+		}
+		snapshot.Docs = append(snapshot.Docs, SnapshotDoc{ID: doc.id, Terms: doc.terms, Length: doc.length})
+	}
+	return snapshot
+}
+
+// WriteSnapshot writes the index as JSON
+func (ix *Index) WriteSnapshot(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(ix.Snapshot()); err != nil {
+		return fmt.Errorf("failed to write index snapshot: %w", err)
+	}
+	return nil
+}
+
+// FromSnapshot rebuilds an index. Tokenize and IDF can't be stored, so config must
+// carry the ones the index was built with; K1, B and Boosts come from the snapshot,
+// because the boosts are already baked into the term frequencies.
+func FromSnapshot(snapshot *Snapshot, config Config) (*Index, error) {
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported index snapshot version %d (want %d)", snapshot.Version, snapshotVersion)
+	}
+
+	config.K1, config.B, config.Boosts = snapshot.K1, snapshot.B, snapshot.Boosts
+	ix := NewIndex(config)
+	for _, doc := range snapshot.Docs {
+		if _, exists := ix.ids[doc.ID]; exists {
+			return nil, fmt.Errorf("index snapshot has duplicate document %q", doc.ID)
+		}
+		terms := doc.Terms
+		if terms == nil {
+			terms = make(map[string]float64)
+		}
+		ix.insert(indexedDoc{id: doc.ID, terms: terms, length: doc.Length})
+	}
+	return ix, nil
+}
+
+// ReadSnapshot reads a JSON snapshot written by WriteSnapshot, see FromSnapshot
+func ReadSnapshot(r io.Reader, config Config) (*Index, error) {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read index snapshot: %w", err)
+	}
+	return FromSnapshot(&snapshot, config)
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+)
+
+// wordRegex matches runs of letters and digits, keeping inner apostrophes ("operator's")
+var wordRegex = regexp.MustCompile(`[\p{L}\p{N}]+(?:['’][\p{L}\p{N}]+)*`)
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "for": true, "from": true, "has": true, "have": true, "he": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "of": true, "on": true,
+	"or": true, "she": true, "that": true, "the": true, "their": true, "they": true,
+	"this": true, "to": true, "was": true, "were": true, "will": true, "with": true,
+}
+
+// Tokenize splits text into normalized index terms: lowercased words without
+// stopwords, possessives or plural endings
+func Tokenize(text string) []string {
+	words := wordRegex.FindAllString(text, -1)
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if token := normalize(word); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// Terms returns the distinct terms of a query, in order
+func Terms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range Tokenize(query) {
+		if !seen[token] {
+			seen[token] = true
+			terms = append(terms, token)
+		}
+	}
+	return terms
+}
+
+// normalize maps a word to its index term, or "" for stopwords
+func normalize(word string) string {
+	word = strings.ToLower(strings.ReplaceAll(word, "’", "'"))
+	word = strings.TrimSuffix(word, "'s")
+	word = strings.ReplaceAll(word, "'", "")
+	if stopwords[word] {
+		return ""
+	}
+	return stem(word)
+}
+
+// stem strips English plural endings ("licences" -> "licence", "lotteries" -> "lottery").
+// It's deliberately minimal: enough for singular and plural queries to meet.
+func stem(word string) string {
+	if len(word) <= 4 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
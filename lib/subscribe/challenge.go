@@ -0,0 +1,138 @@
+package subscribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"main/lib/logger"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	hcaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+)
+
+// ChallengeVerifier checks the bot challenge token sent with a subscribe request
+type ChallengeVerifier interface {
+	// Name identifies the provider in logs
+	Name() string
+	// Verify reports whether the token is a solved challenge. remoteIP may be empty.
+	// The error is only for failing to ask the provider.
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+// SiteVerifier checks tokens against a siteverify endpoint. Turnstile and hCaptcha share
+// the protocol: a form POST of secret, response and remoteip answered with
+// {"success": bool, "error-codes": [...]}.
+type SiteVerifier struct {
+	Provider string
+	URL      string
+	Secret   string
+	// SiteKey is optional; hCaptcha then also checks the token was issued for it
+	SiteKey string
+	Client  *http.Client
+}
+
+// NewTurnstileVerifier verifies Cloudflare Turnstile tokens
+func NewTurnstileVerifier(secret string) *SiteVerifier {
+	return &SiteVerifier{Provider: "turnstile", URL: turnstileVerifyURL, Secret: secret}
+}
+
+// NewHCaptchaVerifier verifies hCaptcha tokens; siteKey may be empty
+func NewHCaptchaVerifier(secret, siteKey string) *SiteVerifier {
+	return &SiteVerifier{Provider: "hcaptcha", URL: hcaptchaVerifyURL, Secret: secret, SiteKey: siteKey}
+}
+
+// Name returns the provider name
+func (v *SiteVerifier) Name() string {
+	return v.Provider
+}
+
+// Verify sends the token to the provider for server-side validation
+func (v *SiteVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	formData := url.Values{}
+	formData.Set("secret", v.Secret)
+	formData.Set("response", token)
+	if remoteIP != "" {
+		formData.Set("remoteip", remoteIP)
+	}
+	if v.SiteKey != "" {
+		formData.Set("sitekey", v.SiteKey)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.PostForm(v.URL, formData)
+	if err != nil {
+		return false, fmt.Errorf("failed to send verification request to %s: %w", v.Provider, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var result SiteverifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode %s response: %w", v.Provider, err)
+	}
+
+	// Log failures for debugging.
+	if !result.Success {
+		logger.Warn("Challenge verification failed", map[string]interface{}{
+			"provider":    v.Provider,
+			"error_codes": result.ErrorCodes,
+		})
+	}
+	return result.Success, nil
+}
+
+// StubVerifier accepts one fixed token, or any non-empty token when Token is empty.
+// It is for local runs and tests and never calls out.
+type StubVerifier struct {
+	Token string
+}
+
+// Name returns "stub"
+func (v StubVerifier) Name() string {
+	return "stub"
+}
+
+// Verify compares the token with the expected one
+func (v StubVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	if v.Token == "" {
+		return token != "", nil
+	}
+	return token == v.Token, nil
+}
+
+// DefaultChallengeVerifier picks the verifier from CHALLENGE_PROVIDER:
+//
+//	turnstile (default)  TURNSTILE_SECRET_KEY
+//	hcaptcha             HCAPTCHA_SECRET_KEY, optionally HCAPTCHA_SITE_KEY
+//	stub                 CHALLENGE_STUB_TOKEN, or any non-empty token without it
+//
+// A provider without its secret is an error, so a misconfigured deployment refuses
+// subscriptions instead of accepting them unchecked.
+func DefaultChallengeVerifier() (ChallengeVerifier, error) {
+	switch provider := os.Getenv("CHALLENGE_PROVIDER"); provider {
+	case "", "turnstile":
+		secret := os.Getenv("TURNSTILE_SECRET_KEY")
+		if secret == "" {
+			return nil, fmt.Errorf("TURNSTILE_SECRET_KEY is not set")
+		}
+		return NewTurnstileVerifier(secret), nil
+	case "hcaptcha":
+		secret := os.Getenv("HCAPTCHA_SECRET_KEY")
+		if secret == "" {
+			return nil, fmt.Errorf("HCAPTCHA_SECRET_KEY is not set")
+		}
+		return NewHCaptchaVerifier(secret, os.Getenv("HCAPTCHA_SITE_KEY")), nil
+	case "stub":
+		return StubVerifier{Token: os.Getenv("CHALLENGE_STUB_TOKEN")}, nil
+	default:
+		return nil, fmt.Errorf("unknown CHALLENGE_PROVIDER %q", provider)
+	}
+}
@@ -0,0 +1,84 @@
+package subscribe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSiteVerifier(t *testing.T) {
+	var form map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = map[string]string{}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		if r.PostForm.Get("response") == "good" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+
+	verifier := NewHCaptchaVerifier("secret", "site-key")
+	verifier.URL = server.URL
+
+	ok, err := verifier.Verify(context.Background(), "good", "198.51.100.7")
+	if err != nil || !ok {
+		t.Fatalf("Verify(good) = %v, %v", ok, err)
+	}
+	want := map[string]string{"secret": "secret", "response": "good", "remoteip": "198.51.100.7", "sitekey": "site-key"}
+	for key, value := range want {
+		if form[key] != value {
+			t.Errorf("form %s = %q, want %q", key, form[key], value)
+		}
+	}
+
+	if ok, err := verifier.Verify(context.Background(), "bad", ""); err != nil || ok {
+		t.Fatalf("Verify(bad) = %v, %v, want false without error", ok, err)
+	}
+	if _, sent := form["remoteip"]; sent {
+		t.Error("remoteip sent without a client IP")
+	}
+}
+
+func TestDefaultChallengeVerifier(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		name    string
+		wantErr bool
+	}{
+		{map[string]string{"TURNSTILE_SECRET_KEY": "s"}, "turnstile", false},
+		{map[string]string{}, "", true},
+		{map[string]string{"CHALLENGE_PROVIDER": "hcaptcha", "HCAPTCHA_SECRET_KEY": "s"}, "hcaptcha", false},
+		{map[string]string{"CHALLENGE_PROVIDER": "hcaptcha", "TURNSTILE_SECRET_KEY": "s"}, "", true},
+		{map[string]string{"CHALLENGE_PROVIDER": "stub"}, "stub", false},
+		{map[string]string{"CHALLENGE_PROVIDER": "recaptcha"}, "", true},
+	}
+	for _, tt := range tests {
+		for _, key := range []string{"CHALLENGE_PROVIDER", "TURNSTILE_SECRET_KEY", "HCAPTCHA_SECRET_KEY"} {
+			t.Setenv(key, tt.env[key])
+		}
+		verifier, err := DefaultChallengeVerifier()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("DefaultChallengeVerifier(%v) = %s, want an error", tt.env, verifier.Name())
+			}
+			continue
+		}
+		if err != nil || verifier.Name() != tt.name {
+			t.Errorf("DefaultChallengeVerifier(%v) = %v, %v, want %s", tt.env, verifier, err, tt.name)
+		}
+	}
+
+	stub := StubVerifier{Token: "let-me-in"}
+	if ok, _ := stub.Verify(context.Background(), "let-me-in", ""); !ok {
+		t.Error("stub rejected its token")
+	}
+	if ok, _ := stub.Verify(context.Background(), "other", ""); ok {
+		t.Error("stub accepted another token")
+	}
+}
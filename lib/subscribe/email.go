@@ -0,0 +1,147 @@
+package subscribe
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"main/lib/logger"
+	"net"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrDisposableEmail is returned for addresses at throwaway inbox providers
+	ErrDisposableEmail = fmt.Errorf("%w: disposable email provider", ErrInvalidEmail)
+	// ErrNoMailServer is returned for domains that can't receive mail
+	ErrNoMailServer = fmt.Errorf("%w: domain does not accept email", ErrInvalidEmail)
+)
+
+//go:embed disposable_domains.txt
+var disposableDomainList string
+
+// MXResolver is the part of *net.Resolver the MX check uses
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// EmailValidator decides whether an address is worth sending a confirmation email to
+type EmailValidator struct {
+	// Resolver looks up the domain's mail servers; nil skips the MX check
+	Resolver MXResolver
+	// Blocked domains are refused along with their subdomains
+	Blocked map[string]bool
+}
+
+// NewEmailValidator creates a validator refusing the built-in disposable domains plus any
+// listed (comma separated) in SUBSCRIBE_BLOCKED_DOMAINS
+func NewEmailValidator(resolver MXResolver) *EmailValidator {
+	blocked := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(disposableDomainList))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			blocked[line] = true
+		}
+	}
+	for _, domain := range strings.Split(os.Getenv("SUBSCRIBE_BLOCKED_DOMAINS"), ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			blocked[domain] = true
+		}
+	}
+	return &EmailValidator{Resolver: resolver, Blocked: blocked}
+}
+
+// DefaultEmailValidator checks MX records with the system resolver, unless
+// SUBSCRIBE_CHECK_MX=false (e.g. offline local runs)
+func DefaultEmailValidator() *EmailValidator {
+	if check, err := strconv.ParseBool(os.Getenv("SUBSCRIBE_CHECK_MX")); err == nil && !check {
+		return NewEmailValidator(nil)
+	}
+	return NewEmailValidator(net.DefaultResolver)
+}
+
+// Validate checks a normalized address: a plain addr-spec with a dotted domain, not at a
+// blocked domain, and (with a Resolver) at a domain that has somewhere to deliver mail.
+// Returns ErrInvalidEmail, ErrDisposableEmail or ErrNoMailServer. A DNS lookup that fails
+// for any other reason lets the address through, so a resolver hiccup loses no subscribers.
+func (v *EmailValidator) Validate(ctx context.Context, email string) error {
+	domain, ok := emailDomain(email)
+	if !ok {
+		return ErrInvalidEmail
+	}
+	if v.blocked(domain) {
+		return ErrDisposableEmail
+	}
+	if v.Resolver == nil {
+		return nil
+	}
+	return v.checkMX(ctx, domain)
+}
+
+// emailDomain returns the domain of a bare address (no display name or angle brackets)
+func emailDomain(email string) (string, bool) {
+	if len(email) > 254 {
+		return "", false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", false
+	}
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") ||
+		strings.HasPrefix(domain, "[") {
+		return "", false
+	}
+	return domain, true
+}
+
+// blocked reports whether the domain or one of its parents is blocked
+func (v *EmailValidator) blocked(domain string) bool {
+	for {
+		if v.Blocked[domain] {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
+
+// checkMX follows RFC 5321: mail goes to the MX hosts, or to the domain itself when it has
+// none. A null MX (RFC 7505, a single "." host) says the domain takes no mail.
+func (v *EmailValidator) checkMX(ctx context.Context, domain string) error {
+	records, err := v.Resolver.LookupMX(ctx, domain)
+	if err == nil && len(records) > 0 {
+		if len(records) == 1 && (records[0].Host == "." || records[0].Host == "") {
+			return ErrNoMailServer
+		}
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		logger.Warn("MX lookup failed, accepting the address", map[string]interface{}{"domain": domain, "error": err.Error()})
+		return nil
+	}
+
+	hosts, err := v.Resolver.LookupHost(ctx, domain)
+	if err == nil && len(hosts) > 0 {
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		logger.Warn("Host lookup failed, accepting the address", map[string]interface{}{"domain": domain, "error": err.Error()})
+		return nil
+	}
+	return ErrNoMailServer
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
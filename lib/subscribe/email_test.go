@@ -0,0 +1,85 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// stubResolver answers MX and host lookups from maps; unknown names are NXDOMAIN
+type stubResolver struct {
+	mx    map[string][]*net.MX
+	hosts map[string][]string
+	err   error
+}
+
+func (s stubResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if records, ok := s.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (s stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if hosts, ok := s.hosts[host]; ok {
+		return hosts, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// testResolver resolves example.com like a domain with a working mail server
+var testResolver = stubResolver{mx: map[string][]*net.MX{"example.com": {{Host: "mail.example.com.", Pref: 10}}}}
+
+func TestEmailValidator(t *testing.T) {
+	t.Setenv("SUBSCRIBE_BLOCKED_DOMAINS", "spam.example, ")
+	validator := NewEmailValidator(stubResolver{
+		mx: map[string][]*net.MX{
+			"example.com":  {{Host: "mail.example.com.", Pref: 10}},
+			"nullmx.test":  {{Host: ".", Pref: 0}},
+			"mailinator.x": {{Host: "mx.mailinator.x.", Pref: 10}},
+		},
+		hosts: map[string][]string{"a-only.test": {"192.0.2.1"}},
+	})
+
+	tests := []struct {
+		email string
+		want  error
+	}{
+		{"reader@example.com", nil},
+		{"first.last+news@example.com", nil},
+		{"reader@a-only.test", nil}, // no MX, delivered to the A record
+		{"reader", ErrInvalidEmail},
+		{"reader@localhost", ErrInvalidEmail},
+		{"Reader <reader@example.com>", ErrInvalidEmail},
+		{"reader@example.com.", ErrInvalidEmail},
+		{"two words@example.com", ErrInvalidEmail},
+		{"reader@mailinator.com", ErrDisposableEmail},
+		{"reader@eu.mailinator.com", ErrDisposableEmail},
+		{"reader@spam.example", ErrDisposableEmail},
+		{"reader@nullmx.test", ErrNoMailServer},
+		{"reader@nowhere.test", ErrNoMailServer},
+	}
+	for _, tt := range tests {
+		err := validator.Validate(context.Background(), tt.email)
+		if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.email, err, tt.want)
+		}
+		if tt.want != nil && !errors.Is(err, ErrInvalidEmail) {
+			t.Errorf("Validate(%q) = %v, want it to match ErrInvalidEmail", tt.email, err)
+		}
+	}
+
+	// A resolver failure that isn't NXDOMAIN lets the address through
+	flaky := NewEmailValidator(stubResolver{err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}})
+	if err := flaky.Validate(context.Background(), "reader@nowhere.test"); err != nil {
+		t.Errorf("Validate() with a failing resolver = %v, want nil", err)
+	}
+	// Without a resolver only the local checks run
+	if err := NewEmailValidator(nil).Validate(context.Background(), "reader@nowhere.test"); err != nil {
+		t.Errorf("Validate() without a resolver = %v, want nil", err)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"main/lib/article"
+	"main/lib/mail"
+	"main/lib/rss"
 	"time"
 )
 
@@ -12,10 +15,7 @@ const welcomeEmailTemplateStr = `
 <!DOCTYPE html>
 <html>
 <head>
-    <style>
-        a { color: rgb(217, 16, 9) !important; text-decoration: none; }
-        a:hover { text-decoration: underline; }
-    </style>
+    <meta charset="utf-8" />
 </head>
 <body style="font-family: 'Lato', 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;">
     <table width="100%" border="0" cellspacing="0" cellpadding="0" style="padding: 40px 0 20px 0;">
@@ -63,12 +63,14 @@ const welcomeEmailTemplateStr = `
                             <p style="font-family: 'Noto Sans', sans-serif; line-height: 200%; font-weight: bold; font-size: 25px; color: rgb(217, 16, 9); margin: 0;">類を変革する</p>
                         </td>
                         <td align="right" valign="top" style="width: 50%;">
-                            <a href="https://takara.ai"><img src="https://tldr.takara.ai/icon.svg" alt="Origami Crane Logo" style="width: 250px; height: 194px; max-width: 100%;" /></a>
+                            <a href="https://takara.ai" style="text-decoration: none;"><img src="https://tldr.takara.ai/icon.svg" alt="Origami Crane Logo" style="width: 250px; height: 194px; max-width: 100%;" /></a>
                         </td>
                     </tr>
                 </table>
                 <table width="100%" border="0" cellspacing="0" cellpadding="0" style="text-align: center; font-family: 'Lato', sans-serif; font-size: 12px; color: rgba(74, 77, 78, 0.8);">
                     <tr><td style="padding-top: 20px;">© {{ .CurrentYear }} takara.ai Ltd. All rights reserved.</td></tr>
+                    {{if .PreferencesURL}}<tr><td style="padding-top: 10px;">Choose what you receive and how often: <a href="{{ .PreferencesURL }}" style="color: rgb(217, 16, 9); text-decoration: none;">Email preferences</a></td></tr>{{end}}
+                    {{if .UnsubscribeURL}}<tr><td style="padding-top: 10px;">Want to stop receiving emails? <a href="{{ .UnsubscribeURL }}" style="color: rgb(217, 16, 9); text-decoration: none;">Unsubscribe</a></td></tr>{{end}}
                 </table>
             </td>
         </tr>
@@ -77,12 +79,18 @@ const welcomeEmailTemplateStr = `
 </html>
 `
 
+// emailLinkStyle colors links in feed content, which carry no styles of their own.
+// Email clients drop <style> blocks, so every link is styled inline.
+const emailLinkStyle = "color: rgb(217, 16, 9); text-decoration: none;"
+
 // TemplateData holds all the necessary data for rendering the welcome email.
 type TemplateData struct {
-	Feed          *RssFeed
-	FormattedDate string
-	CurrentYear   int
-	Items         []struct{ Description template.HTML }
+	Feed           *rss.RssFeed
+	FormattedDate  string
+	CurrentYear    int
+	Items          []struct{ Description template.HTML }
+	UnsubscribeURL string
+	PreferencesURL string
 }
 
 // formatDate converts a date string from the RSS feed into a more readable format.
@@ -106,17 +114,20 @@ func formatDate(dateStr string) string {
 }
 
 // GenerateWelcomeEmailHTML executes the Go template to produce the welcome email body.
-func GenerateWelcomeEmailHTML(feed *RssFeed) (string, error) {
+// unsubscribeURL and preferencesURL (see UnsubscribeURL and PreferencesURL) go in the footer.
+func GenerateWelcomeEmailHTML(feed *rss.RssFeed, unsubscribeURL, preferencesURL string) (string, error) {
 	data := TemplateData{
-		Feed:        feed,
-		CurrentYear: time.Now().Year(),
+		Feed:           feed,
+		CurrentYear:    time.Now().Year(),
+		UnsubscribeURL: unsubscribeURL,
+		PreferencesURL: preferencesURL,
 	}
 
 	if feed != nil {
 		data.FormattedDate = formatDate(feed.LastBuildDate)
 		data.Items = make([]struct{ Description template.HTML }, len(feed.Items))
 		for i, item := range feed.Items {
-			data.Items[i] = struct{ Description template.HTML }{Description: template.HTML(item.Description)}
+			data.Items[i] = struct{ Description template.HTML }{Description: template.HTML(mail.StyleLinks(item.Description, emailLinkStyle))}
 		}
 	}
 
@@ -132,3 +143,183 @@ func GenerateWelcomeEmailHTML(feed *RssFeed) (string, error) {
 
 	return buf.String(), nil
 }
+
+const confirmationEmailTemplateStr = `
+<!DOCTYPE html>
+<html>
+<body style="font-family: 'Lato', 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;">
+    <table width="100%" border="0" cellspacing="0" cellpadding="0" style="padding: 40px 0 20px 0;">
+        <tr>
+            <td align="center">
+                <span style="font-family: 'Lato', sans-serif; font-weight: 900; font-size: 40px; color: rgb(74, 77, 78);">tldr.</span><span style="font-family: 'Lato', sans-serif; font-weight: 900; font-size: 40px; color: rgb(217, 16, 9);">takara.ai</span>
+            </td>
+        </tr>
+    </table>
+
+    <table width="100%" border="0" cellspacing="0" cellpadding="0" style="padding: 20px;">
+        <tr>
+            <td>
+                <h1 style="font-family: 'Noto Sans', Helvetica, Arial, sans-serif; font-weight: bold; font-size: 40px; color: rgb(74, 77, 78); margin: 10px 0 20px 0;">Confirm your subscription</h1>
+                <p style="font-family: 'Lato', sans-serif; font-size: 20px; color: rgb(74, 77, 78); line-height: 140%;">Someone, hopefully you, asked to receive Takara TLDR at this address. Confirm to start getting the daily summary.</p>
+                <p style="margin: 30px 0;">
+                    <a href="{{ .ConfirmURL }}" style="display: inline-block; padding: 14px 28px; background-color: rgb(217, 16, 9); color: #ffffff; font-family: 'Lato', sans-serif; font-weight: bold; font-size: 20px; text-decoration: none; border-radius: 4px;">Confirm subscription</a>
+                </p>
+                <p style="font-family: 'Lato', sans-serif; font-size: 16px; color: rgba(74, 77, 78, 0.8); line-height: 140%;">This link expires on {{ .Expires }}. If you didn't ask to subscribe, ignore this email and you won't hear from us again.</p>
+            </td>
+        </tr>
+    </table>
+
+    <table width="100%" border="0" cellspacing="0" cellpadding="0" style="text-align: center; font-family: 'Lato', sans-serif; font-size: 12px; color: rgba(74, 77, 78, 0.8);">
+        <tr><td style="padding-top: 20px;">© {{ .CurrentYear }} takara.ai Ltd. All rights reserved.</td></tr>
+    </table>
+</body>
+</html>
+`
+
+// GenerateConfirmationEmailHTML renders the double opt-in email linking to confirmURL
+func GenerateConfirmationEmailHTML(confirmURL string, expires time.Time) (string, error) {
+	tpl, err := template.New("confirmationEmail").Parse(confirmationEmailTemplateStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse confirmation email template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, map[string]interface{}{
+		"ConfirmURL":  confirmURL,
+		"Expires":     expires.UTC().Format("January 2, 2006 at 15:04 UTC"),
+		"CurrentYear": time.Now().Year(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute confirmation email template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+const statusPageTemplateStr = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>{{ .Title }} | Takara TLDR</title>
+</head>
+<body style="font-family: 'Lato', 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; color: rgb(74, 77, 78); max-width: 600px; margin: 80px auto; padding: 0 20px;">
+    <a href="{{ .HomeURL }}" style="text-decoration: none;"><span style="font-weight: 900; font-size: 32px; color: rgb(74, 77, 78);">tldr.</span><span style="font-weight: 900; font-size: 32px; color: rgb(217, 16, 9);">takara.ai</span></a>
+    <h1 style="font-size: 32px; margin: 40px 0 16px 0;">{{ .Title }}</h1>
+    <p style="font-size: 18px; line-height: 150%;">{{ .Message }}</p>
+    {{if .FormAction}}
+    <form method="post" action="{{ .FormAction }}" style="margin-top: 30px;">
+        <input type="hidden" name="token" value="{{ .FormToken }}">
+        <button type="submit" style="padding: 14px 28px; background-color: rgb(217, 16, 9); color: #ffffff; font-size: 18px; font-weight: bold; border: 0; border-radius: 4px; cursor: pointer;">{{ .FormLabel }}</button>
+    </form>
+    {{end}}
+</body>
+</html>
+`
+
+// StatusPage is the small page shown after following a link from one of our emails
+type StatusPage struct {
+	Title   string
+	Message string
+	// FormAction, when set, adds a FormLabel button that POSTs FormToken there. Links
+	// only ever show this button, so mail scanners prefetching them can't act for the reader.
+	FormAction string
+	FormToken  string
+	FormLabel  string
+	HomeURL    string
+}
+
+// GenerateStatusPageHTML renders a StatusPage
+func GenerateStatusPageHTML(page StatusPage) (string, error) {
+	tpl, err := template.New("statusPage").Parse(statusPageTemplateStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse status page template: %w", err)
+	}
+
+	page.HomeURL = rss.BaseURL()
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, page); err != nil {
+		return "", fmt.Errorf("failed to execute status page template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+const preferencesPageTemplateStr = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Email preferences | Takara TLDR</title>
+</head>
+<body style="font-family: 'Lato', 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; color: rgb(74, 77, 78); max-width: 600px; margin: 80px auto; padding: 0 20px;">
+    <a href="{{ .HomeURL }}" style="text-decoration: none;"><span style="font-weight: 900; font-size: 32px; color: rgb(74, 77, 78);">tldr.</span><span style="font-weight: 900; font-size: 32px; color: rgb(217, 16, 9);">takara.ai</span></a>
+    <h1 style="font-size: 32px; margin: 40px 0 8px 0;">Email preferences</h1>
+    <p style="font-size: 16px; margin: 0 0 24px 0;">For {{ .Email }}</p>
+    {{if .Message}}<p style="font-size: 18px; padding: 12px 16px; background-color: rgba(74, 77, 78, 0.08); border-radius: 4px;">{{ .Message }}</p>{{end}}
+    {{if .Error}}<p style="font-size: 18px; padding: 12px 16px; color: rgb(217, 16, 9); background-color: rgba(217, 16, 9, 0.08); border-radius: 4px;">{{ .Error }}</p>{{end}}
+    <form method="post" action="/api/preferences" style="font-size: 18px; line-height: 180%;">
+        <input type="hidden" name="token" value="{{ .Token }}">
+        <fieldset style="border: 0; padding: 0; margin: 0 0 24px 0;">
+            <legend style="font-weight: bold; margin-bottom: 8px;">What to send</legend>
+            <label style="display: block;"><input type="checkbox" name="products" value="tldr"{{if .Preferences.Wants "tldr"}} checked{{end}}> AI research TLDR</label>
+            <label style="display: block;"><input type="checkbox" name="products" value="digest"{{if .Preferences.Wants "digest"}} checked{{end}}> iGaming daily digest</label>
+        </fieldset>
+        <fieldset style="border: 0; padding: 0; margin: 0 0 24px 0;">
+            <legend style="font-weight: bold; margin-bottom: 8px;">How often</legend>
+            <label style="display: block;"><input type="radio" name="frequency" value="daily"{{if eq .Preferences.Frequency "daily"}} checked{{end}}> Every day</label>
+            <label style="display: block;"><input type="radio" name="frequency" value="weekly"{{if eq .Preferences.Frequency "weekly"}} checked{{end}}> Weekly roundup on Fridays (AI research TLDR only)</label>
+        </fieldset>
+        <fieldset style="border: 0; padding: 0; margin: 0 0 24px 0;">
+            <legend style="font-weight: bold; margin-bottom: 8px;">iGaming topics (none ticked means all)</legend>
+            {{range .Categories}}<label style="display: block;"><input type="checkbox" name="categories" value="{{ .Name }}"{{if .Checked}} checked{{end}}> {{ .Name }}</label>
+            {{end}}
+        </fieldset>
+        <label style="display: block; font-weight: bold; margin-bottom: 8px;" for="timeZone">Time zone</label>
+        <input id="timeZone" type="text" name="timeZone" value="{{ .Preferences.TimeZone }}" placeholder="Europe/London" style="font-size: 18px; padding: 8px; width: 100%; max-width: 320px; margin-bottom: 30px;">
+        <div><button type="submit" style="padding: 14px 28px; background-color: rgb(217, 16, 9); color: #ffffff; font-size: 18px; font-weight: bold; border: 0; border-radius: 4px; cursor: pointer;">Save preferences</button></div>
+    </form>
+    {{if .UnsubscribeURL}}<p style="font-size: 14px; margin-top: 40px;">Don't want any emails? <a href="{{ .UnsubscribeURL }}" style="color: rgb(217, 16, 9);">Unsubscribe</a></p>{{end}}
+</body>
+</html>
+`
+
+// PreferencesPage is the preferences form reached from the link in our emails
+type PreferencesPage struct {
+	Email          string
+	Token          string // Preferences token, posted back with the form
+	Preferences    Preferences
+	Message        string // Shown above the form, e.g. after saving
+	Error          string
+	UnsubscribeURL string
+}
+
+// GeneratePreferencesPageHTML renders a PreferencesPage
+func GeneratePreferencesPageHTML(page PreferencesPage) (string, error) {
+	tpl, err := template.New("preferencesPage").Parse(preferencesPageTemplateStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse preferences page template: %w", err)
+	}
+
+	type category struct {
+		Name    string
+		Checked bool
+	}
+	chosen := make(map[string]bool)
+	for _, name := range page.Preferences.Categories {
+		chosen[name] = true
+	}
+	categories := make([]category, len(article.Categories))
+	for i, c := range article.Categories {
+		categories[i] = category{Name: string(c), Checked: chosen[string(c)]}
+	}
+
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, struct {
+		PreferencesPage
+		Categories []category
+		HomeURL    string
+	}{page, categories, rss.BaseURL()})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute preferences page template: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,32 @@
+package subscribe
+
+import (
+	"main/lib/logger"
+	"net/http"
+)
+
+// WriteStatusPage renders a StatusPage as the response
+func WriteStatusPage(w http.ResponseWriter, status int, page StatusPage, ctx map[string]interface{}) {
+	body, err := GenerateStatusPageHTML(page)
+	writePage(w, status, "status", body, err, ctx)
+}
+
+// WritePreferencesPage renders a PreferencesPage as the response
+func WritePreferencesPage(w http.ResponseWriter, status int, page PreferencesPage, ctx map[string]interface{}) {
+	body, err := GeneratePreferencesPageHTML(page)
+	writePage(w, status, "preferences", body, err, ctx)
+}
+
+// writePage writes a rendered page, or a plain 500 if it failed to render
+func writePage(w http.ResponseWriter, status int, name, body string, renderErr error, ctx map[string]interface{}) {
+	if renderErr != nil {
+		logger.Error("Failed to render "+name+" page", renderErr, ctx)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(body)); err != nil {
+		logger.Error("Failed to write "+name+" page", err, ctx)
+	}
+}
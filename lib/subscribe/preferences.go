@@ -0,0 +1,316 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/lib/article"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/rss"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // Time zone names must validate on hosts without a zoneinfo database
+)
+
+// Products a subscriber can receive
+const (
+	ProductTLDR   = "tldr"   // Daily AI research summaries
+	ProductDigest = "digest" // iGaming DailyDigest
+)
+
+// Delivery frequencies
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly" // Friday roundup of the week's TLDR issues; the digest is daily only
+)
+
+var (
+	// ErrInvalidPreferences is wrapped by Preferences.Normalize with the offending field
+	ErrInvalidPreferences = errors.New("invalid preferences")
+	// ErrNotSubscribed is returned when saving preferences for a pending or unsubscribed address
+	ErrNotSubscribed = errors.New("not subscribed")
+)
+
+// Preferences is what a subscriber wants to receive and when
+type Preferences struct {
+	Products   []string `json:"products"`             // ProductTLDR and/or ProductDigest
+	Frequency  string   `json:"frequency"`            // FrequencyDaily or FrequencyWeekly
+	Categories []string `json:"categories,omitempty"` // Digest categories to receive (article.Categories); empty means all
+	TimeZone   string   `json:"timeZone"`             // IANA name the reader's digest times are shown in
+}
+
+// DefaultPreferences is what everyone who subscribed before preferences existed receives
+func DefaultPreferences() Preferences {
+	return Preferences{
+		Products:  []string{ProductTLDR},
+		Frequency: FrequencyDaily,
+		TimeZone:  "UTC",
+	}
+}
+
+// Normalize validates the preferences and returns them in canonical form: products and
+// categories deduplicated and sorted, categories in their canonical spelling, and an
+// empty frequency or time zone read as daily and UTC. There is no weekly digest, so the
+// digest can't be chosen weekly. Errors wrap ErrInvalidPreferences.
+func (p Preferences) Normalize() (Preferences, error) {
+	normalized := Preferences{Frequency: p.Frequency, TimeZone: strings.TrimSpace(p.TimeZone)}
+
+	products := make(map[string]bool)
+	for _, product := range p.Products {
+		product = strings.ToLower(strings.TrimSpace(product))
+		if product != ProductTLDR && product != ProductDigest {
+			return normalized, fmt.Errorf("%w: unknown product %q", ErrInvalidPreferences, product)
+		}
+		products[product] = true
+	}
+	if len(products) == 0 {
+		return normalized, fmt.Errorf("%w: choose at least one product, or unsubscribe", ErrInvalidPreferences)
+	}
+	for product := range products {
+		normalized.Products = append(normalized.Products, product)
+	}
+	sort.Strings(normalized.Products)
+
+	if normalized.Frequency == "" {
+		normalized.Frequency = FrequencyDaily
+	}
+	if normalized.Frequency != FrequencyDaily && normalized.Frequency != FrequencyWeekly {
+		return normalized, fmt.Errorf("%w: unknown frequency %q", ErrInvalidPreferences, p.Frequency)
+	}
+	if normalized.Frequency == FrequencyWeekly && products[ProductDigest] {
+		return normalized, fmt.Errorf("%w: the iGaming digest is only sent daily", ErrInvalidPreferences)
+	}
+
+	categories := make(map[string]bool)
+	for _, category := range p.Categories {
+		canonical := ""
+		for _, known := range article.Categories {
+			if strings.EqualFold(strings.TrimSpace(category), string(known)) {
+				canonical = string(known)
+				break
+			}
+		}
+		if canonical == "" {
+			return normalized, fmt.Errorf("%w: unknown category %q", ErrInvalidPreferences, category)
+		}
+		categories[canonical] = true
+	}
+	for _, known := range article.Categories {
+		if categories[string(known)] {
+			normalized.Categories = append(normalized.Categories, string(known))
+		}
+	}
+
+	if normalized.TimeZone == "" {
+		normalized.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(normalized.TimeZone); err != nil {
+		return normalized, fmt.Errorf("%w: unknown time zone %q", ErrInvalidPreferences, p.TimeZone)
+	}
+	return normalized, nil
+}
+
+// PersonalDigest reports whether the subscriber gets a digest of their own instead of the
+// digest audience's broadcast: they want the digest, but only some of its categories
+func (p Preferences) PersonalDigest() bool {
+	return p.Wants(ProductDigest) && len(p.Categories) > 0
+}
+
+// Location returns the subscriber's time zone, or UTC if it isn't set or known
+func (p Preferences) Location() *time.Location {
+	if p.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Wants reports whether product is among the chosen products
+func (p Preferences) Wants(product string) bool {
+	for _, chosen := range p.Products {
+		if chosen == product {
+			return true
+		}
+	}
+	return false
+}
+
+// Segment is one broadcast audience: a product at a frequency
+type Segment struct {
+	Product   string
+	Frequency string
+}
+
+// segmentAudienceEnv names the environment variable holding each segment's Resend audience.
+// The first two predate segmentation, so existing subscribers stay where they are.
+var segmentAudienceEnv = map[Segment]string{
+	{ProductTLDR, FrequencyDaily}:   "RESEND_AUDIENCE_ID",
+	{ProductDigest, FrequencyDaily}: "RESEND_DIGEST_AUDIENCE_ID",
+	{ProductTLDR, FrequencyWeekly}:  "RESEND_TLDR_WEEKLY_AUDIENCE_ID",
+}
+
+// Segments lists every segment, in a fixed order
+func Segments() []Segment {
+	return []Segment{
+		{ProductTLDR, FrequencyDaily},
+		{ProductDigest, FrequencyDaily},
+		{ProductTLDR, FrequencyWeekly},
+	}
+}
+
+// AudienceID returns the segment's Resend audience, or "" if it isn't configured
+func (s Segment) AudienceID() string {
+	return os.Getenv(segmentAudienceEnv[s])
+}
+
+// AudienceEnv returns the environment variable holding the segment's Resend audience
+func (s Segment) AudienceEnv() string {
+	return segmentAudienceEnv[s]
+}
+
+// Segments returns the segments these preferences put a subscriber in. A subscriber with a
+// PersonalDigest isn't in the digest segment: its broadcast has every category.
+func (p Preferences) Segments() []Segment {
+	var segments []Segment
+	for _, segment := range Segments() {
+		if segment.Product == ProductDigest && p.PersonalDigest() {
+			continue
+		}
+		if segment.Frequency == p.Frequency && p.Wants(segment.Product) {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// PreferencesURL returns the signed, non-expiring link to the subscriber's preferences page
+func PreferencesURL(email string) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	token := SignToken(secret, PurposePreferences, NormalizeEmail(email), time.Time{})
+	return rss.BaseURL() + "/api/preferences?token=" + url.QueryEscape(token), nil
+}
+
+// LoadPreferences verifies a preferences token and returns the subscriber it was issued to.
+// Readers who subscribed before subscriptions were stored have no record; they get an
+// active subscriber with DefaultPreferences, which is what they have been receiving.
+func LoadPreferences(ctx context.Context, store Store, token string) (*Subscriber, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return nil, err
+	}
+	email, err := VerifyToken(secret, PurposePreferences, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := store.Get(ctx, email)
+	if errors.Is(err, ErrSubscriberNotFound) {
+		return &Subscriber{Email: email, Status: StatusActive, Preferences: DefaultPreferences()}, nil
+	}
+	return sub, err
+}
+
+// SavePreferences verifies a preferences token, moves the contact into the audiences of
+// the new preferences' segments (and out of the others), then stores the preferences.
+// Returns ErrInvalidToken, ErrInvalidPreferences or ErrNotSubscribed for bad requests;
+// a subscriber who opted out through the provider's link is unsubscribed and gets ErrNotSubscribed.
+func SavePreferences(ctx context.Context, store Store, mailer mail.Mailer, token string, prefs Preferences) (*Subscriber, error) {
+	sub, err := LoadPreferences(ctx, store, token)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != StatusActive {
+		return nil, ErrNotSubscribed
+	}
+	// An opt-out through the provider's link only shows in its audience; record it before
+	// the sync below could subscribe the contact again
+	unsubscribed, err := providerUnsubscribed(ctx, mailer, sub)
+	if err != nil {
+		return nil, err
+	}
+	if unsubscribed {
+		if err := recordProviderUnsubscribe(ctx, store, mailer, sub); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotSubscribed
+	}
+	prefs, err = prefs.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	// Audiences first: stored preferences must never claim segments the contact isn't in
+	previous := sub.Preferences
+	if err := syncContact(ctx, mailer, sub.Email, previous, prefs); err != nil {
+		return nil, err
+	}
+	saved, err := store.SetPreferences(ctx, sub.Email, prefs, time.Now().UTC())
+	if err != nil {
+		if revertErr := syncContact(ctx, mailer, sub.Email, prefs, previous); revertErr != nil {
+			logger.Error("Failed to move contact back after preferences weren't stored", revertErr, map[string]interface{}{
+				"email": sub.Email,
+			})
+		}
+		return nil, err
+	}
+	sub = saved
+
+	logger.Info("Subscriber preferences updated", map[string]interface{}{
+		"email":      sub.Email,
+		"products":   prefs.Products,
+		"frequency":  prefs.Frequency,
+		"categories": prefs.Categories,
+	})
+	return sub, nil
+}
+
+// syncContact subscribes email in the audience of each segment prefs selects and
+// unsubscribes it from the audiences of the previous segments it no longer wants
+func syncContact(ctx context.Context, mailer mail.Mailer, email string, previous, prefs Preferences) error {
+	wanted := make(map[Segment]bool)
+	for _, segment := range prefs.Segments() {
+		wanted[segment] = true
+	}
+	had := make(map[Segment]bool)
+	for _, segment := range previous.Segments() {
+		had[segment] = true
+	}
+
+	for _, segment := range Segments() {
+		if !wanted[segment] && !had[segment] {
+			continue
+		}
+		audienceID := segment.AudienceID()
+		if audienceID == "" {
+			if wanted[segment] {
+				return fmt.Errorf("%s is not set, can't subscribe to %s %s", segment.AudienceEnv(), segment.Frequency, segment.Product)
+			}
+			continue
+		}
+		if err := mailer.UpsertContact(ctx, audienceID, mail.Contact{Email: email, Unsubscribed: !wanted[segment]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsubscribeContact flags email unsubscribed in the audience of each of its segments
+func unsubscribeContact(ctx context.Context, mailer mail.Mailer, email string, prefs Preferences) error {
+	for _, segment := range prefs.Segments() {
+		if audienceID := segment.AudienceID(); audienceID != "" {
+			if err := mailer.UpsertContact(ctx, audienceID, mail.Contact{Email: email, Unsubscribed: true}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"main/lib/mail"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreferencesNormalize(t *testing.T) {
+	got, err := Preferences{
+		Products:   []string{"digest", " TLDR ", "digest"},
+		Categories: []string{"payments", "Regulations", "PAYMENTS"},
+		TimeZone:   "Europe/London",
+	}.Normalize()
+	if err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	want := Preferences{
+		Products:   []string{ProductDigest, ProductTLDR},
+		Frequency:  FrequencyDaily,
+		Categories: []string{"Regulations", "Payments"},
+		TimeZone:   "Europe/London",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalize() = %+v, want %+v", got, want)
+	}
+
+	invalid := []Preferences{
+		{},
+		{Products: []string{"podcast"}},
+		{Products: []string{ProductTLDR}, Frequency: "hourly"},
+		{Products: []string{ProductDigest}, Frequency: FrequencyWeekly}, // No weekly digest is sent
+		{Products: []string{ProductDigest, ProductTLDR}, Frequency: FrequencyWeekly},
+		{Products: []string{ProductTLDR}, Categories: []string{"Esports"}},
+		{Products: []string{ProductTLDR}, TimeZone: "Mars/Olympus_Mons"},
+	}
+	for _, prefs := range invalid {
+		if _, err := prefs.Normalize(); !errors.Is(err, ErrInvalidPreferences) {
+			t.Errorf("Normalize(%+v) error = %v, want ErrInvalidPreferences", prefs, err)
+		}
+	}
+}
+
+func TestPreferencesSegments(t *testing.T) {
+	t.Setenv("RESEND_AUDIENCE_ID", "aud-tldr")
+	t.Setenv("RESEND_DIGEST_AUDIENCE_ID", "aud-digest")
+
+	daily := DefaultPreferences().Segments()
+	if len(daily) != 1 || daily[0].AudienceID() != "aud-tldr" {
+		t.Errorf("default segments = %+v, want the TLDR daily audience", daily)
+	}
+
+	both := Preferences{Products: []string{ProductDigest, ProductTLDR}, Frequency: FrequencyDaily}.Segments()
+	want := []Segment{{ProductTLDR, FrequencyDaily}, {ProductDigest, FrequencyDaily}}
+	if !reflect.DeepEqual(both, want) {
+		t.Errorf("daily segments = %+v, want %+v", both, want)
+	}
+	if both[1].AudienceID() != "aud-digest" {
+		t.Errorf("digest audience = %q", both[1].AudienceID())
+	}
+
+	// Chosen categories take the reader out of the digest audience, to a personal digest
+	topics := Preferences{Products: []string{ProductDigest, ProductTLDR}, Frequency: FrequencyDaily, Categories: []string{"Payments"}}
+	if segments := topics.Segments(); !topics.PersonalDigest() || len(segments) != 1 || segments[0] != (Segment{ProductTLDR, FrequencyDaily}) {
+		t.Errorf("segments with categories = %+v, want only TLDR daily", segments)
+	}
+	if (Preferences{Products: []string{ProductTLDR}, Categories: []string{"Payments"}}).PersonalDigest() {
+		t.Error("PersonalDigest() without the digest = true")
+	}
+
+	weekly := Preferences{Products: []string{ProductTLDR}, Frequency: FrequencyWeekly}.Segments()
+	if len(weekly) != 1 || weekly[0] != (Segment{ProductTLDR, FrequencyWeekly}) || weekly[0].AudienceID() != "" {
+		t.Errorf("weekly segments = %+v, want the unconfigured TLDR weekly audience", weekly)
+	}
+}
+
+func TestPreferencesFlow(t *testing.T) {
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+	ctx := context.Background()
+	store := NewMemoryStore()
+	token := SignToken([]byte("test-secret"), PurposePreferences, "reader@example.com", time.Time{})
+
+	// Subscribers from before the store existed get what they've been receiving
+	sub, err := LoadPreferences(ctx, store, token)
+	if err != nil || sub.Status != StatusActive || !reflect.DeepEqual(sub.Preferences, DefaultPreferences()) {
+		t.Fatalf("LoadPreferences() = %+v, %v; want active with defaults", sub, err)
+	}
+
+	if _, err := LoadPreferences(ctx, store, SignToken([]byte("test-secret"), PurposeUnsubscribe, "reader@example.com", time.Time{})); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("LoadPreferences() with an unsubscribe token error = %v, want ErrInvalidToken", err)
+	}
+
+	store.Unsubscribe(ctx, "reader@example.com", SourcePage, time.Now())
+	if _, err := SavePreferences(ctx, store, mail.NewOutbox(""), token, DefaultPreferences()); !errors.Is(err, ErrNotSubscribed) {
+		t.Errorf("SavePreferences() after unsubscribe error = %v, want ErrNotSubscribed", err)
+	}
+
+	// The store keeps preferences without touching the status
+	prefs := Preferences{Products: []string{ProductTLDR}, Frequency: FrequencyWeekly, TimeZone: "UTC"}
+	saved, _ := store.SetPreferences(ctx, "reader@example.com", prefs, time.Now())
+	if saved.Status != StatusUnsubscribed || !reflect.DeepEqual(saved.Preferences, prefs) {
+		t.Errorf("SetPreferences() = %+v", saved)
+	}
+
+	// ListActive leaves out everyone not active
+	store.Confirm(ctx, "b@example.com", time.Now())
+	store.Confirm(ctx, "a@example.com", time.Now())
+	store.AddPending(ctx, "pending@example.com", DefaultPreferences(), time.Now())
+	active, err := store.ListActive(ctx)
+	if err != nil || len(active) != 2 || active[0].Email != "a@example.com" || active[1].Email != "b@example.com" {
+		t.Errorf("ListActive() = %+v, %v; want a@ and b@", active, err)
+	}
+}
+
+func TestSavePreferencesAfterProviderUnsubscribe(t *testing.T) {
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+	t.Setenv("RESEND_AUDIENCE_ID", "aud-tldr")
+	t.Setenv("RESEND_DIGEST_AUDIENCE_ID", "aud-digest")
+	ctx := context.Background()
+	store := NewMemoryStore()
+	outbox := mail.NewOutbox("")
+	store.Confirm(ctx, "reader@example.com", time.Now())
+	outbox.UpsertContact(ctx, "aud-tldr", mail.Contact{Email: "reader@example.com"})
+	outbox.UpsertContact(ctx, "aud-tldr", mail.Contact{Email: "reader@example.com", Unsubscribed: true})
+
+	// Saving must not put back a contact that opted out through Resend's link
+	token := SignToken([]byte("test-secret"), PurposePreferences, "reader@example.com", time.Time{})
+	both := Preferences{Products: []string{ProductDigest, ProductTLDR}}
+	if _, err := SavePreferences(ctx, store, outbox, token, both); !errors.Is(err, ErrNotSubscribed) {
+		t.Fatalf("SavePreferences() error = %v, want ErrNotSubscribed", err)
+	}
+	sub, _ := store.Get(ctx, "reader@example.com")
+	events, _ := store.Events(ctx, "reader@example.com")
+	if sub.Status != StatusUnsubscribed || len(events) != 1 || events[0].Source != SourceProvider {
+		t.Errorf("subscriber = %+v with events %+v; want unsubscribed by the provider", sub, events)
+	}
+	for _, audience := range []string{"aud-tldr", "aud-digest"} {
+		contacts, _ := outbox.ListContacts(ctx, audience)
+		for _, contact := range contacts {
+			if !contact.Unsubscribed {
+				t.Errorf("%s has %s subscribed again", audience, contact.Email)
+			}
+		}
+	}
+}
+
+// failingUpsert is an outbox whose audiences can be read but not changed
+type failingUpsert struct {
+	*mail.Outbox
+}
+
+func (f failingUpsert) UpsertContact(ctx context.Context, audienceID string, contact mail.Contact) error {
+	return errors.New("audience unavailable")
+}
+
+func TestSavePreferencesSyncsAudiencesFirst(t *testing.T) {
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+	t.Setenv("RESEND_AUDIENCE_ID", "aud-tldr")
+	t.Setenv("RESEND_DIGEST_AUDIENCE_ID", "aud-digest")
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Confirm(ctx, "reader@example.com", time.Now())
+	token := SignToken([]byte("test-secret"), PurposePreferences, "reader@example.com", time.Time{})
+	both := Preferences{Products: []string{ProductDigest, ProductTLDR}}
+
+	// A failed sync leaves the stored preferences as they were
+	if _, err := SavePreferences(ctx, store, failingUpsert{mail.NewOutbox("")}, token, both); err == nil {
+		t.Fatal("SavePreferences() with a failing mailer succeeded")
+	}
+	if sub, _ := store.Get(ctx, "reader@example.com"); !reflect.DeepEqual(sub.Preferences, DefaultPreferences()) {
+		t.Errorf("preferences after a failed sync = %+v, want the defaults", sub.Preferences)
+	}
+
+	outbox := mail.NewOutbox("")
+	sub, err := SavePreferences(ctx, store, outbox, token, both)
+	if err != nil || !reflect.DeepEqual(sub.Preferences.Products, []string{ProductDigest, ProductTLDR}) {
+		t.Fatalf("SavePreferences() = %+v, %v", sub, err)
+	}
+	if contacts, _ := outbox.ListContacts(ctx, "aud-digest"); len(contacts) != 1 || contacts[0].Unsubscribed {
+		t.Errorf("digest audience = %+v, want the reader subscribed", contacts)
+	}
+
+	// Choosing categories moves the reader out of the digest audience to a personal digest
+	both.Categories = []string{"Payments"}
+	if _, err := SavePreferences(ctx, store, outbox, token, both); err != nil {
+		t.Fatalf("SavePreferences() with categories error = %v", err)
+	}
+	if contacts, _ := outbox.ListContacts(ctx, "aud-digest"); len(contacts) != 1 || !contacts[0].Unsubscribed {
+		t.Errorf("digest audience = %+v, want the reader flagged unsubscribed", contacts)
+	}
+}
+
+func TestGeneratePreferencesPageHTML(t *testing.T) {
+	html, err := GeneratePreferencesPageHTML(PreferencesPage{
+		Email: "reader@example.com",
+		Token: "signed-token",
+		Preferences: Preferences{
+			Products:   []string{ProductDigest},
+			Frequency:  FrequencyWeekly,
+			Categories: []string{"Payments"},
+			TimeZone:   "Europe/London",
+		},
+		Error: "the iGaming digest is only sent daily",
+	})
+	if err != nil {
+		t.Fatalf("GeneratePreferencesPageHTML() error = %v", err)
+	}
+	for _, want := range []string{
+		`value="signed-token"`,
+		`value="digest" checked`,
+		`value="weekly" checked`,
+		`value="Payments" checked`,
+		`value="Europe/London"`,
+		"the iGaming digest is only sent daily",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("preferences page missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{`value="tldr" checked`, `value="daily" checked`, `value="Regulations" checked`} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("preferences page has %q", unwanted)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package subscribe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"main/lib/logger"
+	"main/lib/paper"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a client IP or email domain has made too many subscribe
+// requests in the current window
+var ErrRateLimited = errors.New("too many subscription requests")
+
+// RateLimitError says which limit was hit and when the window ends; it matches ErrRateLimited
+type RateLimitError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s for %s, retry in %s", ErrRateLimited, e.Key, e.RetryAfter.Round(time.Second))
+}
+
+// Is makes errors.Is(err, ErrRateLimited) true
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimiter counts requests per key in fixed windows
+type RateLimiter interface {
+	// Hit counts one request for key in the window starting at windowStart and returns
+	// the hits so far, this one included
+	Hit(ctx context.Context, key string, windowStart time.Time) (int, error)
+	// Prune removes windows that started before cutoff
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// RateLimits are the subscribe request limits per window; a zero limit turns it off
+type RateLimits struct {
+	PerIP     int
+	PerDomain int
+	Window    time.Duration
+}
+
+// sharedMailDomains are the big webmail providers, left out of the per-domain limit: many
+// real readers share them, and their own sign-up checks keep bots from minting addresses
+var sharedMailDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true,
+	"live.com": true, "yahoo.com": true, "icloud.com": true, "me.com": true,
+	"proton.me": true, "protonmail.com": true, "aol.com": true, "gmx.com": true,
+}
+
+// RateLimitsFromEnv reads the limits from the environment:
+//
+//	SUBSCRIBE_RATE_LIMIT_IP      requests per client IP (default 5)
+//	SUBSCRIBE_RATE_LIMIT_DOMAIN  requests per email domain (default 20, big webmail exempt)
+//	SUBSCRIBE_RATE_WINDOW        window length (default 1h)
+func RateLimitsFromEnv() RateLimits {
+	limits := RateLimits{PerIP: 5, PerDomain: 20, Window: time.Hour}
+	if n, err := strconv.Atoi(os.Getenv("SUBSCRIBE_RATE_LIMIT_IP")); err == nil && n >= 0 {
+		limits.PerIP = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("SUBSCRIBE_RATE_LIMIT_DOMAIN")); err == nil && n >= 0 {
+		limits.PerDomain = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("SUBSCRIBE_RATE_WINDOW")); err == nil && d > 0 {
+		limits.Window = d
+	}
+	return limits
+}
+
+// CheckIPRateLimit counts a subscribe request from ip and returns a *RateLimitError once it
+// is over limits.PerIP. It runs before the bot challenge, so no client can spend verification
+// calls without limit.
+func CheckIPRateLimit(ctx context.Context, limiter RateLimiter, limits RateLimits, ip string, now time.Time) error {
+	if ip == "" {
+		return nil
+	}
+	return checkRateLimit(ctx, limiter, limits, "ip:"+ip, limits.PerIP, now)
+}
+
+// CheckDomainRateLimit counts a subscribe request for email's domain and returns a
+// *RateLimitError once it is over limits.PerDomain. Only call it once the bot challenge has
+// passed: counting unverified requests would let anyone lock a domain out.
+func CheckDomainRateLimit(ctx context.Context, limiter RateLimiter, limits RateLimits, email string, now time.Time) error {
+	domain, ok := emailDomain(NormalizeEmail(email))
+	if !ok || sharedMailDomains[domain] {
+		return nil
+	}
+	return checkRateLimit(ctx, limiter, limits, "domain:"+domain, limits.PerDomain, now)
+}
+
+// checkRateLimit counts a hit for key in the current window. A failing limiter is logged and
+// lets the request through; the bot challenge still applies.
+func checkRateLimit(ctx context.Context, limiter RateLimiter, limits RateLimits, key string, limit int, now time.Time) error {
+	if limits.Window <= 0 || limit <= 0 {
+		return nil
+	}
+	windowStart := now.UTC().Truncate(limits.Window)
+
+	hits, err := limiter.Hit(ctx, key, windowStart)
+	if err != nil {
+		logger.Error("Rate limiter failed, allowing the request", err, map[string]interface{}{"key": key})
+		return nil
+	}
+	if hits > limit {
+		return &RateLimitError{Key: key, RetryAfter: windowStart.Add(limits.Window).Sub(now)}
+	}
+	return nil
+}
+
+var (
+	defaultRateLimiter     RateLimiter
+	defaultRateLimiterOnce sync.Once
+)
+
+// DefaultRateLimiter returns the process-wide rate limiter: Postgres when the database is
+// reachable, else memory, which only limits requests reaching the same instance
+func DefaultRateLimiter() RateLimiter {
+	defaultRateLimiterOnce.Do(func() {
+		if err := paper.InitDB(); err != nil {
+			logger.Warn("Database unavailable, subscribe rate limits are per instance only", map[string]interface{}{
+				"error": err.Error(),
+			})
+			defaultRateLimiter = NewMemoryRateLimiter()
+			return
+		}
+		defaultRateLimiter = NewPostgresRateLimiter(paper.GetDB())
+	})
+	return defaultRateLimiter
+}
+
+// MemoryRateLimiter keeps counters in process (local runs and tests)
+type MemoryRateLimiter struct {
+	mu   sync.Mutex
+	hits map[string]map[time.Time]int
+}
+
+// NewMemoryRateLimiter creates an empty in-memory rate limiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{hits: make(map[string]map[time.Time]int)}
+}
+
+// Hit counts one request for key in the window
+func (m *MemoryRateLimiter) Hit(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	windows, ok := m.hits[key]
+	if !ok {
+		windows = make(map[time.Time]int)
+		m.hits[key] = windows
+	}
+	windows[windowStart]++
+	return windows[windowStart], nil
+}
+
+// Prune removes windows that started before cutoff
+func (m *MemoryRateLimiter) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pruned := 0
+	for key, windows := range m.hits {
+		for start := range windows {
+			if start.Before(cutoff) {
+				delete(windows, start)
+				pruned++
+			}
+		}
+		if len(windows) == 0 {
+			delete(m.hits, key)
+		}
+	}
+	return pruned, nil
+}
+
+// PostgresRateLimiter keeps counters in the subscribe_rate_limits table (migration 0010),
+// so the limits hold across serverless instances
+type PostgresRateLimiter struct {
+	db *sql.DB
+}
+
+// NewPostgresRateLimiter creates a rate limiter on an open database
+func NewPostgresRateLimiter(db *sql.DB) *PostgresRateLimiter {
+	return &PostgresRateLimiter{db: db}
+}
+
+// Hit increments the window's counter in one upsert
+func (p *PostgresRateLimiter) Hit(ctx context.Context, key string, windowStart time.Time) (int, error) {
+	var hits int
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO subscribe_rate_limits (key, window_start, hits)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (key, window_start) DO UPDATE SET hits = subscribe_rate_limits.hits + 1
+		RETURNING hits`,
+		key, windowStart,
+	).Scan(&hits)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count subscribe request: %w", err)
+	}
+	return hits, nil
+}
+
+// Prune deletes windows that started before cutoff
+func (p *PostgresRateLimiter) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := p.db.ExecContext(ctx, `DELETE FROM subscribe_rate_limits WHERE window_start < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune subscribe rate limits: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
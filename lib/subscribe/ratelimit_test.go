@@ -0,0 +1,60 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckRateLimits(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewMemoryRateLimiter()
+	limits := RateLimits{PerIP: 2, PerDomain: 3, Window: time.Hour}
+	now := time.Date(2025, 1, 15, 10, 20, 0, 0, time.UTC)
+
+	// The IP limit applies to every request from the address
+	for i := 0; i < 2; i++ {
+		if err := CheckIPRateLimit(ctx, limiter, limits, "198.51.100.7", now); err != nil {
+			t.Fatalf("request %d error = %v", i+1, err)
+		}
+	}
+	err := CheckIPRateLimit(ctx, limiter, limits, "198.51.100.7", now)
+	var limited *RateLimitError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &limited) || limited.Key != "ip:198.51.100.7" {
+		t.Fatalf("third request from the IP = %v, want the IP limit", err)
+	}
+	if limited.RetryAfter != 40*time.Minute {
+		t.Errorf("RetryAfter = %s, want the rest of the window", limited.RetryAfter)
+	}
+
+	// The domain limit is separate, so IP-limited (unverified) requests never count against it
+	for i := 0; i < 3; i++ {
+		if err := CheckDomainRateLimit(ctx, limiter, limits, "reader"+strconv.Itoa(i)+"@Example.com", now); err != nil {
+			t.Fatalf("domain request %d error = %v", i+1, err)
+		}
+	}
+	err = CheckDomainRateLimit(ctx, limiter, limits, "fourth@example.com", now)
+	if !errors.As(err, &limited) || limited.Key != "domain:example.com" {
+		t.Fatalf("fourth request for the domain = %v, want the domain limit", err)
+	}
+
+	// Big webmail domains have no domain limit
+	for i := 0; i < 5; i++ {
+		if err := CheckDomainRateLimit(ctx, limiter, limits, "reader@gmail.com", now); err != nil {
+			t.Fatalf("gmail request %d error = %v", i+1, err)
+		}
+	}
+
+	// A new window starts over, and Prune drops the old one
+	if err := CheckIPRateLimit(ctx, limiter, limits, "198.51.100.7", now.Add(time.Hour)); err != nil {
+		t.Fatalf("request in the next window error = %v", err)
+	}
+	if pruned, _ := limiter.Prune(ctx, now.Add(time.Hour).Truncate(time.Hour)); pruned == 0 {
+		t.Error("Prune() removed nothing")
+	}
+	if hits, _ := limiter.Hit(ctx, "ip:198.51.100.7", now.Truncate(time.Hour)); hits != 1 {
+		t.Errorf("hits in the pruned window = %d, want a fresh count", hits)
+	}
+}
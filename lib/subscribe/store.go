@@ -0,0 +1,428 @@
+package subscribe
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/lib/logger"
+	"main/lib/paper"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subscriber statuses
+const (
+	StatusPending      = "pending"      // Asked to subscribe, hasn't confirmed yet
+	StatusActive       = "active"       // Confirmed and in the audiences of its segments
+	StatusUnsubscribed = "unsubscribed" // Opted out; must not be emailed until they subscribe again
+)
+
+// Audit event types
+const (
+	EventUnsubscribed = "unsubscribed"
+)
+
+// ErrSubscriberNotFound is returned by stores for an unknown address
+var ErrSubscriberNotFound = errors.New("subscriber not found")
+
+// Subscriber is the subscription state of one email address
+type Subscriber struct {
+	Email          string      `json:"email"`
+	Status         string      `json:"status"`
+	RequestedAt    time.Time   `json:"requestedAt"` // Last time a confirmation was requested
+	ConfirmedAt    *time.Time  `json:"confirmedAt,omitempty"`
+	UnsubscribedAt *time.Time  `json:"unsubscribedAt,omitempty"`
+	Preferences    Preferences `json:"preferences"`
+}
+
+// Event is an entry in the audit trail of subscription changes
+type Event struct {
+	Email  string    `json:"email"`
+	Type   string    `json:"type"`
+	Source string    `json:"source,omitempty"` // How it was requested, e.g. one-click or page
+	At     time.Time `json:"at"`
+}
+
+// Store persists subscription state. Addresses are stored normalized (see NormalizeEmail).
+type Store interface {
+	// AddPending records a subscription request with the preferences asked for. An active
+	// subscriber is left as is; the returned record shows which happened.
+	AddPending(ctx context.Context, email string, prefs Preferences, now time.Time) (*Subscriber, error)
+	// Confirm activates a pending subscriber, creating the record if the store has none
+	// (the signed token is proof enough). Confirming an active subscriber is a no-op.
+	Confirm(ctx context.Context, email string, now time.Time) (*Subscriber, error)
+	// Get returns ErrSubscriberNotFound for an unknown address
+	Get(ctx context.Context, email string) (*Subscriber, error)
+	// ListActive returns the active subscribers, ordered by address
+	ListActive(ctx context.Context) ([]*Subscriber, error)
+	// DeletePending removes pending subscribers last requested before cutoff
+	DeletePending(ctx context.Context, cutoff time.Time) (int, error)
+	// Unsubscribe marks an address unsubscribed (creating the record if needed) and
+	// appends an EventUnsubscribed audit event, even when it was already unsubscribed
+	Unsubscribe(ctx context.Context, email, source string, now time.Time) (*Subscriber, error)
+	// Events returns the audit trail for an address, oldest first
+	Events(ctx context.Context, email string) ([]Event, error)
+	// SetPreferences replaces a subscriber's preferences, leaving the status alone. An unknown
+	// address is recorded as active: it subscribed before subscriptions were stored.
+	SetPreferences(ctx context.Context, email string, prefs Preferences, now time.Time) (*Subscriber, error)
+}
+
+var (
+	defaultStore     Store
+	defaultStoreOnce sync.Once
+)
+
+// DefaultStore returns the process-wide subscriber store: Postgres when the database is
+// reachable, else memory. Without a database nothing outlives the request, but
+// confirmation still works because the signed token carries the address.
+func DefaultStore() Store {
+	defaultStoreOnce.Do(func() {
+		if err := paper.InitDB(); err != nil {
+			logger.Warn("Database unavailable, subscription state is kept in memory only", map[string]interface{}{
+				"error": err.Error(),
+			})
+			defaultStore = NewMemoryStore()
+			return
+		}
+		defaultStore = NewPostgresStore(paper.GetDB())
+	})
+	return defaultStore
+}
+
+// NormalizeEmail trims and lowercases an address so each inbox has one record
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// MemoryStore keeps subscribers in process (local runs and tests)
+type MemoryStore struct {
+	mu          sync.RWMutex
+	subscribers map[string]*Subscriber
+	events      []Event
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subscribers: make(map[string]*Subscriber)}
+}
+
+// AddPending records a subscription request
+func (m *MemoryStore) AddPending(ctx context.Context, email string, prefs Preferences, now time.Time) (*Subscriber, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subscribers[email]
+	if !ok {
+		sub = &Subscriber{Email: email, Preferences: DefaultPreferences()}
+		m.subscribers[email] = sub
+	}
+	if sub.Status != StatusActive {
+		sub.Status = StatusPending
+		sub.RequestedAt = now
+		sub.Preferences = prefs
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+// Confirm activates a subscriber
+func (m *MemoryStore) Confirm(ctx context.Context, email string, now time.Time) (*Subscriber, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subscribers[email]
+	if !ok {
+		sub = &Subscriber{Email: email, RequestedAt: now, Preferences: DefaultPreferences()}
+		m.subscribers[email] = sub
+	}
+	if sub.Status != StatusActive {
+		confirmedAt := now
+		sub.Status = StatusActive
+		sub.ConfirmedAt = &confirmedAt
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+// Get returns a copy of a subscriber
+func (m *MemoryStore) Get(ctx context.Context, email string) (*Subscriber, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sub, ok := m.subscribers[email]
+	if !ok {
+		return nil, ErrSubscriberNotFound
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+// ListActive returns copies of the active subscribers
+func (m *MemoryStore) ListActive(ctx context.Context) ([]*Subscriber, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var active []*Subscriber
+	for _, sub := range m.subscribers {
+		if sub.Status == StatusActive {
+			copied := *sub
+			active = append(active, &copied)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Email < active[j].Email })
+	return active, nil
+}
+
+// DeletePending removes stale pending subscribers
+func (m *MemoryStore) DeletePending(ctx context.Context, cutoff time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for email, sub := range m.subscribers {
+		if sub.Status == StatusPending && sub.RequestedAt.Before(cutoff) {
+			delete(m.subscribers, email)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Unsubscribe marks a subscriber unsubscribed and records the event
+func (m *MemoryStore) Unsubscribe(ctx context.Context, email, source string, now time.Time) (*Subscriber, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subscribers[email]
+	if !ok {
+		sub = &Subscriber{Email: email, RequestedAt: now, Preferences: DefaultPreferences()}
+		m.subscribers[email] = sub
+	}
+	if sub.Status != StatusUnsubscribed {
+		unsubscribedAt := now
+		sub.Status = StatusUnsubscribed
+		sub.UnsubscribedAt = &unsubscribedAt
+	}
+	m.events = append(m.events, Event{Email: email, Type: EventUnsubscribed, Source: source, At: now})
+	copied := *sub
+	return &copied, nil
+}
+
+// Events returns the audit trail for an address
+func (m *MemoryStore) Events(ctx context.Context, email string) ([]Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var events []Event
+	for _, event := range m.events {
+		if event.Email == email {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// SetPreferences replaces a subscriber's preferences
+func (m *MemoryStore) SetPreferences(ctx context.Context, email string, prefs Preferences, now time.Time) (*Subscriber, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subscribers[email]
+	if !ok {
+		sub = &Subscriber{Email: email, Status: StatusActive, RequestedAt: now}
+		m.subscribers[email] = sub
+	}
+	sub.Preferences = prefs
+	copied := *sub
+	return &copied, nil
+}
+
+// PostgresStore keeps subscribers in the subscribers table (migration 0006) and the
+// audit trail in subscription_events (migration 0007)
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store on an open database
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// AddPending upserts a pending row unless the address is already active
+func (p *PostgresStore) AddPending(ctx context.Context, email string, prefs Preferences, now time.Time) (*Subscriber, error) {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode subscriber preferences: %w", err)
+	}
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO subscribers (email, status, requested_at, preferences)
+		VALUES ($1, $2, $3, $5)
+		ON CONFLICT (email) DO UPDATE SET status = EXCLUDED.status, requested_at = EXCLUDED.requested_at, preferences = EXCLUDED.preferences, updated_at = NOW()
+		WHERE subscribers.status <> $4`,
+		email, StatusPending, now, StatusActive, data,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record pending subscription: %w", err)
+	}
+	return p.Get(ctx, email)
+}
+
+// Confirm activates a pending row, or inserts an active one
+func (p *PostgresStore) Confirm(ctx context.Context, email string, now time.Time) (*Subscriber, error) {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO subscribers (email, status, requested_at, confirmed_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (email) DO UPDATE SET status = EXCLUDED.status, confirmed_at = EXCLUDED.confirmed_at, updated_at = NOW()
+		WHERE subscribers.status <> $2`,
+		email, StatusActive, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm subscription: %w", err)
+	}
+	return p.Get(ctx, email)
+}
+
+// subscriberColumns are the columns scanSubscriber reads, in order
+const subscriberColumns = "email, status, requested_at, confirmed_at, unsubscribed_at, preferences"
+
+// Get loads one subscriber
+func (p *PostgresStore) Get(ctx context.Context, email string) (*Subscriber, error) {
+	sub, err := scanSubscriber(p.db.QueryRowContext(ctx,
+		"SELECT "+subscriberColumns+" FROM subscribers WHERE email = $1", email,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrSubscriberNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscriber: %w", err)
+	}
+	return sub, nil
+}
+
+// ListActive loads the active subscribers
+func (p *PostgresStore) ListActive(ctx context.Context) ([]*Subscriber, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT "+subscriberColumns+" FROM subscribers WHERE status = $1 ORDER BY email", StatusActive,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	var active []*Subscriber
+	for rows.Next() {
+		sub, err := scanSubscriber(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscriber: %w", err)
+		}
+		active = append(active, sub)
+	}
+	return active, rows.Err()
+}
+
+// rowScanner is the scanning side of *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSubscriber reads a row of subscriberColumns; NULL preferences are the defaults
+func scanSubscriber(row rowScanner) (*Subscriber, error) {
+	var sub Subscriber
+	var prefs []byte
+	if err := row.Scan(&sub.Email, &sub.Status, &sub.RequestedAt, &sub.ConfirmedAt, &sub.UnsubscribedAt, &prefs); err != nil {
+		return nil, err
+	}
+
+	sub.Preferences = DefaultPreferences()
+	if prefs != nil {
+		if err := json.Unmarshal(prefs, &sub.Preferences); err != nil {
+			return nil, fmt.Errorf("failed to decode subscriber preferences: %w", err)
+		}
+	}
+	return &sub, nil
+}
+
+// DeletePending removes stale pending rows
+func (p *PostgresStore) DeletePending(ctx context.Context, cutoff time.Time) (int, error) {
+	result, err := p.db.ExecContext(ctx,
+		"DELETE FROM subscribers WHERE status = $1 AND requested_at < $2", StatusPending, cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete pending subscriptions: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted subscriptions: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// Unsubscribe updates the row and appends the audit event in one transaction
+func (p *PostgresStore) Unsubscribe(ctx context.Context, email, source string, now time.Time) (*Subscriber, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin unsubscribe: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO subscribers (email, status, requested_at, unsubscribed_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (email) DO UPDATE SET status = EXCLUDED.status, unsubscribed_at = EXCLUDED.unsubscribed_at, updated_at = NOW()
+		WHERE subscribers.status <> $2`,
+		email, StatusUnsubscribed, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record unsubscribe: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO subscription_events (email, event, source, created_at) VALUES ($1, $2, $3, $4)",
+		email, EventUnsubscribed, source, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record unsubscribe event: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit unsubscribe: %w", err)
+	}
+	return p.Get(ctx, email)
+}
+
+// Events loads the audit trail for an address
+func (p *PostgresStore) Events(ctx context.Context, email string) ([]Event, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT email, event, source, created_at FROM subscription_events WHERE email = $1 ORDER BY created_at, id", email,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscription events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.Email, &event.Type, &event.Source, &event.At); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// SetPreferences stores the preferences, inserting an active row for an unknown address
+func (p *PostgresStore) SetPreferences(ctx context.Context, email string, prefs Preferences, now time.Time) (*Subscriber, error) {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode subscriber preferences: %w", err)
+	}
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO subscribers (email, status, requested_at, preferences)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (email) DO UPDATE SET preferences = EXCLUDED.preferences, updated_at = NOW()`,
+		email, StatusActive, now, data,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save subscriber preferences: %w", err)
+	}
+	return p.Get(ctx, email)
+}
@@ -1,77 +1,202 @@
 package subscribe
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"main/lib/analytics"
 	"main/lib/logger"
-	"os"
-	"regexp"
-
-	"github.com/resend/resend-go/v2"
+	"main/lib/mail"
+	"main/lib/rss"
+	"net/url"
+	"time"
 )
 
-// emailRegex is a simple regex to validate email format.
-var emailRegex = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+// ConfirmationTTL is how long a confirmation link stays valid; pending subscriptions
+// older than this are removed by CleanupPending
+const ConfirmationTTL = 48 * time.Hour
+
+// ErrInvalidEmail is returned for addresses that fail validation, see EmailValidator
+var ErrInvalidEmail = errors.New("invalid email format")
+
+// fromAddress returns the sender for subscription emails.
+// Audiences are per segment, see Segment.AudienceID.
+func fromAddress() (string, error) {
+	from := mail.FromAddress()
+	if from == "" {
+		return "", fmt.Errorf("missing MAIL_FROM or RESEND_FROM_EMAIL environment variable")
+	}
+	return from, nil
+}
+
+// RequestSubscription records a pending subscription with the preferences asked for and
+// emails a link to confirm it. Nothing is added to an audience until the link is
+// followed (double opt-in). An address that's already subscribed gets no email and the
+// same nil result, so the endpoint can't be used to find out who subscribes; it can
+// change its preferences from the link in any email.
+func RequestSubscription(ctx context.Context, store Store, mailer mail.Mailer, validator *EmailValidator, email string, prefs Preferences) error {
+	// 1. Validate the address and preferences
+	email = NormalizeEmail(email)
+	if err := validator.Validate(ctx, email); err != nil {
+		return err
+	}
+	prefs, err := prefs.Normalize()
+	if err != nil {
+		return err
+	}
+
+	// 2. Check configuration before recording anything
+	from, err := fromAddress()
+	if err != nil {
+		return err
+	}
+	secret, err := tokenSecret()
+	if err != nil {
+		return err
+	}
 
-// SubscribeEmail adds a user to the Resend audience and sends them a welcome email.
-func SubscribeEmail(email string) error {
-	// 1. Validate email format
-	if !emailRegex.MatchString(email) {
-		return fmt.Errorf("invalid email format")
+	// 3. Record the pending subscription
+	now := time.Now().UTC()
+	sub, err := store.AddPending(ctx, email, prefs, now)
+	if err != nil {
+		return err
+	}
+	if sub.Status == StatusActive {
+		logger.Info("Subscription requested for an active subscriber, skipping confirmation email", nil)
+		return nil
 	}
 
-	// 2. Get Resend configuration from environment
-	apiKey := os.Getenv("RESEND_API_KEY")
-	audienceID := os.Getenv("RESEND_AUDIENCE_ID")
-	fromEmail := os.Getenv("RESEND_FROM_EMAIL")
+	// 4. Email the signed confirmation link (critical: without it the request is useless)
+	expires := now.Add(ConfirmationTTL)
+	token := SignToken(secret, PurposeConfirm, email, expires)
+	confirmURL := rss.BaseURL() + "/api/subscribe/confirm?token=" + url.QueryEscape(token)
 
-	if apiKey == "" || audienceID == "" || fromEmail == "" {
-		return fmt.Errorf("missing Resend configuration in environment variables")
+	emailHTML, err := GenerateConfirmationEmailHTML(confirmURL, expires)
+	if err != nil {
+		return err
+	}
+	if err := mail.Lint(emailHTML); err != nil {
+		return err
 	}
 
-	client := resend.NewClient(apiKey)
+	_, err = mailer.Send(ctx, mail.Message{
+		From:    from,
+		To:      []string{email},
+		Subject: "Confirm your Takara TLDR subscription",
+		HTML:    emailHTML,
+		Text:    mail.PlainText(emailHTML),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send confirmation email: %w", err)
+	}
+
+	_ = analytics.Track("subscription_requested", email, map[string]interface{}{
+		"source": "subscribe",
+	})
+	return nil
+}
 
-	// 3. Add contact to Resend audience (critical step)
-	createContactParams := &resend.CreateContactRequest{
-		Email:      email,
-		AudienceId: audienceID,
+// ConfirmSubscription verifies a confirmation token, activates the subscriber, adds them
+// to the audience of each segment they chose and sends the welcome email. It returns the confirmed address.
+// Returns ErrInvalidToken or ErrExpiredToken for a bad link.
+func ConfirmSubscription(ctx context.Context, store Store, mailer mail.Mailer, token string) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
 	}
-	_, err := client.Contacts.Create(createContactParams)
+	email, err := VerifyToken(secret, PurposeConfirm, token, time.Now())
 	if err != nil {
-		// This is a critical failure. If we can't add them, the subscription failed.
-		return fmt.Errorf("failed to add contact to Resend audience: %w", err)
+		return "", err
+	}
+	from, err := fromAddress()
+	if err != nil {
+		return "", err
+	}
+
+	// 1. Check whether this is a repeat click before changing anything. Without a record
+	// (no database, or it was cleaned up) the reader gets the default preferences.
+	prefs := DefaultPreferences()
+	wasActive := false
+	if existing, err := store.Get(ctx, email); err == nil {
+		wasActive = existing.Status == StatusActive
+		prefs = existing.Preferences
+	} else if !errors.Is(err, ErrSubscriberNotFound) {
+		return "", err
+	}
+
+	// 2. Add contact to the audience of each chosen segment (critical step). Doing this before
+	// marking the subscriber active means a failure here can be retried by following the link again.
+	// A contact left flagged by an earlier unsubscribe is flagged back.
+	if !wasActive {
+		if err := syncContact(ctx, mailer, email, Preferences{}, prefs); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := store.Confirm(ctx, email, time.Now().UTC()); err != nil {
+		return "", err
+	}
+	if wasActive {
+		return email, nil
 	}
 
 	_ = analytics.Track("email_subscribed", email, map[string]interface{}{
 		"source": "subscribe",
 	})
 
-	// 4. Fetch current feed for welcome email (non-critical)
-	feed, err := ParseRssFeed()
+	// 3. Fetch current feed for welcome email (non-critical)
+	feed, err := rss.FetchTldr(ctx)
 	if err != nil {
 		logger.Error("Failed to fetch current feed for welcome email", err, nil)
 		// Do not return; continue to send a welcome email without the feed.
 	}
 
-	// 5. Generate and send welcome email (non-critical)
-	emailHTML, err := GenerateWelcomeEmailHTML(feed)
+	// 4. Generate and send welcome email (non-critical)
+	unsubscribeURL, err := UnsubscribeURL(email)
+	if err != nil {
+		logger.Error("Failed to create unsubscribe link for welcome email", err, nil)
+		return email, nil
+	}
+	preferencesURL, err := PreferencesURL(email)
+	if err != nil {
+		logger.Error("Failed to create preferences link for welcome email", err, nil)
+		return email, nil
+	}
+	emailHTML, err := GenerateWelcomeEmailHTML(feed, unsubscribeURL, preferencesURL)
 	if err != nil {
 		logger.Error("Failed to generate welcome email HTML", err, nil)
 		// Do not return; the main subscription was successful.
-		return nil
+		return email, nil
+	}
+	if err := mail.Lint(emailHTML); err != nil {
+		logger.Error("Welcome email HTML failed lint, not sending it", err, nil)
+		return email, nil
 	}
 
-	sendEmailParams := &resend.SendEmailRequest{
-		From:    fromEmail,
+	_, err = mailer.Send(ctx, mail.Message{
+		From:    from,
 		To:      []string{email},
 		Subject: "Welcome to Takara TLDR",
-		Html:    emailHTML,
-	}
-	_, err = client.Emails.Send(sendEmailParams)
+		HTML:    emailHTML,
+		Text:    mail.PlainText(emailHTML),
+		Headers: mail.UnsubscribeHeaders(unsubscribeURL),
+	})
 	if err != nil {
 		logger.Error("Failed to send welcome email", err, nil)
 		// Do not return; the main subscription was successful.
 	}
 
-	return nil
+	return email, nil
+}
+
+// CleanupPending removes subscriptions that were never confirmed within ConfirmationTTL
+func CleanupPending(ctx context.Context, store Store, now time.Time) (int, error) {
+	deleted, err := store.DeletePending(ctx, now.Add(-ConfirmationTTL))
+	if err != nil {
+		return 0, err
+	}
+	logger.Info("Removed unconfirmed subscriptions", map[string]interface{}{
+		"deleted": deleted,
+	})
+	return deleted, nil
 }
@@ -0,0 +1,100 @@
+package subscribe
+
+import (
+	"context"
+	"main/lib/mail"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testTldrFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<title>Takara TLDR</title><link>https://example.com</link><description>Daily AI research summaries</description>
+<item><title>TLDR</title><link>https://example.com/tldr</link><description><![CDATA[<h2>A Paper</h2><p>What it found</p>]]></description></item>
+</channel></rss>`
+
+// TestSubscriptionFlow runs subscribe, confirm, daily send and unsubscribe against the outbox
+func TestSubscriptionFlow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(testTldrFeed))
+	}))
+	defer server.Close()
+
+	t.Setenv("BASE_URL", server.URL)
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+	t.Setenv("MAIL_FROM", "news@example.com")
+	t.Setenv("RESEND_AUDIENCE_ID", "aud-tldr")
+	ctx := context.Background()
+	store := NewMemoryStore()
+	outbox := mail.NewOutbox("")
+
+	// 1. Subscribing only sends the confirmation link
+	if err := RequestSubscription(ctx, store, outbox, NewEmailValidator(testResolver), "Reader@Example.com", DefaultPreferences()); err != nil {
+		t.Fatalf("RequestSubscription() error = %v", err)
+	}
+	if contacts, _ := outbox.ListContacts(ctx, "aud-tldr"); len(contacts) != 0 {
+		t.Fatalf("contact added before confirmation: %+v", contacts)
+	}
+	messages := outbox.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "reader@example.com" {
+		t.Fatalf("messages after subscribing = %+v, want one confirmation", messages)
+	}
+	link := regexp.MustCompile(`/api/subscribe/confirm\?token=[^"]+`).FindString(messages[0].HTML)
+	parsed, err := url.Parse(strings.ReplaceAll(link, "&amp;", "&"))
+	if link == "" || err != nil {
+		t.Fatalf("no confirmation link in %q", messages[0].HTML)
+	}
+	if !strings.Contains(messages[0].Text, "Confirm subscription ("+server.URL+"/api/subscribe/confirm?token=") {
+		t.Errorf("confirmation text part is missing the link:\n%s", messages[0].Text)
+	}
+
+	// 2. Confirming adds the contact and sends the welcome email
+	email, err := ConfirmSubscription(ctx, store, outbox, parsed.Query().Get("token"))
+	if err != nil || email != "reader@example.com" {
+		t.Fatalf("ConfirmSubscription() = %q, %v", email, err)
+	}
+	if contacts, _ := outbox.ListContacts(ctx, "aud-tldr"); len(contacts) != 1 || contacts[0].Unsubscribed {
+		t.Fatalf("contacts after confirming = %+v", contacts)
+	}
+	messages = outbox.Messages()
+	if len(messages) != 2 || messages[1].Subject != "Welcome to Takara TLDR" {
+		t.Fatalf("messages after confirming = %+v, want a welcome email", messages)
+	}
+	welcome := messages[1]
+	if !strings.Contains(welcome.HTML, "A Paper") {
+		t.Error("welcome email is missing the current feed")
+	}
+	if !strings.Contains(welcome.Text, "A Paper") || strings.Contains(welcome.Text, "<") {
+		t.Errorf("welcome text part = %q, want the feed as plain text", welcome.Text)
+	}
+	unsubscribeHeader := welcome.Headers["List-Unsubscribe"]
+	if !strings.HasPrefix(unsubscribeHeader, "<"+server.URL+"/api/unsubscribe?token=") {
+		t.Fatalf("List-Unsubscribe = %q", unsubscribeHeader)
+	}
+
+	// 3. The daily broadcast reaches the new subscriber
+	broadcastID, err := outbox.CreateBroadcast(ctx, mail.Broadcast{AudienceID: "aud-tldr", From: "news@example.com", Subject: "Daily", HTML: "<p>Daily</p>"})
+	if err != nil {
+		t.Fatalf("CreateBroadcast() error = %v", err)
+	}
+	if err := outbox.SendBroadcast(ctx, broadcastID); err != nil {
+		t.Fatalf("SendBroadcast() error = %v", err)
+	}
+	if messages = outbox.Messages(); len(messages) != 3 || messages[2].To[0] != "reader@example.com" {
+		t.Fatalf("daily broadcast not delivered: %+v", messages)
+	}
+
+	// 4. Unsubscribing through the header link flags the contact
+	unsubscribeURL, _ := url.Parse(strings.Trim(unsubscribeHeader, "<>"))
+	if _, err := Unsubscribe(ctx, store, outbox, unsubscribeURL.Query().Get("token"), SourceOneClick); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if contacts, _ := outbox.ListContacts(ctx, "aud-tldr"); len(contacts) != 1 || !contacts[0].Unsubscribed {
+		t.Fatalf("contacts after unsubscribing = %+v", contacts)
+	}
+}
@@ -0,0 +1,112 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	token := SignToken(secret, PurposeConfirm, "reader@example.com", now.Add(time.Hour))
+
+	email, err := VerifyToken(secret, PurposeConfirm, token, now)
+	if err != nil || email != "reader@example.com" {
+		t.Fatalf("VerifyToken() = %q, %v; want reader@example.com", email, err)
+	}
+
+	if _, err := VerifyToken(secret, PurposeConfirm, token, now.Add(2*time.Hour)); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expired token error = %v, want ErrExpiredToken", err)
+	}
+	if _, err := VerifyToken([]byte("other-secret"), PurposeConfirm, token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong secret error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := VerifyToken(secret, "other", token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong purpose error = %v, want ErrInvalidToken", err)
+	}
+
+	// Swapping in another address must break the signature
+	parts := strings.Split(token, ".")
+	forged := SignToken(secret, PurposeConfirm, "victim@example.com", now.Add(time.Hour))
+	parts[0] = strings.Split(forged, ".")[0]
+	if _, err := VerifyToken(secret, PurposeConfirm, strings.Join(parts, "."), now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered token error = %v, want ErrInvalidToken", err)
+	}
+
+	for _, bad := range []string{"", "abc", "a.b.c", "a.b.c.d"} {
+		if _, err := VerifyToken(secret, PurposeConfirm, bad, now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("VerifyToken(%q) error = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+
+	// A zero expiry never expires
+	forever := SignToken(secret, PurposeConfirm, "reader@example.com", time.Time{})
+	if _, err := VerifyToken(secret, PurposeConfirm, forever, now.AddDate(10, 0, 0)); err != nil {
+		t.Errorf("non-expiring token error = %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	if _, err := store.Get(ctx, "a@example.com"); !errors.Is(err, ErrSubscriberNotFound) {
+		t.Fatalf("Get() unknown error = %v, want ErrSubscriberNotFound", err)
+	}
+
+	sub, _ := store.AddPending(ctx, "a@example.com", DefaultPreferences(), now)
+	if sub.Status != StatusPending {
+		t.Errorf("AddPending() status = %q, want pending", sub.Status)
+	}
+	sub, _ = store.Confirm(ctx, "a@example.com", now.Add(time.Minute))
+	if sub.Status != StatusActive || sub.ConfirmedAt == nil {
+		t.Errorf("Confirm() = %+v, want active with confirmedAt", sub)
+	}
+
+	// Asking again doesn't demote an active subscriber
+	sub, _ = store.AddPending(ctx, "a@example.com", DefaultPreferences(), now.Add(time.Hour))
+	if sub.Status != StatusActive {
+		t.Errorf("AddPending() on active status = %q, want active", sub.Status)
+	}
+
+	store.AddPending(ctx, "old@example.com", DefaultPreferences(), now.Add(-72*time.Hour))
+	store.AddPending(ctx, "new@example.com", DefaultPreferences(), now)
+	deleted, err := CleanupPending(ctx, store, now)
+	if err != nil || deleted != 1 {
+		t.Fatalf("CleanupPending() = %d, %v; want 1", deleted, err)
+	}
+	if _, err := store.Get(ctx, "old@example.com"); !errors.Is(err, ErrSubscriberNotFound) {
+		t.Errorf("stale pending subscriber not removed")
+	}
+	for _, email := range []string{"a@example.com", "new@example.com"} {
+		if _, err := store.Get(ctx, email); err != nil {
+			t.Errorf("Get(%q) error = %v, want kept", email, err)
+		}
+	}
+}
+
+func TestGenerateStatusPageHTML(t *testing.T) {
+	html, err := GenerateStatusPageHTML(StatusPage{
+		Title:      "Confirm your subscription",
+		FormAction: "/api/subscribe/confirm",
+		FormToken:  `tok"en`,
+		FormLabel:  "Confirm subscription",
+	})
+	if err != nil {
+		t.Fatalf("GenerateStatusPageHTML() error = %v", err)
+	}
+	for _, want := range []string{`method="post"`, `action="/api/subscribe/confirm"`, `value="tok&#34;en"`, "Confirm subscription"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("status page missing %q", want)
+		}
+	}
+
+	html, _ = GenerateStatusPageHTML(StatusPage{Title: "Link expired", Message: "Subscribe again"})
+	if strings.Contains(html, "<form") {
+		t.Error("status page without FormAction should have no form")
+	}
+}
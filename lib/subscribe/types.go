@@ -2,38 +2,45 @@ package subscribe
 
 // RequestBody defines the structure for the incoming subscription request.
 type RequestBody struct {
-	Email          string `json:"email"`
-	TurnstileToken string `json:"turnstileToken"`
+	Email          string       `json:"email"`
+	ChallengeToken string       `json:"challengeToken"`
+	TurnstileToken string       `json:"turnstileToken"`        // Older name for ChallengeToken
+	Preferences    *Preferences `json:"preferences,omitempty"` // Default: DefaultPreferences
 }
 
-// TurnstileResponse defines the structure of the JSON response from Cloudflare's siteverify endpoint.
-type TurnstileResponse struct {
+// Token returns the bot challenge token under either of its names
+func (b RequestBody) Token() string {
+	if b.ChallengeToken != "" {
+		return b.ChallengeToken
+	}
+	return b.TurnstileToken
+}
+
+// SiteverifyResponse defines the JSON response of the Turnstile and hCaptcha siteverify endpoints.
+type SiteverifyResponse struct {
 	Success     bool     `json:"success"`
 	ErrorCodes  []string `json:"error-codes"`
 	ChallengeTS string   `json:"challenge_ts"`
 	Hostname    string   `json:"hostname"`
 }
 
-// FeedItem corresponds to a single item in an RSS feed.
-type FeedItem struct {
-	Title       string `json:"title"`
-	Link        string `json:"link"`
-	Description string `json:"description"`
-	PubDate     string `json:"pubDate"`
-	GUID        string `json:"guid"`
-}
-
-// RssFeed corresponds to the overall RSS feed structure.
-type RssFeed struct {
-	Title         string     `json:"title"`
-	Description   string     `json:"description"`
-	Link          string     `json:"link"`
-	LastBuildDate string     `json:"lastBuildDate,omitempty"`
-	Items         []FeedItem `json:"items"`
-}
-
 // ApiResponse defines a generic success/error response structure.
 type ApiResponse struct {
 	Success bool   `json:"success,omitempty"`
+	Message string `json:"message,omitempty"`
 	Error   string `json:"error,omitempty"`
 }
+
+// PreferencesRequest is the JSON body for saving preferences
+type PreferencesRequest struct {
+	Token       string      `json:"token"`
+	Preferences Preferences `json:"preferences"`
+}
+
+// PreferencesResponse is the JSON form of the preferences page
+type PreferencesResponse struct {
+	Email       string      `json:"email"`
+	Status      string      `json:"status"`
+	Preferences Preferences `json:"preferences"`
+	Categories  []string    `json:"categories"` // Every category that can be chosen
+}
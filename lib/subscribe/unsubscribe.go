@@ -0,0 +1,168 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"main/lib/analytics"
+	"main/lib/logger"
+	"main/lib/mail"
+	"main/lib/rss"
+	"net/url"
+	"time"
+)
+
+// Unsubscribe request sources, recorded in the audit trail
+const (
+	SourceOneClick = "one-click" // RFC 8058 POST sent by the mail client
+	SourcePage     = "page"      // Button on the /api/unsubscribe page
+	SourceProvider = "provider"  // The mail provider's own unsubscribe link in a broadcast
+)
+
+// UnsubscribeURL returns the signed, per-recipient unsubscribe link for email.
+// It never expires: it has to keep working in every email we've ever sent.
+// Send it with mail.UnsubscribeHeaders so mail clients offer one-click unsubscribe.
+func UnsubscribeURL(email string) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	token := SignToken(secret, PurposeUnsubscribe, NormalizeEmail(email), time.Time{})
+	return rss.BaseURL() + "/api/unsubscribe?token=" + url.QueryEscape(token), nil
+}
+
+// Unsubscribe verifies an unsubscribe token and opts the address out at once: the store
+// records it (with an audit event) before anything else, then the contact is marked
+// unsubscribed so broadcasts skip it. Repeating it is harmless. Returns the address, or
+// ErrInvalidToken for a bad link.
+func Unsubscribe(ctx context.Context, store Store, mailer mail.Mailer, token, source string) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	email, err := VerifyToken(secret, PurposeUnsubscribe, token, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	// 1. Record the opt-out (critical: this is what our own sends check)
+	sub, err := store.Unsubscribe(ctx, email, source, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	logger.Info("Unsubscribe recorded", map[string]interface{}{
+		"email":  email,
+		"source": source,
+	})
+
+	// 2. Flag the contact in its audiences so broadcasts skip it too
+	if err := unsubscribeContact(ctx, mailer, email, sub.Preferences); err != nil {
+		return "", err
+	}
+
+	_ = analytics.Track("email_unsubscribed", email, map[string]interface{}{
+		"source": source,
+	})
+	return email, nil
+}
+
+// SyncUnsubscribes records opt-outs made through the mail provider's own unsubscribe link
+// (Resend's, in broadcasts), which only flag the contact in that audience. A contact flagged
+// in the audience of a segment its subscriber still wants is unsubscribed from everything,
+// with a SourceProvider audit event. Returns how many subscribers were unsubscribed.
+func SyncUnsubscribes(ctx context.Context, store Store, mailer mail.Mailer) (int, error) {
+	synced := make(map[string]bool)
+	for _, segment := range Segments() {
+		audienceID := segment.AudienceID()
+		if audienceID == "" {
+			continue
+		}
+		contacts, err := mailer.ListContacts(ctx, audienceID)
+		if err != nil {
+			return len(synced), err
+		}
+
+		for _, contact := range contacts {
+			email := NormalizeEmail(contact.Email)
+			if !contact.Unsubscribed || synced[email] {
+				continue
+			}
+			sub, err := activeSubscriber(ctx, store, email)
+			if err != nil {
+				return len(synced), err
+			}
+			if sub == nil || !wantsSegment(sub.Preferences, segment) {
+				// Already opted out, or flagged by us when they left the segment
+				continue
+			}
+			if err := recordProviderUnsubscribe(ctx, store, mailer, sub); err != nil {
+				return len(synced), err
+			}
+			synced[email] = true
+		}
+	}
+	return len(synced), nil
+}
+
+// providerUnsubscribed reports whether sub is flagged unsubscribed in the audience of any
+// segment it wants, i.e. it opted out through the mail provider's link
+func providerUnsubscribed(ctx context.Context, mailer mail.Mailer, sub *Subscriber) (bool, error) {
+	for _, segment := range sub.Preferences.Segments() {
+		audienceID := segment.AudienceID()
+		if audienceID == "" {
+			continue
+		}
+		contacts, err := mailer.ListContacts(ctx, audienceID)
+		if err != nil {
+			return false, err
+		}
+		for _, contact := range contacts {
+			if contact.Unsubscribed && NormalizeEmail(contact.Email) == sub.Email {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// recordProviderUnsubscribe stores an opt-out made through the mail provider and flags
+// the contact in the audiences of its other segments
+func recordProviderUnsubscribe(ctx context.Context, store Store, mailer mail.Mailer, sub *Subscriber) error {
+	if _, err := store.Unsubscribe(ctx, sub.Email, SourceProvider, time.Now().UTC()); err != nil {
+		return err
+	}
+	logger.Info("Unsubscribe recorded", map[string]interface{}{
+		"email":  sub.Email,
+		"source": SourceProvider,
+	})
+	if err := unsubscribeContact(ctx, mailer, sub.Email, sub.Preferences); err != nil {
+		return err
+	}
+
+	_ = analytics.Track("email_unsubscribed", sub.Email, map[string]interface{}{
+		"source": SourceProvider,
+	})
+	return nil
+}
+
+// activeSubscriber returns the active subscriber for email, or nil if it isn't active.
+// Like LoadPreferences, an address without a record subscribed before records were kept.
+func activeSubscriber(ctx context.Context, store Store, email string) (*Subscriber, error) {
+	sub, err := store.Get(ctx, email)
+	if errors.Is(err, ErrSubscriberNotFound) {
+		return &Subscriber{Email: email, Status: StatusActive, Preferences: DefaultPreferences()}, nil
+	}
+	if err != nil || sub.Status != StatusActive {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// wantsSegment reports whether prefs put a subscriber in segment
+func wantsSegment(prefs Preferences, segment Segment) bool {
+	for _, wanted := range prefs.Segments() {
+		if wanted == segment {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"main/lib/mail"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestUnsubscribeURL(t *testing.T) {
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+
+	link, err := UnsubscribeURL(" Reader@Example.com ")
+	if err != nil {
+		t.Fatalf("UnsubscribeURL() error = %v", err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Path != "/api/unsubscribe" {
+		t.Fatalf("UnsubscribeURL() = %q, want an /api/unsubscribe link", link)
+	}
+
+	// Links in old emails must keep working, and only for unsubscribing
+	token := parsed.Query().Get("token")
+	email, err := VerifyToken([]byte("test-secret"), PurposeUnsubscribe, token, time.Now().AddDate(5, 0, 0))
+	if err != nil || email != "reader@example.com" {
+		t.Errorf("VerifyToken() = %q, %v; want reader@example.com", email, err)
+	}
+	if _, err := VerifyToken([]byte("test-secret"), PurposeConfirm, token, time.Now()); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unsubscribe token accepted for confirmation: %v", err)
+	}
+
+	headers := mail.UnsubscribeHeaders(link)
+	if headers["List-Unsubscribe"] != "<"+link+">" {
+		t.Errorf("List-Unsubscribe = %q", headers["List-Unsubscribe"])
+	}
+	if headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", headers["List-Unsubscribe-Post"])
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	t.Setenv("SUBSCRIBE_TOKEN_SECRET", "test-secret")
+	t.Setenv("RESEND_AUDIENCE_ID", "aud-tldr")
+	failing := mail.NewResend("") // Every call fails
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+	store.Confirm(ctx, "reader@example.com", now)
+
+	if _, err := Unsubscribe(ctx, store, failing, "not-a-token", SourcePage); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Unsubscribe() bad token error = %v, want ErrInvalidToken", err)
+	}
+	if events, _ := store.Events(ctx, "reader@example.com"); len(events) != 0 {
+		t.Fatalf("bad token recorded %d events", len(events))
+	}
+
+	// The opt-out is recorded before the mailer is reached, so it holds even when the mailer fails
+	token := SignToken([]byte("test-secret"), PurposeUnsubscribe, "reader@example.com", time.Time{})
+	if _, err := Unsubscribe(ctx, store, failing, token, SourceOneClick); err == nil {
+		t.Fatal("Unsubscribe() with a failing mailer should fail")
+	}
+	sub, err := store.Get(ctx, "reader@example.com")
+	if err != nil || sub.Status != StatusUnsubscribed || sub.UnsubscribedAt == nil {
+		t.Fatalf("subscriber after unsubscribe = %+v, %v", sub, err)
+	}
+
+	// Every request is audited, including repeats
+	store.Unsubscribe(ctx, "reader@example.com", SourcePage, now.Add(time.Minute))
+	events, _ := store.Events(ctx, "reader@example.com")
+	if len(events) != 2 || events[0].Source != SourceOneClick || events[1].Source != SourcePage || events[0].Type != EventUnsubscribed {
+		t.Errorf("Events() = %+v, want one-click then page unsubscribes", events)
+	}
+
+	// Subscribing again starts over with a pending confirmation
+	if sub, _ := store.AddPending(ctx, "reader@example.com", DefaultPreferences(), now); sub.Status != StatusPending {
+		t.Errorf("AddPending() after unsubscribe status = %q, want pending", sub.Status)
+	}
+}
+
+func TestSyncUnsubscribes(t *testing.T) {
+	t.Setenv("RESEND_AUDIENCE_ID", "aud-tldr")
+	t.Setenv("RESEND_DIGEST_AUDIENCE_ID", "aud-digest")
+	t.Setenv("RESEND_TLDR_WEEKLY_AUDIENCE_ID", "aud-tldr-weekly")
+	ctx := context.Background()
+	store := NewMemoryStore()
+	outbox := mail.NewOutbox("")
+	now := time.Now().UTC()
+
+	both := Preferences{Products: []string{ProductDigest, ProductTLDR}, Frequency: FrequencyDaily, TimeZone: "UTC"}
+	store.AddPending(ctx, "clicked@example.com", both, now)
+	store.Confirm(ctx, "clicked@example.com", now)
+	weekly := Preferences{Products: []string{ProductTLDR}, Frequency: FrequencyWeekly, TimeZone: "UTC"}
+	store.AddPending(ctx, "moved@example.com", weekly, now)
+	store.Confirm(ctx, "moved@example.com", now)
+
+	for _, contact := range []mail.Contact{
+		{Email: "clicked@example.com"},
+		{Email: "moved@example.com"},
+		{Email: "legacy@example.com"},
+		{Email: "reader@example.com"},
+	} {
+		outbox.UpsertContact(ctx, "aud-tldr", contact)
+	}
+	outbox.UpsertContact(ctx, "aud-digest", mail.Contact{Email: "clicked@example.com"})
+	outbox.UpsertContact(ctx, "aud-tldr-weekly", mail.Contact{Email: "moved@example.com"})
+
+	// Resend's link flags the contact in one audience; moving to weekly flagged the other
+	outbox.UpsertContact(ctx, "aud-digest", mail.Contact{Email: "Clicked@example.com", Unsubscribed: true})
+	outbox.UpsertContact(ctx, "aud-tldr", mail.Contact{Email: "moved@example.com", Unsubscribed: true})
+	outbox.UpsertContact(ctx, "aud-tldr", mail.Contact{Email: "legacy@example.com", Unsubscribed: true})
+
+	synced, err := SyncUnsubscribes(ctx, store, outbox)
+	if err != nil || synced != 2 {
+		t.Fatalf("SyncUnsubscribes() = %d, %v; want the clicked and legacy subscribers", synced, err)
+	}
+	for _, email := range []string{"clicked@example.com", "legacy@example.com"} {
+		sub, _ := store.Get(ctx, email)
+		events, _ := store.Events(ctx, email)
+		if sub == nil || sub.Status != StatusUnsubscribed || len(events) != 1 || events[0].Source != SourceProvider {
+			t.Errorf("%s after sync = %+v with events %+v; want unsubscribed by the provider", email, sub, events)
+		}
+	}
+	if sub, _ := store.Get(ctx, "moved@example.com"); sub.Status != StatusActive {
+		t.Errorf("moved@example.com status = %q; a segment it left isn't an unsubscribe", sub.Status)
+	}
+
+	// The opt-out covers every audience, so no broadcast reaches the address
+	contacts, _ := outbox.ListContacts(ctx, "aud-tldr")
+	for _, contact := range contacts {
+		if contact.Email == "clicked@example.com" && !contact.Unsubscribed {
+			t.Error("clicked@example.com is still subscribed to the TLDR audience")
+		}
+	}
+
+	// Nothing new on the next run
+	if synced, err := SyncUnsubscribes(ctx, store, outbox); err != nil || synced != 0 {
+		t.Errorf("second SyncUnsubscribes() = %d, %v; want 0", synced, err)
+	}
+}
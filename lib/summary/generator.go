@@ -9,13 +9,13 @@ import (
 	"io"
 	"log/slog"
 	"main/lib/logger"
-	"math"
+	"main/lib/search"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gomarkdown/markdown/ast"
@@ -239,13 +239,6 @@ func isRetryableValidationError(err error) bool {
 
 // summarizeWithLLMAttempt performs a single LLM summarization attempt
 func summarizeWithLLMAttempt(ctx context.Context, markdownContent string, feedURLs map[string]string, attempt int) (string, error) {
-	apiURL := openAPIURL
-	apiKey := os.Getenv("OPENAI_API_KEY")
-
-	if apiKey == "" {
-		return "", fmt.Errorf("OPENAI_API_KEY environment variable is not set")
-	}
-
 	// Construct the exact prompt as requested
 	basePrompt := `Create a brief morning briefing on these AI research papers, written in a conversational style for busy professionals. Focus on what's new and what it means for businesses and society.
 Format the output in markdown:
@@ -281,6 +274,41 @@ Below are the paper abstracts and information in markdown format:`
 
 	promptText := basePrompt + markdownContent
 
+	markdownSummary, err := requestOpenAI(ctx, promptText)
+	if err != nil {
+		return "", err
+	}
+
+	// Sanitize any raw URLs and programmatically inject links from the feed
+	sanitized := sanitizeSummaryMarkdown(markdownSummary)
+	// Apply a conservative headline length clamp to avoid occasional LLM overflow
+	sanitized = enforceHeadlineLength(sanitized, maxHeadlineLength)
+	linkedMarkdown := replacePlaceholdersWithLinks(sanitized, feedURLs)
+
+	// Validate the linked summary content
+	if err := validateSummaryContent(linkedMarkdown, feedURLs); err != nil {
+		slog.Error("LLM summary validation failed",
+			"error", err,
+			"summary", linkedMarkdown)
+		return "", fmt.Errorf("LLM summary validation failed: %w", err)
+	}
+
+	slog.Info("Successfully validated LLM summary",
+		"summary_length", len(linkedMarkdown),
+		"link_count", len(regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`).FindAllString(linkedMarkdown, -1)))
+
+	return linkedMarkdown, nil
+}
+
+// requestOpenAI sends a single-message prompt to the OpenAI Responses API and returns the text of the reply
+func requestOpenAI(ctx context.Context, promptText string) (string, error) {
+	apiURL := openAPIURL
+	apiKey := os.Getenv("OPENAI_API_KEY")
+
+	if apiKey == "" {
+		return "", fmt.Errorf("OPENAI_API_KEY environment variable is not set")
+	}
+
 	// Construct the OpenAI request body
 	request := OpenAIRequest{
 		Model: openAIModel,
@@ -368,28 +396,7 @@ Below are the paper abstracts and information in markdown format:`
 		return "", fmt.Errorf("invalid or empty response structure from OpenAI API")
 	}
 
-	// Extract the markdown text directly from the nested path
-	markdownSummary := openAIResp.Output[0].Content[0].Text
-
-	// Sanitize any raw URLs and programmatically inject links from the feed
-	sanitized := sanitizeSummaryMarkdown(markdownSummary)
-	// Apply a conservative headline length clamp to avoid occasional LLM overflow
-	sanitized = enforceHeadlineLength(sanitized, maxHeadlineLength)
-	linkedMarkdown := replacePlaceholdersWithLinks(sanitized, feedURLs)
-
-	// Validate the linked summary content
-	if err := validateSummaryContent(linkedMarkdown, feedURLs); err != nil {
-		slog.Error("LLM summary validation failed",
-			"error", err,
-			"summary", linkedMarkdown)
-		return "", fmt.Errorf("LLM summary validation failed: %w", err)
-	}
-
-	slog.Info("Successfully validated LLM summary",
-		"summary_length", len(linkedMarkdown),
-		"link_count", len(regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`).FindAllString(linkedMarkdown, -1)))
-
-	return linkedMarkdown, nil
+	return openAIResp.Output[0].Content[0].Text, nil
 }
 
 // sanitizeSummaryMarkdown removes raw URLs that the LLM may include
@@ -594,114 +601,50 @@ func DefaultBM25Config() BM25Config {
 	}
 }
 
-// BM25 holds the corpus and scoring parameters for BM25 ranking
+// BM25 matches summary placeholders to paper titles: a lib/search title index scored
+// with the classic IDF, so words shared by most titles count against a match, plus the
+// thresholds in Config
 type BM25 struct {
-	Docs      [][]string        // Tokenized documents
-	DocFreq   map[string]int    // Term frequency across all documents
-	AvgDocLen float64           // Average document length
-	Config    BM25Config        // BM25 scoring configuration
-	Titles    map[string]string // docID -> original title
-	URLs      map[string]string // docID -> URL
+	Index  *search.Index
+	Config BM25Config
+	Titles map[string]string // docID -> original title
+	URLs   map[string]string // docID -> URL
 }
 
 // NewBM25 creates a new BM25 instance from paper titles with proper normalization
 func NewBM25(links map[string]string) *BM25 {
-	logger.Debug("Building BM25 index", map[string]interface{}{
-		"num_titles": len(links),
-	})
-
-	// Pre-allocate slices and maps for better performance
-	docs := make([][]string, 0, len(links))
-	titles := make(map[string]string)
-	urls := make(map[string]string)
-
-	// Process documents in parallel for better performance with large feeds
-	type docResult struct {
-		docID   int
-		title   string
-		url     string
-		tokens  []string
-		tokenLen int
-		df      map[string]int
-	}
-
-	results := make(chan docResult, len(links))
-	var wg sync.WaitGroup
-
-	// Process each document in parallel
-	docID := 0
-	for title, url := range links {
-		wg.Add(1)
-		go func(id int, t, u string) {
-			defer wg.Done()
-
-			// Use the same normalization for documents as queries
-			tokens := normalizeText(t)
-
-			// Build document frequency for this document only
-			localDF := make(map[string]int)
-			seen := make(map[string]bool)
-			for _, token := range tokens {
-				if !seen[token] {
-					localDF[token] = 1
-					seen[token] = true
-				}
-			}
-
-			results <- docResult{
-				docID:    id,
-				title:    t,
-				url:      u,
-				tokens:   tokens,
-				tokenLen: len(tokens),
-				df:       localDF,
-			}
-		}(docID, title, url)
-		docID++
+	config := DefaultBM25Config()
+	bm25 := &BM25{
+		Index: search.NewIndex(search.Config{
+			K1:       config.K1,
+			B:        config.B,
+			Tokenize: normalizeText,
+			IDF:      search.ClassicIDF,
+		}),
+		Config: config,
+		Titles: make(map[string]string, len(links)),
+		URLs:   make(map[string]string, len(links)),
 	}
 
-	// Close results channel when all goroutines complete
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Collect results and merge document frequencies
-	var totalLen float64
-	globalDF := make(map[string]int)
-
-	for result := range results {
-		titles[strconv.Itoa(result.docID)] = result.title
-		urls[strconv.Itoa(result.docID)] = result.url
-		docs = append(docs, result.tokens)
-		totalLen += float64(result.tokenLen)
-
-		// Merge document frequencies
-		for token, count := range result.df {
-			globalDF[token] += count
-		}
+	// Number titles in sorted order so ties break the same way on every run
+	titles := make([]string, 0, len(links))
+	for title := range links {
+		titles = append(titles, title)
 	}
+	sort.Strings(titles)
 
-	avgDocLen := totalLen / float64(len(docs))
-	if len(docs) == 0 {
-		avgDocLen = 0
+	for i, title := range titles {
+		docID := strconv.Itoa(i)
+		bm25.Titles[docID] = title
+		bm25.URLs[docID] = links[title]
+		bm25.Index.Add(search.Document{ID: docID, Fields: map[string]string{"title": title}})
 	}
 
 	logger.Debug("BM25 index built", map[string]interface{}{
-		"num_docs":     len(docs),
-		"avg_doc_len":  avgDocLen,
-		"total_tokens": int(totalLen),
-		"vocab_size":   len(globalDF),
+		"num_docs":    bm25.Index.Len(),
+		"avg_doc_len": bm25.Index.AvgDocLen(),
 	})
-
-	return &BM25{
-		Docs:      docs,
-		DocFreq:   globalDF,
-		AvgDocLen: avgDocLen,
-		Config:    DefaultBM25Config(),
-		Titles:    titles,
-		URLs:      urls,
-	}
+	return bm25
 }
 
 // normalizeText applies comprehensive text normalization for BM25
@@ -727,11 +670,6 @@ func normalizeText(text string) []string {
 	return tokens
 }
 
-// tokenize splits text into lowercase tokens (legacy function, use normalizeText instead)
-func tokenize(text string) []string {
-	return normalizeText(text)
-}
-
 // removeStopwords removes common English stopwords
 func removeStopwords(tokens []string) []string {
 	stopwords := map[string]bool{
@@ -754,335 +692,118 @@ func removeStopwords(tokens []string) []string {
 	return filtered
 }
 
-// IDF calculates inverse document frequency for a term
-func (bm *BM25) IDF(term string) float64 {
-	N := float64(len(bm.Docs))
-	n := float64(bm.DocFreq[term])
-	if n == 0 {
-		return 0
-	}
-	return math.Log((N - n + 0.5) / (n + 0.5))
+// findMatchingURL uses BM25 to find the best matching URL for a title
+func findMatchingURL(title string, links map[string]string) string {
+	bm25 := NewBM25(links)
+	return findMatchingURLWithBM25(title, bm25)
 }
 
-// Score calculates BM25 score for a document against a query
-func (bm *BM25) Score(doc []string, query []string) float64 {
-	score := 0.0
-	termFreq := make(map[string]int)
-	for _, token := range doc {
-		termFreq[token]++
-	}
-	docLen := float64(len(doc))
-
-	for _, qTerm := range query {
-		f := float64(termFreq[qTerm])
-		idf := bm.IDF(qTerm)
-		score += idf * ((f * (bm.Config.K1 + 1)) / (f + bm.Config.K1*(1-bm.Config.B+bm.Config.B*docLen/bm.AvgDocLen)))
+// minScore is the score a match must beat: shorter titles share fewer terms with their paper
+func (bm *BM25) minScore(titleLength int) float64 {
+	if titleLength < maxTitleLengthForShort {
+		return bm.Config.ShortTitleMinScore
+	} else if titleLength < maxTitleLengthForMedium {
+		return bm.Config.MediumTitleMinScore
 	}
-
-	return score
+	return bm.Config.MinScore
 }
 
-// Rank returns BM25 scores for all documents against a query
-func (bm *BM25) Rank(query string) []float64 {
-	qTerms := tokenize(query)
-	scores := make([]float64, len(bm.Docs))
-	for i, doc := range bm.Docs {
-		scores[i] = bm.Score(doc, qTerms)
+// onlyCommonTerms reports whether every query term appears in so many titles (over 80%,
+// or 90% for short titles) that a match on them means nothing
+func (bm *BM25) onlyCommonTerms(queryTerms []string) bool {
+	ratio := bm.Config.CommonTermThreshold
+	if len(queryTerms) < maxTitleLengthForShort {
+		ratio = bm.Config.ShortTitleCommonTermThreshold
+	}
+	threshold := int(float64(bm.Index.Len()) * ratio)
+	for _, term := range queryTerms {
+		if bm.Index.DocFreq(term) <= threshold {
+			return false
+		}
 	}
-	return scores
+	return true
 }
 
-// RankTokens returns BM25 scores for all documents against pre-normalized query tokens
-func (bm *BM25) RankTokens(queryTokens []string) []float64 {
-	scores := make([]float64, len(bm.Docs))
-	for i, doc := range bm.Docs {
-		scores[i] = bm.Score(doc, queryTokens)
+// substringMatch is the fallback for very short titles (acronyms): the first paper title
+// that contains the title, or is contained in it, and is similar enough in length
+func (bm *BM25) substringMatch(title string) (string, float64) {
+	titleLower := strings.ToLower(title)
+	for i := 0; i < len(bm.Titles); i++ {
+		docID := strconv.Itoa(i)
+		docTitle := strings.ToLower(bm.Titles[docID])
+
+		similarity := 0.0
+		if strings.Contains(docTitle, titleLower) {
+			similarity = float64(len(titleLower)) / float64(len(docTitle))
+		} else if strings.Contains(titleLower, docTitle) {
+			similarity = float64(len(docTitle)) / float64(len(titleLower))
+		}
+		if similarity > bm.Config.FallbackSimilarityThreshold {
+			return docID, similarity
+		}
 	}
-	return scores
-}
-
-// findMatchingURL uses BM25 to find the best matching URL for a title
-func findMatchingURL(title string, links map[string]string) string {
-	bm25 := NewBM25(links)
-	return findMatchingURLWithBM25(title, bm25)
+	return "", 0
 }
 
 // findMatchingURLWithBM25 uses a pre-built BM25 instance to find the best matching URL for a title
 func findMatchingURLWithBM25(title string, bm25 *BM25) string {
-	if len(bm25.Titles) == 0 {
+	if bm25.Index.Len() == 0 {
 		logger.Debug("No links provided for title matching", map[string]interface{}{
 			"title": title,
 		})
 		return ""
 	}
 
-	logger.Debug("Starting BM25 title matching", map[string]interface{}{
-		"title":     title,
-		"num_docs":  len(bm25.Titles),
-	})
-
-	// Normalize query once and reuse
 	queryTerms := normalizeText(title)
 	titleLength := len(queryTerms)
+	threshold := bm25.minScore(titleLength)
 
-	// Log query normalization details (only for the query, not corpus)
 	logger.Debug("Query normalization", map[string]interface{}{
 		"originalTitle":    title,
 		"normalizedTokens": queryTerms,
 		"tokenCount":       titleLength,
+		"threshold":        threshold,
 	})
 
-	// Use pre-normalized tokens to avoid double normalization
-	scores := bm25.RankTokens(queryTerms)
-
-	var candidates []map[string]interface{}
-
-	// Find best scoring document with detailed logging
-
-	logger.Debug("Query analysis", map[string]interface{}{
-		"title": title,
-		"queryTerms": queryTerms,
-		"titleLength": titleLength,
-		"avgDocLen": bm25.AvgDocLen,
-		"totalDocs": len(bm25.Docs),
-		"vocabSize": len(bm25.DocFreq),
-		"bm25Params": map[string]float64{
-			"k1": bm25.Config.K1,
-			"b":  bm25.Config.B,
-		},
-	})
-
-	// Process candidates in parallel for better performance with large document sets
-	type candidateResult struct {
-		index int
-		candidate map[string]interface{}
-	}
-
-	candidateChan := make(chan candidateResult, len(scores))
-	var wg sync.WaitGroup
-
-	for i, score := range scores {
-		wg.Add(1)
-		go func(idx int, documentScore float64) {
-			defer wg.Done()
-
-			docID := strconv.Itoa(idx)
-			docTitle := bm25.Titles[docID]
-			docTokens := bm25.Docs[idx]
-
-			// Calculate detailed matching info
-			termMatches := make(map[string]float64)
-			for _, qTerm := range queryTerms {
-				idf := bm25.IDF(qTerm)
-				tf := 0
-				for _, token := range docTokens {
-					if token == qTerm {
-						tf++
-					}
-				}
-				if tf > 0 {
-					termMatches[qTerm] = float64(tf) * idf
-				}
-			}
-
-			candidate := map[string]interface{}{
-				"docID":      docID,
-				"title":      docTitle,
-				"url":        bm25.URLs[docID],
-				"score":      documentScore,
-				"termMatches": termMatches,
-				"docLength":  len(docTokens),
-			}
-
-			candidateChan <- candidateResult{index: idx, candidate: candidate}
-		}(i, score)
-	}
-
-	// Close channel when all goroutines complete
-	go func() {
-		wg.Wait()
-		close(candidateChan)
-	}()
-
-	// Collect results in original order
-	candidates = make([]map[string]interface{}, len(scores))
-	for result := range candidateChan {
-		candidates[result.index] = result.candidate
-	}
-
-	// Filter candidates and find best match
-	var finalBestScore float64 = -1
-	var finalBestDocID string
-
-	for _, candidate := range candidates {
-		docID := candidate["docID"].(string)
-		docTitle := candidate["title"].(string)
-		score := candidate["score"].(float64)
-
-		// Skip very common terms (appears in >80% of docs) for longer titles only
-		shouldSkip := true
-		for _, qTerm := range queryTerms {
-			// For very short titles (< 3 tokens), be less strict about common terms
-			threshold := int(float64(len(bm25.Docs)) * bm25.Config.CommonTermThreshold)
-			if titleLength < maxTitleLengthForShort {
-				threshold = int(float64(len(bm25.Docs)) * bm25.Config.ShortTitleCommonTermThreshold)
-			}
-			if bm25.DocFreq[qTerm] <= threshold {
-				shouldSkip = false
-				break
-			}
-		}
-
-		if shouldSkip {
-			logger.Debug("Skipping document due to common terms", map[string]interface{}{
-				"docID": docID,
-				"title": docTitle,
-				"commonTerms": func() []string {
-					var common []string
-					for _, qTerm := range queryTerms {
-						if bm25.DocFreq[qTerm] > len(bm25.Docs)*8/10 {
-							common = append(common, qTerm)
-						}
-					}
-					return common
-				}(),
-			})
-			continue
-		}
-
-		// Use different thresholds based on title length
-		threshold := bm25.Config.MinScore
-		if titleLength < maxTitleLengthForShort {
-			threshold = bm25.Config.ShortTitleMinScore
-		} else if titleLength < maxTitleLengthForMedium {
-			threshold = bm25.Config.MediumTitleMinScore
-		}
-
-		logger.Debug("Evaluating candidate", map[string]interface{}{
-			"docID":     docID,
-			"title":     docTitle,
-			"score":     score,
-			"threshold": threshold,
-		})
-
-		if score > finalBestScore && score > threshold {
-			finalBestScore = score
-			finalBestDocID = docID
-		}
-	}
-
-	// Special fallback for very short titles (acronyms) - if no good BM25 match,
-	// try simple substring matching as a last resort
-	if finalBestDocID == "" && titleLength <= 2 && titleLength > 0 {
-		logger.Debug("Trying fallback substring matching for short title", map[string]interface{}{
-			"title": title,
-			"titleLength": titleLength,
+	if bm25.onlyCommonTerms(queryTerms) {
+		logger.Debug("Skipping BM25 match due to common terms", map[string]interface{}{
+			"title":      title,
+			"queryTerms": queryTerms,
 		})
-
-		titleLower := strings.ToLower(title)
-		for i := range scores {
-			docID := strconv.Itoa(i)
-			docTitle := strings.ToLower(bm25.Titles[docID])
-
-			// Try substring matching for very short titles
-			if strings.Contains(docTitle, titleLower) || strings.Contains(titleLower, docTitle) {
-				// Calculate a simple similarity score
-				similarity := 0.0
-				if strings.Contains(docTitle, titleLower) {
-					similarity = float64(len(titleLower)) / float64(len(docTitle))
-				} else {
-					similarity = float64(len(docTitle)) / float64(len(titleLower))
-				}
-
-				if similarity > bm25.Config.FallbackSimilarityThreshold {
-					finalBestScore = similarity
-					finalBestDocID = docID
-					logger.Debug("Fallback substring match for short title", map[string]interface{}{
-						"searchTitle":  title,
-						"matchedTitle": bm25.Titles[docID],
-						"similarity":   similarity,
-					})
-					break
-				}
-			}
-		}
-	}
-
-	// Always log all candidates for debugging (moved before match check)
-	logger.Debug("All BM25 candidates", map[string]interface{}{
-		"searchTitle": title,
-		"candidates":  candidates,
-		"bestScore":   finalBestScore,
-		"bestDocID":   finalBestDocID,
-	})
-
-	if finalBestDocID != "" {
-		matchedTitle := bm25.Titles[finalBestDocID]
-		bestURL := bm25.URLs[finalBestDocID]
-
-		// Calculate what threshold was used
-		usedThreshold := bm25.Config.MinScore
-		if titleLength < maxTitleLengthForShort {
-			usedThreshold = bm25.Config.ShortTitleMinScore
-		} else if titleLength < maxTitleLengthForMedium {
-			usedThreshold = bm25.Config.MediumTitleMinScore
-		}
-
+	} else if hits := bm25.Index.Search(title, 1); len(hits) > 0 && hits[0].Score > threshold {
 		logger.Info("BM25 title match found", map[string]interface{}{
 			"searchTitle":   title,
-			"matchedTitle":  matchedTitle,
-			"bestScore":     finalBestScore,
-			"usedThreshold": usedThreshold,
+			"matchedTitle":  bm25.Titles[hits[0].ID],
+			"bestScore":     hits[0].Score,
+			"usedThreshold": threshold,
 			"titleLength":   titleLength,
-			"numCandidates": len(candidates),
 		})
-
-		return bestURL
+		return bm25.URLs[hits[0].ID]
 	}
 
-	// No match found - calculate what threshold was used for logging
-	usedThreshold := bm25.Config.MinScore
-	if titleLength < maxTitleLengthForShort {
-		usedThreshold = bm25.Config.ShortTitleMinScore
-	} else if titleLength < maxTitleLengthForMedium {
-		usedThreshold = bm25.Config.MediumTitleMinScore
+	// Special fallback for very short titles (acronyms) - if no good BM25 match,
+	// try simple substring matching as a last resort
+	if titleLength <= 2 && titleLength > 0 {
+		if docID, similarity := bm25.substringMatch(title); docID != "" {
+			logger.Debug("Fallback substring match for short title", map[string]interface{}{
+				"searchTitle":  title,
+				"matchedTitle": bm25.Titles[docID],
+				"similarity":   similarity,
+			})
+			return bm25.URLs[docID]
+		}
 	}
 
 	logger.Warn("No BM25 title match found", map[string]interface{}{
 		"searchTitle":   title,
-		"bestScore":     finalBestScore,
-		"usedThreshold": usedThreshold,
+		"usedThreshold": threshold,
 		"titleLength":   titleLength,
-		"numCandidates": len(candidates),
-		"topCandidates": getTopCandidates(candidates, 5), // Show top 5 for analysis
+		"numCandidates": bm25.Index.Len(),
+		"topCandidates": bm25.Index.Search(title, 5), // Show top 5 for analysis
 	})
-
 	return ""
 }
 
-// getTopCandidates returns the top N candidates sorted by score for analysis
-func getTopCandidates(candidates []map[string]interface{}, n int) []map[string]interface{} {
-	if len(candidates) <= n {
-		return candidates
-	}
-
-	// Sort candidates by score (descending)
-	sorted := make([]map[string]interface{}, len(candidates))
-	copy(sorted, candidates)
-
-	for i := 0; i < len(sorted)-1; i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			scoreI := sorted[i]["score"].(float64)
-			scoreJ := sorted[j]["score"].(float64)
-			if scoreJ > scoreI {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
-		}
-	}
-
-	return sorted[:n]
-}
-
 // createValidationError creates a standardized ValidationError
 func createValidationError(field, message, details string, severity ValidationSeverity) ValidationError {
 	return ValidationError{
@@ -107,6 +107,37 @@ func TestGeneratePapersRSS_TLDRLinks(t *testing.T) {
 	}
 }
 
+func TestFindMatchingURL(t *testing.T) {
+	links := map[string]string{
+		"Attention Is All You Need":                          "https://huggingface.co/papers/1706.03762",
+		"Scaling Laws for Neural Language Models":            "https://huggingface.co/papers/2001.08361",
+		"Deep Residual Learning for Image Recognition":       "https://huggingface.co/papers/1512.03385",
+		"Language Models are Few-Shot Learners":              "https://huggingface.co/papers/2005.14165",
+		"LoRA: Low-Rank Adaptation of Large Language Models": "https://huggingface.co/papers/2106.09685",
+	}
+	bm25 := NewBM25(links)
+
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{"Scaling Laws for Neural Language Models", "https://huggingface.co/papers/2001.08361"},
+		{"deep residual learning", "https://huggingface.co/papers/1512.03385"},
+		{"Few-Shot Learners", "https://huggingface.co/papers/2005.14165"},
+		{"LoRA", "https://huggingface.co/papers/2106.09685"},
+		{"Quantum Chemistry Benchmarks", ""},
+	}
+	for _, tt := range tests {
+		if got := findMatchingURLWithBM25(tt.title, bm25); got != tt.expected {
+			t.Errorf("findMatchingURLWithBM25(%q) = %q; expected %q", tt.title, got, tt.expected)
+		}
+	}
+
+	if got := findMatchingURL("Attention", map[string]string{}); got != "" {
+		t.Errorf("findMatchingURL() with no links = %q; expected no match", got)
+	}
+}
+
 func TestIsBlobCacheDisabled(t *testing.T) {
 	tests := []struct {
 		name     string
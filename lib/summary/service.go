@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"main/lib/analytics"
+	"main/lib/pipeline"
 	"os"
 	"regexp"
 	"strings"
@@ -294,41 +295,81 @@ func (s *Service) forceGeneratePapersRaw(ctx context.Context, requestURL string)
 	}, nil
 }
 
+// UpdateCachePipeline is the run ledger name for UpdateCache
+const UpdateCachePipeline = "update-cache"
+
 // UpdateCache forces a refresh of both papers and summary caches (like rss_old.go updateAllCaches)
-func (s *Service) UpdateCache(ctx context.Context, requestURL string) error {
+// runs records each stage so a failed update can be resumed; nil keeps the record in memory only
+func (s *Service) UpdateCache(ctx context.Context, runs *pipeline.Runner, requestURL string) (*pipeline.Run, error) {
 	s.logger.Info("Starting comprehensive cache update for papers and summary")
-
-	// 1. Generate fresh papers data (force bypass cache)
-	s.logger.Info("Generating fresh papers data")
-	papersData, err := s.forceGeneratePapersRaw(ctx, requestURL)
-	if err != nil {
-		return fmt.Errorf("failed to generate fresh papers data: %w", err)
-	}
-
-	// 2. Store papers in cache (this will be skipped if DISABLE_BLOB_CACHE is true)
-	if err := StorePapers(papersData.Data); err != nil {
-		s.logger.Warn("Failed to store papers in cache", "error", err)
-		// Continue with summary generation even if papers cache fails
-	} else {
-		s.logger.Info("Successfully updated papers cache")
+	if runs == nil {
+		runs = pipeline.NewRunner(nil)
 	}
 
-	// 3. Generate summary from the fresh papers data
-	s.logger.Info("Generating summary from fresh papers data")
-	summaryData, err := s.GenerateSummaryFromRSS(ctx, papersData.Data, requestURL)
+	run, err := runs.Start(ctx, UpdateCachePipeline, map[string]string{"requestURL": requestURL}, s.UpdateCacheStages(requestURL))
 	if err != nil {
-		return fmt.Errorf("failed to generate summary from fresh papers: %w", err)
+		return run, err
 	}
 
-	// 4. Store summary in cache (this will be skipped if DISABLE_BLOB_CACHE is true)
-	if err := StoreSummary(summaryData); err != nil {
-		s.logger.Warn("Failed to store summary in cache", "error", err)
-		// Continue - at least we generated fresh data
-	} else {
-		s.logger.Info("Successfully updated summary cache")
-	}
-
-	s.logger.Info("Comprehensive cache update completed successfully")
+	s.logger.Info("Comprehensive cache update completed successfully", "run_id", run.ID)
 	_ = analytics.Track("cache_updated", "update", map[string]interface{}{"type": "comprehensive"})
-	return nil
+	return run, nil
+}
+
+// UpdateCacheStages splits the cache update into resumable stages:
+// scrape-papers -> store-papers -> summarize -> store-summary
+// Each stage passes RSS bytes on; the store stages are optional and pass their input through
+func (s *Service) UpdateCacheStages(requestURL string) []pipeline.Stage {
+	return []pipeline.Stage{
+		{
+			Name:    "scrape-papers",
+			Retries: 1,
+			Run: func(ctx context.Context, _ []byte) ([]byte, error) {
+				// Generate fresh papers data (force bypass cache)
+				papersData, err := s.forceGeneratePapersRaw(ctx, requestURL)
+				if err != nil {
+					return nil, fmt.Errorf("failed to generate fresh papers data: %w", err)
+				}
+				return papersData.Data, nil
+			},
+		},
+		{
+			// Skipped when DISABLE_BLOB_CACHE is true; summary generation continues even if this fails
+			Name:     "store-papers",
+			Optional: true,
+			Run: func(ctx context.Context, papersRSS []byte) ([]byte, error) {
+				if err := StorePapers(papersRSS); err != nil {
+					s.logger.Warn("Failed to store papers in cache", "error", err)
+					return nil, err
+				}
+				s.logger.Info("Successfully updated papers cache")
+				return papersRSS, nil
+			},
+		},
+		{
+			// summarizeWithLLM already retries invalid output, so no stage-level retries
+			Name: "summarize",
+			Run: func(ctx context.Context, papersRSS []byte) ([]byte, error) {
+				s.logger.Info("Generating summary from fresh papers data")
+				summaryData, err := s.GenerateSummaryFromRSS(ctx, papersRSS, requestURL)
+				if err != nil {
+					return nil, fmt.Errorf("failed to generate summary from fresh papers: %w", err)
+				}
+				return summaryData, nil
+			},
+		},
+		{
+			// Skipped when DISABLE_BLOB_CACHE is true; at least we generated fresh data
+			Name:     "store-summary",
+			Optional: true,
+			Run: func(ctx context.Context, summaryRSS []byte) ([]byte, error) {
+				if err := StoreSummary(summaryRSS); err != nil {
+					s.logger.Warn("Failed to store summary in cache", "error", err)
+					return nil, err
+				}
+				s.logger.Info("Successfully updated summary cache")
+				return summaryRSS, nil
+			},
+		},
+	}
 }
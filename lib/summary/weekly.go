@@ -0,0 +1,51 @@
+package summary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// WeeklyPaper is a paper picked for the weekly roundup, as the headline prompt sees it
+type WeeklyPaper struct {
+	Title   string
+	Summary string
+}
+
+// GenerateWeeklyHeadline asks the LLM for a one-sentence headline over the week's top papers,
+// most significant first. Replies longer than the daily Morning Headline limit are rejected.
+func GenerateWeeklyHeadline(ctx context.Context, papers []WeeklyPaper) (string, error) {
+	if len(papers) == 0 {
+		return "", fmt.Errorf("no papers to create a weekly headline from")
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(`Write the headline for a weekly roundup of AI research papers, for busy professionals.
+One sentence, 15 words or less, capturing the week's main theme rather than a single paper.
+Return only the headline: no markdown, no quotes, no URLs.
+
+This week's top papers, most significant first:
+`)
+	for i, paper := range papers {
+		fmt.Fprintf(&prompt, "%d. %s\n", i+1, paper.Title)
+		if paper.Summary != "" {
+			fmt.Fprintf(&prompt, "   %s\n", paper.Summary)
+		}
+	}
+
+	reply, err := requestOpenAI(ctx, prompt.String())
+	if err != nil {
+		return "", err
+	}
+
+	headline := strings.TrimSpace(sanitizeSummaryMarkdown(reply))
+	headline = strings.TrimLeft(headline, "# ")
+	headline = strings.Trim(headline, "\"'“”*_ ")
+	if headline == "" {
+		return "", fmt.Errorf("LLM returned an empty weekly headline")
+	}
+	if strings.Contains(headline, "\n") || len([]rune(headline)) > maxHeadlineLength {
+		return "", fmt.Errorf("LLM weekly headline is not a single short sentence: %q", headline)
+	}
+	return headline, nil
+}
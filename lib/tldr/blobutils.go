@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"main/lib/rss"
 	"net/http"
 	"os"
 	"time"
@@ -135,7 +136,7 @@ func GetTldrFeedURL(date string) string {
 
 // GetTldrFeed fetches a specific feed by date from blob storage.
 // Optimized: constructs URL directly instead of calling expensive list API.
-func GetTldrFeed(date string) (*RssFeed, error) {
+func GetTldrFeed(date string) (*rss.RssFeed, error) {
 	blobURL := GetTldrFeedURL(date)
 
 	resp, err := http.Get(blobURL)
@@ -151,7 +152,7 @@ func GetTldrFeed(date string) (*RssFeed, error) {
 		return nil, fmt.Errorf("non-200 status when fetching feed blob for date %s: %s", date, resp.Status)
 	}
 
-	var feed RssFeed
+	var feed rss.RssFeed
 	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
 		return nil, fmt.Errorf("failed to decode feed content for date %s: %w", date, err)
 	}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"main/lib/logger"
+	"main/lib/paper"
+)
+
+func main() {
+	// Initialize environment (load .env if available)
+	err := godotenv.Load()
+	if err != nil {
+		logger.Warn("Error loading .env file", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	source := flag.String("source", "blob", "where to read papers from: blob (papers/ cache) or hf (daily papers history)")
+	since := flag.String("since", "", "hf only: oldest date to backfill (YYYY-MM-DD, default 30 days ago)")
+	until := flag.String("until", "", "hf only: newest date to backfill (YYYY-MM-DD, default today)")
+	checkpointPath := flag.String("checkpoint", ".backfill-checkpoint.json", "checkpoint file for resuming (empty to disable)")
+	limit := flag.Int("limit", 0, "stop after this many papers (0 for no limit)")
+	flag.Parse()
+
+	var backfillSource paper.BackfillSource
+	switch *source {
+	case "blob":
+		backfillSource, err = paper.NewBlobPaperSource()
+		if err != nil {
+			log.Fatalf("Failed to create blob source: %v", err)
+		}
+	case "hf":
+		untilDate := time.Now().UTC()
+		if *until != "" {
+			untilDate, err = time.Parse("2006-01-02", *until)
+			if err != nil {
+				log.Fatalf("Invalid -until date: %v", err)
+			}
+		}
+		sinceDate := untilDate.AddDate(0, 0, -30)
+		if *since != "" {
+			sinceDate, err = time.Parse("2006-01-02", *since)
+			if err != nil {
+				log.Fatalf("Invalid -since date: %v", err)
+			}
+		}
+		backfillSource = paper.NewDailyPapersSource(sinceDate, untilDate)
+	default:
+		log.Fatalf("Unknown source %q (use blob or hf)", *source)
+	}
+
+	// Stop cleanly on Ctrl-C; progress is checkpointed after every page
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cache := paper.GetVectorDBCache()
+	if !paper.IsDBEnabled() {
+		log.Fatalf("Vector database is not available (check VECTOR_DB_DATABASE_URL)")
+	}
+
+	service, err := paper.GetEmbeddingService()
+	if err != nil {
+		log.Fatalf("Failed to create embedding service: %v", err)
+	}
+
+	logger.Info("Starting embedding backfill", map[string]interface{}{
+		"source":     backfillSource.Name(),
+		"model":      cache.Model().String(),
+		"checkpoint": *checkpointPath,
+	})
+
+	checkpoint, err := paper.RunBackfill(ctx, paper.BackfillOptions{
+		Source:         backfillSource,
+		Cache:          cache,
+		Service:        service,
+		CheckpointPath: *checkpointPath,
+		Limit:          *limit,
+		OnProgress: func(p paper.BackfillProgress) {
+			fmt.Printf("seen=%d embedded=%d existing=%d failed=%d elapsed=%s rate=%.1f papers/s cursor=%q\n",
+				p.Checkpoint.Seen, p.Checkpoint.Embedded, p.Checkpoint.Existing, p.Checkpoint.Failed,
+				p.Elapsed.Round(time.Second), p.Rate, p.Checkpoint.Cursor)
+		},
+	})
+	if err != nil {
+		if checkpoint != nil && *checkpointPath != "" {
+			log.Printf("Backfill stopped; re-run to resume from %s", *checkpointPath)
+		}
+		log.Fatalf("Backfill failed: %v", err)
+	}
+
+	status := "complete"
+	switch {
+	case !checkpoint.Exhausted:
+		status = "paused (limit reached)"
+	case !checkpoint.Done:
+		status = "incomplete (re-run to retry the failed papers)"
+	}
+	fmt.Printf("Backfill %s: %d seen, %d embedded, %d already stored, %d failed\n",
+		status, checkpoint.Seen, checkpoint.Embedded, checkpoint.Existing, checkpoint.Failed)
+}
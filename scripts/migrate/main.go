@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"main/lib/logger"
+	"main/lib/paper"
+)
+
+const usage = `Usage: go run ./scripts/migrate <command> [arg]
+
+Commands:
+  up [version]   Apply pending migrations (up to version, default latest), then indexes
+  down [steps]   Roll back the most recent migrations (default 1)
+  status         List migrations and whether they are applied
+  indexes        Build the active embedding model's HNSW indexes (CONCURRENTLY)`
+
+func main() {
+	// Initialize environment (load .env if available)
+	err := godotenv.Load()
+	if err != nil {
+		logger.Warn("Error loading .env file", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	arg := 0
+	if len(os.Args) > 2 {
+		arg, err = strconv.Atoi(os.Args[2])
+		if err != nil || arg < 0 {
+			log.Fatalf("Invalid argument %q: must be a non-negative integer", os.Args[2])
+		}
+	}
+
+	// Open without InitDB so the schema is only changed by the requested command
+	db, err := paper.OpenDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	switch command {
+	case "up":
+		applied, err := paper.MigrateUp(ctx, db, arg)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+		ensureIndexes(ctx, db)
+
+	case "down":
+		steps := arg
+		if steps == 0 {
+			steps = 1
+		}
+		rolledBack, err := paper.MigrateDown(ctx, db, steps)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", rolledBack)
+
+	case "status":
+		statuses, err := paper.GetMigrationStatus(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	case "indexes":
+		ensureIndexes(ctx, db)
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// ensureIndexes builds the active model's HNSW indexes, which migrations leave to this
+// command so no serverless cold start ever waits on an index build
+func ensureIndexes(ctx context.Context, db *sql.DB) {
+	model := paper.ActiveEmbeddingModel()
+	if err := paper.EnsureModelIndexes(ctx, db, model); err != nil {
+		log.Fatalf("Failed to build indexes for %s: %v", model, err)
+	}
+	fmt.Printf("HNSW indexes ready for %s\n", model)
+}
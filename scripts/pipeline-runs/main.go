@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"main/lib/jobs"
+	"main/lib/logger"
+	"main/lib/pipeline"
+)
+
+const usage = `Usage: go run ./scripts/pipeline-runs <command> [args]
+
+Commands:
+  list [pipeline] [limit]   Newest runs first (pipelines: update-cache, daily-broadcast, weekly-broadcast, digest)
+  show <run-id>             A run with per-stage status, timings, hashes and errors
+  resume <run-id> [stage]   Re-run from a stage (default: the first failed stage)`
+
+func main() {
+	// Initialize environment (load .env if available)
+	err := godotenv.Load()
+	if err != nil {
+		logger.Warn("Error loading .env file", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	store := jobs.RunStore()
+
+	switch os.Args[1] {
+	case "list":
+		pipelineName, limit := "", 0
+		if len(os.Args) > 2 {
+			pipelineName = os.Args[2]
+		}
+		if len(os.Args) > 3 {
+			limit, err = strconv.Atoi(os.Args[3])
+			if err != nil || limit < 1 {
+				log.Fatalf("Invalid limit %q: must be a positive integer", os.Args[3])
+			}
+		}
+		runs, err := store.ListRuns(ctx, pipelineName, limit)
+		if err != nil {
+			log.Fatalf("Failed to list runs: %v", err)
+		}
+		for _, run := range runs {
+			printSummary(run)
+		}
+
+	case "show":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		run, err := store.GetRun(ctx, os.Args[2])
+		if err != nil {
+			log.Fatalf("Failed to load run: %v", err)
+		}
+		printRun(run)
+
+	case "resume":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		fromStage := ""
+		if len(os.Args) > 3 {
+			fromStage = os.Args[3]
+		}
+		run, err := jobs.Resume(ctx, os.Args[2], fromStage)
+		if run != nil {
+			printRun(run)
+		}
+		if err != nil {
+			log.Fatalf("Resume failed: %v", err)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// printSummary prints one line per run
+func printSummary(run *pipeline.Run) {
+	failed := ""
+	if stage := run.FailedStage(); stage != "" && run.Status == pipeline.RunFailed {
+		failed = " at " + stage
+	}
+	fmt.Printf("%-26s %-16s %-10s%s  %s\n", run.ID, run.Pipeline, run.Status, failed, run.StartedAt.Format(time.RFC3339))
+}
+
+// printRun prints the run header, a stage table and the params as JSON
+func printRun(run *pipeline.Run) {
+	printSummary(run)
+	if run.ParentID != "" {
+		fmt.Printf("  resumed %s from %s\n", run.ParentID, run.ResumedFrom)
+	}
+	if len(run.Params) > 0 {
+		params, _ := json.Marshal(run.Params)
+		fmt.Printf("  params %s\n", params)
+	}
+	for _, stage := range run.Stages {
+		fmt.Printf("  %-18s %-10s attempts=%d %6dms in=%s out=%s\n",
+			stage.Name, stage.Status, stage.Attempts, stage.DurationMs, shortHash(stage.InputHash), shortHash(stage.OutputHash))
+		if stage.Error != "" {
+			fmt.Printf("    error: %s\n", stage.Error)
+		}
+	}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	if hash == "" {
+		return "-"
+	}
+	return hash
+}
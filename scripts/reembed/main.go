@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"main/lib/logger"
+	"main/lib/paper"
+)
+
+const usage = `Usage: go run ./scripts/reembed <command> [flags]
+
+Commands:
+  status                         Count stored embeddings per model
+  run -endpoint NAME [-model ID] [-dimension N] [-from ID]
+                                 Re-embed the active (or -from) model's rows with a new endpoint
+  prune -model ID                Delete all embeddings and indexes for a retired model
+
+Typical model swap:
+  1. run against the new endpoint while the old model keeps serving search
+  2. set SAGEMAKER_ENDPOINT_NAME / EMBEDDING_MODEL_ID / EMBEDDING_DIMENSION and redeploy
+  3. run again to pick up rows written during the switch, then prune the old model`
+
+func main() {
+	// Initialize environment (load .env if available)
+	err := godotenv.Load()
+	if err != nil {
+		logger.Warn("Error loading .env file", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// InitDB also applies pending migrations so the model columns exist
+	if err := paper.InitDB(); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := paper.InitSchema(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+	db := paper.GetDB()
+	defer paper.CloseDB()
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "status":
+		counts, err := paper.CountEmbeddingsByModel(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to count embeddings: %v", err)
+		}
+		active := paper.ActiveEmbeddingModel()
+		for _, c := range counts {
+			marker := ""
+			if c.ID == active.ID {
+				marker = " (active)"
+			}
+			fmt.Printf("%-18s %-40s %5d %10d%s\n", c.Table, c.ID, c.Dimension, c.Count, marker)
+		}
+
+	case "run":
+		fs := flag.NewFlagSet("run", flag.ExitOnError)
+		endpoint := fs.String("endpoint", "", "SageMaker endpoint for the target model (required)")
+		modelID := fs.String("model", "", "target model ID (defaults to the endpoint name)")
+		dimension := fs.Int("dimension", 512, "target embedding dimension")
+		fromID := fs.String("from", "", "source model ID (defaults to the active model)")
+		fromDimension := fs.Int("from-dimension", 0, "source embedding dimension (defaults to the active dimension)")
+		batchSize := fs.Int("batch", 0, "rows per page (default 256)")
+		_ = fs.Parse(os.Args[2:])
+
+		if *endpoint == "" {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		source := paper.ActiveEmbeddingModel()
+		if *fromID != "" {
+			source.ID = *fromID
+		}
+		if *fromDimension > 0 {
+			source.Dimension = *fromDimension
+		}
+
+		target := paper.EmbeddingModel{ID: *modelID, Dimension: *dimension}
+		if target.ID == "" {
+			target.ID = *endpoint
+		}
+
+		service, err := paper.NewEmbeddingServiceForEndpoint(*endpoint)
+		if err != nil {
+			log.Fatalf("Failed to create embedding service: %v", err)
+		}
+
+		logger.Info("Starting re-embedding", map[string]interface{}{
+			"source": source.String(),
+			"target": target.String(),
+		})
+
+		stats, err := paper.ReembedModel(ctx, db, paper.ReembedOptions{
+			Source:    source,
+			Target:    target,
+			Service:   service,
+			BatchSize: *batchSize,
+		})
+		if err != nil {
+			log.Fatalf("Re-embedding failed: %v", err)
+		}
+
+		fmt.Printf("Re-embedded %d queries and %d results in %s (%d skipped, %d failed)\n",
+			stats.Queries, stats.Results, stats.Duration.Round(time.Second), stats.Skipped, stats.Failed)
+
+	case "prune":
+		fs := flag.NewFlagSet("prune", flag.ExitOnError)
+		modelID := fs.String("model", "", "model ID to delete (required)")
+		dimension := fs.Int("dimension", 512, "model embedding dimension (used for index names)")
+		_ = fs.Parse(os.Args[2:])
+
+		if *modelID == "" {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+
+		deleted, err := paper.PruneEmbeddingModel(ctx, db, paper.EmbeddingModel{ID: *modelID, Dimension: *dimension})
+		if err != nil {
+			log.Fatalf("Prune failed: %v", err)
+		}
+		fmt.Printf("Deleted %d embeddings for %s\n", deleted, *modelID)
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}